@@ -2,25 +2,35 @@
 package main
 
 import (
+	"crypto"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/buildconfig"
 	"github.com/osbuild/images/internal/cmdutil"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/bootc"
+	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/generic"
 	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/lockfile"
 	"github.com/osbuild/images/pkg/manifestgen"
 	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/provenance"
 	"github.com/osbuild/images/pkg/reporegistry"
 	"github.com/osbuild/images/pkg/rhsm/facts"
 	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
 )
 
 func u(s string) string {
@@ -35,6 +45,8 @@ func run() error {
 	flag.StringVar(&rpmCacheRoot, "rpmmd", "/tmp/rpmmd", "rpm metadata cache directory")
 	flag.StringVar(&repositories, "repositories", "test/data/repositories", "path to repository file or directory")
 	flag.StringVar(&archName, "arch", "", "target architecture")
+	var bootstrapDepsolve bool
+	flag.BoolVar(&bootstrapDepsolve, "depsolve-in-bootstrap-container", false, "run the depsolver inside the bootstrap container of the distro")
 
 	// osbuild checkpoint arg
 	var checkpoints cmdutil.MultiValue
@@ -53,6 +65,30 @@ func run() error {
 	flag.StringVar(&bootcBuildRef, "bootc-build-ref", "", "separate build container image ref")
 	flag.BoolVar(&bootcRemote, "bootc-remote", false, "use org.osbuild.skopeo sources instead of containers-storage")
 
+	// lockfile args
+	var lockfilePath string
+	var writeLockfile, securityOnly bool
+	flag.StringVar(&lockfilePath, "lockfile", "", "use the packages from the given lockfile instead of depsolving")
+	flag.BoolVar(&securityOnly, "security-only", false, "only update the packages of -lockfile that have security advisories")
+	flag.BoolVar(&writeLockfile, "write-lockfile", false, "write the lockfile of the depsolved packages to the build directory")
+
+	// sbom args
+	var sbomTypeName string
+	var imageSBOM bool
+	flag.StringVar(&sbomTypeName, "sbom", "", "write SBOMs of the given type (spdx or cyclonedx) to the build directory")
+	flag.BoolVar(&imageSBOM, "image-sbom", false, "also write a single SBOM of all the content of the image (requires -sbom)")
+
+	// provenance args
+	var writeProvenance bool
+	var provenanceKeyPath string
+	flag.BoolVar(&writeProvenance, "provenance", false, "write a SLSA provenance statement of the artifacts to the build directory")
+	flag.StringVar(&provenanceKeyPath, "provenance-key", "", "sign the provenance statement with the given ed25519 or ECDSA private key (PEM)")
+
+	// container signature args
+	var containerSignatures, containerPolicyPath string
+	flag.StringVar(&containerSignatures, "container-signatures", "none", "verify the signatures of embedded containers against the signature policy (none, warn or enforce)")
+	flag.StringVar(&containerPolicyPath, "container-policy", "", "signature policy for -container-signatures (default /etc/containers/policy.json)")
+
 	flag.Parse()
 
 	if imgTypeName == "" || configFile == "" {
@@ -69,6 +105,22 @@ func run() error {
 		flag.Usage()
 		os.Exit(1)
 	}
+	if securityOnly && lockfilePath == "" {
+		fmt.Fprintf(os.Stderr, "error: -security-only requires -lockfile\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if provenanceKeyPath != "" && !writeProvenance {
+		fmt.Fprintf(os.Stderr, "error: -provenance-key requires -provenance\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+	signatureVerification, err := container.ParseSignatureVerification(containerSignatures)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
 	if bootcRef != "" && repositories != "test/data/repositories" {
 		fmt.Fprintf(os.Stderr, "warning: -repositories is ignored when -bootc-ref is used\n")
 	}
@@ -87,6 +139,15 @@ func run() error {
 		return err
 	}
 
+	// load the key early so that a build is not wasted on a broken key
+	var provenanceKey crypto.Signer
+	if provenanceKeyPath != "" {
+		provenanceKey, err = provenance.LoadPrivateKey(provenanceKeyPath)
+		if err != nil {
+			return err
+		}
+	}
+
 	if err := os.MkdirAll(outputDir, 0777); err != nil {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
@@ -197,6 +258,56 @@ func run() error {
 	if archName != arch.Current().String() {
 		manifestOpts.UseBootstrapContainer = true
 	}
+	manifestOpts.DepsolveInBootstrapContainer = bootstrapDepsolve
+	manifestOpts.ContainerSignatures = container.SignatureOptions{
+		Verification: signatureVerification,
+		PolicyPath:   containerPolicyPath,
+	}
+	if lockfilePath != "" {
+		f, err := os.Open(lockfilePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		lf, err := lockfile.Read(f)
+		if err != nil {
+			return fmt.Errorf("cannot read lockfile %q: %w", lockfilePath, err)
+		}
+		manifestOpts.Lockfile = lf
+		manifestOpts.SecurityOnly = securityOnly
+	}
+	if imageSBOM && sbomTypeName == "" {
+		return fmt.Errorf("-image-sbom requires -sbom")
+	}
+	if sbomTypeName != "" {
+		manifestOpts.ImageSBOM = imageSBOM
+		switch sbomTypeName {
+		case "spdx":
+			manifestOpts.SBOMType = sbom.StandardTypeSpdx
+		case "cyclonedx":
+			manifestOpts.SBOMType = sbom.StandardTypeCycloneDX
+		default:
+			return fmt.Errorf("unsupported SBOM type %q", sbomTypeName)
+		}
+		manifestOpts.SBOMWriter = func(filename string, content io.Reader, docType sbom.StandardType) error {
+			data, err := io.ReadAll(content)
+			if err != nil {
+				return err
+			}
+			// nolint:gosec
+			return os.WriteFile(filepath.Join(buildDir, filename), data, 0644)
+		}
+	}
+	if writeLockfile {
+		manifestOpts.LockfileWriter = func(filename string, content io.Reader) error {
+			data, err := io.ReadAll(content)
+			if err != nil {
+				return err
+			}
+			// nolint:gosec
+			return os.WriteFile(filepath.Join(buildDir, filename), data, 0644)
+		}
+	}
 	// add RHSM fact to detect changes
 	config.Options.Facts = &facts.ImageOptions{
 		APIType: facts.TEST_APITYPE,
@@ -214,6 +325,12 @@ func run() error {
 		}
 	}
 
+	var resolvedContent *manifestgen.ResolvedContent
+	manifestOpts.ResolvedContentCallback = func(content *manifestgen.ResolvedContent) error {
+		resolvedContent = content
+		return nil
+	}
+
 	mg, err := manifestgen.New(nil, &manifestOpts)
 	if err != nil {
 		return fmt.Errorf("[ERROR] manifest generator creation failed: %w", err)
@@ -230,24 +347,101 @@ func run() error {
 		return fmt.Errorf("failed to write output file %q: %w", manifestPath, err)
 	}
 
+	// containers from archives are embedded from the local storage
+	if resolvedContent != nil {
+		for _, name := range slices.Sorted(maps.Keys(resolvedContent.Containers)) {
+			if err := container.LoadArchives(resolvedContent.Containers[name]); err != nil {
+				return err
+			}
+		}
+	}
+
 	fmt.Printf("Building manifest: %s\n", manifestPath)
 
 	jobOutput := filepath.Join(outputDir, buildName)
-	_, err = osbuild.RunOSBuild(mf, &osbuild.OSBuildOptions{
+	startedOn := time.Now()
+	result, err := osbuild.RunOSBuild(mf, &osbuild.OSBuildOptions{
 		StoreDir:    osbuildStore,
 		OutputDir:   jobOutput,
 		Exports:     imgType.Exports(),
 		Checkpoints: checkpoints,
-		JSONOutput:  false,
+		// the stages that were run are recorded as the byproducts
+		// of the provenance
+		JSONOutput: writeProvenance,
 	})
 	if err != nil {
 		return err
 	}
+	if writeProvenance && !result.Success {
+		if err := result.Write(os.Stderr); err != nil {
+			return err
+		}
+		return fmt.Errorf("osbuild build failed")
+	}
+
+	if writeProvenance {
+		inputs := provenance.Inputs{
+			Distro:       distribution.Name(),
+			Arch:         archName,
+			ImageType:    imgTypeName,
+			Blueprint:    config.Blueprint,
+			ImageOptions: &config.Options,
+			Content:      resolvedContent,
+			Manifest:     mf,
+			StartedOn:    startedOn,
+			FinishedOn:   time.Now(),
+		}
+		if err := writeProvenanceFiles(jobOutput, imgType.Exports(), inputs, result, provenanceKey); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("Jobs done. Results saved in\n%s\n", outputDir)
 	return nil
 }
 
+// writeProvenanceFiles writes the provenance statement of the exported
+// artifacts and, if a key is given, the signed statement to buildDir
+func writeProvenanceFiles(buildDir string, exports []string, inputs provenance.Inputs, result *osbuild.Result, key crypto.Signer) error {
+	osbuildVersion, err := osbuild.OSBuildVersion()
+	if err != nil {
+		return err
+	}
+	inputs.OSBuildVersion = osbuildVersion
+
+	subjects, err := provenance.SubjectsFromDir(buildDir, exports...)
+	if err != nil {
+		return err
+	}
+	statement, err := provenance.New(inputs, result, subjects)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return err
+	}
+	// nolint:gosec
+	if err := os.WriteFile(filepath.Join(buildDir, "provenance.json"), data, 0644); err != nil {
+		return err
+	}
+
+	if key == nil {
+		return nil
+	}
+	envelope, err := statement.Sign(key)
+	if err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return err
+	}
+	// nolint:gosec
+	return os.WriteFile(filepath.Join(buildDir, "provenance.dsse.json"), data, 0644)
+}
+
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
@@ -0,0 +1,78 @@
+package check
+
+import (
+	"bytes"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/internal/buildconfig"
+	"github.com/osbuild/images/pkg/imageinfo"
+)
+
+func init() {
+	RegisterCheck(Metadata{
+		Name: "image-info",
+	}, imageInfoCheck)
+}
+
+// packageName returns the name of a top-level package of the image info,
+// which is either a plain package name or a NEVRA
+func packageName(pkg string) string {
+	if !strings.Contains(pkg, ":") {
+		return pkg
+	}
+	// name-epoch:version-release.arch
+	nameEpoch, _, _ := strings.Cut(pkg, ":")
+	if idx := strings.LastIndex(nameEpoch, "-"); idx > 0 {
+		return nameEpoch[:idx]
+	}
+	return pkg
+}
+
+func imageInfoCheck(meta *Metadata, config *buildconfig.BuildConfig) error {
+	if !config.Options.ImageInfo {
+		return Skip("no image info requested")
+	}
+
+	data, err := ReadFile(imageinfo.Path)
+	if err != nil {
+		return Fail("failed to read image info:", err)
+	}
+	info, err := imageinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return Fail(err)
+	}
+	log.Printf("Image %s (%s/%s) built by images %s, inputs %s\n",
+		info.ImageType, info.Distro, info.Arch, info.LibraryVersion, info.InputsDigest)
+	if !info.BuildTime.IsZero() {
+		log.Printf("Image built at %s\n", info.BuildTime)
+	}
+
+	if info.Distro == "" || info.Arch == "" || info.ImageType == "" || info.InputsDigest == "" {
+		return Fail("image info is incomplete:", string(data))
+	}
+
+	bp := config.Blueprint
+	if bp == nil {
+		return Pass()
+	}
+	if info.BlueprintName != bp.Name || info.BlueprintVersion != bp.Version {
+		return Fail("blueprint does not match, got", info.BlueprintName, info.BlueprintVersion, "expected", bp.Name, bp.Version)
+	}
+	var names []string
+	for _, pkg := range info.Packages {
+		names = append(names, packageName(pkg))
+	}
+	for _, pkg := range bp.Packages {
+		// versioned packages are listed as they are requested
+		if pkg.Version != "" && pkg.Version != "*" {
+			continue
+		}
+		if !slices.Contains(names, pkg.Name) {
+			return Fail("blueprint package missing in image info:", pkg.Name)
+		}
+	}
+
+	return Pass()
+}
@@ -0,0 +1,133 @@
+package check_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	check "github.com/osbuild/images/cmd/check-host-config/check"
+	"github.com/osbuild/images/internal/buildconfig"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/imageinfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImageInfo = `{
+  "blueprint_name": "web",
+  "blueprint_version": "1.0.0",
+  "distro": "rhel-10.0",
+  "arch": "x86_64",
+  "image_type": "qcow2",
+  "build_time": "2026-10-19T10:00:00Z",
+  "inputs_digest": "1234",
+  "library_version": "v0.200.0",
+  "packages": ["bash-0:5.2.26-3.el10.x86_64", "nginx-core-1:1.26.3-1.el10.x86_64", "kernel"]
+}`
+
+func TestImageInfoCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		imageInfo    bool
+		packages     []blueprint.Package
+		mockReadFile map[string]ReadFileResult
+		wantErr      error
+	}{
+		{
+			name:    "skip when image info is not requested",
+			wantErr: check.ErrCheckSkipped,
+		},
+		{
+			name:      "pass when image info matches",
+			imageInfo: true,
+			packages:  []blueprint.Package{{Name: "bash"}, {Name: "kernel"}, {Name: "nginx-core"}},
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Data: []byte(testImageInfo)},
+			},
+		},
+		{
+			name:      "pass with versioned blueprint packages",
+			imageInfo: true,
+			packages:  []blueprint.Package{{Name: "vim", Version: "9.1"}},
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Data: []byte(testImageInfo)},
+			},
+		},
+		{
+			name:      "fail when a blueprint package is missing",
+			imageInfo: true,
+			packages:  []blueprint.Package{{Name: "nginx"}},
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Data: []byte(testImageInfo)},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:      "fail when the image info is missing",
+			imageInfo: true,
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Err: errors.New("no such file or directory")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:      "fail when the image info is invalid",
+			imageInfo: true,
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Data: []byte("{")},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+		{
+			name:      "fail when the image info is incomplete",
+			imageInfo: true,
+			mockReadFile: map[string]ReadFileResult{
+				imageinfo.Path: {Data: []byte(`{"blueprint_name": "web", "blueprint_version": "1.0.0"}`)},
+			},
+			wantErr: check.ErrCheckFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installMockReadFile(t, tt.mockReadFile)
+
+			chk, found := check.FindCheckByName("image-info")
+			require.True(t, found, "Image Info Check not found")
+			config := &buildconfig.BuildConfig{
+				Blueprint: &blueprint.Blueprint{
+					Name:     "web",
+					Version:  "1.0.0",
+					Packages: tt.packages,
+				},
+				Options: distro.ImageOptions{
+					ImageInfo: tt.imageInfo,
+				},
+			}
+
+			err := chk.Func(chk.Meta, config)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tt.wantErr))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestImageInfoCheckBlueprintMismatch(t *testing.T) {
+	installMockReadFile(t, map[string]ReadFileResult{
+		imageinfo.Path: {Data: []byte(testImageInfo)},
+	})
+
+	chk, found := check.FindCheckByName("image-info")
+	require.True(t, found)
+	config := &buildconfig.BuildConfig{
+		Blueprint: &blueprint.Blueprint{Name: "web", Version: "2.0.0"},
+		Options:   distro.ImageOptions{ImageInfo: true},
+	}
+	err := chk.Func(chk.Meta, config)
+	assert.ErrorIs(t, err, check.ErrCheckFailed)
+	assert.ErrorContains(t, err, "blueprint does not match")
+}
@@ -0,0 +1,132 @@
+// Standalone executable that explains why packages are part of an image:
+// the image type is depsolved and for every given package the chain of
+// dependencies back to the requested package set entry (or comps group) is
+// printed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/internal/buildconfig"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/manifestgen"
+	"github.com/osbuild/images/pkg/reporegistry"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+func run() error {
+	var rpmCacheRoot, repositories, distroName, imgTypeName, archName, configFile, pipeline string
+	flag.StringVar(&rpmCacheRoot, "rpmmd", "/tmp/rpmmd", "rpm metadata cache directory")
+	flag.StringVar(&repositories, "repositories", "test/data/repositories", "path to the repository directory")
+	flag.StringVar(&distroName, "distro", "", "distribution (required)")
+	flag.StringVar(&imgTypeName, "type", "", "image type name (required)")
+	flag.StringVar(&archName, "arch", arch.Current().String(), "target architecture")
+	flag.StringVar(&configFile, "config", "", "build config file")
+	flag.StringVar(&pipeline, "pipeline", "", "only explain the packages of the given pipeline (e.g. \"os\" or \"build\")")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <package>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if distroName == "" || imgTypeName == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	distribution := distrofactory.NewDefault().GetDistro(distroName)
+	if distribution == nil {
+		return fmt.Errorf("invalid or unsupported distribution: %q", distroName)
+	}
+	archi, err := distribution.GetArch(archName)
+	if err != nil {
+		return fmt.Errorf("invalid arch name %q for distro %q: %w", archName, distribution.Name(), err)
+	}
+	imgType, err := archi.GetImageType(imgTypeName)
+	if err != nil {
+		return fmt.Errorf("invalid image type %q for distro %q and arch %q: %w", imgTypeName, distribution.Name(), archName, err)
+	}
+
+	config := &buildconfig.BuildConfig{}
+	if configFile != "" {
+		config, err = buildconfig.New(configFile, nil)
+		if err != nil {
+			return err
+		}
+	}
+	if config.Blueprint == nil {
+		config.Blueprint = &blueprint.Blueprint{}
+	}
+
+	reporeg, err := reporegistry.New([]string{repositories}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load repositories from %q: %w", repositories, err)
+	}
+
+	var depsolved map[string]depsolvednf.DepsolveResult
+	var pkgSetChains map[string][]rpmmd.PackageSet
+	opts := &manifestgen.Options{
+		Cachedir:       rpmCacheRoot,
+		WarningsOutput: os.Stderr,
+		SolverDepsolve: func(solver depsolvednf.Depsolver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error) {
+			if dnfSolver, ok := solver.(*depsolvednf.Solver); ok {
+				dnfSolver.SetDependencyGraph(true)
+			}
+			res, err := manifestgen.DefaultSolverDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch, sbomType)
+			depsolved = res
+			pkgSetChains = packageSets
+			return res, err
+		},
+	}
+	mg, err := manifestgen.New(reporeg, opts)
+	if err != nil {
+		return fmt.Errorf("manifest generator creation failed: %w", err)
+	}
+	if _, err := mg.Generate(config.Blueprint, imgType, &config.Options); err != nil {
+		return fmt.Errorf("manifest generation failed: %w", err)
+	}
+
+	for _, pkgName := range flag.Args() {
+		found := false
+		for _, plName := range slices.Sorted(maps.Keys(depsolved)) {
+			if pipeline != "" && plName != pipeline {
+				continue
+			}
+			res := depsolved[plName]
+			if _, err := res.Transactions.FindPackage(pkgName); err != nil {
+				continue
+			}
+			// other depsolvers do not provide the dependency graph
+			if res.Dependencies == nil {
+				res.Dependencies = depsolvednf.NewDependencyGraph(pkgSetChains[plName], res.Transactions)
+			}
+			expl, err := res.Explain(pkgName)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s: %s\n\n", plName, expl)
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("package %q is not part of the %s image", pkgName, imgTypeName)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rhsm/facts"
 	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
 	testrepos "github.com/osbuild/images/test/data/repositories"
 )
 
@@ -224,6 +225,21 @@ func loadImgConfig(configPath string, opts *buildconfig.Options) *BuildConfigs {
 
 type manifestJob func(chan string) error
 
+// newDepsolver returns the depsolver the distribution uses for its
+// packages, this is the APT based one for Debian like distributions and
+// osbuild-depsolve-dnf otherwise.
+func newDepsolver(distribution distro.Distro, archName, cacheDir string) (depsolvednf.Depsolver, func() error, error) {
+	if dd, ok := distribution.(distro.DepsolverDistro); ok {
+		archi, err := arch.FromString(archName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return dd.NewDepsolver(cacheDir, archi)
+	}
+	solver := depsolvednf.NewSolver(distribution.ModulePlatformID(), distribution.Releasever(), archName, distribution.Name(), cacheDir)
+	return solver, func() error { return nil }, nil
+}
+
 func makeManifestJob(
 	bc *buildconfig.BuildConfig,
 	imgType distro.ImageType,
@@ -322,8 +338,19 @@ func makeManifestJob(
 
 		var depsolvedSets map[string]depsolvednf.DepsolveResult
 		if content["packages"] {
-			solver := depsolvednf.NewSolver(distribution.ModulePlatformID(), distribution.Releasever(), archName, distribution.Name(), cacheDir)
-			depsolvedSets, err = solver.DepsolveAll(common.Must(manifest.GetPackageSetChains()))
+			var solver depsolvednf.Depsolver
+			var cleanup func() error
+			solver, cleanup, err = newDepsolver(distribution, archName, cacheDir)
+			if err != nil {
+				err = fmt.Errorf("[%s] cannot create the depsolver: %s", filename, err.Error())
+				return
+			}
+			defer func() {
+				if cerr := cleanup(); cerr != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: [%s] depsolver cleanup failed: %s\n", filename, cerr.Error())
+				}
+			}()
+			depsolvedSets, err = depsolvednf.DepsolveAll(solver, common.Must(manifest.GetPackageSetChains()), sbom.StandardTypeNone)
 			if err != nil {
 				err = fmt.Errorf("[%s] depsolve failed: %s", filename, err.Error())
 				return
@@ -7,10 +7,54 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 
+	"github.com/osbuild/images/internal/cmdutil"
+	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/container"
 )
 
+// parseArchContainers parses the <arch>=<path> values of -arch-containers
+// into the entries of the image index
+func parseArchContainers(values []string) ([]container.IndexEntry, error) {
+	entries := make([]container.IndexEntry, 0, len(values))
+	for _, value := range values {
+		archName, filename, ok := strings.Cut(value, "=")
+		if !ok || filename == "" {
+			return nil, fmt.Errorf("invalid container %q, must be <arch>=<path>", value)
+		}
+		a, err := arch.FromString(archName)
+		if err != nil {
+			return nil, err
+		}
+		absPath, err := filepath.Abs(filename)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, container.IndexEntry{
+			Source: fmt.Sprintf("oci-archive://%s", absPath),
+			Arch:   a,
+		})
+	}
+	return entries, nil
+}
+
+// parseAnnotations parses the <key>=<value> values of -annotations
+func parseAnnotations(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	annotations := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, must be <key>=<value>", value)
+		}
+		annotations[key] = val
+	}
+	return annotations, nil
+}
+
 func main() {
 	var filename string
 	var destination string
@@ -18,8 +62,13 @@ func main() {
 	var password string
 	var tag string
 	var ignoreTLS bool
+	var archContainers, annotationValues cmdutil.MultiValue
+	var variant string
 
-	flag.StringVar(&filename, "container", "", "path to the oci-archive to upload (required)")
+	flag.StringVar(&filename, "container", "", "path to the oci-archive to upload (required unless -arch-containers is used)")
+	flag.Var(&archContainers, "arch-containers", "comma-separated list of <arch>=<path> single-arch oci-archives to upload as a multi-arch image index")
+	flag.Var(&annotationValues, "annotations", "comma-separated list of <key>=<value> annotations of the image index")
+	flag.StringVar(&variant, "variant", "", "platform variant of the containers of the host architecture in the image index")
 	flag.StringVar(&destination, "destination", "", "destination to upload to (required)")
 	flag.StringVar(&tag, "tag", "", "destination tag to use for the container")
 	flag.StringVar(&username, "username", "", "username to use for registry")
@@ -27,19 +76,11 @@ func main() {
 	flag.BoolVar(&ignoreTLS, "ignore-tls", false, "ignore tls verification for destination")
 	flag.Parse()
 
-	if filename == "" || destination == "" {
+	if (filename == "") == (len(archContainers) == 0) || destination == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	absPath, err := filepath.Abs(filename)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return
-	}
-
-	fmt.Println("Container to upload is:", filename)
-
 	client, err := container.NewClient(destination)
 
 	if err != nil {
@@ -65,6 +106,39 @@ func main() {
 
 	ctx := context.Background()
 
+	if len(archContainers) > 0 {
+		entries, err := parseArchContainers(archContainers)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		annotations, err := parseAnnotations(annotationValues)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		if variant != "" {
+			client.SetVariantChoice(variant)
+		}
+
+		digest, err := client.UploadIndex(ctx, entries, tag, annotations)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error uploading: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("upload done; destination index: %s\n", digest.String())
+		return
+	}
+
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+
+	fmt.Println("Container to upload is:", filename)
+
 	from := fmt.Sprintf("oci-archive://%s", absPath)
 
 	digest, err := client.UploadImage(ctx, from, tag)
@@ -0,0 +1,173 @@
+// Standalone executable that compares the packages of two image builds and
+// writes a report (text, JSON or Markdown) of the added, removed, upgraded
+// and downgraded packages.
+//
+// The inputs can be lockfiles (see pkg/lockfile), rpmlist.json files (as
+// written by manifestgen) or the JSON output of "osbuild --json".
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/osbuild/images/internal/cmdutil"
+	"github.com/osbuild/images/pkg/lockfile"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/pkgdiff"
+	"github.com/osbuild/images/pkg/rpmlist"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/upload/koji"
+)
+
+// defaultPipeline is compared for lockfiles and osbuild results when no
+// pipeline is given
+const defaultPipeline = "os"
+
+func loadPackages(path, pipeline string) (rpmmd.PackageList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	// rpmlist.json, which only contains the packages of a single pipeline
+	if bytes.HasPrefix(data, []byte("[")) {
+		if pipeline != "" {
+			return nil, fmt.Errorf("cannot select pipeline %q of rpmlist %q, it only contains the packages of a single pipeline", pipeline, path)
+		}
+		pkgs, err := rpmlist.DecodePackages(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse rpmlist %q: %w", path, err)
+		}
+		return pkgs, nil
+	}
+	if pipeline == "" {
+		pipeline = defaultPipeline
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	if _, ok := probe["package_sets"]; ok {
+		lf, err := lockfile.Read(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		psl, ok := lf.PackageSets[pipeline]
+		if !ok {
+			return nil, fmt.Errorf("lockfile %q has no package set %q", path, pipeline)
+		}
+		var pkgs rpmmd.PackageList
+		for _, trans := range psl.Transactions {
+			for _, p := range trans {
+				pkgs = append(pkgs, rpmmd.Package{Name: p.Name, Epoch: p.Epoch, Version: p.Version, Release: p.Release, Arch: p.Arch})
+			}
+		}
+		return pkgs, nil
+	}
+	if _, ok := probe["metadata"]; ok {
+		var res osbuild.Result
+		if err := json.Unmarshal(data, &res); err != nil {
+			return nil, fmt.Errorf("cannot parse osbuild result %q: %w", path, err)
+		}
+		md, ok := res.Metadata[pipeline]
+		if !ok {
+			return nil, fmt.Errorf("osbuild result %q has no metadata for pipeline %q", path, pipeline)
+		}
+		return koji.RPMsToPackageList(koji.DeduplicateRPMs(koji.OSBuildMetadataToRPMs(md)))
+	}
+	return nil, fmt.Errorf("unknown input format of %q, expected a lockfile, rpmlist.json or osbuild result", path)
+}
+
+// addRepoMetadata adds the source RPM, build time and changelogs from the
+// given repositories to the packages
+func addRepoMetadata(pkgs rpmmd.PackageList, reader *rpmmd.MetadataReader, repos []rpmmd.RepoConfig) error {
+	available, err := reader.FetchMetadata(repos)
+	if err != nil {
+		return err
+	}
+	byNEVRA := make(map[string]rpmmd.Package, len(available))
+	for _, pkg := range available {
+		byNEVRA[pkg.FullNEVRA()] = pkg
+	}
+	for idx := range pkgs {
+		if pkg, ok := byNEVRA[pkgs[idx].FullNEVRA()]; ok {
+			pkgs[idx].SourceRpm = pkg.SourceRpm
+			pkgs[idx].BuildTime = pkg.BuildTime
+			pkgs[idx].Changelogs = pkg.Changelogs
+		}
+	}
+	return nil
+}
+
+func run() error {
+	var format, pipeline, cacheDir string
+	var advisories bool
+	var repoURLs cmdutil.MultiValue
+	flag.StringVar(&format, "format", string(pkgdiff.FormatText), "report format (text, json or markdown)")
+	flag.StringVar(&pipeline, "pipeline", "", "pipeline to compare the packages of (default \"os\", not supported for rpmlist.json inputs)")
+	flag.Var(&repoURLs, "repo", "comma-separated list of repository baseurls to read source packages and changelogs from")
+	flag.StringVar(&cacheDir, "rpmmd", "", "rpm metadata cache directory")
+	flag.BoolVar(&advisories, "advisories", false, "report the fixed and unpatched advisories from the updateinfo of the -repo repositories")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <old> <new>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	oldPkgs, err := loadPackages(flag.Arg(0), pipeline)
+	if err != nil {
+		return err
+	}
+	newPkgs, err := loadPackages(flag.Arg(1), pipeline)
+	if err != nil {
+		return err
+	}
+
+	if advisories && len(repoURLs) == 0 {
+		return fmt.Errorf("-advisories requires at least one -repo")
+	}
+
+	var reader *rpmmd.MetadataReader
+	var repos []rpmmd.RepoConfig
+	if len(repoURLs) > 0 {
+		for idx, url := range repoURLs {
+			repos = append(repos, rpmmd.RepoConfig{Id: fmt.Sprintf("repo-%d", idx), BaseURLs: []string{url}})
+		}
+		reader = rpmmd.NewMetadataReader(cacheDir)
+		reader.SetChangelogs(true)
+		if err := addRepoMetadata(oldPkgs, reader, repos); err != nil {
+			return err
+		}
+		if err := addRepoMetadata(newPkgs, reader, repos); err != nil {
+			return err
+		}
+	}
+
+	diff := pkgdiff.Compare(oldPkgs, newPkgs)
+	if advisories {
+		repoAdvisories, err := reader.FetchAdvisories(repos)
+		if err != nil {
+			return err
+		}
+		diff.SetAdvisories(oldPkgs, newPkgs, repoAdvisories)
+	}
+
+	return diff.Write(os.Stdout, pkgdiff.Format(format))
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
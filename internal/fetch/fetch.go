@@ -0,0 +1,94 @@
+// Package fetch retrieves the metadata files of package repositories from
+// "file://", "http://" and "https://" locations.
+package fetch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TLSOptions are the TLS options of a repository
+type TLSOptions struct {
+	// InsecureSkipVerify disables the verification of the server
+	// certificate
+	InsecureSkipVerify bool
+	// CACert is the path to the CA certificate to verify the server
+	// certificate with instead of the system CAs
+	CACert string
+	// ClientCert and ClientKey are the paths to the client certificate
+	// and its key, both must be set for them to be used
+	ClientCert string
+	ClientKey  string
+}
+
+// Fetcher retrieves files honouring the TLS options it was created with.
+type Fetcher struct {
+	client *http.Client
+}
+
+// New returns a fetcher for the given TLS options.
+func New(opts TLSOptions) (*Fetcher, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if opts.InsecureSkipVerify {
+		/* #nosec G402 */
+		tlsConf.InsecureSkipVerify = true
+	}
+	if opts.CACert != "" {
+		caCertPEM, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA certificate: %w", err)
+		}
+		tlsConf.RootCAs = x509.NewCertPool()
+		if ok := tlsConf.RootCAs.AppendCertsFromPEM(caCertPEM); !ok {
+			return nil, fmt.Errorf("cannot add CA certificate %q", opts.CACert)
+		}
+	}
+	if opts.ClientCert != "" && opts.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConf
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   300 * time.Second,
+		},
+	}, nil
+}
+
+// Fetch returns the content of the file at the given location.
+func (f *Fetcher) Fetch(location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return os.ReadFile(u.Path)
+	case "http", "https":
+		resp, err := f.client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s returned status: %s", location, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported url scheme %q in %q", u.Scheme, location)
+	}
+}
@@ -0,0 +1,61 @@
+package fetch_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/fetch"
+)
+
+func TestFetchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Release")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0o644))
+
+	f, err := fetch.New(fetch.TLSOptions{})
+	require.NoError(t, err)
+	data, err := f.Fetch("file://" + path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+}
+
+func TestFetchHTTPS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repomd.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	// the test server certificate is not trusted
+	f, err := fetch.New(fetch.TLSOptions{})
+	require.NoError(t, err)
+	_, err = f.Fetch(srv.URL + "/repomd.xml")
+	assert.ErrorContains(t, err, "certificate")
+
+	f, err = fetch.New(fetch.TLSOptions{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	data, err := f.Fetch(srv.URL + "/repomd.xml")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), data)
+
+	_, err = f.Fetch(srv.URL + "/missing")
+	assert.ErrorContains(t, err, srv.URL+"/missing returned status: 404 Not Found")
+}
+
+func TestFetchErrors(t *testing.T) {
+	_, err := fetch.New(fetch.TLSOptions{CACert: "/does/not/exist"})
+	assert.ErrorContains(t, err, "cannot read CA certificate")
+
+	f, err := fetch.New(fetch.TLSOptions{})
+	require.NoError(t, err)
+	_, err = f.Fetch("ftp://example.com/repomd.xml")
+	assert.EqualError(t, err, `unsupported url scheme "ftp" in "ftp://example.com/repomd.xml"`)
+}
@@ -0,0 +1,502 @@
+// Package gvariant reads and writes values in the GVariant serialization
+// format of GLib, which ostree and flatpak use for the metadata of their
+// repositories. Only the normal form on little endian machines is
+// supported. See
+// https://docs.gtk.org/glib/struct.Variant.html#serialization-format
+//
+// Values are represented as:
+//   - b: bool
+//   - y: uint8
+//   - n, q, i, u, x, t, h: int16, uint16, int32, uint32, int64, uint64, int32
+//   - d: float64
+//   - s, o, g: string
+//   - v: Variant
+//   - m<type>: nil or the value
+//   - ay: []byte
+//   - a<type>: []any
+//   - (<types>): []any
+//   - {<key><value>}: DictEntry
+package gvariant
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Variant is a value of the type "v", a value together with its type
+type Variant struct {
+	Type  string
+	Value any
+}
+
+// DictEntry is a value of a dict entry type like "{sv}"
+type DictEntry struct {
+	Key   any
+	Value any
+}
+
+// nextType splits the first complete type off typ
+func nextType(typ string) (string, string, error) {
+	if typ == "" {
+		return "", "", fmt.Errorf("missing type")
+	}
+	switch typ[0] {
+	case 'b', 'y', 'n', 'q', 'i', 'u', 'x', 't', 'h', 'd', 's', 'o', 'g', 'v':
+		return typ[:1], typ[1:], nil
+	case 'a', 'm':
+		elem, rest, err := nextType(typ[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return typ[:1] + elem, rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if typ[0] == '{' {
+			closing = '}'
+		}
+		rest := typ[1:]
+		for {
+			if rest == "" {
+				return "", "", fmt.Errorf("unterminated type %q", typ)
+			}
+			if rest[0] == closing {
+				n := len(typ) - len(rest) + 1
+				return typ[:n], typ[n:], nil
+			}
+			var err error
+			_, rest, err = nextType(rest)
+			if err != nil {
+				return "", "", err
+			}
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported type %q", typ)
+	}
+}
+
+// memberTypes returns the types of the members of a tuple or dict entry type
+func memberTypes(typ string) ([]string, error) {
+	var members []string
+	rest := typ[1 : len(typ)-1]
+	for rest != "" {
+		var member string
+		var err error
+		member, rest, err = nextType(rest)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if typ[0] == '{' && len(members) != 2 {
+		return nil, fmt.Errorf("invalid dict entry type %q", typ)
+	}
+	return members, nil
+}
+
+// alignment returns the alignment of the values of the type typ
+func alignment(typ string) int {
+	switch typ[0] {
+	case 'n', 'q':
+		return 2
+	case 'i', 'u', 'h':
+		return 4
+	case 'x', 't', 'd', 'v':
+		return 8
+	case 'a', 'm':
+		return alignment(typ[1:])
+	case '(', '{':
+		members, _ := memberTypes(typ)
+		align := 1
+		for _, member := range members {
+			align = max(align, alignment(member))
+		}
+		return align
+	default:
+		return 1
+	}
+}
+
+// fixedSize returns the size of the values of the type typ, or 0 if the
+// values have a variable size
+func fixedSize(typ string) int {
+	switch typ[0] {
+	case 'b', 'y':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'i', 'u', 'h':
+		return 4
+	case 'x', 't', 'd':
+		return 8
+	case '(', '{':
+		members, _ := memberTypes(typ)
+		if len(members) == 0 {
+			// the unit type
+			return 1
+		}
+		size := 0
+		for _, member := range members {
+			memberSize := fixedSize(member)
+			if memberSize == 0 {
+				return 0
+			}
+			size = align(size, alignment(member)) + memberSize
+		}
+		return align(size, alignment(typ))
+	default:
+		return 0
+	}
+}
+
+func align(offset, alignment int) int {
+	return (offset + alignment - 1) &^ (alignment - 1)
+}
+
+// offsetSize returns the size of the framing offsets of a container of the
+// given size
+func offsetSize(size int) int {
+	switch {
+	case size == 0:
+		return 0
+	case size <= math.MaxUint8:
+		return 1
+	case size <= math.MaxUint16:
+		return 2
+	case uint64(size) <= math.MaxUint32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func readOffset(data []byte, size int) int {
+	var buf [8]byte
+	copy(buf[:], data[:size])
+	return int(binary.LittleEndian.Uint64(buf[:]))
+}
+
+// Parse parses data as a value of the type typ
+func Parse(typ string, data []byte) (any, error) {
+	t, rest, err := nextType(typ)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid type %q", typ)
+	}
+	return parse(t, data)
+}
+
+func parse(typ string, data []byte) (any, error) {
+	if size := fixedSize(typ); size > 0 && len(data) != size {
+		return nil, fmt.Errorf("invalid size %d of a value of type %q", len(data), typ)
+	}
+
+	switch typ[0] {
+	case 'b':
+		return data[0] != 0, nil
+	case 'y':
+		return data[0], nil
+	case 'n':
+		return int16(binary.LittleEndian.Uint16(data)), nil
+	case 'q':
+		return binary.LittleEndian.Uint16(data), nil
+	case 'i', 'h':
+		return int32(binary.LittleEndian.Uint32(data)), nil
+	case 'u':
+		return binary.LittleEndian.Uint32(data), nil
+	case 'x':
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case 't':
+		return binary.LittleEndian.Uint64(data), nil
+	case 'd':
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case 's', 'o', 'g':
+		if len(data) == 0 || data[len(data)-1] != 0 {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		return string(data[:len(data)-1]), nil
+	case 'v':
+		idx := bytes.LastIndexByte(data, 0)
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid variant")
+		}
+		valueType, rest, err := nextType(string(data[idx+1:]))
+		if err != nil || rest != "" {
+			return nil, fmt.Errorf("invalid variant type %q", data[idx+1:])
+		}
+		value, err := parse(valueType, data[:idx])
+		if err != nil {
+			return nil, err
+		}
+		return Variant{Type: valueType, Value: value}, nil
+	case 'm':
+		if len(data) == 0 {
+			return nil, nil
+		}
+		if fixedSize(typ[1:]) == 0 {
+			data = data[:len(data)-1]
+		}
+		return parse(typ[1:], data)
+	case 'a':
+		return parseArray(typ[1:], data)
+	case '(', '{':
+		return parseTuple(typ, data)
+	}
+	return nil, fmt.Errorf("unsupported type %q", typ)
+}
+
+func parseArray(elemType string, data []byte) (any, error) {
+	if elemType == "y" {
+		return data, nil
+	}
+
+	var elements [][]byte
+	if size := fixedSize(elemType); size > 0 {
+		if len(data)%size != 0 {
+			return nil, fmt.Errorf("invalid size %d of an array of %q", len(data), elemType)
+		}
+		for start := 0; start < len(data); start += size {
+			elements = append(elements, data[start:start+size])
+		}
+	} else if len(data) > 0 {
+		osize := offsetSize(len(data))
+		lastEnd := readOffset(data[len(data)-osize:], osize)
+		if lastEnd > len(data) || (len(data)-lastEnd)%osize != 0 {
+			return nil, fmt.Errorf("invalid framing of an array of %q", elemType)
+		}
+		start := 0
+		for i := 0; i < (len(data)-lastEnd)/osize; i++ {
+			end := readOffset(data[lastEnd+i*osize:], osize)
+			if i > 0 {
+				start = align(start, alignment(elemType))
+			}
+			if start > end || end > lastEnd {
+				return nil, fmt.Errorf("invalid framing of an array of %q", elemType)
+			}
+			elements = append(elements, data[start:end])
+			start = end
+		}
+	}
+
+	values := make([]any, 0, len(elements))
+	for _, element := range elements {
+		value, err := parse(elemType, element)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func parseTuple(typ string, data []byte) (any, error) {
+	members, err := memberTypes(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	osize := offsetSize(len(data))
+	framingEnd := len(data)
+	offset := 0
+	values := make([]any, 0, len(members))
+	for i, member := range members {
+		offset = align(offset, alignment(member))
+		var end int
+		switch {
+		case fixedSize(member) > 0:
+			end = offset + fixedSize(member)
+		case i == len(members)-1:
+			end = framingEnd
+		default:
+			if framingEnd-osize < offset {
+				return nil, fmt.Errorf("invalid framing of a value of type %q", typ)
+			}
+			framingEnd -= osize
+			end = readOffset(data[framingEnd:], osize)
+		}
+		if offset > end || end > framingEnd {
+			return nil, fmt.Errorf("invalid framing of a value of type %q", typ)
+		}
+		value, err := parse(member, data[offset:end])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		offset = end
+	}
+
+	if typ[0] == '{' {
+		return DictEntry{Key: values[0], Value: values[1]}, nil
+	}
+	return values, nil
+}
+
+// ParseDict parses data as an "a{sv}" dictionary
+func ParseDict(data []byte) (map[string]Variant, error) {
+	value, err := Parse("a{sv}", data)
+	if err != nil {
+		return nil, err
+	}
+	return Dict(value), nil
+}
+
+// Dict converts a parsed "a{sv}" value into a map
+func Dict(value any) map[string]Variant {
+	entries, _ := value.([]any)
+	dict := make(map[string]Variant, len(entries))
+	for _, entry := range entries {
+		entry := entry.(DictEntry)
+		dict[entry.Key.(string)] = entry.Value.(Variant)
+	}
+	return dict
+}
+
+// Serialize serializes value as a value of the type typ, the inverse of
+// Parse
+func Serialize(typ string, value any) ([]byte, error) {
+	t, rest, err := nextType(typ)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid type %q", typ)
+	}
+	return serialize(t, value)
+}
+
+// frame appends the framing offsets to the data of a container
+func frame(data []byte, offsets []int) []byte {
+	if len(offsets) == 0 {
+		return data
+	}
+	osize := 1
+	for offsetSize(len(data)+len(offsets)*osize) != osize {
+		osize *= 2
+	}
+	for _, offset := range offsets {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(offset))
+		data = append(data, buf[:osize]...)
+	}
+	return data
+}
+
+func pad(data []byte, alignment int) []byte {
+	return append(data, make([]byte, align(len(data), alignment)-len(data))...)
+}
+
+func serialize(typ string, value any) (data []byte, err error) {
+	defer func() {
+		// the type assertions panic for values of the wrong type
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot serialize %T as %q: %v", value, typ, r)
+		}
+	}()
+
+	switch typ[0] {
+	case 'b':
+		if value.(bool) {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case 'y':
+		return []byte{value.(uint8)}, nil
+	case 'n':
+		return binary.LittleEndian.AppendUint16(nil, uint16(value.(int16))), nil
+	case 'q':
+		return binary.LittleEndian.AppendUint16(nil, value.(uint16)), nil
+	case 'i', 'h':
+		return binary.LittleEndian.AppendUint32(nil, uint32(value.(int32))), nil
+	case 'u':
+		return binary.LittleEndian.AppendUint32(nil, value.(uint32)), nil
+	case 'x':
+		return binary.LittleEndian.AppendUint64(nil, uint64(value.(int64))), nil
+	case 't':
+		return binary.LittleEndian.AppendUint64(nil, value.(uint64)), nil
+	case 'd':
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(value.(float64))), nil
+	case 's', 'o', 'g':
+		return append([]byte(value.(string)), 0), nil
+	case 'v':
+		variant := value.(Variant)
+		data, err := Serialize(variant.Type, variant.Value)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(data, 0), variant.Type...), nil
+	case 'm':
+		if value == nil {
+			return nil, nil
+		}
+		data, err := serialize(typ[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		if fixedSize(typ[1:]) == 0 {
+			data = append(data, 0)
+		}
+		return data, nil
+	case 'a':
+		elemType := typ[1:]
+		if elemType == "y" {
+			return value.([]byte), nil
+		}
+		var data []byte
+		var offsets []int
+		for _, elem := range value.([]any) {
+			elemData, err := serialize(elemType, elem)
+			if err != nil {
+				return nil, err
+			}
+			data = append(pad(data, alignment(elemType)), elemData...)
+			offsets = append(offsets, len(data))
+		}
+		if fixedSize(elemType) > 0 {
+			return data, nil
+		}
+		return frame(data, offsets), nil
+	case '(', '{':
+		members, err := memberTypes(typ)
+		if err != nil {
+			return nil, err
+		}
+		var values []any
+		if typ[0] == '{' {
+			entry := value.(DictEntry)
+			values = []any{entry.Key, entry.Value}
+		} else {
+			values = value.([]any)
+		}
+		if len(values) != len(members) {
+			return nil, fmt.Errorf("cannot serialize %d values as %q", len(values), typ)
+		}
+		if len(members) == 0 {
+			return []byte{0}, nil
+		}
+
+		var data []byte
+		var offsets []int
+		for i, member := range members {
+			memberData, err := serialize(member, values[i])
+			if err != nil {
+				return nil, err
+			}
+			data = append(pad(data, alignment(member)), memberData...)
+			if fixedSize(member) == 0 && i != len(members)-1 {
+				offsets = append(offsets, len(data))
+			}
+		}
+		if fixedSize(typ) > 0 {
+			return pad(data, alignment(typ)), nil
+		}
+		// the framing offsets of tuples are stored in reverse order
+		for i, j := 0, len(offsets)-1; i < j; i, j = i+1, j-1 {
+			offsets[i], offsets[j] = offsets[j], offsets[i]
+		}
+		return frame(data, offsets), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", typ)
+}
@@ -0,0 +1,139 @@
+package gvariant_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/gvariant"
+)
+
+func TestParseSerialize(t *testing.T) {
+	// the data is serialized by GLib
+	type testCase struct {
+		typ   string
+		value any
+		data  []byte
+	}
+	for name, tc := range map[string]testCase{
+		"byte-string": {
+			typ:   "(ys)",
+			value: []any{uint8(1), "ab"},
+			data:  []byte{0x01, 'a', 'b', 0},
+		},
+		"string-byte": {
+			typ:   "(sy)",
+			value: []any{"ab", uint8(1)},
+			data:  []byte{'a', 'b', 0, 0x01, 0x03},
+		},
+		"fixed-size": {
+			typ:   "(ty)",
+			value: []any{uint64(0x0102), uint8(3)},
+			data:  []byte{0x02, 0x01, 0, 0, 0, 0, 0, 0, 0x03, 0, 0, 0, 0, 0, 0, 0},
+		},
+		"strings": {
+			typ:   "as",
+			value: []any{"a", "bc"},
+			data:  []byte{'a', 0, 'b', 'c', 0, 0x02, 0x05},
+		},
+		"dict": {
+			typ: "a{sv}",
+			value: []any{
+				gvariant.DictEntry{
+					Key:   "a",
+					Value: gvariant.Variant{Type: "aay", Value: []any{[]byte{0x01}}},
+				},
+			},
+			data: []byte{
+				'a', 0, 0, 0, 0, 0, 0, 0, // key and padding
+				0x01, 0x01, 0, 'a', 'a', 'y', // variant of the value
+				0x02, // framing offset of the key
+				0x0f, // framing offset of the entry
+			},
+		},
+		"maybe": {
+			typ:   "(msmy)",
+			value: []any{"a", nil},
+			data:  []byte{'a', 0, 0, 0x03},
+		},
+		"empty-array": {
+			typ:   "as",
+			value: []any{},
+			data:  nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := gvariant.Serialize(tc.typ, tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.data, data)
+
+			value, err := gvariant.Parse(tc.typ, tc.data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.value, value)
+		})
+	}
+}
+
+func TestParseWideOffsets(t *testing.T) {
+	// bigger containers use wider framing offsets
+	big := bytes.Repeat([]byte{0x42}, 300)
+	value := []any{
+		gvariant.DictEntry{
+			Key:   "ostree.gpgsigs",
+			Value: gvariant.Variant{Type: "aay", Value: []any{big, []byte{0x01, 0x02}}},
+		},
+		gvariant.DictEntry{
+			Key:   "x",
+			Value: gvariant.Variant{Type: "(tts)", Value: []any{uint64(1), uint64(2), "c"}},
+		},
+	}
+	data, err := gvariant.Serialize("a{sv}", value)
+	require.NoError(t, err)
+
+	dict, err := gvariant.ParseDict(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]gvariant.Variant{
+		"ostree.gpgsigs": {Type: "aay", Value: []any{big, []byte{0x01, 0x02}}},
+		"x":              {Type: "(tts)", Value: []any{uint64(1), uint64(2), "c"}},
+	}, dict)
+}
+
+func TestParseErrors(t *testing.T) {
+	type testCase struct {
+		typ    string
+		data   []byte
+		errMsg string
+	}
+	for name, tc := range map[string]testCase{
+		"invalid-framing": {
+			typ:    "a{sv}",
+			data:   []byte{'a', 0, 0xff},
+			errMsg: `invalid framing of an array of "{sv}"`,
+		},
+		"fixed-size": {
+			typ:    "t",
+			data:   []byte{0x01},
+			errMsg: `invalid size 1 of a value of type "t"`,
+		},
+		"unterminated-string": {
+			typ:    "s",
+			data:   []byte{'a'},
+			errMsg: "unterminated string",
+		},
+		"unsupported-type": {
+			typ:    "(sz)",
+			errMsg: `unsupported type "z)"`,
+		},
+		"trailing-type": {
+			typ:    "ss",
+			errMsg: `invalid type "ss"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := gvariant.Parse(tc.typ, tc.data)
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
@@ -11,12 +11,14 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/opencontainers/go-digest"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/containers/image/v5/manifest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
@@ -114,6 +116,13 @@ func (sm *SyncMap[T]) Add(name string, elem T) {
 	sm.mappy[name] = elem
 }
 
+func (sm *SyncMap[T]) Delete(name string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	delete(sm.mappy, name)
+}
+
 func (sm *SyncMap[T]) Get(name string) (T, bool) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
@@ -128,6 +137,15 @@ type Repo struct {
 	manifests SyncMap[*manifest.Schema2]
 	images    SyncMap[*manifest.Schema2List]
 	tags      SyncMap[string]
+
+	// referrers indexes by the digest of their subject
+	referrers SyncMap[*imgspecv1.Index]
+	// referrersAPI serves the referrers via the referrers API instead
+	// of the referrers tag schema, see EnableReferrersAPI()
+	referrersAPI bool
+
+	// uploads are the blob upload sessions that were started by pushes
+	uploads SyncMap[*bytes.Buffer]
 }
 
 func NewRepo() *Repo {
@@ -136,6 +154,8 @@ func NewRepo() *Repo {
 		manifests: SyncMap[*manifest.Schema2]{},
 		images:    SyncMap[*manifest.Schema2List]{},
 		tags:      SyncMap[string]{},
+		referrers: SyncMap[*imgspecv1.Index]{},
+		uploads:   SyncMap[*bytes.Buffer]{},
 	}
 }
 
@@ -223,6 +243,126 @@ func (r *Repo) AddTag(checksum, tag string) {
 	r.tags.Add(tag, checksum)
 }
 
+// EnableReferrersAPI serves the referrers of the repository via the
+// referrers API of the OCI distribution spec 1.1 instead of the referrers
+// tag schema. It must be called before adding referrers.
+func (r *Repo) EnableReferrersAPI() {
+	r.referrersAPI = true
+}
+
+// AddReferrer attaches an artifact with the given data to the image with
+// the subject digest, using the referrers tag schema of the OCI
+// distribution spec or the referrers API (see EnableReferrersAPI()). It
+// returns the digest of the artifact manifest.
+func (r *Repo) AddReferrer(subject, artifactType string, data []byte) string {
+	subjectDigest := digest.Digest(subject)
+	subjectBlob, ok := r.blobs.Get(subject)
+	if !ok {
+		panic("cannot add referrer: subject not found: " + subject)
+	}
+
+	layer := r.AddBlob(dataBlob{Data: data, MediaType: artifactType})
+	config := r.AddBlob(dataBlob{Data: []byte("{}"), MediaType: imgspecv1.MediaTypeEmptyJSON})
+	mf := imgspecv1.Manifest{
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: artifactType,
+		Config: imgspecv1.Descriptor{
+			MediaType: config.MediaType,
+			Digest:    config.Digest,
+			Size:      config.Size,
+		},
+		Layers: []imgspecv1.Descriptor{
+			{
+				MediaType: layer.MediaType,
+				Digest:    layer.Digest,
+				Size:      layer.Size,
+			},
+		},
+		Subject: &imgspecv1.Descriptor{
+			MediaType: subjectBlob.GetMediaType(),
+			Digest:    subjectDigest,
+			Size:      subjectBlob.GetSize(),
+		},
+	}
+	mf.SchemaVersion = 2
+	mfDesc := r.AddObject(mf, mf.MediaType)
+
+	index, ok := r.referrers.Get(subject)
+	if !ok {
+		index = &imgspecv1.Index{MediaType: imgspecv1.MediaTypeImageIndex}
+		index.SchemaVersion = 2
+		r.referrers.Add(subject, index)
+	}
+	index.Manifests = append(index.Manifests, imgspecv1.Descriptor{
+		MediaType:    mfDesc.MediaType,
+		ArtifactType: artifactType,
+		Digest:       mfDesc.Digest,
+		Size:         mfDesc.Size,
+	})
+	if !r.referrersAPI {
+		indexDesc := r.AddObject(index, index.MediaType)
+		r.tags.Add(fmt.Sprintf("%s-%s", subjectDigest.Algorithm(), subjectDigest.Encoded()), indexDesc.Digest.String())
+	}
+
+	return mfDesc.Digest.String()
+}
+
+// AddSigstoreSignature attaches a sigstore signature to the manifest with
+// the subject digest, the way cosign stores signatures in registries. The
+// signature is the base64 encoded signature of the payload. It returns the
+// digest of the signature layer.
+func (r *Repo) AddSigstoreSignature(subject string, payload []byte, signature string) string {
+	subjectDigest := digest.Digest(subject)
+	if _, ok := r.blobs.Get(subject); !ok {
+		panic("cannot add signature: subject not found: " + subject)
+	}
+
+	layer := r.AddBlob(dataBlob{Data: payload, MediaType: "application/vnd.dev.cosign.simplesigning.v1+json"})
+	config := r.AddBlob(dataBlob{Data: []byte("{}"), MediaType: imgspecv1.MediaTypeImageConfig})
+	mf := imgspecv1.Manifest{
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config: imgspecv1.Descriptor{
+			MediaType: config.MediaType,
+			Digest:    config.Digest,
+			Size:      config.Size,
+		},
+		Layers: []imgspecv1.Descriptor{
+			{
+				MediaType: layer.MediaType,
+				Digest:    layer.Digest,
+				Size:      layer.Size,
+				Annotations: map[string]string{
+					"dev.cosignproject.cosign/signature": signature,
+				},
+			},
+		},
+	}
+	mf.SchemaVersion = 2
+	mfDesc := r.AddObject(mf, mf.MediaType)
+	r.tags.Add(fmt.Sprintf("%s-%s.sig", subjectDigest.Algorithm(), subjectDigest.Encoded()), mfDesc.Digest.String())
+
+	return layer.Digest.String()
+}
+
+// GetManifest returns the data and the media type of the manifest with the
+// given tag or digest, e.g. to inspect manifests that were pushed.
+func (r *Repo) GetManifest(ref string) ([]byte, string, bool) {
+	if checksum, ok := r.tags.Get(ref); ok {
+		ref = checksum
+	}
+
+	blob, ok := r.blobs.Get(ref)
+	if !ok || !BlobIsManifest(blob) {
+		return nil, "", false
+	}
+
+	data, err := io.ReadAll(blob.Reader())
+	if err != nil {
+		panic("cannot read manifest: " + err.Error())
+	}
+	return data, blob.GetMediaType(), true
+}
+
 func WriteBlob(blob Blob, w http.ResponseWriter) {
 	w.Header().Add("Content-Type", blob.GetMediaType())
 	w.Header().Add("Content-Length", fmt.Sprintf("%d", blob.GetSize()))
@@ -238,8 +378,19 @@ func WriteBlob(blob Blob, w http.ResponseWriter) {
 }
 
 func BlobIsManifest(blob Blob) bool {
-	mt := blob.GetMediaType()
-	return mt == manifest.DockerV2Schema2MediaType || mt == manifest.DockerV2ListMediaType
+	switch blob.GetMediaType() {
+	case manifest.DockerV2Schema2MediaType, manifest.DockerV2ListMediaType, imgspecv1.MediaTypeImageManifest, imgspecv1.MediaTypeImageIndex:
+		return true
+	}
+	return false
+}
+
+// writeManifestUnknown writes the error of the distribution spec for
+// manifests that do not exist
+func writeManifestUnknown(ref string, w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown","detail":%q}]}`, ref)
 }
 
 func (r *Repo) ServeManifest(ref string, w http.ResponseWriter, req *http.Request) {
@@ -250,13 +401,33 @@ func (r *Repo) ServeManifest(ref string, w http.ResponseWriter, req *http.Reques
 	blob, ok := r.blobs.Get(ref)
 	if !ok || !BlobIsManifest(blob) {
 		fmt.Fprintf(os.Stderr, "manifest %s not found", ref)
-		http.NotFound(w, req)
+		writeManifestUnknown(ref, w)
 		return
 	}
 
 	WriteBlob(blob, w)
 }
 
+// ServeReferrers serves the referrers index of the subject digest, or a
+// 404 if the repository does not support the referrers API.
+func (r *Repo) ServeReferrers(subject string, w http.ResponseWriter, req *http.Request) {
+	if !r.referrersAPI {
+		http.NotFound(w, req)
+		return
+	}
+	index, ok := r.referrers.Get(subject)
+	if !ok {
+		index = &imgspecv1.Index{MediaType: imgspecv1.MediaTypeImageIndex, Manifests: []imgspecv1.Descriptor{}}
+		index.SchemaVersion = 2
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		panic(err)
+	}
+	w.Header().Set("Content-Type", imgspecv1.MediaTypeImageIndex)
+	_, _ = w.Write(data)
+}
+
 func (r *Repo) ServeBlob(ref string, w http.ResponseWriter, req *http.Request) {
 
 	blob, ok := r.blobs.Get(ref)
@@ -270,6 +441,101 @@ func (r *Repo) ServeBlob(ref string, w http.ResponseWriter, req *http.Request) {
 	WriteBlob(blob, w)
 }
 
+// PutManifest stores a pushed manifest and tags it if ref is not a digest
+func (r *Repo) PutManifest(ref string, w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	desc := r.AddBlob(dataBlob{
+		Data:      data,
+		MediaType: req.Header.Get("Content-Type"),
+	})
+	checksum := desc.Digest.String()
+
+	if _, err := digest.Parse(ref); err != nil {
+		r.tags.Add(ref, checksum)
+	} else if ref != checksum {
+		http.Error(w, "manifest digest mismatch", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Docker-Content-Digest", checksum)
+	w.Header().Add("Location", req.URL.Path)
+	w.WriteHeader(http.StatusCreated)
+}
+
+var uploadCounter atomic.Int64
+
+// StartUpload starts a blob upload session or mounts a blob of another
+// repo of the registry
+func (r *Repo) StartUpload(reg *Registry, w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if mount := query.Get("mount"); mount != "" {
+		if from, ok := reg.repos.Get(query.Get("from")); ok {
+			if blob, ok := from.blobs.Get(mount); ok {
+				r.AddBlob(blob)
+				w.Header().Add("Docker-Content-Digest", mount)
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+	}
+
+	id := fmt.Sprintf("upload-%d", uploadCounter.Add(1))
+	r.uploads.Add(id, &bytes.Buffer{})
+
+	w.Header().Add("Location", strings.TrimSuffix(req.URL.Path, "/")+"/"+id)
+	w.Header().Add("Range", "0-0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ServeUpload writes the data of a blob upload session and stores the
+// blob when the upload is completed
+func (r *Repo) ServeUpload(id string, w http.ResponseWriter, req *http.Request) {
+	buf, ok := r.uploads.Get(id)
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPatch, http.MethodPut:
+		if _, err := io.Copy(buf, req.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		r.uploads.Delete(id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if req.Method == http.MethodPatch {
+		w.Header().Add("Location", req.URL.Path)
+		w.Header().Add("Range", fmt.Sprintf("0-%d", max(buf.Len()-1, 0)))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	blob := dataBlob{Data: buf.Bytes()}
+	if expected := req.URL.Query().Get("digest"); expected != blob.GetDigest().String() {
+		http.Error(w, "blob digest mismatch", http.StatusBadRequest)
+		return
+	}
+	r.uploads.Delete(id)
+	desc := r.AddBlob(blob)
+
+	w.Header().Add("Docker-Content-Digest", desc.Digest.String())
+	w.Header().Add("Location", req.URL.Path)
+	w.WriteHeader(http.StatusCreated)
+}
+
 // Registry //
 
 type Registry struct {
@@ -286,6 +552,9 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// [1] version-check:  /v2/
 	// [2] blobs:          /v2/<repo_name>/blobs/<digest>
 	// [3] manifest:       /v2/<repo_name>/manifests/<ref>
+	// [4] upload start:   /v2/<repo_name>/blobs/uploads/
+	// [5] upload:         /v2/<repo_name>/blobs/uploads/<id>
+	// [6] referrers:      /v2/<repo_name>/referrers/<digest>
 	//
 	// we need at least 4 path components and path has to start with "/v2"
 
@@ -306,8 +575,16 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// we asserted that we have at least 4 path components
 	ref := paths[len(paths)-1]
 	cmd := paths[len(paths)-2]
+	nameEnd := len(paths) - 2
+
+	if cmd == "blobs" && ref == "uploads" {
+		cmd = "uploads"
+	} else if len(paths) > 4 && cmd == "uploads" && paths[len(paths)-3] == "blobs" {
+		cmd = "upload"
+		nameEnd = len(paths) - 3
+	}
 
-	repoName := strings.Join(paths[1:len(paths)-2], "/")
+	repoName := strings.Join(paths[1:nameEnd], "/")
 
 	repo, ok := reg.repos.Get(repoName)
 	if !ok {
@@ -318,9 +595,23 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	switch cmd {
 	case "manifests":
+		if req.Method == http.MethodPut {
+			repo.PutManifest(ref, w, req)
+			return
+		}
 		repo.ServeManifest(ref, w, req)
 	case "blobs":
 		repo.ServeBlob(ref, w, req)
+	case "uploads":
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		repo.StartUpload(reg, w, req)
+	case "upload":
+		repo.ServeUpload(ref, w, req)
+	case "referrers":
+		repo.ServeReferrers(ref, w, req)
 	default:
 		http.NotFound(w, req)
 	}
@@ -22,6 +22,14 @@ type Info struct {
 	// The default root filesystem from the container's bootc config
 	DefaultRootFs string
 
+	// The minimum size of the root filesystem from the container's bootc
+	// config, 0 if not set
+	RootSize uint64
+
+	// The default block setup from the container's bootc config, i.e.
+	// BlockSetupDirect or BlockSetupTPM2LUKS
+	BlockSetup string
+
 	// The size of the container image
 	Size uint64
 
@@ -30,4 +38,8 @@ type Info struct {
 
 	// What bootloader should be passed?
 	Bootloader *string
+
+	// The logically bound images of the container that are pulled
+	// into the container storage of bootc
+	BoundImages []string
 }
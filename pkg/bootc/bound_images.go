@@ -0,0 +1,98 @@
+package bootc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// boundImagesDir contains the logically bound images of a bootc
+// container, as symlinks to podman quadlet ".container" or ".image"
+// files. See https://bootc-dev.github.io/bootc/logically-bound-images.html
+const boundImagesDir = "/usr/lib/bootc/bound-images.d"
+
+// parseBoundImage returns the image of the quadlet file with the given
+// name, i.e. the "Image=" key of its [Container] or [Image] section.
+func parseBoundImage(name string, data []byte) (string, error) {
+	var section string
+	switch filepath.Ext(name) {
+	case ".container":
+		section = "[Container]"
+	case ".image":
+		section = "[Image]"
+	default:
+		return "", fmt.Errorf("unsupported bound image %s, must be a .container or .image file", name)
+	}
+
+	var image string
+	inSection := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "["):
+			inSection = line == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Image":
+			image = strings.Trim(strings.TrimSpace(value), `"`)
+		case "AuthFile":
+			// like bootc itself
+			return "", fmt.Errorf("bound image %s: AuthFile is not supported", name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("cannot parse bound image %s: %w", name, err)
+	}
+	if image == "" {
+		return "", fmt.Errorf("bound image %s: no Image key in %s section", name, section)
+	}
+	return image, nil
+}
+
+// boundImages returns the logically bound images of the bootc container c
+func boundImages(c inspector) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(c.Root(), boundImagesDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read bound images: %w", err)
+	}
+
+	var images []string
+	// os.ReadDir sorts the entries by name
+	for _, entry := range entries {
+		// the entries are usually symlinks into the container, read
+		// them through the container to resolve them
+		data, err := c.ReadFile(path.Join(boundImagesDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		image, err := parseBoundImage(entry.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(images, image) {
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,54 @@
+package bootc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/bootc"
+)
+
+func TestParseBoundImage(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected string
+		err      string
+	}{
+		{
+			name:     "app.container",
+			data:     "[Unit]\nDescription=App\n\n[Container]\n# a comment\nImage=quay.io/example/app:latest\nPublishPort=8080:80\n",
+			expected: "quay.io/example/app:latest",
+		},
+		{
+			name:     "db.image",
+			data:     "[Image]\nImage = \"quay.io/example/db:16\"\n",
+			expected: "quay.io/example/db:16",
+		},
+		{
+			name: "wrong-section.image",
+			data: "[Container]\nImage=quay.io/example/app:latest\n",
+			err:  "bound image wrong-section.image: no Image key in [Image] section",
+		},
+		{
+			name: "auth.container",
+			data: "[Container]\nImage=quay.io/example/app:latest\nAuthFile=/etc/auth.json\n",
+			err:  "bound image auth.container: AuthFile is not supported",
+		},
+		{
+			name: "app.kube",
+			data: "[Kube]\nYaml=app.yaml\n",
+			err:  "unsupported bound image app.kube, must be a .container or .image file",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			image, err := bootc.ParseBoundImage(tc.name, []byte(tc.data))
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, image)
+		})
+	}
+}
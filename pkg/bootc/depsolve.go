@@ -0,0 +1,119 @@
+package bootc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// depsolveDNFPath is the path of osbuild-depsolve-dnf inside of the
+// bootstrap container
+const depsolveDNFPath = "/usr/libexec/osbuild-depsolve-dnf"
+
+// rhsmSecretDirs are the host directories with the entitlement
+// certificates and the CA of RHSM repositories, the depsolver resolves
+// the secrets of RHSM repositories to paths in them.
+var rhsmSecretDirs = []string{
+	"/etc/pki/entitlement",
+	"/etc/rhsm/ca",
+}
+
+// repoSecretVolumes returns the read-only volumes that make the TLS
+// certificates and keys of the repositories available in the container
+// at the same paths as on the host.
+func repoSecretVolumes(repos []rpmmd.RepoConfig) ([]string, error) {
+	var paths []string
+	for _, repo := range repos {
+		for _, path := range []string{repo.SSLCACert, repo.SSLClientKey, repo.SSLClientCert} {
+			if path == "" {
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				return nil, fmt.Errorf("repository %q: TLS certificate or key path %q must be absolute", repo.Id, path)
+			}
+			paths = append(paths, path)
+		}
+		if repo.RHSM {
+			for _, dir := range rhsmSecretDirs {
+				if _, err := os.Stat(dir); err == nil {
+					paths = append(paths, dir)
+				}
+			}
+		}
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	volumes := make([]string, 0, len(paths))
+	for _, path := range paths {
+		volumes = append(volumes, fmt.Sprintf("%s:%s:ro", path, path))
+	}
+	return volumes, nil
+}
+
+// installDepsolveDNF installs osbuild-depsolve-dnf in the container unless
+// it is already available.
+func (c *Container) installDepsolveDNF() error {
+	if err := exec.Command("podman", "exec", c.id, "test", "-x", depsolveDNFPath).Run(); err == nil {
+		return nil
+	}
+	if err := exec.Command("podman", "exec", c.id, "sh", "-c", `command -v dnf`).Run(); err != nil {
+		return ErrNoDnf
+	}
+	/* #nosec G204 */
+	if output, err := exec.Command("podman", "exec", c.id, "dnf", "install", "-y", "--setopt=install_weak_deps=False", "osbuild-depsolve-dnf").CombinedOutput(); err != nil {
+		return fmt.Errorf("installing osbuild-depsolve-dnf in %s container failed: %w\noutput:\n%s", c.ref, err, string(output))
+	}
+	return nil
+}
+
+// NewBootstrapSolver starts the given (bootstrap) container and returns a
+// Solver that runs osbuild-depsolve-dnf inside of it. This way the
+// libdnf and rpm of the target distribution read the repository metadata,
+// which is needed when the host cannot handle it (e.g. newer compression
+// formats or rpm headers). osbuild-depsolve-dnf is installed in the
+// container if needed.
+//
+// The cache directory is shared with the host, so the solver can be used
+// like one created with depsolvednf.NewSolver(). The custom TLS
+// certificates and keys of the given repositories and the RHSM
+// entitlements (for RHSM repositories) are mounted read-only at their
+// host paths. The returned cleanup function stops the container and must
+// always be called.
+func NewBootstrapSolver(ref, cacheDir, modulePlatformID, releaseVer, arch, distro string, repos []rpmmd.RepoConfig) (*depsolvednf.Solver, func() error, error) {
+	cacheDir, err := filepath.Abs(cacheDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("cannot create cache directory: %w", err)
+	}
+	secretVolumes, err := repoSecretVolumes(repos)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the cache and the secrets are mounted at the same paths so that
+	// the paths of the depsolve requests are valid in the container
+	volumes := append([]string{fmt.Sprintf("%s:%s", cacheDir, cacheDir)}, secretVolumes...)
+	cnt, err := newContainer(ref, volumes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cnt.installDepsolveDNF(); err != nil {
+		if stopErr := cnt.Stop(); stopErr != nil {
+			err = fmt.Errorf("%w\nstopping the container failed too: %s", err, stopErr)
+		}
+		return nil, nil, err
+	}
+
+	solver := depsolvednf.NewSolver(modulePlatformID, releaseVer, arch, distro, cacheDir)
+	argv := cnt.ExecArgv()
+	solver.SetDepsolveDNFPath(argv[0], append(argv[1:], depsolveDNFPath)...)
+	return solver, cnt.Stop, nil
+}
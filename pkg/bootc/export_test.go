@@ -3,3 +3,21 @@ package bootc
 func (cnt *Container) ID() string {
 	return cnt.id
 }
+
+var ParseBoundImage = parseBoundImage
+
+var RepoSecretVolumes = repoSecretVolumes
+
+func MockRHSMSecretDirs(dirs []string) (restore func()) {
+	saved := rhsmSecretDirs
+	rhsmSecretDirs = dirs
+	return func() {
+		rhsmSecretDirs = saved
+	}
+}
+
+// NewTestContainer returns a container whose tree is at root, without
+// running it
+func NewTestContainer(root, arch string) *Container {
+	return &Container{root: root, arch: arch}
+}
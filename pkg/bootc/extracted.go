@@ -0,0 +1,396 @@
+package bootc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dockerarchive "github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/image"
+	ociarchive "github.com/containers/image/v5/oci/archive"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/storage/pkg/archive"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+)
+
+// ExtractOptions configure how NewExtractedContainer reads an image
+type ExtractOptions struct {
+	// TempDir is the directory in which the image is extracted,
+	// os.TempDir() when empty
+	TempDir string
+
+	// Arch selects the image of multi-arch containers, the host
+	// architecture when empty
+	Arch string
+}
+
+// ExtractedContainer is a bootc container whose image is extracted into a
+// directory on the host. Unlike Container it does not need podman and
+// does not run any processes inside the container, which makes it usable
+// in restricted environments like unprivileged build pods. The
+// information that Container gets by running tools inside the container
+// is read from the files of the image instead.
+//
+// Only images in OCI layouts and archives are supported. Images in
+// containers-storage are not read directly, they are handled by Container.
+type ExtractedContainer struct {
+	ref  string
+	id   string
+	root string
+	arch string
+	size uint64
+}
+
+// imageReference returns the reference of the image ref, which must be
+// an "oci:", "oci-archive:" or "docker-archive:" reference.
+func imageReference(ref string) (types.ImageReference, error) {
+	transport, path, ok := container.ParseArchiveSource(ref)
+	if !ok {
+		return nil, fmt.Errorf("unsupported container reference '%s', must be an oci, oci-archive or docker-archive reference", ref)
+	}
+
+	var imgRef types.ImageReference
+	var err error
+	switch transport {
+	case container.OCILayoutTransport:
+		imgRef, err = layout.ParseReference(path)
+	case container.OCIArchiveTransport:
+		imgRef, err = ociarchive.ParseReference(path)
+	case container.DockerArchiveTransport:
+		imgRef, err = dockerarchive.ParseReference(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", ref, err)
+	}
+	return imgRef, nil
+}
+
+// openImage opens the image ref for reading and returns it with its
+// configuration. The instance of multi-arch images is selected by
+// architecture, the host architecture when empty. Temporary files, e.g.
+// of unpacked archives, are written to tmpdir. The caller needs to close
+// the returned source.
+func openImage(ctx context.Context, ref, architecture, tmpdir string) (types.ImageSource, types.Image, *imgspecv1.Image, error) {
+	imgRef, err := imageReference(ref)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sys := &types.SystemContext{
+		OSChoice:             "linux",
+		BigFilesTemporaryDir: tmpdir,
+	}
+	if architecture != "" {
+		a, err := arch.FromString(architecture)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		sys.ArchitectureChoice = a.GoArch()
+		sys.VariantChoice = a.GoVariant()
+	}
+
+	src, err := imgRef.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot read %s container: %w", ref, err)
+	}
+
+	img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		src.Close()
+		return nil, nil, nil, fmt.Errorf("cannot read %s container: %w", ref, err)
+	}
+	config, err := img.OCIConfig(ctx)
+	if err != nil {
+		src.Close()
+		return nil, nil, nil, fmt.Errorf("cannot read the configuration of %s container: %w", ref, err)
+	}
+	return src, img, config, nil
+}
+
+// NewExtractedContainer extracts the layers of the image ref into a new
+// directory. ref is an "oci:", "oci-archive:" or "docker-archive:"
+// reference (see container.ParseArchiveSource). The image is only read. Close removes the
+// extracted image.
+func NewExtractedContainer(ctx context.Context, ref string, opts ExtractOptions) (c *ExtractedContainer, err error) {
+	tmpdir, err := os.MkdirTemp(opts.TempDir, "bootc-container-")
+	if err != nil {
+		return nil, err
+	}
+	c = &ExtractedContainer{
+		ref:  ref,
+		root: filepath.Join(tmpdir, "root"),
+	}
+	// Ensure that the extracted image is removed when this function errors
+	defer func() {
+		if err != nil {
+			if closeErr := removeTree(tmpdir); closeErr != nil {
+				err = fmt.Errorf("%w\nremoving the extracted container failed too: %s", err, closeErr)
+			}
+			c = nil
+		}
+	}()
+	if err := os.Mkdir(c.root, 0755); err != nil {
+		return nil, err
+	}
+
+	src, img, config, err := openImage(ctx, ref, opts.Arch, tmpdir)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	c.id = img.ConfigInfo().Digest.Encoded()
+	c.arch = config.Architecture
+
+	for _, layer := range img.LayerInfos() {
+		size, err := c.applyLayer(ctx, src, layer)
+		if err != nil {
+			return nil, fmt.Errorf("cannot extract layer %s of %s container: %w", layer.Digest, ref, err)
+		}
+		c.size += size
+	}
+
+	return c, nil
+}
+
+// countingWriter counts the bytes that are written to it
+type countingWriter struct {
+	n uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += uint64(len(p))
+	return len(p), nil
+}
+
+// applyLayer extracts the layer into the root of the container, applying
+// the whiteouts of the layer. It returns the uncompressed size of the
+// layer.
+func (c *ExtractedContainer) applyLayer(ctx context.Context, src types.ImageSource, layer types.BlobInfo) (uint64, error) {
+	blob, _, err := src.GetBlob(ctx, layer, none.NoCache)
+	if err != nil {
+		return 0, err
+	}
+	defer blob.Close()
+
+	decompressed, err := archive.DecompressStream(blob)
+	if err != nil {
+		return 0, err
+	}
+	defer decompressed.Close()
+
+	var counter countingWriter
+	_, err = archive.ApplyUncompressedLayer(c.root, io.TeeReader(decompressed, &counter), &archive.TarOptions{
+		// ownership and device nodes cannot be restored without
+		// privileges, which are not needed to inspect the image
+		IgnoreChownErrors: true,
+		InUserNS:          os.Geteuid() != 0,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// removeTree removes the directory tree at path, including directories
+// without write permissions that are common in images
+func removeTree(path string) error {
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			return os.Chmod(p, 0700)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// Close removes the extracted image.
+func (c *ExtractedContainer) Close() error {
+	return removeTree(filepath.Dir(c.root))
+}
+
+// ResolveInfo loads all information from the extracted container.
+func (c *ExtractedContainer) ResolveInfo() (*Info, error) {
+	bootcInfo := &Info{
+		Imgref:  c.ref,
+		ImageID: c.id,
+		Arch:    c.Arch(),
+		Size:    c.size,
+	}
+
+	if err := resolveInfo(c, bootcInfo); err != nil {
+		return nil, err
+	}
+
+	return bootcInfo, nil
+}
+
+// Root returns the root directory of the extracted container.
+func (c *ExtractedContainer) Root() string {
+	return c.root
+}
+
+// Arch returns the architecture of the container
+func (c *ExtractedContainer) Arch() string {
+	return c.arch
+}
+
+// maxSymlinks is the number of symlinks that resolvePath follows, like
+// the limit of the kernel
+const maxSymlinks = 40
+
+// resolveInRoot returns the path on the host of the given path in the
+// container tree at root, resolving symlinks as if root was the root
+// directory. The returned path is always inside root.
+func resolveInRoot(root, path string) (string, error) {
+	resolved := "/"
+	parts := strings.Split(path, "/")
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		st, err := os.Lstat(filepath.Join(root, next))
+		if errors.Is(err, fs.ErrNotExist) {
+			// let the caller report the missing file
+			return filepath.Join(append([]string{root, next}, parts...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if st.Mode()&fs.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(target, "/"), parts...)
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+func (c *ExtractedContainer) resolvePath(path string) (string, error) {
+	return resolveInRoot(c.root, path)
+}
+
+// Reads a file from the container
+func (c *ExtractedContainer) ReadFile(path string) ([]byte, error) {
+	hostPath, err := c.resolvePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from %s container failed: %w", path, c.ref, err)
+	}
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s from %s container failed: %w", path, c.ref, err)
+	}
+	return data, nil
+}
+
+// InstallConfiguration returns the install configuration for bootc container
+// merged from the configuration files of the container like bootc does it
+func (c *ExtractedContainer) InstallConfiguration() (BootcInstallConfiguration, error) {
+	return readInstallConfiguration(c.root, c.arch)
+}
+
+// InitrdModules gets the list of modules from the container's initrd
+func (c *ExtractedContainer) InitrdModules(kver string) ([]string, error) {
+	initrd, err := c.resolvePath(filepath.Join("/usr/lib/modules", kver, "initramfs.img"))
+	if err != nil {
+		return nil, err
+	}
+	return readInitrdModules(initrd)
+}
+
+// UnifiedKernel finds out if the kernel inside the bootc container is
+// unified, i.e. if the container has a unified kernel image (UKI)
+func (c *ExtractedContainer) UnifiedKernel() (bool, error) {
+	for _, dir := range []string{"/boot/EFI/Linux", "/usr/lib/modules/*"} {
+		matches, err := filepath.Glob(filepath.Join(c.root, dir, "*.efi"))
+		if err != nil {
+			return false, err
+		}
+		if len(matches) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ResolveExtractedBootcInfo resolves the bootc container reference like
+// ResolveBootcInfo, but extracts the image instead of running it with
+// podman (see ExtractedContainer)
+func ResolveExtractedBootcInfo(ctx context.Context, ref string, opts ExtractOptions) (info *Info, err error) {
+	c, err := NewExtractedContainer(ctx, ref, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cErr := c.Close(); cErr != nil {
+			if err != nil {
+				err = fmt.Errorf("%w\nremoving the extracted container failed too: %s", err, cErr)
+			} else {
+				err = fmt.Errorf("removing the extracted container failed: %s", cErr)
+			}
+		}
+	}()
+
+	return c.ResolveInfo()
+}
+
+// ResolveExtractedBootcBuildInfo resolves the build container reference
+// like ResolveBootcBuildInfo. Only the configuration of the image is read,
+// its layers are not extracted.
+func ResolveExtractedBootcBuildInfo(ctx context.Context, ref string, opts ExtractOptions) (*Info, error) {
+	tmpdir, err := os.MkdirTemp(opts.TempDir, "bootc-container-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	src, img, config, err := openImage(ctx, ref, opts.Arch, tmpdir)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return &Info{
+		Imgref:  ref,
+		ImageID: img.ConfigInfo().Digest.Encoded(),
+		Arch:    config.Architecture,
+	}, nil
+}
@@ -0,0 +1,273 @@
+package bootc_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/bootc"
+)
+
+type tarEntry struct {
+	name     string
+	body     string
+	linkname string
+	dir      bool
+}
+
+func makeLayer(t *testing.T, entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		case e.linkname != "":
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.linkname
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// makeCPIO writes an uncompressed "newc" cpio archive with the given files
+func makeCPIO(files map[string]string, names ...string) []byte {
+	var buf bytes.Buffer
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	for i, name := range append(names, "TRAILER!!!") {
+		data := files[name]
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			i+1, 0100644, 0, 0, 1, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name + "\x00")
+		pad()
+		buf.WriteString(data)
+		pad()
+	}
+	return buf.Bytes()
+}
+
+func makeInitrd(t *testing.T) string {
+	early := makeCPIO(map[string]string{
+		"kernel/x86/microcode/GenuineIntel.bin": "microcode",
+	}, "kernel/x86/microcode/GenuineIntel.bin")
+
+	main := makeCPIO(map[string]string{
+		"usr/lib/dracut/modules.txt": "bash\nsystemd\nostree\n",
+		"usr/bin/true":               "",
+	}, "usr/bin/true", "usr/lib/dracut/modules.txt")
+	var compressed bytes.Buffer
+	enc, err := zstd.NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = enc.Write(main)
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+
+	// dracut pads the early archive to a block boundary
+	initrd := append(early, make([]byte, 512-len(early)%512)...)
+	return string(append(initrd, compressed.Bytes()...))
+}
+
+// makeOCILayout writes an oci layout with an image with the given layers
+func makeOCILayout(t *testing.T, architecture string, layers ...[]byte) string {
+	dir := t.TempDir()
+	addBlob := func(mediaType string, data []byte) imgspecv1.Descriptor {
+		dgst := digest.FromBytes(data)
+		blobDir := filepath.Join(dir, "blobs", dgst.Algorithm().String())
+		require.NoError(t, os.MkdirAll(blobDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(blobDir, dgst.Encoded()), data, 0644))
+		return imgspecv1.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+	}
+	marshal := func(v any) []byte {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return data
+	}
+
+	var layerDescs []imgspecv1.Descriptor
+	for _, layer := range layers {
+		layerDescs = append(layerDescs, addBlob(imgspecv1.MediaTypeImageLayerGzip, layer))
+	}
+	config := addBlob(imgspecv1.MediaTypeImageConfig, marshal(imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: architecture, OS: "linux"},
+		RootFS:   imgspecv1.RootFS{Type: "layers"},
+	}))
+	manifest := addBlob(imgspecv1.MediaTypeImageManifest, marshal(imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    layerDescs,
+	}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, imgspecv1.ImageIndexFile), marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{manifest},
+	}), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, imgspecv1.ImageLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+	return dir
+}
+
+func makeBootcLayout(t *testing.T, architecture string) string {
+	base := makeLayer(t, []tarEntry{
+		{name: "etc/", dir: true},
+		{name: "etc/bootc/", dir: true},
+		{name: "etc/bootc/install/", dir: true},
+		{name: "etc/bootc/install/20-aarch64.toml", body: "[install]\nmatch-architectures = [\"aarch64\"]\nroot-fs-type = \"btrfs\"\n"},
+		{name: "etc/os-release", linkname: "../usr/lib/os-release"},
+		{name: "usr/", dir: true},
+		{name: "usr/lib/", dir: true},
+		{name: "usr/lib/os-release", body: "NAME=\"Fedora Linux\"\nID=fedora\nVERSION_ID=42\n"},
+		{name: "usr/lib/removed.txt", body: "removed by the next layer"},
+		{name: "usr/lib/bootc/", dir: true},
+		{name: "usr/lib/bootc/install/", dir: true},
+		{name: "usr/lib/bootc/install/00-base.toml", body: "[install.filesystem.root]\ntype = \"xfs\"\n"},
+		{name: "usr/lib/bootc/install/README", body: "not a config"},
+		{name: "usr/lib/modules/", dir: true},
+		{name: "usr/lib/modules/6.14.0-1.fc42.x86_64/", dir: true},
+		{name: "usr/lib/modules/6.14.0-1.fc42.x86_64/vmlinuz", body: "kernel"},
+		{name: "usr/lib/modules/6.14.0-1.fc42.x86_64/initramfs.img", body: makeInitrd(t)},
+	})
+	update := makeLayer(t, []tarEntry{
+		{name: "usr/lib/.wh.removed.txt"},
+		{name: "usr/lib/bootc/install/10-bootloader.toml", body: "[install]\nbootloader = \"grub\"\n"},
+		{name: "usr/share/", dir: true},
+		{name: "usr/share/os-release", linkname: "/etc/os-release"},
+		{name: "usr/share/containers/", dir: true},
+		{name: "usr/share/containers/systemd/", dir: true},
+		{name: "usr/share/containers/systemd/app.container", body: "[Unit]\nDescription=App\n\n[Container]\nImage=quay.io/example/app:latest\n"},
+		{name: "usr/share/containers/systemd/db.image", body: "[Image]\nImage=quay.io/example/db:16\n"},
+		{name: "usr/lib/bootc/bound-images.d/", dir: true},
+		{name: "usr/lib/bootc/bound-images.d/app.container", linkname: "/usr/share/containers/systemd/app.container"},
+		{name: "usr/lib/bootc/bound-images.d/db.image", linkname: "../../../share/containers/systemd/db.image"},
+	})
+	return makeOCILayout(t, architecture, base, update)
+}
+
+func TestExtractedContainer(t *testing.T) {
+	dir := makeBootcLayout(t, "amd64")
+
+	c, err := bootc.NewExtractedContainer(t.Context(), "oci:"+dir, bootc.ExtractOptions{TempDir: t.TempDir()})
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	assert.Equal(t, "amd64", c.Arch())
+
+	// absolute symlinks are resolved inside the container
+	osRelease, err := c.ReadFile("/usr/share/os-release")
+	require.NoError(t, err)
+	assert.Equal(t, "NAME=\"Fedora Linux\"\nID=fedora\nVERSION_ID=42\n", string(osRelease))
+	_, err = c.ReadFile("/usr/lib/removed.txt")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	config, err := c.InstallConfiguration()
+	require.NoError(t, err)
+	assert.Equal(t, "xfs", config.Filesystem.Root.Type)
+	require.NotNil(t, config.Bootloader)
+	assert.Equal(t, "grub", *config.Bootloader)
+
+	modules, err := c.InitrdModules("6.14.0-1.fc42.x86_64")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "systemd", "ostree"}, modules)
+
+	unified, err := c.UnifiedKernel()
+	require.NoError(t, err)
+	assert.False(t, unified)
+
+	info, err := c.ResolveInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "oci:"+dir, info.Imgref)
+	assert.Equal(t, "amd64", info.Arch)
+	assert.Equal(t, "xfs", info.DefaultRootFs)
+	assert.NotZero(t, info.Size)
+	assert.Equal(t, "fedora", info.OSInfo.OSRelease.ID)
+	require.NotNil(t, info.OSInfo.KernelInfo)
+	assert.Equal(t, "6.14.0-1.fc42.x86_64", info.OSInfo.KernelInfo.Version)
+	assert.Equal(t, []string{"bash", "systemd", "ostree"}, info.OSInfo.InitrdModules)
+	assert.Equal(t, []string{"quay.io/example/app:latest", "quay.io/example/db:16"}, info.BoundImages)
+
+	root := c.Root()
+	require.NoError(t, c.Close())
+	assert.NoDirExists(t, root)
+}
+
+func TestExtractedContainerMatchArchitectures(t *testing.T) {
+	dir := makeBootcLayout(t, "arm64")
+
+	info, err := bootc.ResolveExtractedBootcInfo(t.Context(), "oci:"+dir, bootc.ExtractOptions{TempDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, "arm64", info.Arch)
+	assert.Equal(t, "btrfs", info.DefaultRootFs)
+}
+
+func TestResolveBootcInfoExtractsArchives(t *testing.T) {
+	dir := makeBootcLayout(t, "amd64")
+
+	// archive references are resolved without podman
+	info, err := bootc.ResolveBootcInfo("oci:" + dir)
+	require.NoError(t, err)
+	assert.Equal(t, "oci:"+dir, info.Imgref)
+	assert.Equal(t, "amd64", info.Arch)
+	assert.Equal(t, "xfs", info.DefaultRootFs)
+	assert.Equal(t, "fedora", info.OSInfo.OSRelease.ID)
+
+	buildInfo, err := bootc.ResolveBootcBuildInfo("oci:" + dir)
+	require.NoError(t, err)
+	assert.Equal(t, &bootc.Info{
+		Imgref:  "oci:" + dir,
+		ImageID: info.ImageID,
+		Arch:    "amd64",
+	}, buildInfo)
+}
+
+func TestExtractedContainerUnifiedKernel(t *testing.T) {
+	dir := makeOCILayout(t, "amd64", makeLayer(t, []tarEntry{
+		{name: "boot/", dir: true},
+		{name: "boot/EFI/", dir: true},
+		{name: "boot/EFI/Linux/", dir: true},
+		{name: "boot/EFI/Linux/fedora.efi", body: "uki"},
+	}))
+
+	c, err := bootc.NewExtractedContainer(t.Context(), "oci:"+dir, bootc.ExtractOptions{TempDir: t.TempDir()})
+	require.NoError(t, err)
+	defer c.Close()
+
+	unified, err := c.UnifiedKernel()
+	require.NoError(t, err)
+	assert.True(t, unified)
+}
+
+func TestExtractedContainerUnsupportedReference(t *testing.T) {
+	tmpdir := t.TempDir()
+	_, err := bootc.NewExtractedContainer(t.Context(), "quay.io/centos-bootc/centos-bootc:stream9", bootc.ExtractOptions{TempDir: tmpdir})
+	assert.EqualError(t, err, "unsupported container reference 'quay.io/centos-bootc/centos-bootc:stream9', must be an oci, oci-archive or docker-archive reference")
+
+	// the temporary directory is cleaned up on errors
+	entries, err := os.ReadDir(tmpdir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,188 @@
+package bootc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// dracutModulesPath is the list of dracut modules of an initrd, as
+// printed by "lsinitrd --mod"
+const dracutModulesPath = "usr/lib/dracut/modules.txt"
+
+var (
+	cpioNewcMagic = []byte("070701")
+	cpioCRCMagic  = []byte("070702")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic       = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+var errInitrdFileNotFound = errors.New("file not found in initrd")
+
+// countingReader counts the bytes that are read, which is needed for the
+// alignment of the cpio entries
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) skip(n int64) error {
+	skipped, err := cr.r.Discard(int(n))
+	cr.n += int64(skipped)
+	return err
+}
+
+func (cr *countingReader) align(to int64) error {
+	if rem := cr.n % to; rem != 0 {
+		return cr.skip(to - rem)
+	}
+	return nil
+}
+
+// readCPIOFile reads the file with the given name from the "newc" cpio
+// archive of r. It returns errInitrdFileNotFound if the archive ends
+// without the file. The reader is positioned after the trailer of the
+// archive in that case.
+func readCPIOFile(cr *countingReader, name string) ([]byte, error) {
+	header := make([]byte, 110)
+	for {
+		if _, err := io.ReadFull(cr, header); err != nil {
+			return nil, fmt.Errorf("cannot read cpio header: %w", err)
+		}
+		if !bytes.HasPrefix(header, cpioNewcMagic) && !bytes.HasPrefix(header, cpioCRCMagic) {
+			return nil, fmt.Errorf("unsupported cpio format %q", header[:6])
+		}
+		field := func(i int) (int64, error) {
+			return strconv.ParseInt(string(header[6+i*8:6+(i+1)*8]), 16, 64)
+		}
+		fileSize, err := field(6)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %w", err)
+		}
+		nameSize, err := field(11)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header: %w", err)
+		}
+
+		nameBuf := make([]byte, nameSize)
+		if _, err := io.ReadFull(cr, nameBuf); err != nil {
+			return nil, fmt.Errorf("cannot read cpio entry name: %w", err)
+		}
+		entryName := strings.TrimPrefix(strings.TrimRight(string(nameBuf), "\x00"), "./")
+		if err := cr.align(4); err != nil {
+			return nil, err
+		}
+
+		if entryName == "TRAILER!!!" {
+			return nil, errInitrdFileNotFound
+		}
+		if entryName == name {
+			data := make([]byte, fileSize)
+			if _, err := io.ReadFull(cr, data); err != nil {
+				return nil, fmt.Errorf("cannot read %s from cpio: %w", name, err)
+			}
+			return data, nil
+		}
+		if err := cr.skip(fileSize); err != nil {
+			return nil, err
+		}
+		if err := cr.align(4); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decompressInitrd returns a reader for the decompressed contents of the
+// compressed archive at the start of r
+func decompressInitrd(r *bufio.Reader) (io.Reader, error) {
+	magic, err := r.Peek(len(xzMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(r)
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		return xz.NewReader(r)
+	}
+	return nil, fmt.Errorf("unsupported initrd compression (magic %x)", magic)
+}
+
+// readInitrdFile reads the file with the given name from the initrd at
+// initrdPath without running any tools. The initrd can start with any
+// number of uncompressed cpio archives (e.g. early microcode) followed
+// by a gzip, zstd or xz compressed cpio archive, like the ones that are
+// generated by dracut.
+func readInitrdFile(initrdPath, name string) ([]byte, error) {
+	f, err := os.Open(initrdPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := &countingReader{r: bufio.NewReader(f)}
+	for {
+		magic, err := cr.r.Peek(len(cpioNewcMagic))
+		if errors.Is(err, io.EOF) {
+			return nil, errInitrdFileNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// uncompressed archives are padded with zeros
+		if magic[0] == 0 {
+			if err := cr.skip(1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if bytes.Equal(magic, cpioNewcMagic) || bytes.Equal(magic, cpioCRCMagic) {
+			data, err := readCPIOFile(cr, name)
+			if errors.Is(err, errInitrdFileNotFound) {
+				continue
+			}
+			return data, err
+		}
+
+		// the compressed archive is the last one
+		decompressed, err := decompressInitrd(cr.r)
+		if err != nil {
+			return nil, err
+		}
+		return readCPIOFile(&countingReader{r: bufio.NewReader(decompressed)}, name)
+	}
+}
+
+// readInitrdModules returns the dracut modules of the initrd at
+// initrdPath, like "lsinitrd --mod"
+func readInitrdModules(initrdPath string) ([]string, error) {
+	data, err := readInitrdFile(initrdPath, dracutModulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s from %s: %w", dracutModulesPath, initrdPath, err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
@@ -0,0 +1,256 @@
+package bootc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/datasizes"
+)
+
+// Block setups of bootc, i.e. how the root filesystem is put on the disk
+const (
+	BlockSetupDirect   = "direct"
+	BlockSetupTPM2LUKS = "tpm2-luks"
+)
+
+type BootcInstallConfiguration struct {
+	Filesystem struct {
+		Root struct {
+			Type string `json:"type"`
+		} `json:"root"`
+	} `json:"filesystem"`
+
+	// The supported block setups, the first one is the default
+	Block []string `json:"block,omitempty"`
+
+	// Kernel arguments, bootc adds them itself when it installs the
+	// container
+	Kargs []string `json:"kargs,omitempty"`
+
+	// The name of the ostree stateroot, bootc uses it itself when it
+	// installs the container
+	Stateroot string `json:"stateroot,omitempty"`
+
+	// The minimum size of the root filesystem in bytes, like the
+	// --root-size option of "bootc install to-disk"
+	RootSize uint64 `json:"root-size,omitempty"`
+
+	Bootloader *string `json:"bootloader"`
+}
+
+// BlockSetup returns the default block setup of the configuration
+func (c BootcInstallConfiguration) BlockSetup() string {
+	if len(c.Block) == 0 {
+		return BlockSetupDirect
+	}
+	return c.Block[0]
+}
+
+// installConfigDirs are the directories of the bootc install
+// configuration files, from the lowest to the highest priority. A file
+// in a directory masks the files with the same name in the directories
+// before it.
+var installConfigDirs = []string{
+	"/usr/lib/bootc/install",
+	"/usr/local/lib/bootc/install",
+	"/etc/bootc/install",
+	"/run/bootc/install",
+}
+
+type installConfigFile struct {
+	Install *struct {
+		RootFsType string `toml:"root-fs-type"`
+		Filesystem struct {
+			Root struct {
+				Type string `toml:"type"`
+			} `toml:"root"`
+		} `toml:"filesystem"`
+		Block              []string `toml:"block"`
+		Kargs              []string `toml:"kargs"`
+		Stateroot          string   `toml:"stateroot"`
+		RootSize           string   `toml:"root-size"`
+		Bootloader         *string  `toml:"bootloader"`
+		MatchArchitectures []string `toml:"match-architectures"`
+	} `toml:"install"`
+}
+
+// bootcArch returns the name of the architecture a the way bootc uses it
+// for match-architectures
+func bootcArch(a string) string {
+	archi, err := arch.FromString(a)
+	if err != nil {
+		return a
+	}
+	if archi == arch.ARCH_PPC64LE {
+		return "powerpc64"
+	}
+	return archi.String()
+}
+
+// parseRootSize parses a size like the --root-size option of
+// "bootc install to-disk": a number with an optional M, G or T suffix,
+// mebibytes if there is none
+func parseRootSize(size string) (uint64, error) {
+	unit := uint64(datasizes.MiB)
+	number := size
+	switch {
+	case strings.HasSuffix(size, "M"):
+		number = strings.TrimSuffix(size, "M")
+	case strings.HasSuffix(size, "G"):
+		unit = datasizes.GiB
+		number = strings.TrimSuffix(size, "G")
+	case strings.HasSuffix(size, "T"):
+		unit = datasizes.TiB
+		number = strings.TrimSuffix(size, "T")
+	}
+	n, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid root-size %q, must be a number with an optional M, G or T suffix", size)
+	}
+	return n * unit, nil
+}
+
+// installConfigFiles returns the paths of the install configuration
+// files under root, in the order in which they are merged: sorted by
+// name, with the files of the directories with a higher priority
+// masking the ones with the same name in the others
+func installConfigFiles(root string) ([]string, error) {
+	files := map[string]string{}
+	for _, dir := range installConfigDirs {
+		hostDir, err := resolveInRoot(root, dir)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(hostDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bootc install configuration: %w", err)
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".toml") {
+				files[entry.Name()] = path.Join(dir, entry.Name())
+			}
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, files[name])
+	}
+	return paths, nil
+}
+
+// readInstallConfiguration merges the bootc install configuration from
+// the files of the container tree at root the way bootc does: the files
+// whose match-architectures don't include the architecture a are
+// skipped, kernel arguments are appended and everything else is
+// replaced by the later files.
+func readInstallConfiguration(root, a string) (BootcInstallConfiguration, error) {
+	var bootcInstallConfig BootcInstallConfiguration
+
+	files, err := installConfigFiles(root)
+	if err != nil {
+		return BootcInstallConfiguration{}, err
+	}
+	for _, file := range files {
+		hostPath, err := resolveInRoot(root, file)
+		if err != nil {
+			return BootcInstallConfiguration{}, err
+		}
+		data, err := os.ReadFile(hostPath)
+		if err != nil {
+			return BootcInstallConfiguration{}, fmt.Errorf("failed to read bootc install configuration: %w", err)
+		}
+		var config installConfigFile
+		if err := toml.Unmarshal(data, &config); err != nil {
+			return BootcInstallConfiguration{}, fmt.Errorf("failed to unmarshal bootc install configuration %s: %w", file, err)
+		}
+		install := config.Install
+		if install == nil {
+			continue
+		}
+		if len(install.MatchArchitectures) > 0 && !slices.Contains(install.MatchArchitectures, bootcArch(a)) {
+			continue
+		}
+
+		// filesystem.root.type is the preferred way instead of the old root-fs-type top-level key.
+		// See https://github.com/containers/bootc/commit/558cd4b1d242467e0ffec77fb02b35166469dcc7
+		if install.RootFsType != "" {
+			bootcInstallConfig.Filesystem.Root.Type = install.RootFsType
+		}
+		if install.Filesystem.Root.Type != "" {
+			bootcInstallConfig.Filesystem.Root.Type = install.Filesystem.Root.Type
+		}
+		if install.Block != nil {
+			bootcInstallConfig.Block = install.Block
+		}
+		bootcInstallConfig.Kargs = append(bootcInstallConfig.Kargs, install.Kargs...)
+		if install.Stateroot != "" {
+			bootcInstallConfig.Stateroot = install.Stateroot
+		}
+		if install.RootSize != "" {
+			bootcInstallConfig.RootSize, err = parseRootSize(install.RootSize)
+			if err != nil {
+				return BootcInstallConfiguration{}, fmt.Errorf("bootc install configuration %s: %w", file, err)
+			}
+		}
+		if install.Bootloader != nil {
+			bootcInstallConfig.Bootloader = install.Bootloader
+		}
+	}
+
+	if err := validateInstallConfiguration(bootcInstallConfig); err != nil {
+		return BootcInstallConfiguration{}, err
+	}
+
+	return bootcInstallConfig, nil
+}
+
+// validateInstallConfiguration checks that the images library can handle
+// the install configuration
+func validateInstallConfiguration(bootcInstallConfig BootcInstallConfiguration) error {
+	supportedBlock := []string{BlockSetupDirect, BlockSetupTPM2LUKS}
+	for _, block := range bootcInstallConfig.Block {
+		if !slices.Contains(supportedBlock, block) {
+			return fmt.Errorf("unsupported block setup: %s, supported: %s", block, strings.Join(supportedBlock, ", "))
+		}
+	}
+
+	fsType := bootcInstallConfig.Filesystem.Root.Type
+
+	// Return early to skip validating the empty default root filesystem type
+	if fsType == "" {
+		return nil
+	}
+
+	// Note that these are the only filesystems that the "images" library
+	// knows how to handle, i.e. how to construct the required osbuild
+	// stages for.
+	// TODO: move this into a helper in "images" so that there is only
+	// a single place that needs updating when we add e.g. btrfs or
+	// bcachefs
+	supportedFS := []string{"ext4", "xfs", "btrfs"}
+	if !slices.Contains(supportedFS, fsType) {
+		return fmt.Errorf("unsupported root filesystem type: %s, supported: %s", fsType, strings.Join(supportedFS, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package bootc_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/bootc"
+	"github.com/osbuild/images/pkg/datasizes"
+)
+
+func makeInstallConfigTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return root
+}
+
+func TestInstallConfigurationMerge(t *testing.T) {
+	root := makeInstallConfigTree(t, map[string]string{
+		"usr/lib/bootc/install/00-base.toml": `[install]
+root-fs-type = "ext4"
+kargs = ["console=tty0"]
+block = ["direct"]
+stateroot = "base"
+`,
+		"usr/lib/bootc/install/10-fs.toml": `[install.filesystem.root]
+type = "xfs"
+`,
+		// masked by the file with the same name in /etc
+		"usr/lib/bootc/install/20-size.toml": `[install]
+root-size = "5G"
+`,
+		"etc/bootc/install/20-size.toml": `[install]
+root-size = "10G"
+kargs = ["nosmt"]
+`,
+		"usr/lib/bootc/install/30-luks.toml": `[install]
+block = ["tpm2-luks", "direct"]
+`,
+		"usr/lib/bootc/install/40-aarch64.toml": `[install]
+match-architectures = ["aarch64"]
+root-fs-type = "btrfs"
+stateroot = "arm"
+`,
+		"usr/lib/bootc/install/50-x86_64.toml": `[install]
+match-architectures = ["x86_64", "aarch64"]
+stateroot = "fedora"
+`,
+		"usr/lib/bootc/install/README": "not a config",
+	})
+
+	config, err := bootc.NewTestContainer(root, "x86_64").InstallConfiguration()
+	require.NoError(t, err)
+	assert.Equal(t, "xfs", config.Filesystem.Root.Type)
+	assert.Equal(t, []string{"console=tty0", "nosmt"}, config.Kargs)
+	assert.Equal(t, []string{"tpm2-luks", "direct"}, config.Block)
+	assert.Equal(t, bootc.BlockSetupTPM2LUKS, config.BlockSetup())
+	assert.Equal(t, "fedora", config.Stateroot)
+	assert.Equal(t, uint64(10*datasizes.GiB), config.RootSize)
+
+	config, err = bootc.NewTestContainer(root, "aarch64").InstallConfiguration()
+	require.NoError(t, err)
+	assert.Equal(t, "btrfs", config.Filesystem.Root.Type)
+	assert.Equal(t, "fedora", config.Stateroot)
+}
+
+func TestInstallConfigurationDefaults(t *testing.T) {
+	config, err := bootc.NewTestContainer(t.TempDir(), "x86_64").InstallConfiguration()
+	require.NoError(t, err)
+	assert.Equal(t, "", config.Filesystem.Root.Type)
+	assert.Equal(t, bootc.BlockSetupDirect, config.BlockSetup())
+	assert.Equal(t, uint64(0), config.RootSize)
+	assert.Nil(t, config.Kargs)
+}
+
+func TestInstallConfigurationRootSize(t *testing.T) {
+	for _, tc := range []struct {
+		size     string
+		expected uint64
+		err      string
+	}{
+		{"512", 512 * datasizes.MiB, ""},
+		{"512M", 512 * datasizes.MiB, ""},
+		{"20G", 20 * datasizes.GiB, ""},
+		{"1T", datasizes.TiB, ""},
+		{"20 GiB", 0, `invalid root-size "20 GiB", must be a number with an optional M, G or T suffix`},
+	} {
+		t.Run(tc.size, func(t *testing.T) {
+			root := makeInstallConfigTree(t, map[string]string{
+				"usr/lib/bootc/install/00-size.toml": "[install]\nroot-size = \"" + tc.size + "\"\n",
+			})
+			config, err := bootc.NewTestContainer(root, "x86_64").InstallConfiguration()
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, config.RootSize)
+		})
+	}
+}
+
+func TestInstallConfigurationUnsupportedBlock(t *testing.T) {
+	root := makeInstallConfigTree(t, map[string]string{
+		"usr/lib/bootc/install/00-block.toml": "[install]\nblock = [\"zfs\"]\n",
+	})
+	_, err := bootc.NewTestContainer(root, "x86_64").InstallConfiguration()
+	assert.EqualError(t, err, "unsupported block setup: zfs, supported: direct, tpm2-luks")
+}
@@ -3,6 +3,7 @@ package bootc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,10 +14,9 @@ import (
 	"strconv"
 	"strings"
 
-	"slices"
-
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/bib/osinfo"
+	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/depsolvednf"
 )
 
@@ -65,6 +65,12 @@ type Container struct {
 // - --net host is used to make networking work in a nested container
 // - /run/secrets is mounted from the host to make sure RHSM credentials are available
 func NewContainer(ref string) (*Container, error) {
+	return newContainer(ref, nil)
+}
+
+// newContainer creates a new running container like NewContainer() with the
+// given additional volumes ("src:dst") mounted.
+func newContainer(ref string, volumes []string) (*Container, error) {
 	extraOpts := []string{}
 	if isRootless, _ := isPodmanRootless(); isRootless {
 		// When running bc-i-b In a rootless container, its typically the case that /var/lib/containers/storage
@@ -95,6 +101,9 @@ func NewContainer(ref string) (*Container, error) {
 	if _, err := os.Stat(secretDir); err == nil {
 		args = append(args, "--volume", secretVolume)
 	}
+	for _, volume := range volumes {
+		args = append(args, "--volume", volume)
+	}
 
 	args = append(args, extraOpts...)
 
@@ -172,22 +181,26 @@ func (c *Container) Stop() error {
 	return nil
 }
 
-// ResolveInfo loads all information from the running container.
-func (c *Container) ResolveInfo() (*Info, error) {
-	bootcInfo := &Info{
-		Imgref:  c.ref,
-		ImageID: c.id,
-		Arch:    c.Arch(),
-	}
+// inspector reads the information of a bootc container, see Container
+// and ExtractedContainer
+type inspector interface {
+	Root() string
+	ReadFile(path string) ([]byte, error)
+	InstallConfiguration() (BootcInstallConfiguration, error)
+	InitrdModules(kver string) ([]string, error)
+	UnifiedKernel() (bool, error)
+}
 
+// resolveInfo loads the information of the container c into bootcInfo
+func resolveInfo(c inspector, bootcInfo *Info) error {
 	os, err := osinfo.Load(c.Root())
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if os.KernelInfo != nil {
 		modules, err := c.InitrdModules(os.KernelInfo.Version)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		os.InitrdModules = modules
 	}
@@ -195,17 +208,40 @@ func (c *Container) ResolveInfo() (*Info, error) {
 
 	bootcInstallConfig, err := c.InstallConfiguration()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	bootcInfo.DefaultRootFs = bootcInstallConfig.Filesystem.Root.Type
+	bootcInfo.RootSize = bootcInstallConfig.RootSize
+	bootcInfo.BlockSetup = bootcInstallConfig.BlockSetup()
 	bootcInfo.Bootloader = bootcInstallConfig.Bootloader
 
 	unifiedKernel, err := c.UnifiedKernel()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	bootcInfo.UnifiedKernel = unifiedKernel
 
+	images, err := boundImages(c)
+	if err != nil {
+		return err
+	}
+	bootcInfo.BoundImages = images
+
+	return nil
+}
+
+// ResolveInfo loads all information from the running container.
+func (c *Container) ResolveInfo() (*Info, error) {
+	bootcInfo := &Info{
+		Imgref:  c.ref,
+		ImageID: c.id,
+		Arch:    c.Arch(),
+	}
+
+	if err := resolveInfo(c, bootcInfo); err != nil {
+		return nil, err
+	}
+
 	size, err := getContainerSize(c.ref, c.extraOpts)
 	if err != nil {
 		return nil, err
@@ -275,56 +311,10 @@ func (c *Container) ExecArgv() []string {
 	return args
 }
 
-type BootcInstallConfiguration struct {
-	Filesystem struct {
-		Root struct {
-			Type string `json:"type"`
-		} `json:"root"`
-	} `json:"filesystem"`
-
-	Bootloader *string `json:"bootloader"`
-}
-
 // InstallConfiguration returns the install configuration for bootc container
-// as given by `bootc install print-configuration`
+// merged from the configuration files of the container like bootc does it
 func (c *Container) InstallConfiguration() (BootcInstallConfiguration, error) {
-	args := []string{"exec"}
-	args = append(args, c.extraOpts...)
-	args = append(args, c.id, "bootc", "install", "print-configuration")
-
-	/* #nosec G204 */
-	output, err := exec.Command("podman", args...).Output()
-	if err != nil {
-		return BootcInstallConfiguration{}, fmt.Errorf("failed to run bootc install print-configuration: %w, output:\n%s", err, output)
-	}
-
-	var bootcInstallConfig BootcInstallConfiguration
-
-	if err := json.Unmarshal(output, &bootcInstallConfig); err != nil {
-		return BootcInstallConfiguration{}, fmt.Errorf("failed to unmarshal bootc install configuration: %w", err)
-	}
-
-	// filesystem.root.type is the preferred way instead of the old root-fs-type top-level key.
-	// See https://github.com/containers/bootc/commit/558cd4b1d242467e0ffec77fb02b35166469dcc7
-	fsType := bootcInstallConfig.Filesystem.Root.Type
-
-	// Return early to skip validating the empty default root filesystem type
-	if fsType == "" {
-		return bootcInstallConfig, nil
-	}
-
-	// Note that these are the only filesystems that the "images" library
-	// knows how to handle, i.e. how to construct the required osbuild
-	// stages for.
-	// TODO: move this into a helper in "images" so that there is only
-	// a single place that needs updating when we add e.g. btrfs or
-	// bcachefs
-	supportedFS := []string{"ext4", "xfs", "btrfs"}
-	if !slices.Contains(supportedFS, fsType) {
-		return BootcInstallConfiguration{}, fmt.Errorf("unsupported root filesystem type: %s, supported: %s", fsType, strings.Join(supportedFS, ", "))
-	}
-
-	return bootcInstallConfig, nil
+	return readInstallConfiguration(c.root, c.arch)
 }
 
 // InitrdModules gets the list of modules from the container's initrd
@@ -576,8 +566,14 @@ func runContainer(ref string, fn func(c *Container) error) (err error) {
 }
 
 // ResolveBootcInfo resolves the bootc container reference and returns the relevant info structure
-// for a container
+// for a container. Images in "oci:", "oci-archive:" and "docker-archive:"
+// references are extracted instead of run with podman (see
+// ExtractedContainer).
 func ResolveBootcInfo(ref string) (*Info, error) {
+	if _, _, ok := container.ParseArchiveSource(ref); ok {
+		return ResolveExtractedBootcInfo(context.Background(), ref, ExtractOptions{})
+	}
+
 	var info *Info
 	err := runContainer(ref, func(c *Container) error {
 		var err error
@@ -588,8 +584,13 @@ func ResolveBootcInfo(ref string) (*Info, error) {
 }
 
 // ResolveBootcBuildInfo resolves the bootc container reference and returns the minimal info structure
-// for a build container
+// for a build container. Like ResolveBootcInfo, archive references are
+// read without podman.
 func ResolveBootcBuildInfo(ref string) (*Info, error) {
+	if _, _, ok := container.ParseArchiveSource(ref); ok {
+		return ResolveExtractedBootcBuildInfo(context.Background(), ref, ExtractOptions{})
+	}
+
 	var info *Info
 	err := runContainer(ref, func(c *Container) error {
 		var err error
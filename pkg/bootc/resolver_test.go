@@ -196,16 +196,19 @@ exec /usr/bin/podman "$@"
 	assert.ErrorContains(t, err, "stderr:\nforced-crash")
 }
 
+func writeInstallConfig(t *testing.T, root, name, content string) {
+	dir := filepath.Join(root, "usr/lib/bootc/install")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
 func TestRootfsTypeHappy(t *testing.T) {
 	for _, tc := range []string{"", "ext4", "xfs"} {
-		jsonStr := "{}"
+		root := t.TempDir()
 		if tc != "" {
-			jsonStr = fmt.Sprintf(`{"filesystem": {"root": {"type": "%s"}}}`, tc)
+			writeInstallConfig(t, root, "00-root.toml", fmt.Sprintf("[install.filesystem.root]\ntype = %q\n", tc))
 		}
-		makeFakePodman(t, fmt.Sprintf(`#!/bin/sh
-echo '%s'
-`, jsonStr))
-		cnt := bootc.Container{}
+		cnt := bootc.NewTestContainer(root, "x86_64")
 		installConfig, err := cnt.InstallConfiguration()
 		assert.NoError(t, err)
 		assert.Equal(t, tc, installConfig.Filesystem.Root.Type)
@@ -214,11 +217,9 @@ echo '%s'
 
 func TestRootfsTypeSad(t *testing.T) {
 	for _, tc := range []string{"ext1"} {
-		jsonStr := fmt.Sprintf(`{"filesystem": {"root": {"type": "%s"}}}`, tc)
-		makeFakePodman(t, fmt.Sprintf(`#!/bin/sh
-echo '%s'
-`, jsonStr))
-		cnt := bootc.Container{}
+		root := t.TempDir()
+		writeInstallConfig(t, root, "00-root.toml", fmt.Sprintf("[install.filesystem.root]\ntype = %q\n", tc))
+		cnt := bootc.NewTestContainer(root, "x86_64")
 		_, err := cnt.InstallConfiguration()
 		assert.ErrorContains(t, err, "unsupported root filesystem type: ext1, supported: ")
 	}
@@ -271,14 +272,11 @@ func TestBootloaderHappy(t *testing.T) {
 		{"none", common.ToPtr("none")},
 		{"grub", common.ToPtr("grub")},
 	} {
-		jsonStr := "{}"
+		root := t.TempDir()
 		if tc.In != "" {
-			jsonStr = fmt.Sprintf(`{"bootloader": "%s"}`, tc.In)
+			writeInstallConfig(t, root, "00-bootloader.toml", fmt.Sprintf("[install]\nbootloader = %q\n", tc.In))
 		}
-		makeFakePodman(t, fmt.Sprintf(`#!/bin/sh
-echo '%s'
-`, jsonStr))
-		cnt := bootc.Container{}
+		cnt := bootc.NewTestContainer(root, "x86_64")
 		installConfig, err := cnt.InstallConfiguration()
 		assert.NoError(t, err)
 		assert.Equal(t, tc.Out, installConfig.Bootloader)
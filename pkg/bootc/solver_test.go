@@ -71,6 +71,81 @@ func TestDepsolveDNFWorks(t *testing.T) {
 	assert.True(t, len(res.Transactions.AllPackages()) > 0)
 }
 
+func TestBootstrapSolverDepsolvesInContainer(t *testing.T) {
+	if !hasPodman() {
+		t.Skip("skipping test: no podman")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("skipping test; not running as root")
+	}
+
+	cacheRoot := t.TempDir()
+	solver, cleanup, err := bootc.NewBootstrapSolver(dnfTestingImageFedoraLatest, cacheRoot, "platform:f42", "42", arch.Current().String(), "fedora-42", nil)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, cleanup())
+	}()
+
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include: []string{"coreutils"},
+			Repositories: []rpmmd.RepoConfig{
+				{
+					Id:       "fedora",
+					Metalink: "https://mirrors.fedoraproject.org/metalink?repo=fedora-42&arch=" + arch.Current().String(),
+				},
+			},
+		},
+	}, 0)
+	require.NoError(t, err)
+	assert.True(t, len(res.Transactions.AllPackages()) > 0)
+	// the metadata is cached on the host
+	entries, err := os.ReadDir(solver.GetCacheDir())
+	require.NoError(t, err)
+	assert.NotEmpty(t, entries)
+}
+
+func TestRepoSecretVolumes(t *testing.T) {
+	rhsmDir := t.TempDir()
+	restore := bootc.MockRHSMSecretDirs([]string{rhsmDir, "/does/not/exist"})
+	defer restore()
+
+	volumes, err := bootc.RepoSecretVolumes([]rpmmd.RepoConfig{
+		{
+			Id:            "custom",
+			SSLCACert:     "/etc/pki/custom/ca.pem",
+			SSLClientKey:  "/etc/pki/custom/key.pem",
+			SSLClientCert: "/etc/pki/custom/cert.pem",
+		},
+		{
+			Id:        "custom-ca",
+			SSLCACert: "/etc/pki/custom/ca.pem",
+		},
+		{
+			Id:   "rhsm",
+			RHSM: true,
+		},
+		{
+			Id: "plain",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/etc/pki/custom/ca.pem:/etc/pki/custom/ca.pem:ro",
+		"/etc/pki/custom/cert.pem:/etc/pki/custom/cert.pem:ro",
+		"/etc/pki/custom/key.pem:/etc/pki/custom/key.pem:ro",
+		rhsmDir + ":" + rhsmDir + ":ro",
+	}, volumes)
+
+	_, err = bootc.RepoSecretVolumes([]rpmmd.RepoConfig{
+		{
+			Id:        "relative",
+			SSLCACert: "ca.pem",
+		},
+	})
+	assert.EqualError(t, err, `repository "relative": TLS certificate or key path "ca.pem" must be absolute`)
+}
+
 func subscribeMachine(t *testing.T) (restore func()) {
 	if _, err := exec.LookPath("subscription-manager"); err != nil {
 		t.Skip("no subscription-manager found")
@@ -0,0 +1,147 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/osbuild/images/pkg/arch"
+)
+
+// Transports for containers that are read from the host filesystem
+// instead of a registry or the local containers-storage.
+const (
+	OCIArchiveTransport    = "oci-archive"
+	OCILayoutTransport     = "oci"
+	DockerArchiveTransport = "docker-archive"
+)
+
+// ParseArchiveSource splits a container source of the form
+// "<transport>:<path>" into its transport and path. The path of an
+// "oci" layout directory can carry a ":<ref>" suffix to select an
+// image of the layout. ok is false if source does not use one of the
+// archive transports, e.g. for registry references.
+func ParseArchiveSource(source string) (transport, path string, ok bool) {
+	transport, path, found := strings.Cut(source, ":")
+	if !found {
+		return "", "", false
+	}
+	switch transport {
+	case OCIArchiveTransport, OCILayoutTransport, DockerArchiveTransport:
+		return transport, path, true
+	}
+	return "", "", false
+}
+
+func parseArchiveReference(transport, path string) (types.ImageReference, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path of %s container %q must be absolute", transport, path)
+	}
+	tr := transports.Get(transport)
+	if tr == nil {
+		return nil, fmt.Errorf("unknown transport '%s'", transport)
+	}
+	ref, err := tr.ParseReference(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s:%s': %w", transport, path, err)
+	}
+	return ref, nil
+}
+
+// getArchiveManifest reads the manifest of the archive or layout
+// directory of the Client. If instanceDigest is not empty it reads the
+// manifest of that instance of the index in the archive.
+func (cl *Client) getArchiveManifest(ctx context.Context, instanceDigest digest.Digest) (RawManifest, error) {
+	src, err := cl.archive.NewImageSource(ctx, cl.sysCtx)
+	if err != nil {
+		return RawManifest{}, err
+	}
+	defer src.Close()
+
+	var instance *digest.Digest
+	if instanceDigest != "" {
+		instance = &instanceDigest
+	}
+	data, _, err := src.GetManifest(ctx, instance)
+	if err != nil {
+		return RawManifest{}, err
+	}
+
+	mime, err := parseMediaType(data)
+	if err != nil {
+		return RawManifest{}, err
+	}
+
+	a, err := arch.FromString(cl.sysCtx.ArchitectureChoice)
+	if err != nil {
+		return RawManifest{}, err
+	}
+
+	return RawManifest{
+		Data:     data,
+		MimeType: mime,
+		Arch:     a,
+	}, nil
+}
+
+// archiveName returns the name of the container in the archive, if the
+// archive records one (only docker archives do).
+func (cl *Client) archiveName() string {
+	if named := cl.archive.DockerReference(); named != nil {
+		return named.String()
+	}
+	return ""
+}
+
+// localStorageRef returns the reference of target in the local
+// containers-storage of the Client for skopeo.
+func (cl *Client) localStorageRef(target string) string {
+	return fmt.Sprintf("containers-storage:[overlay@%s+/run/containers/storage]%s", cl.store, target)
+}
+
+// Load copies the container of the archive or layout directory of the
+// Client into the local containers-storage as name, like "podman load".
+// Resolving an archive container does not touch the local storage, the
+// container has to be loaded before the manifest is built so that it can
+// be embedded from there.
+func (cl *Client) Load(name string) error {
+	if cl.archive == nil {
+		return fmt.Errorf("cannot load a container that is not read from an archive or layout directory")
+	}
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return fmt.Errorf("invalid name %q for the %s container %q: %w", name, cl.transport, cl.archivePath, err)
+	}
+	src := fmt.Sprintf("%s:%s", cl.transport, cl.archivePath)
+	if err := skopeoCopy(cl, src, cl.localStorageRef(reference.TagNameOnly(named).String())); err != nil {
+		return fmt.Errorf("cannot load the %s container %q into the local storage: %w", cl.transport, cl.archivePath, err)
+	}
+	return nil
+}
+
+// LoadArchives loads the containers of the specs that are read from an
+// archive or layout directory into the local containers-storage under
+// their LocalName (see Client.Load). The other specs are skipped.
+func LoadArchives(specs []Spec) error {
+	for _, spec := range specs {
+		if spec.Transport == "" {
+			continue
+		}
+		cl, err := NewClient(fmt.Sprintf("%s:%s", spec.Transport, spec.Source))
+		if err != nil {
+			return err
+		}
+		if spec.Arch != arch.ARCH_UNSET {
+			cl.SetArchitectureChoice(spec.Arch.String())
+		}
+		if err := cl.Load(spec.LocalName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
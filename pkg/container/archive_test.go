@@ -0,0 +1,264 @@
+package container_test
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+)
+
+type ociLayout struct {
+	dir string
+}
+
+func (l ociLayout) addBlob(t *testing.T, mediaType string, obj any) imgspecv1.Descriptor {
+	data, err := json.Marshal(obj)
+	require.NoError(t, err)
+	dgst := digest.FromBytes(data)
+	blobDir := filepath.Join(l.dir, "blobs", dgst.Algorithm().String())
+	require.NoError(t, os.MkdirAll(blobDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(blobDir, dgst.Encoded()), data, 0644))
+	return imgspecv1.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+}
+
+func (l ociLayout) addImage(t *testing.T, architecture string) (manifest, config imgspecv1.Descriptor) {
+	config = l.addBlob(t, imgspecv1.MediaTypeImageConfig, imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: architecture, OS: "linux"},
+		RootFS:   imgspecv1.RootFS{Type: "layers"},
+	})
+	manifest = l.addBlob(t, imgspecv1.MediaTypeImageManifest, imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []imgspecv1.Descriptor{},
+	})
+	manifest.Platform = &imgspecv1.Platform{Architecture: architecture, OS: "linux"}
+	return manifest, config
+}
+
+func (l ociLayout) writeIndex(t *testing.T, desc imgspecv1.Descriptor) {
+	desc.Annotations = map[string]string{imgspecv1.AnnotationRefName: "latest"}
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{desc},
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(l.dir, imgspecv1.ImageIndexFile), index, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(l.dir, imgspecv1.ImageLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644))
+}
+
+// tarLayout writes the layout as an oci-archive
+func tarLayout(t *testing.T, dir, archive string) {
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.AddFS(os.DirFS(dir)))
+	require.NoError(t, tw.Close())
+}
+
+func TestParseArchiveSource(t *testing.T) {
+	for _, tc := range []struct {
+		source    string
+		transport string
+		path      string
+		ok        bool
+	}{
+		{"oci-archive:/srv/fedora.tar", container.OCIArchiveTransport, "/srv/fedora.tar", true},
+		{"oci:/srv/layout:latest", container.OCILayoutTransport, "/srv/layout:latest", true},
+		{"docker-archive:/srv/fedora.tar", container.DockerArchiveTransport, "/srv/fedora.tar", true},
+		{"registry.example.org/fedora:latest", "", "", false},
+		{"localhost:5000/fedora", "", "", false},
+		{"fedora", "", "", false},
+	} {
+		t.Run(tc.source, func(t *testing.T) {
+			transport, path, ok := container.ParseArchiveSource(tc.source)
+			assert.Equal(t, tc.transport, transport)
+			assert.Equal(t, tc.path, path)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestClientResolveOCILayout(t *testing.T) {
+	layout := ociLayout{dir: t.TempDir()}
+	amd64Manifest, amd64Config := layout.addImage(t, "amd64")
+	arm64Manifest, arm64Config := layout.addImage(t, "arm64")
+	index := layout.addBlob(t, imgspecv1.MediaTypeImageIndex, imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+		Manifests: []imgspecv1.Descriptor{amd64Manifest, arm64Manifest},
+	})
+	layout.writeIndex(t, index)
+
+	archive := filepath.Join(t.TempDir(), "fedora.tar")
+	tarLayout(t, layout.dir, archive)
+
+	for _, tc := range []struct {
+		source    string
+		transport string
+		path      string
+	}{
+		{"oci:" + layout.dir, container.OCILayoutTransport, layout.dir},
+		{"oci:" + layout.dir + ":latest", container.OCILayoutTransport, layout.dir + ":latest"},
+		{"oci-archive:" + archive, container.OCIArchiveTransport, archive},
+	} {
+		t.Run(tc.source, func(t *testing.T) {
+			var loaded []string
+			restore := container.MockSkopeoCopy(func(cl *container.Client, src, dest string) error {
+				loaded = append(loaded, src+" "+dest)
+				return nil
+			})
+			defer restore()
+
+			client, err := container.NewClientWithTestStorage(tc.source, "/srv/storage")
+			require.NoError(t, err)
+			assert.Nil(t, client.Target)
+
+			client.SetArchitectureChoice("x86_64")
+			spec, err := client.Resolve(t.Context(), "localhost/fedora:latest", false)
+			require.NoError(t, err)
+			assert.Equal(t, container.Spec{
+				Source:       tc.path,
+				Transport:    tc.transport,
+				Digest:       amd64Manifest.Digest.String(),
+				ImageID:      amd64Config.Digest.String(),
+				LocalName:    "localhost/fedora:latest",
+				ListDigest:   index.Digest.String(),
+				LocalStorage: true,
+				Arch:         arch.ARCH_X86_64,
+			}, spec)
+			// resolving does not load the container
+			assert.Empty(t, loaded)
+
+			client.SetArchitectureChoice("aarch64")
+			spec, err = client.Resolve(t.Context(), "localhost/fedora:latest", false)
+			require.NoError(t, err)
+			assert.Equal(t, arm64Manifest.Digest.String(), spec.Digest)
+			assert.Equal(t, arm64Config.Digest.String(), spec.ImageID)
+			assert.Equal(t, arch.ARCH_AARCH64, spec.Arch)
+
+			// don't have that architecture
+			client.SetArchitectureChoice("s390x")
+			_, err = client.Resolve(t.Context(), "localhost/fedora:latest", false)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestClientResolveOCILayoutSingleManifest(t *testing.T) {
+	layout := ociLayout{dir: t.TempDir()}
+	manifest, config := layout.addImage(t, "amd64")
+	layout.writeIndex(t, manifest)
+
+	client, err := container.NewClient("oci:" + layout.dir)
+	require.NoError(t, err)
+	client.SetArchitectureChoice("x86_64")
+
+	spec, err := client.Resolve(t.Context(), "localhost/fedora", false)
+	require.NoError(t, err)
+	assert.Equal(t, container.Spec{
+		Source:       layout.dir,
+		Transport:    container.OCILayoutTransport,
+		Digest:       manifest.Digest.String(),
+		ImageID:      config.Digest.String(),
+		LocalName:    "localhost/fedora",
+		LocalStorage: true,
+		Arch:         arch.ARCH_X86_64,
+	}, spec)
+
+	// oci layouts do not record a name that could be used
+	_, err = client.Resolve(t.Context(), "", false)
+	assert.EqualError(t, err, `a name is required for the oci container "`+layout.dir+`"`)
+}
+
+func TestLoadArchives(t *testing.T) {
+	var loaded []string
+	restore := container.MockSkopeoCopy(func(cl *container.Client, src, dest string) error {
+		loaded = append(loaded, container.ClientSysctx(cl).ArchitectureChoice+" "+src+" "+dest)
+		return nil
+	})
+	defer restore()
+
+	err := container.LoadArchives([]container.Spec{
+		{
+			Source:       "/srv/fedora.tar",
+			Transport:    container.OCIArchiveTransport,
+			LocalName:    "localhost/fedora",
+			LocalStorage: true,
+			Arch:         arch.ARCH_AARCH64,
+		},
+		// not read from an archive
+		{
+			Source:    "registry.example.com/fedora",
+			LocalName: "registry.example.com/fedora",
+			Arch:      arch.ARCH_X86_64,
+		},
+		{
+			Source:       "/srv/layout:latest",
+			Transport:    container.OCILayoutTransport,
+			LocalName:    "localhost/layout:v1",
+			LocalStorage: true,
+			Arch:         arch.ARCH_X86_64,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"arm64 oci-archive:/srv/fedora.tar containers-storage:[overlay@/var/lib/containers/storage+/run/containers/storage]localhost/fedora:latest",
+		"amd64 oci:/srv/layout:latest containers-storage:[overlay@/var/lib/containers/storage+/run/containers/storage]localhost/layout:v1",
+	}, loaded)
+}
+
+func TestLoadArchivesError(t *testing.T) {
+	restore := container.MockSkopeoCopy(func(cl *container.Client, src, dest string) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	spec := container.Spec{
+		Source:    "/srv/fedora.tar",
+		Transport: container.OCIArchiveTransport,
+		LocalName: "localhost/fedora",
+		Arch:      arch.ARCH_X86_64,
+	}
+	err := container.LoadArchives([]container.Spec{spec})
+	assert.EqualError(t, err, `cannot load the oci-archive container "/srv/fedora.tar" into the local storage: boom`)
+
+	spec.LocalName = "Not A Name"
+	err = container.LoadArchives([]container.Spec{spec})
+	assert.ErrorContains(t, err, `invalid name "Not A Name" for the oci-archive container "/srv/fedora.tar"`)
+
+	client, err := container.NewClient("registry.example.com/fedora")
+	require.NoError(t, err)
+	assert.EqualError(t, client.Load("localhost/fedora"), "cannot load a container that is not read from an archive or layout directory")
+}
+
+func TestClientWithoutTarget(t *testing.T) {
+	client := &container.Client{}
+	_, err := client.GetManifest(t.Context(), "", false)
+	assert.EqualError(t, err, "container client has no target")
+	_, err = client.GetManifest(t.Context(), "", true)
+	assert.EqualError(t, err, "container client has no target")
+}
+
+func TestNewClientArchiveErrors(t *testing.T) {
+	_, err := container.NewClient("oci-archive:fedora.tar")
+	assert.EqualError(t, err, `path of oci-archive container "fedora.tar" must be absolute`)
+
+	client, err := container.NewClient("oci-archive:/srv/fedora.tar")
+	require.NoError(t, err)
+	_, err = client.UploadImage(t.Context(), "oci-archive:/srv/other.tar", "")
+	assert.EqualError(t, err, "cannot upload to oci-archive container")
+}
@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/olog"
 )
 
 const (
@@ -100,7 +102,7 @@ func ApplyDefaultDomainPath(target, defaultDomain, defaultPath string) (string,
 // All mentioned defaults are only set when using the
 // NewClient constructor.
 type Client struct {
-	Target reference.Named // the target object to interact with
+	Target reference.Named // the target object to interact with, nil for archives
 
 	ReportWriter io.Writer // used for writing status reports, defaults to os.Stdout
 
@@ -113,16 +115,42 @@ type Client struct {
 	policy *signature.Policy
 	sysCtx *types.SystemContext
 
+	signatureVerification SignatureVerification
+
+	// archive is the reference of a container that is read from an
+	// archive or a layout directory, see ParseArchiveSource
+	archive     types.ImageReference
+	transport   string
+	archivePath string
+
 	store string // another store location other than the main one, useful for testing
 }
 
+// errNoTarget is returned when the Client is used with a nil Target, e.g.
+// when it was not created with NewClient
+var errNoTarget = errors.New("container client has no target")
+
 // NewClient constructs a new Client for target with default options.
-// It will add the "latest" tag if target does not contain it.
+// It will add the "latest" tag if target does not contain it. Targets
+// that use one of the archive transports (see ParseArchiveSource) can
+// only be resolved.
 func NewClient(target string) (*Client, error) {
 
-	ref, err := reference.ParseNormalizedNamed(target)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse '%s': %w", target, err)
+	var ref reference.Named
+	var archive types.ImageReference
+	transport, path, isArchive := ParseArchiveSource(target)
+	if isArchive {
+		var err error
+		archive, err = parseArchiveReference(transport, path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		named, err := reference.ParseNormalizedNamed(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse '%s': %w", target, err)
+		}
+		ref = reference.TagNameOnly(named)
 	}
 
 	var policy *signature.Policy
@@ -140,7 +168,7 @@ func NewClient(target string) (*Client, error) {
 	}
 
 	client := Client{
-		Target: reference.TagNameOnly(ref),
+		Target: ref,
 
 		ReportWriter:      os.Stdout,
 		PrecomputeDigests: true,
@@ -156,8 +184,11 @@ func NewClient(target string) (*Client, error) {
 
 			AuthFilePath: GetDefaultAuthFile(),
 		},
-		policy: policy,
-		store:  "/var/lib/containers/storage",
+		policy:      policy,
+		archive:     archive,
+		transport:   transport,
+		archivePath: path,
+		store:       "/var/lib/containers/storage",
 	}
 
 	// default to the host architecture
@@ -176,10 +207,9 @@ func (cl *Client) GetAuthFilePath() string {
 	return cl.sysCtx.AuthFilePath
 }
 
-func (cl *Client) SetArchitectureChoice(arch string) {
-	// Translate some well-known Composer architecture strings
-	// into the corresponding container ones
-
+// containerPlatform translates some well-known Composer architecture
+// strings into the corresponding container architecture and variant
+func containerPlatform(arch string) (string, string) {
 	variant := ""
 
 	switch arch {
@@ -188,21 +218,20 @@ func (cl *Client) SetArchitectureChoice(arch string) {
 
 	case "aarch64":
 		arch = "arm64"
-		if variant == "" {
-			variant = "v8"
-		}
+		variant = "v8"
 
 	case "armhfp":
 		arch = "arm"
-		if variant == "" {
-			variant = "v7"
-		}
+		variant = "v7"
 
 		//ppc64le and s390x are the same
 	}
 
-	cl.sysCtx.ArchitectureChoice = arch
-	cl.sysCtx.VariantChoice = variant
+	return arch, variant
+}
+
+func (cl *Client) SetArchitectureChoice(arch string) {
+	cl.sysCtx.ArchitectureChoice, cl.sysCtx.VariantChoice = containerPlatform(arch)
 }
 
 func (cl *Client) SetVariantChoice(variant string) {
@@ -274,6 +303,10 @@ func parseImageName(name string) (types.ImageReference, error) {
 // Returns the digest of the manifest that was written to the server.
 func (cl *Client) UploadImage(ctx context.Context, from, tag string) (digest.Digest, error) {
 
+	if cl.archive != nil {
+		return "", fmt.Errorf("cannot upload to %s container", cl.transport)
+	}
+
 	targetCtx := *cl.sysCtx
 	targetCtx.DockerRegistryPushPrecomputeDigests = cl.PrecomputeDigests
 
@@ -289,6 +322,9 @@ func (cl *Client) UploadImage(ctx context.Context, from, tag string) (digest.Dig
 	}
 
 	target := cl.Target
+	if target == nil {
+		return "", errNoTarget
+	}
 
 	if tag != "" {
 		target = reference.TrimNamed(target)
@@ -355,10 +391,16 @@ func (m RawManifest) Digest() (digest.Digest, error) {
 // If digest is not empty it will retrieve the manifest for the image that
 // matches the digest.
 func (cl *Client) GetManifest(ctx context.Context, instanceDigest digest.Digest, local bool) (RawManifest, error) {
+	if cl.archive != nil {
+		return cl.getArchiveManifest(ctx, instanceDigest)
+	}
 	if local {
 		return cl.getLocalManifest(ctx, instanceDigest)
 	}
 
+	if cl.Target == nil {
+		return RawManifest{}, errNoTarget
+	}
 	target := cl.Target.String()
 	if instanceDigest != "" {
 		// resolve config for specific instance
@@ -389,6 +431,9 @@ func (cl *Client) GetManifest(ctx context.Context, instanceDigest digest.Digest,
 }
 
 func (cl *Client) getLocalManifest(ctx context.Context, instanceDigest digest.Digest) (RawManifest, error) {
+	if cl.Target == nil {
+		return RawManifest{}, errNoTarget
+	}
 	target := cl.Target.String()
 	if instanceDigest != "" {
 		imageId, err := cl.getLocalImageID(instanceDigest.String())
@@ -397,7 +442,7 @@ func (cl *Client) getLocalManifest(ctx context.Context, instanceDigest digest.Di
 		}
 		target = fmt.Sprintf("@%s", imageId)
 	}
-	data, err := cl.skopeoInspect(fmt.Sprintf("containers-storage:[overlay@%s+/run/containers/storage]%s", cl.store, target))
+	data, err := cl.skopeoInspect(cl.localStorageRef(target))
 	if err != nil {
 		return RawManifest{}, err
 	}
@@ -528,20 +573,54 @@ func (cl *Client) Resolve(ctx context.Context, name string, local bool) (Spec, e
 		return Spec{}, err
 	}
 
-	if name == "" {
-		name = cl.Target.String()
+	source := ""
+	if cl.archive != nil {
+		// the transport is recorded separately in the spec
+		source = cl.archivePath
+		if name == "" {
+			name = cl.archiveName()
+		}
+		if name == "" {
+			return Spec{}, fmt.Errorf("a name is required for the %s container %q", cl.transport, source)
+		}
+	} else {
+		if cl.Target == nil {
+			return Spec{}, errNoTarget
+		}
+		source = cl.Target.Name()
+		if name == "" {
+			name = cl.Target.String()
+		}
 	}
 
 	spec := NewSpec(
-		cl.Target.Name(),
+		source,
 		ids.Manifest.String(),
 		ids.Config.String(),
 		cl.GetTLSVerify(),
 		ids.ListManifest.String(),
 		name,
-		local,
+		// containers from archives are embedded from the local storage
+		// after they were loaded into it, see LoadArchives()
+		local || cl.archive != nil,
 	)
 
+	spec.Transport = cl.transport
+
+	// containers in the local storage were verified when they were
+	// pulled into it
+	if cl.signatureVerification != SignatureVerificationNone && !local {
+		sigs, err := cl.VerifySignatures(ctx, ids.ListManifest, ids.Manifest)
+		switch {
+		case err != nil && cl.signatureVerification == SignatureVerificationEnforce:
+			return Spec{}, err
+		case err != nil:
+			olog.Printf("WARNING: %v", err)
+		default:
+			spec.Signatures = sigs
+		}
+	}
+
 	if imageArch != nil {
 		spec.Arch = *imageArch
 	} else {
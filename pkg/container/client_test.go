@@ -641,3 +641,79 @@ func getManifestListAndImageDigests(t *testing.T, storage string, manifestName s
 
 	return stdout.String(), digests
 }
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := container.ParseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:foo:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:foo:pull",
+	}, params)
+
+	scheme, params = container.ParseChallenge(`Basic realm=registry`)
+	assert.Equal(t, "basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "registry"}, params)
+}
+
+func TestGetSBOMReferrer(t *testing.T) {
+	for _, referrersAPI := range []bool{false, true} {
+		t.Run(fmt.Sprintf("referrersAPI: %v", referrersAPI), func(t *testing.T) {
+			registry := testregistry.New()
+			defer registry.Close()
+
+			repo := registry.AddRepo("library/osbuild")
+			if referrersAPI {
+				repo.EnableReferrersAPI()
+			}
+			repo.AddImage(
+				[]testregistry.Blob{testregistry.NewDataBlobFromBase64(testregistry.RootLayer)},
+				[]string{"amd64", "arm64"},
+				"some kind of container",
+				time.Time{},
+			)
+			amd64Digest := "sha256:505fe73a6102a624a46a1732e14e47034b9cca6f1ceaa3ef728aefbb2390f026"
+			arm64Digest := "sha256:28ddcb768b2624aac065aaa4feccb1be3e340054954183c2908a607b1f133478"
+
+			spdxDoc := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+			cdxDoc := []byte(`{"bomFormat":"CycloneDX"}`)
+			spdxReferrer := repo.AddReferrer(amd64Digest, container.SPDXArtifactType, spdxDoc)
+			cdxReferrer := repo.AddReferrer(amd64Digest, container.CycloneDXArtifactType, cdxDoc)
+			repo.AddReferrer(arm64Digest, "application/vnd.dev.sigstore.bundle.v0.3+json", []byte(`{}`))
+
+			client, err := container.NewClient(registry.GetRef("library/osbuild"))
+			require.NoError(t, err)
+			client.SkipTLSVerify()
+			ctx := t.Context()
+
+			artifact, err := client.GetSBOMReferrer(ctx, digest.Digest(amd64Digest))
+			require.NoError(t, err)
+			assert.Equal(t, &container.Artifact{
+				ArtifactType: container.SPDXArtifactType,
+				Digest:       digest.Digest(spdxReferrer),
+				Data:         spdxDoc,
+			}, artifact)
+
+			artifact, err = client.GetSBOMReferrer(ctx, digest.Digest(amd64Digest), container.CycloneDXArtifactType)
+			require.NoError(t, err)
+			assert.Equal(t, &container.Artifact{
+				ArtifactType: container.CycloneDXArtifactType,
+				Digest:       digest.Digest(cdxReferrer),
+				Data:         cdxDoc,
+			}, artifact)
+
+			// referrers that are not SBOMs are ignored
+			artifact, err = client.GetSBOMReferrer(ctx, digest.Digest(arm64Digest))
+			require.NoError(t, err)
+			assert.Nil(t, artifact)
+
+			// no referrers at all
+			artifact, err = client.GetSBOMReferrer(ctx, digest.FromString("unknown"))
+			require.NoError(t, err)
+			assert.Nil(t, artifact)
+
+			_, err = client.GetSBOMReferrer(ctx, "invalid")
+			assert.ErrorContains(t, err, `invalid image digest "invalid"`)
+		})
+	}
+}
@@ -25,3 +25,13 @@ func ClientSysctx(cl *Client) *types.SystemContext {
 }
 
 var ParseImageName = parseImageName
+
+var ParseChallenge = parseChallenge
+
+func MockSkopeoCopy(f func(cl *Client, src, dest string) error) (restore func()) {
+	saved := skopeoCopy
+	skopeoCopy = f
+	return func() {
+		skopeoCopy = saved
+	}
+}
@@ -0,0 +1,275 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/containers/common/pkg/retry"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/osbuild/images/pkg/arch"
+)
+
+// An IndexEntry is a single-arch container that is pushed as one of the
+// manifests of an image index, see Client.UploadIndex.
+type IndexEntry struct {
+	// Source of the container, e.g. "oci-archive:/path/to/container.tar"
+	// as produced by the manifest.OCIContainer pipeline
+	Source string
+
+	// Arch of the container, used for the platform of its entry
+	Arch arch.Arch
+
+	// Variant of the platform of the entry; if empty the variant of the
+	// Client (see Client.SetVariantChoice) is used for entries with the
+	// architecture of the Client and the default variant of Arch for
+	// all others, e.g. "v8" for aarch64
+	Variant string
+
+	// Annotations of the entry in the index
+	Annotations map[string]string
+}
+
+// UploadIndex pushes the containers of entries by digest to the Target of
+// the Client and creates an OCI image index that references them. If tag
+// is set the index is pushed with that tag instead of the one of Target.
+// An OCI image index that already exists at the tag is updated: its
+// entries for the platforms of entries are replaced, all others are kept.
+// Any other manifest at the tag is replaced. annotations are set on the
+// index itself. Returns the digest of the index that was pushed.
+func (cl *Client) UploadIndex(ctx context.Context, entries []IndexEntry, tag string, annotations map[string]string) (digest.Digest, error) {
+	if cl.archive != nil {
+		return "", fmt.Errorf("cannot upload to %s container", cl.transport)
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("no containers for the index")
+	}
+
+	target := cl.Target
+	if target == nil {
+		return "", errNoTarget
+	}
+	if tag != "" {
+		var err error
+		target, err = reference.WithTag(reference.TrimNamed(target), tag)
+		if err != nil {
+			return "", fmt.Errorf("error creating reference with tag '%s': %w", tag, err)
+		}
+	}
+
+	platforms := make([]*imgspecv1.Platform, 0, len(entries))
+	for _, entry := range entries {
+		platform := cl.indexPlatform(entry)
+		for _, other := range platforms {
+			if samePlatform(other, platform) {
+				return "", fmt.Errorf("more than one container for platform %s", platformString(platform))
+			}
+		}
+		platforms = append(platforms, platform)
+	}
+
+	descriptors := make([]imgspecv1.Descriptor, 0, len(entries))
+	for i, entry := range entries {
+		desc, err := cl.uploadIndexEntry(ctx, entry, platforms[i])
+		if err != nil {
+			return "", fmt.Errorf("error uploading %s: %w", entry.Source, err)
+		}
+		descriptors = append(descriptors, desc)
+	}
+
+	existing, err := cl.getIndex(ctx, target)
+	if err != nil {
+		return "", err
+	}
+
+	index := imgspecv1.Index{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageIndex,
+	}
+	if existing != nil {
+		index.Annotations = existing.Annotations
+		for _, m := range existing.Manifests {
+			replaced := slices.ContainsFunc(descriptors, func(desc imgspecv1.Descriptor) bool {
+				return samePlatform(m.Platform, desc.Platform)
+			})
+			if !replaced {
+				index.Manifests = append(index.Manifests, m)
+			}
+		}
+	}
+	index.Manifests = append(index.Manifests, descriptors...)
+
+	if len(annotations) > 0 && index.Annotations == nil {
+		index.Annotations = make(map[string]string, len(annotations))
+	}
+	for key, value := range annotations {
+		index.Annotations[key] = value
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return "", err
+	}
+
+	err = cl.putManifest(ctx, target, data)
+	if err != nil {
+		return "", fmt.Errorf("error uploading index: %w", err)
+	}
+
+	return digest.FromBytes(data), nil
+}
+
+// indexPlatform returns the platform of the entry in the index
+func (cl *Client) indexPlatform(entry IndexEntry) *imgspecv1.Platform {
+	architecture, variant := containerPlatform(entry.Arch.String())
+	if architecture == cl.sysCtx.ArchitectureChoice {
+		variant = cl.sysCtx.VariantChoice
+	}
+	if entry.Variant != "" {
+		variant = entry.Variant
+	}
+	return &imgspecv1.Platform{
+		Architecture: architecture,
+		OS:           "linux",
+		Variant:      variant,
+	}
+}
+
+// uploadIndexEntry pushes the container of entry by digest and returns
+// its descriptor for the index
+func (cl *Client) uploadIndexEntry(ctx context.Context, entry IndexEntry, platform *imgspecv1.Platform) (imgspecv1.Descriptor, error) {
+	srcRef, err := parseImageName(entry.Source)
+	if err != nil {
+		return imgspecv1.Descriptor{}, fmt.Errorf("invalid source name '%s': %w", entry.Source, err)
+	}
+
+	destRef, err := docker.NewReferenceUnknownDigest(reference.TrimNamed(cl.Target))
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+
+	policyContext, err := signature.NewPolicyContext(cl.policy)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	defer func() {
+		_ = policyContext.Destroy()
+	}()
+
+	sourceCtx := *cl.sysCtx
+	sourceCtx.ArchitectureChoice = platform.Architecture
+	sourceCtx.VariantChoice = platform.Variant
+
+	targetCtx := *cl.sysCtx
+	targetCtx.DockerRegistryPushPrecomputeDigests = cl.PrecomputeDigests
+
+	retryOpts := retry.RetryOptions{
+		MaxRetry: cl.MaxRetries,
+	}
+
+	var manifestBytes []byte
+	err = retry.RetryIfNecessary(ctx, func() error {
+		manifestBytes, err = copy.Image(ctx, policyContext, destRef, srcRef, &copy.Options{
+			ReportWriter:       cl.ReportWriter,
+			SourceCtx:          &sourceCtx,
+			DestinationCtx:     &targetCtx,
+			ImageListSelection: copy.CopySystemImage,
+		})
+		return err
+	}, &retryOpts)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+
+	mediaType := manifest.GuessMIMEType(manifestBytes)
+	if manifest.MIMETypeIsMultiImage(mediaType) {
+		return imgspecv1.Descriptor{}, fmt.Errorf("unexpected manifest list")
+	}
+
+	return imgspecv1.Descriptor{
+		MediaType:   mediaType,
+		Digest:      digest.FromBytes(manifestBytes),
+		Size:        int64(len(manifestBytes)),
+		Platform:    platform,
+		Annotations: entry.Annotations,
+	}, nil
+}
+
+// getIndex returns the OCI image index at target or nil if there is no
+// manifest or a different kind of manifest at target
+func (cl *Client) getIndex(ctx context.Context, target reference.Named) (*imgspecv1.Index, error) {
+	src, err := cl.newImageSource(ctx, target)
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, mediaType, err := src.GetManifest(ctx, nil)
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mediaType != imgspecv1.MediaTypeImageIndex {
+		return nil, nil
+	}
+
+	var index imgspecv1.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("cannot parse image index: %w", err)
+	}
+	return &index, nil
+}
+
+// putManifest pushes the manifest data to target
+func (cl *Client) putManifest(ctx context.Context, target reference.Named, data []byte) error {
+	destRef, err := docker.NewReference(target)
+	if err != nil {
+		return err
+	}
+
+	retryOpts := retry.RetryOptions{
+		MaxRetry: cl.MaxRetries,
+	}
+
+	return retry.RetryIfNecessary(ctx, func() error {
+		dest, err := destRef.NewImageDestination(ctx, cl.sysCtx)
+		if err != nil {
+			return err
+		}
+		defer dest.Close()
+
+		if err := dest.PutManifest(ctx, data, nil); err != nil {
+			return err
+		}
+		return dest.Commit(ctx, nil)
+	}, &retryOpts)
+}
+
+func samePlatform(a, b *imgspecv1.Platform) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.OS == b.OS && a.Architecture == b.Architecture && a.Variant == b.Variant
+}
+
+func platformString(p *imgspecv1.Platform) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
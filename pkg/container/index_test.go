@@ -0,0 +1,161 @@
+package container_test
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testregistry"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+)
+
+// makeArchive writes a single-arch oci-archive like the ones of the
+// OCIContainer pipeline and returns its path and manifest descriptor
+func makeArchive(t *testing.T, architecture string) (string, imgspecv1.Descriptor) {
+	layout := ociLayout{dir: t.TempDir()}
+	manifest, _ := layout.addImage(t, architecture)
+	manifest.Platform = nil
+	layout.writeIndex(t, manifest)
+
+	archive := filepath.Join(t.TempDir(), architecture+".tar")
+	tarLayout(t, layout.dir, archive)
+	return archive, manifest
+}
+
+func getIndex(t *testing.T, repo *testregistry.Repo, ref string) imgspecv1.Index {
+	data, mediaType, ok := repo.GetManifest(ref)
+	require.True(t, ok)
+	assert.Equal(t, imgspecv1.MediaTypeImageIndex, mediaType)
+
+	var index imgspecv1.Index
+	require.NoError(t, json.Unmarshal(data, &index))
+	return index
+}
+
+func TestClientUploadIndex(t *testing.T) {
+	registry := testregistry.New()
+	defer registry.Close()
+	repo := registry.AddRepo("library/multi")
+
+	amd64Archive, amd64Manifest := makeArchive(t, "amd64")
+	arm64Archive, arm64Manifest := makeArchive(t, "arm64")
+
+	client, err := container.NewClient(registry.GetRef("library/multi"))
+	require.NoError(t, err)
+	client.SkipTLSVerify()
+	client.ReportWriter = io.Discard
+	client.SetArchitectureChoice("x86_64")
+
+	indexDigest, err := client.UploadIndex(t.Context(), []container.IndexEntry{
+		{
+			Source:      "oci-archive:" + amd64Archive,
+			Arch:        arch.ARCH_X86_64,
+			Annotations: map[string]string{"org.osbuild.image-type": "container"},
+		},
+		{
+			Source: "oci-archive:" + arm64Archive,
+			Arch:   arch.ARCH_AARCH64,
+		},
+	}, "", map[string]string{imgspecv1.AnnotationVersion: "1"})
+	require.NoError(t, err)
+
+	// the containers are pushed by digest
+	for _, desc := range []imgspecv1.Descriptor{amd64Manifest, arm64Manifest} {
+		_, mediaType, ok := repo.GetManifest(desc.Digest.String())
+		assert.True(t, ok)
+		assert.Equal(t, imgspecv1.MediaTypeImageManifest, mediaType)
+	}
+
+	index := getIndex(t, repo, "latest")
+	assert.Equal(t, map[string]string{imgspecv1.AnnotationVersion: "1"}, index.Annotations)
+	assert.Equal(t, []imgspecv1.Descriptor{
+		{
+			MediaType:   imgspecv1.MediaTypeImageManifest,
+			Digest:      amd64Manifest.Digest,
+			Size:        amd64Manifest.Size,
+			Platform:    &imgspecv1.Platform{Architecture: "amd64", OS: "linux"},
+			Annotations: map[string]string{"org.osbuild.image-type": "container"},
+		},
+		{
+			MediaType: imgspecv1.MediaTypeImageManifest,
+			Digest:    arm64Manifest.Digest,
+			Size:      arm64Manifest.Size,
+			Platform:  &imgspecv1.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"},
+		},
+	}, index.Manifests)
+	indexData, _, _ := repo.GetManifest("latest")
+	assert.Equal(t, digest.FromBytes(indexData), indexDigest)
+
+	// updating the index replaces the entry of the platform and keeps
+	// the others
+	s390xArchive, s390xManifest := makeArchive(t, "s390x")
+	newArm64Archive, newArm64Manifest := makeArchive(t, "arm64")
+	_, err = client.UploadIndex(t.Context(), []container.IndexEntry{
+		{Source: "oci-archive:" + newArm64Archive, Arch: arch.ARCH_AARCH64},
+		{Source: "oci-archive:" + s390xArchive, Arch: arch.ARCH_S390X},
+	}, "", map[string]string{imgspecv1.AnnotationRevision: "abc"})
+	require.NoError(t, err)
+
+	index = getIndex(t, repo, "latest")
+	assert.Equal(t, map[string]string{
+		imgspecv1.AnnotationVersion:  "1",
+		imgspecv1.AnnotationRevision: "abc",
+	}, index.Annotations)
+	require.Len(t, index.Manifests, 3)
+	assert.Equal(t, amd64Manifest.Digest, index.Manifests[0].Digest)
+	assert.Equal(t, newArm64Manifest.Digest, index.Manifests[1].Digest)
+	assert.Equal(t, s390xManifest.Digest, index.Manifests[2].Digest)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "s390x", OS: "linux"}, index.Manifests[2].Platform)
+
+	// a variant of the client applies to the containers of its
+	// architecture, a variant of an entry takes precedence
+	client.SetArchitectureChoice("aarch64")
+	client.SetVariantChoice("v9")
+	_, err = client.UploadIndex(t.Context(), []container.IndexEntry{
+		{Source: "oci-archive:" + arm64Archive, Arch: arch.ARCH_AARCH64},
+		{Source: "oci-archive:" + amd64Archive, Arch: arch.ARCH_X86_64, Variant: "v3"},
+	}, "variants", nil)
+	require.NoError(t, err)
+
+	index = getIndex(t, repo, "variants")
+	assert.Nil(t, index.Annotations)
+	require.Len(t, index.Manifests, 2)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "arm64", OS: "linux", Variant: "v9"}, index.Manifests[0].Platform)
+	assert.Equal(t, &imgspecv1.Platform{Architecture: "amd64", OS: "linux", Variant: "v3"}, index.Manifests[1].Platform)
+}
+
+func TestClientUploadIndexErrors(t *testing.T) {
+	registry := testregistry.New()
+	defer registry.Close()
+	registry.AddRepo("library/multi")
+
+	amd64Archive, _ := makeArchive(t, "amd64")
+
+	client, err := container.NewClient(registry.GetRef("library/multi"))
+	require.NoError(t, err)
+	client.SkipTLSVerify()
+	client.ReportWriter = io.Discard
+
+	_, err = client.UploadIndex(t.Context(), nil, "", nil)
+	assert.EqualError(t, err, "no containers for the index")
+
+	_, err = client.UploadIndex(t.Context(), []container.IndexEntry{
+		{Source: "oci-archive:" + amd64Archive, Arch: arch.ARCH_X86_64},
+		{Source: "oci-archive:" + amd64Archive, Arch: arch.ARCH_X86_64},
+	}, "", nil)
+	assert.EqualError(t, err, "more than one container for platform linux/amd64")
+
+	client, err = container.NewClient("oci-archive:/srv/fedora.tar")
+	require.NoError(t, err)
+	_, err = client.UploadIndex(t.Context(), []container.IndexEntry{
+		{Source: "oci-archive:" + amd64Archive, Arch: arch.ARCH_X86_64},
+	}, "", nil)
+	assert.EqualError(t, err, "cannot upload to oci-archive container")
+}
@@ -0,0 +1,368 @@
+package container
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/opencontainers/go-digest"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Artifact types of SBOMs that are attached to images as OCI referrers
+const (
+	SPDXArtifactType      = "application/spdx+json"
+	CycloneDXArtifactType = "application/vnd.cyclonedx+json"
+)
+
+// An Artifact is the content of an artifact that refers to an image, e.g. an
+// SBOM document.
+type Artifact struct {
+	ArtifactType string
+	Digest       digest.Digest
+	Data         []byte
+}
+
+// referrersTag returns the tag of the referrers index of the image with the
+// given digest when the referrers tag schema of the OCI distribution spec
+// is used, e.g. "sha256-1234..."
+func referrersTag(imageDigest digest.Digest) string {
+	return fmt.Sprintf("%s-%s", imageDigest.Algorithm(), imageDigest.Encoded())
+}
+
+// perHostCertDirs are the directories with the per registry TLS
+// configuration, see containers-certs.d(5)
+var perHostCertDirs = []string{
+	"/etc/containers/certs.d",
+	"/etc/docker/certs.d",
+}
+
+// registryHost returns the host of the registry API of the domain of a
+// reference, the docker.io API is served by registry-1.docker.io
+func registryHost(domain string) string {
+	if domain == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return domain
+}
+
+// httpClient returns a client for requests to the registry of the
+// domain that uses the TLS configuration of the Client.
+func (cl *Client) httpClient(domain string) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cl.sysCtx.DockerInsecureSkipTLSVerify == types.OptionalBoolTrue {
+		tlsConfig.InsecureSkipVerify = true // #nosec G402
+	}
+	certDir := cl.sysCtx.DockerCertPath
+	if certDir == "" {
+		for _, dir := range perHostCertDirs {
+			if _, err := os.Stat(filepath.Join(dir, domain)); err == nil {
+				certDir = filepath.Join(dir, domain)
+				break
+			}
+		}
+	}
+	if certDir != "" {
+		if err := tlsclientconfig.SetupCertificates(certDir, tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// parseChallenge parses the WWW-Authenticate header of a registry, e.g.
+// `Bearer realm="https://auth.example.com/token",service="example.com"`
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key != "" {
+			params[strings.ToLower(strings.TrimSpace(key))] = value
+		}
+	}
+	return strings.ToLower(scheme), params
+}
+
+// registryGet sends a GET request for the API path of the registry of the
+// named repository. If the registry requires authentication the request is
+// repeated with the credentials of the Client, for token authentication a
+// pull token for the repository is requested first.
+func (cl *Client) registryGet(ctx context.Context, name reference.Named, path, accept string) (*http.Response, error) {
+	domain := reference.Domain(name)
+	client, err := cl.httpClient(domain)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := fmt.Sprintf("https://%s/v2/%s/%s", registryHost(domain), reference.Path(name), path)
+	newRequest := func(target string) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", cl.UserAgent)
+		return req, nil
+	}
+
+	req, err := newRequest(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	creds, err := config.GetCredentials(cl.sysCtx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get credentials for %s: %w", domain, err)
+	}
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	req, err = newRequest(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	switch scheme {
+	case "basic":
+		req.SetBasicAuth(creds.Username, creds.Password)
+	case "bearer":
+		tokenURL, err := url.Parse(params["realm"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token realm of %s: %w", domain, err)
+		}
+		query := tokenURL.Query()
+		if service := params["service"]; service != "" {
+			query.Set("service", service)
+		}
+		query.Set("scope", fmt.Sprintf("repository:%s:pull", reference.Path(name)))
+		tokenURL.RawQuery = query.Encode()
+		tokenReq, err := newRequest(tokenURL.String())
+		if err != nil {
+			return nil, err
+		}
+		if creds.Username != "" {
+			tokenReq.SetBasicAuth(creds.Username, creds.Password)
+		}
+		tokenResp, err := client.Do(tokenReq)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get token for %s: %w", domain, err)
+		}
+		defer tokenResp.Body.Close()
+		if tokenResp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("cannot get token for %s: %s", domain, tokenResp.Status)
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.NewDecoder(tokenResp.Body).Decode(&token); err != nil {
+			return nil, fmt.Errorf("cannot parse token of %s: %w", domain, err)
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+	default:
+		return nil, fmt.Errorf("unsupported authentication scheme %q of %s", scheme, domain)
+	}
+	return client.Do(req)
+}
+
+// getReferrersAPIIndex returns the referrers index of the image with the
+// given digest from the referrers API of the OCI distribution spec 1.1. It
+// returns false if the registry does not support the API.
+func (cl *Client) getReferrersAPIIndex(ctx context.Context, name reference.Named, imageDigest digest.Digest) (*imgspecv1.Index, bool, error) {
+	resp, err := cl.registryGet(ctx, name, "referrers/"+imageDigest.String(), imgspecv1.MediaTypeImageIndex)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot get referrers of %s: %w", imageDigest, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("cannot get referrers of %s: %s", imageDigest, resp.Status)
+	}
+	// registries that do not implement the API might serve something
+	// else on this path
+	if mediaType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";"); mediaType != imgspecv1.MediaTypeImageIndex {
+		return nil, false, nil
+	}
+	var index imgspecv1.Index
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, false, fmt.Errorf("cannot parse referrers of %s: %w", imageDigest, err)
+	}
+	return &index, true, nil
+}
+
+// getReferrersTagIndex returns the referrers index of the image with the
+// given digest from the referrers tag schema of the OCI distribution spec
+// (which is the fallback for registries without the referrers API), nil
+// if there is no index.
+func (cl *Client) getReferrersTagIndex(ctx context.Context, name reference.Named, imageDigest digest.Digest) (*imgspecv1.Index, error) {
+	indexRef, err := reference.WithTag(name, referrersTag(imageDigest))
+	if err != nil {
+		return nil, err
+	}
+	// no referrers index means that nothing refers to the image, note
+	// that the manifest is already fetched when creating the source
+	indexSrc, err := cl.newImageSource(ctx, indexRef)
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get referrers of %s: %w", imageDigest, err)
+	}
+	defer indexSrc.Close()
+	data, _, err := indexSrc.GetManifest(ctx, nil)
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get referrers of %s: %w", imageDigest, err)
+	}
+	var index imgspecv1.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("cannot parse referrers index of %s: %w", imageDigest, err)
+	}
+	return &index, nil
+}
+
+// isManifestUnknown returns true if the error is the "manifest unknown"
+// error of the registry
+func isManifestUnknown(err error) bool {
+	var ec errcode.ErrorCoder
+	return errors.As(err, &ec) && ec.ErrorCode() == v2.ErrorCodeManifestUnknown
+}
+
+func (cl *Client) newImageSource(ctx context.Context, named reference.Named) (types.ImageSource, error) {
+	ref, err := docker.NewReference(named)
+	if err != nil {
+		return nil, err
+	}
+	return ref.NewImageSource(ctx, cl.sysCtx)
+}
+
+// GetSBOMReferrer returns the SBOM that is attached to the image with the
+// given digest as an OCI referrer. The referrers are looked up via the
+// referrers API of the OCI distribution spec 1.1, for registries that do
+// not support the API the referrers tag schema is used instead. If several
+// SBOMs are attached, the first one with one of the given artifact types
+// is returned (in the order of the artifact types). It returns nil if the
+// image has no SBOM.
+func (cl *Client) GetSBOMReferrer(ctx context.Context, imageDigest digest.Digest, artifactTypes ...string) (*Artifact, error) {
+	if len(artifactTypes) == 0 {
+		artifactTypes = []string{SPDXArtifactType, CycloneDXArtifactType}
+	}
+	if err := imageDigest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid image digest %q: %w", imageDigest, err)
+	}
+	if cl.archive != nil {
+		return nil, fmt.Errorf("cannot get referrers of %s container", cl.transport)
+	}
+	if cl.Target == nil {
+		return nil, errNoTarget
+	}
+
+	name := reference.TrimNamed(cl.Target)
+	index, ok, err := cl.getReferrersAPIIndex(ctx, name, imageDigest)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		index, err = cl.getReferrersTagIndex(ctx, name, imageDigest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if index == nil {
+		return nil, nil
+	}
+
+	var found *imgspecv1.Descriptor
+	for _, artifactType := range artifactTypes {
+		idx := slices.IndexFunc(index.Manifests, func(desc imgspecv1.Descriptor) bool {
+			return desc.ArtifactType == artifactType
+		})
+		if idx >= 0 {
+			found = &index.Manifests[idx]
+			break
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	artifactRef, err := reference.WithDigest(name, found.Digest)
+	if err != nil {
+		return nil, err
+	}
+	src, err := cl.newImageSource(ctx, artifactRef)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get referrer manifest %s: %w", found.Digest, err)
+	}
+	var mf imgspecv1.Manifest
+	if err := json.Unmarshal(data, &mf); err != nil {
+		return nil, fmt.Errorf("cannot parse referrer manifest %s: %w", found.Digest, err)
+	}
+	if len(mf.Layers) != 1 {
+		return nil, fmt.Errorf("referrer %s has %d layers, expected exactly one", found.Digest, len(mf.Layers))
+	}
+
+	blob, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: mf.Layers[0].Digest, Size: mf.Layers[0].Size}, none.NoCache)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get content of referrer %s: %w", found.Digest, err)
+	}
+	defer blob.Close()
+	content, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, err
+	}
+	if mf.Layers[0].Digest.Algorithm().FromBytes(content) != mf.Layers[0].Digest {
+		return nil, fmt.Errorf("content of referrer %s does not match its digest", found.Digest)
+	}
+
+	return &Artifact{
+		ArtifactType: found.ArtifactType,
+		Digest:       found.Digest,
+		Data:         content,
+	}, nil
+}
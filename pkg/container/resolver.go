@@ -29,6 +29,8 @@ type asyncResolver struct {
 	Arch         string
 	AuthFilePath string
 
+	Signatures SignatureOptions
+
 	newClient func(string) (*Client, error)
 }
 
@@ -66,6 +68,10 @@ func (r *asyncResolver) Add(spec SourceSpec) {
 	if r.AuthFilePath != "" {
 		client.SetAuthFilePath(r.AuthFilePath)
 	}
+	if err := client.SetSignatureOptions(r.Signatures); err != nil {
+		r.queue <- resolveResult{err: err}
+		return
+	}
 
 	go func() {
 		ctx, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
@@ -138,6 +144,8 @@ type blockingResolver struct {
 	Arch         string
 	AuthFilePath string
 
+	Signatures SignatureOptions
+
 	newClient func(string) (*Client, error)
 
 	results []resolveResult
@@ -153,6 +161,16 @@ func NewBlockingResolver(arch string) Resolver {
 	}
 }
 
+// NewBlockingResolverWithSignatures returns a blocking [Resolver] that
+// verifies the signatures of the containers with the given options.
+func NewBlockingResolverWithSignatures(arch string, signatures SignatureOptions) Resolver {
+	return &blockingResolver{
+		Arch:       arch,
+		Signatures: signatures,
+		newClient:  NewClient,
+	}
+}
+
 func (r *blockingResolver) Add(src SourceSpec) {
 	spec, err := r.Resolve(src)
 	if err != nil {
@@ -194,6 +212,9 @@ func (r *blockingResolver) Resolve(source SourceSpec) (Spec, error) {
 	if r.AuthFilePath != "" {
 		client.SetAuthFilePath(r.AuthFilePath)
 	}
+	if err := client.SetSignatureOptions(r.Signatures); err != nil {
+		return Spec{}, err
+	}
 
 	ctx, cancelTimeout := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancelTimeout()
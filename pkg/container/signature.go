@@ -0,0 +1,240 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// SignatureVerification controls if the signatures of containers are
+// verified against the signature policy (see containers-policy.json(5))
+// when they are resolved.
+type SignatureVerification int
+
+const (
+	// SignatureVerificationNone does not verify signatures
+	SignatureVerificationNone SignatureVerification = iota
+	// SignatureVerificationWarn logs a warning for containers that are
+	// rejected by the policy but resolves them anyway
+	SignatureVerificationWarn
+	// SignatureVerificationEnforce fails to resolve containers that are
+	// rejected by the policy
+	SignatureVerificationEnforce
+)
+
+// ParseSignatureVerification parses the name of a SignatureVerification,
+// one of "none", "warn" or "enforce". The empty string is "none".
+func ParseSignatureVerification(name string) (SignatureVerification, error) {
+	switch name {
+	case "", "none":
+		return SignatureVerificationNone, nil
+	case "warn":
+		return SignatureVerificationWarn, nil
+	case "enforce":
+		return SignatureVerificationEnforce, nil
+	}
+	return SignatureVerificationNone, fmt.Errorf("unknown signature verification %q, must be one of none, warn or enforce", name)
+}
+
+// Formats of container signatures
+const (
+	SimpleSigningFormat = "simple-signing"
+	SigstoreFormat      = "sigstore"
+)
+
+// sigstoreSignatureMediaType is the media type of the layers of sigstore
+// signature attachments
+const sigstoreSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+// A Signature of a container that was accepted by the signature policy.
+// Signatures belong to the manifest list of the container (Spec.ListDigest)
+// or, if the container has no list or the list is not signed, to the
+// manifest that was resolved (Spec.Digest).
+type Signature struct {
+	Format         string // SimpleSigningFormat or SigstoreFormat
+	Digest         string // digest of the signature blob
+	ManifestDigest string // digest of the signed manifest
+}
+
+// SignatureOptions configure the verification of the signatures of
+// containers when they are resolved.
+type SignatureOptions struct {
+	Verification SignatureVerification
+
+	// PolicyPath is the signature policy to use, DefaultPolicyPath
+	// (if it exists) when empty
+	PolicyPath string
+
+	// RegistriesDirPath is the location of the registries.d directory
+	// that configures the signature lookaside storage and the use of
+	// sigstore attachments (see containers-registries.d(5)), the system
+	// default when empty
+	RegistriesDirPath string
+}
+
+// SetSignatureOptions controls if and how Resolve verifies the
+// signatures of the container.
+func (cl *Client) SetSignatureOptions(opts SignatureOptions) error {
+	if opts.PolicyPath != "" {
+		policy, err := signature.NewPolicyFromFile(opts.PolicyPath)
+		if err != nil {
+			return fmt.Errorf("cannot load signature policy: %w", err)
+		}
+		cl.policy = policy
+	}
+	cl.sysCtx.RegistriesDirPath = opts.RegistriesDirPath
+	cl.signatureVerification = opts.Verification
+	return nil
+}
+
+// imageReference returns the reference of the manifest with the given
+// digest of the container. Archives cannot be addressed by digest, their
+// reference is returned as is.
+func (cl *Client) imageReference(manifestDigest digest.Digest) (types.ImageReference, error) {
+	if cl.archive != nil {
+		return cl.archive, nil
+	}
+	if cl.Target == nil {
+		return nil, errNoTarget
+	}
+	named, err := reference.WithDigest(reference.TrimNamed(cl.Target), manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	return docker.NewReference(named)
+}
+
+// VerifySignatures evaluates the signature policy for the container as
+// it was resolved and returns its signatures if the policy accepts it. The
+// policy is evaluated for the manifest list with the given digest, i.e. what
+// the tag of the container resolves to, if there is one, and for the
+// manifest that is embedded (manifestDigest) if the list is not accepted.
+// Both pin the embedded manifest. Containers in the local storage cannot be
+// verified.
+func (cl *Client) VerifySignatures(ctx context.Context, listDigest, manifestDigest digest.Digest) ([]Signature, error) {
+	digests := []digest.Digest{manifestDigest}
+	// archives cannot be addressed by digest, there is only one
+	// reference to verify
+	if listDigest != "" && cl.archive == nil {
+		digests = []digest.Digest{listDigest, manifestDigest}
+	}
+
+	var firstErr error
+	for _, dg := range digests {
+		sigs, err := cl.verifyManifestSignatures(ctx, dg)
+		if err == nil {
+			return sigs, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// verifyManifestSignatures evaluates the signature policy for the manifest
+// with the given digest of the container and returns its signatures if the
+// policy accepts it.
+func (cl *Client) verifyManifestSignatures(ctx context.Context, manifestDigest digest.Digest) ([]Signature, error) {
+	ref, err := cl.imageReference(manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	policyContext, err := signature.NewPolicyContext(cl.policy)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = policyContext.Destroy()
+	}()
+
+	src, err := ref.NewImageSource(ctx, cl.sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	unparsed := image.UnparsedInstance(src, nil)
+	if _, err := policyContext.IsRunningImageAllowed(ctx, unparsed); err != nil {
+		return nil, fmt.Errorf("signature policy rejects %s: %w", transports.ImageName(ref), err)
+	}
+
+	var sigs []Signature
+	simpleSigs, err := src.GetSignatures(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get signatures of %s: %w", transports.ImageName(ref), err)
+	}
+	for _, sig := range simpleSigs {
+		sigs = append(sigs, Signature{
+			Format:         SimpleSigningFormat,
+			Digest:         digest.FromBytes(sig).String(),
+			ManifestDigest: manifestDigest.String(),
+		})
+	}
+
+	// sigstore signatures are only available from registries
+	if cl.archive != nil {
+		return sigs, nil
+	}
+	sigstoreSigs, err := cl.getSigstoreSignatures(ctx, manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get sigstore signatures of %s: %w", transports.ImageName(ref), err)
+	}
+	return append(sigs, sigstoreSigs...), nil
+}
+
+// sigstoreAttachmentTag returns the tag of the sigstore signatures of the
+// manifest with the given digest, e.g. "sha256-1234....sig"
+func sigstoreAttachmentTag(manifestDigest digest.Digest) string {
+	return fmt.Sprintf("%s-%s.sig", manifestDigest.Algorithm(), manifestDigest.Encoded())
+}
+
+// getSigstoreSignatures returns the sigstore signatures that are attached
+// to the manifest with the given digest.
+func (cl *Client) getSigstoreSignatures(ctx context.Context, manifestDigest digest.Digest) ([]Signature, error) {
+	attachmentRef, err := reference.WithTag(reference.TrimNamed(cl.Target), sigstoreAttachmentTag(manifestDigest))
+	if err != nil {
+		return nil, err
+	}
+	src, err := cl.newImageSource(ctx, attachmentRef)
+	if isManifestUnknown(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var attachments imgspecv1.Manifest
+	if err := json.Unmarshal(data, &attachments); err != nil {
+		return nil, fmt.Errorf("cannot parse sigstore attachments: %w", err)
+	}
+
+	var sigs []Signature
+	for _, layer := range attachments.Layers {
+		if layer.MediaType != sigstoreSignatureMediaType {
+			continue
+		}
+		sigs = append(sigs, Signature{
+			Format:         SigstoreFormat,
+			Digest:         layer.Digest.String(),
+			ManifestDigest: manifestDigest.String(),
+		})
+	}
+	return sigs, nil
+}
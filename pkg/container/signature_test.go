@@ -0,0 +1,184 @@
+package container_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/testregistry"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+)
+
+// writeSigstoreConfig writes a signature policy that requires sigstore
+// signatures of key for the signed repo and a registries.d config that
+// enables sigstore attachments for the registry
+func writeSigstoreConfig(t *testing.T, key *ecdsa.PrivateKey, registry, signed string) container.SignatureOptions {
+	tmpdir := t.TempDir()
+
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	keyPath := filepath.Join(tmpdir, "cosign.pub")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0644))
+
+	policy := map[string]any{
+		"default": []any{map[string]any{"type": "insecureAcceptAnything"}},
+		"transports": map[string]any{
+			"docker": map[string]any{
+				signed: []any{
+					map[string]any{
+						"type":           "sigstoreSigned",
+						"keyPath":        keyPath,
+						"signedIdentity": map[string]any{"type": "matchRepository"},
+					},
+				},
+			},
+		},
+	}
+	policyData, err := json.Marshal(policy)
+	require.NoError(t, err)
+	policyPath := filepath.Join(tmpdir, "policy.json")
+	require.NoError(t, os.WriteFile(policyPath, policyData, 0644))
+
+	registriesDir := filepath.Join(tmpdir, "registries.d")
+	require.NoError(t, os.Mkdir(registriesDir, 0755))
+	registriesConf := fmt.Sprintf("docker:\n  %s:\n    use-sigstore-attachments: true\n", registry)
+	require.NoError(t, os.WriteFile(filepath.Join(registriesDir, "test.yaml"), []byte(registriesConf), 0644))
+
+	return container.SignatureOptions{
+		Verification:      container.SignatureVerificationEnforce,
+		PolicyPath:        policyPath,
+		RegistriesDirPath: registriesDir,
+	}
+}
+
+func TestVerifySignaturesSigstore(t *testing.T) {
+	registry := testregistry.New()
+	defer registry.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signedRepo := registry.AddRepo("library/signed")
+	listDigest := signedRepo.AddImage(
+		[]testregistry.Blob{testregistry.NewDataBlobFromBase64(testregistry.RootLayer)},
+		[]string{"amd64"},
+		"signed container",
+		time.Time{})
+	signedRef := registry.GetRef("library/signed")
+	signedSpec, err := registry.Resolve(signedRef, arch.ARCH_X86_64)
+	require.NoError(t, err)
+
+	sign := func(manifestDigest string) string {
+		payload := fmt.Appendf(nil, `{"critical":{"identity":{"docker-reference":%q},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, signedRef, manifestDigest)
+		payloadDigest := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, payloadDigest[:])
+		require.NoError(t, err)
+		return signedRepo.AddSigstoreSignature(manifestDigest, payload, base64.StdEncoding.EncodeToString(sig))
+	}
+	require.Equal(t, listDigest, signedSpec.ListDigest)
+
+	unsignedRepo := registry.AddRepo("library/unsigned")
+	unsignedRepo.AddImage(
+		[]testregistry.Blob{testregistry.NewDataBlobFromBase64(testregistry.RootLayer)},
+		[]string{"amd64"},
+		"unsigned container",
+		time.Time{})
+	unsignedRef := registry.GetRef("library/unsigned")
+	unsignedSpec, err := registry.Resolve(unsignedRef, arch.ARCH_X86_64)
+	require.NoError(t, err)
+
+	registryHost, _, _ := strings.Cut(signedRef, "/")
+	opts := writeSigstoreConfig(t, key, registryHost, signedRef)
+
+	client, err := container.NewClient(signedRef)
+	require.NoError(t, err)
+	client.SkipTLSVerify()
+	require.NoError(t, client.SetSignatureOptions(opts))
+
+	// neither the list nor the manifest is signed
+	_, err = client.VerifySignatures(t.Context(), digest.Digest(listDigest), digest.Digest(signedSpec.Digest))
+	assert.ErrorContains(t, err, "signature policy rejects docker://"+signedRef+"@"+listDigest)
+
+	// a signature of the manifest is accepted if the list is not signed
+	manifestSigDigest := sign(signedSpec.Digest)
+	sigs, err := client.VerifySignatures(t.Context(), digest.Digest(listDigest), digest.Digest(signedSpec.Digest))
+	require.NoError(t, err)
+	assert.Equal(t, []container.Signature{
+		{Format: container.SigstoreFormat, Digest: manifestSigDigest, ManifestDigest: signedSpec.Digest},
+	}, sigs)
+
+	// the signature of the list is what the tag resolves to
+	listSigDigest := sign(listDigest)
+	sigs, err = client.VerifySignatures(t.Context(), digest.Digest(listDigest), digest.Digest(signedSpec.Digest))
+	require.NoError(t, err)
+	assert.Equal(t, []container.Signature{
+		{Format: container.SigstoreFormat, Digest: listSigDigest, ManifestDigest: listDigest},
+	}, sigs)
+
+	// without a list only the manifest is verified
+	sigs, err = client.VerifySignatures(t.Context(), "", digest.Digest(signedSpec.Digest))
+	require.NoError(t, err)
+	assert.Equal(t, []container.Signature{
+		{Format: container.SigstoreFormat, Digest: manifestSigDigest, ManifestDigest: signedSpec.Digest},
+	}, sigs)
+
+	// the unsigned container is accepted by the default of the policy
+	client, err = container.NewClient(unsignedRef)
+	require.NoError(t, err)
+	client.SkipTLSVerify()
+	require.NoError(t, client.SetSignatureOptions(container.SignatureOptions{
+		PolicyPath:        opts.PolicyPath,
+		RegistriesDirPath: opts.RegistriesDirPath,
+	}))
+	sigs, err = client.VerifySignatures(t.Context(), digest.Digest(unsignedSpec.ListDigest), digest.Digest(unsignedSpec.Digest))
+	require.NoError(t, err)
+	assert.Empty(t, sigs)
+
+	// but rejected when the policy requires a signature
+	opts = writeSigstoreConfig(t, key, registryHost, unsignedRef)
+	require.NoError(t, client.SetSignatureOptions(opts))
+	_, err = client.VerifySignatures(t.Context(), digest.Digest(unsignedSpec.ListDigest), digest.Digest(unsignedSpec.Digest))
+	assert.ErrorContains(t, err, "signature policy rejects docker://"+unsignedRef+"@"+unsignedSpec.ListDigest)
+}
+
+func TestSetSignatureOptionsBadPolicy(t *testing.T) {
+	client, err := container.NewClient("registry.example.org/fedora")
+	require.NoError(t, err)
+
+	err = client.SetSignatureOptions(container.SignatureOptions{
+		PolicyPath: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	assert.ErrorContains(t, err, "cannot load signature policy: ")
+}
+
+func TestParseSignatureVerification(t *testing.T) {
+	for name, expected := range map[string]container.SignatureVerification{
+		"":        container.SignatureVerificationNone,
+		"none":    container.SignatureVerificationNone,
+		"warn":    container.SignatureVerificationWarn,
+		"enforce": container.SignatureVerificationEnforce,
+	} {
+		verification, err := container.ParseSignatureVerification(name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, verification)
+	}
+
+	_, err := container.ParseSignatureVerification("strict")
+	assert.EqualError(t, err, `unknown signature verification "strict", must be one of none, warn or enforce`)
+}
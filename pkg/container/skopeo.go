@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // Inspect a target ref, either local or remote, returning its manifest. Uses
@@ -32,3 +33,25 @@ func (cl *Client) skopeoInspect(target string) ([]byte, error) {
 	}
 	return stdout.Bytes(), nil
 }
+
+// skopeoCopy copies the image at src to dest. Uses 'skopeo'.
+var skopeoCopy = func(cl *Client, src, dest string) error {
+	cmd := exec.Command("skopeo", "copy", "--quiet")
+
+	if arch := cl.sysCtx.ArchitectureChoice; arch != "" {
+		cmd.Args = append(cmd.Args, fmt.Sprintf("--override-arch=%s", arch))
+	}
+	if variant := cl.sysCtx.VariantChoice; variant != "" {
+		cmd.Args = append(cmd.Args, fmt.Sprintf("--override-variant=%s", variant))
+	}
+
+	cmd.Args = append(cmd.Args, src, dest)
+
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command failed: %s: %w", strings.Join(cmd.Args, " "), err)
+	}
+	return nil
+}
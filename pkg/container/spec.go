@@ -18,6 +18,18 @@ type Spec struct {
 	ListDigest   string // digest of the list manifest at the Source (optional)
 	LocalStorage bool
 
+	// Transport is the transport of containers that are read from an
+	// archive or layout directory on the host (see ParseArchiveSource),
+	// Source is the path then. It is empty for containers from a
+	// registry or the local storage.
+	Transport string
+
+	// Signatures are the signatures of the container that were accepted
+	// by the signature policy, if the signatures were verified. They are
+	// only recorded, not copied into the image (osbuild cannot do that
+	// yet).
+	Signatures []Signature
+
 	Arch arch.Arch // the architecture of the image
 }
 
@@ -9,4 +9,8 @@ type Config struct {
 
 	// Extra kernel args to append
 	KernelArgs []string
+
+	// Block setups that are supported for installations, the first one
+	// is the default ("direct" or "tpm2-luks")
+	Block []string
 }
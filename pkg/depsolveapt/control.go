@@ -0,0 +1,64 @@
+package depsolveapt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// paragraph is a single deb822 control paragraph (e.g. one package entry in
+// a "Packages" index or the "Release" file). Field names are kept verbatim,
+// continuation lines are joined with "\n".
+type paragraph map[string]string
+
+// parseControl parses a deb822 formatted stream (as used by the "Packages"
+// and "Release" files of an APT repository) into a list of paragraphs.
+func parseControl(r io.Reader) ([]paragraph, error) {
+	var paragraphs []paragraph
+
+	scanner := bufio.NewScanner(r)
+	// Some fields (e.g. Description or the SHA256 list of a Release
+	// file) can be long so give the scanner more room than the default.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	current := paragraph{}
+	var lastKey string
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, current)
+				current = paragraph{}
+				lastKey = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if lastKey == "" {
+				return nil, fmt.Errorf("line %d: continuation line without a field", lineNo)
+			}
+			current[lastKey] += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("line %d: missing ':' in %q", lineNo, line)
+		}
+		lastKey = strings.TrimSpace(key)
+		current[lastKey] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, current)
+	}
+
+	return paragraphs, nil
+}
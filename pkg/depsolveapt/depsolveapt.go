@@ -0,0 +1,491 @@
+// Package depsolveapt resolves the dependencies of Debian (.deb) packages
+// against APT repositories. The core component of this package is the
+// Solver type which implements the depsolvednf.Depsolver interface so it can
+// be used anywhere the dnf based solver is used (e.g. in manifestgen).
+//
+// Repositories are configured with the regular rpmmd.RepoConfig type where
+// the baseurls point to the root of the APT archive (the directory that
+// contains "dists/" and "pool/"). The suite (e.g. "bookworm") and the
+// components (e.g. "main") are properties of the Solver. Package indices are
+// verified against the checksums in the "Release" file of the suite.
+//
+// The resolver is intentionally simple: it picks the highest version of every
+// requested package and follows the Pre-Depends and Depends (and optionally
+// Recommends) relationships without backtracking. Candidates that conflict
+// with (Conflicts or Breaks) or are broken by the already selected packages
+// are skipped in favour of the next alternative; if there is none the
+// depsolve fails instead of returning a transaction that cannot be
+// installed.
+package depsolveapt
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// SolverName is reported in the Solver field of the results
+const SolverName = "apt"
+
+// DebianArch returns the Debian architecture name for the given
+// architecture name (e.g. "amd64" for "x86_64").
+func DebianArch(archName string) string {
+	switch archName {
+	case "x86_64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "ppc64le":
+		return "ppc64el"
+	default:
+		return archName
+	}
+}
+
+// Solver resolves Debian package dependencies using APT repositories.
+type Solver struct {
+	suite      string
+	components []string
+	arch       string
+	distro     string
+	cacheDir   string
+
+	// loaded package indices, keyed by the repository hash
+	indices map[string][]*debPackage
+}
+
+var _ depsolvednf.Depsolver = (*Solver)(nil)
+
+// NewSolver creates a new Solver for the given suite (e.g. "bookworm") and
+// components (e.g. "main", "contrib") of the APT repositories. The arch is
+// the architecture name as used in the rest of the library (e.g. "x86_64"),
+// the distro is the full distribution name (e.g. "debian-12") and the
+// cacheDir is used to store verified package indices.
+func NewSolver(suite string, components []string, arch, distro, cacheDir string) *Solver {
+	if len(components) == 0 {
+		components = []string{"main"}
+	}
+	return &Solver{
+		suite:      suite,
+		components: components,
+		arch:       arch,
+		distro:     distro,
+		cacheDir:   cacheDir,
+		indices:    make(map[string][]*debPackage),
+	}
+}
+
+func (s *Solver) loadRepos(repos []rpmmd.RepoConfig) ([]*debPackage, error) {
+	var all []*debPackage
+	for idx := range repos {
+		repo := &repos[idx]
+		hash := repo.Hash()
+		pkgs, ok := s.indices[hash]
+		if !ok {
+			var err error
+			pkgs, err = loadRepo(repo, s.suite, s.components, DebianArch(s.arch), s.cacheDirFor(hash))
+			if err != nil {
+				return nil, err
+			}
+			s.indices[hash] = pkgs
+		}
+		all = append(all, pkgs...)
+	}
+	return all, nil
+}
+
+func (s *Solver) cacheDirFor(repoHash string) string {
+	if s.cacheDir == "" {
+		return ""
+	}
+	return path.Join(s.cacheDir, "apt", repoHash)
+}
+
+// universe is the set of packages available for a single depsolve
+// transaction chain, indexed by name and by provided (virtual) name.
+type universe struct {
+	byName     map[string][]*debPackage
+	providedBy map[string][]*debPackage
+	essential  []*debPackage
+}
+
+func newUniverse(pkgs []*debPackage, debArch string) *universe {
+	u := &universe{
+		byName:     make(map[string][]*debPackage),
+		providedBy: make(map[string][]*debPackage),
+	}
+	for _, pkg := range pkgs {
+		if pkg.Architecture != debArch && pkg.Architecture != "all" {
+			continue
+		}
+		u.byName[pkg.Name] = append(u.byName[pkg.Name], pkg)
+		for _, prov := range pkg.Provides {
+			u.providedBy[prov.Name] = append(u.providedBy[prov.Name], pkg)
+		}
+	}
+	for name, candidates := range u.byName {
+		// highest version first, the order of the repositories is
+		// used as the tie breaker (SortStableFunc)
+		slices.SortStableFunc(candidates, func(a, b *debPackage) int {
+			return compareVersions(b.Version, a.Version)
+		})
+		if candidates[0].Essential {
+			u.essential = append(u.essential, candidates[0])
+		}
+		u.byName[name] = candidates
+	}
+	slices.SortFunc(u.essential, func(a, b *debPackage) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return u
+}
+
+// transaction resolves a single package set on top of the already
+// installed packages.
+type transaction struct {
+	universe  *universe
+	installed map[string]*debPackage
+	excluded  []string
+	weakDeps  bool
+
+	added []*debPackage
+}
+
+func (t *transaction) isExcluded(name string) bool {
+	for _, pattern := range t.excluded {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfied returns true if any of the installed packages satisfies any of
+// the alternatives.
+func (t *transaction) satisfied(alternatives []relation) bool {
+	for _, rel := range alternatives {
+		if pkg, ok := t.installed[rel.Name]; ok && pkg.provides(rel) {
+			return true
+		}
+		for _, pkg := range t.universe.providedBy[rel.Name] {
+			if inst, ok := t.installed[pkg.Name]; ok && inst == pkg && pkg.provides(rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conflict returns an error if the package conflicts with or breaks one of
+// the installed packages, or is broken by one of them.
+func (t *transaction) conflict(pkg *debPackage) error {
+	names := make([]string, 0, len(t.installed))
+	for name := range t.installed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		inst := t.installed[name]
+		if kind, rel := pkg.conflictsWith(inst); rel != nil {
+			return fmt.Errorf("%s %s %s %s (%s %s is selected)", pkg.Name, pkg.Version, kind, rel, inst.Name, inst.Version)
+		}
+		if kind, rel := inst.conflictsWith(pkg); rel != nil {
+			return fmt.Errorf("%s %s (selected) %s %s, cannot select %s %s", inst.Name, inst.Version, kind, rel, pkg.Name, pkg.Version)
+		}
+	}
+	return nil
+}
+
+// candidate finds the best package that satisfies the given relation and
+// does not conflict with the installed packages. If all the packages that
+// satisfy the relation conflict, the first conflict is returned.
+func (t *transaction) candidate(rel relation) (*debPackage, error) {
+	var conflictErr error
+	usable := func(pkg *debPackage) bool {
+		if t.isExcluded(pkg.Name) || !pkg.provides(rel) {
+			return false
+		}
+		if err := t.conflict(pkg); err != nil {
+			if conflictErr == nil {
+				conflictErr = err
+			}
+			return false
+		}
+		return true
+	}
+
+	for _, pkg := range t.universe.byName[rel.Name] {
+		if usable(pkg) {
+			return pkg, nil
+		}
+	}
+	// virtual package, pick the first provider by name
+	providers := slices.Clone(t.universe.providedBy[rel.Name])
+	slices.SortStableFunc(providers, func(a, b *debPackage) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	for _, pkg := range providers {
+		if _, ok := t.installed[pkg.Name]; ok {
+			// a different version is already installed
+			continue
+		}
+		if usable(pkg) {
+			return pkg, nil
+		}
+	}
+	return nil, conflictErr
+}
+
+func (t *transaction) install(pkg *debPackage) error {
+	t.installed[pkg.Name] = pkg
+	t.added = append(t.added, pkg)
+
+	groups := slices.Concat(pkg.PreDepends, pkg.Depends)
+	for _, alternatives := range groups {
+		if err := t.require(alternatives); err != nil {
+			return fmt.Errorf("%w (required by %s)", err, pkg.Name)
+		}
+	}
+	if t.weakDeps {
+		for _, alternatives := range pkg.Recommends {
+			// recommends are best-effort
+			_ = t.require(alternatives)
+		}
+	}
+	return nil
+}
+
+func (t *transaction) require(alternatives []relation) error {
+	if t.satisfied(alternatives) {
+		return nil
+	}
+	var conflictErr error
+	for _, rel := range alternatives {
+		pkg, err := t.candidate(rel)
+		if pkg != nil {
+			if inst, ok := t.installed[pkg.Name]; ok && inst != pkg {
+				return fmt.Errorf("cannot satisfy %s, %s %s is already selected", rel, inst.Name, inst.Version)
+			}
+			return t.install(pkg)
+		}
+		if conflictErr == nil {
+			conflictErr = err
+		}
+	}
+	names := make([]string, 0, len(alternatives))
+	for _, rel := range alternatives {
+		names = append(names, rel.String())
+	}
+	if conflictErr != nil {
+		return fmt.Errorf("unresolvable dependency %q: %w", strings.Join(names, " | "), conflictErr)
+	}
+	return fmt.Errorf("unresolvable dependency %q", strings.Join(names, " | "))
+}
+
+// Depsolve the list of package sets, each package set is depsolved as a
+// separate transaction in a chain. For the first transaction all
+// "Essential: yes" packages are added, similar to what debootstrap does.
+func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	for idx, ps := range pkgSets {
+		if len(ps.Include) == 0 {
+			return nil, fmt.Errorf("packageSet %d has empty Include list", idx)
+		}
+		if len(ps.EnabledModules) > 0 {
+			return nil, fmt.Errorf("packageSet %d: modules are not supported for APT repositories", idx)
+		}
+	}
+
+	installed := make(map[string]*debPackage)
+	var transactions depsolvednf.TransactionList
+	usedRepos := make(map[string]bool)
+	var allPkgs []*debPackage
+	for idx, ps := range pkgSets {
+		available, err := s.loadRepos(ps.Repositories)
+		if err != nil {
+			return nil, err
+		}
+		trans := &transaction{
+			universe:  newUniverse(available, DebianArch(s.arch)),
+			installed: installed,
+			excluded:  ps.Exclude,
+			weakDeps:  ps.InstallWeakDeps,
+		}
+		include := slices.Clone(ps.Include)
+		if idx == 0 {
+			for _, pkg := range trans.universe.essential {
+				include = append(include, pkg.Name)
+			}
+		}
+		for _, name := range include {
+			rel, err := parseRelation(name)
+			if err != nil {
+				return nil, err
+			}
+			if err := trans.require([]relation{rel}); err != nil {
+				return nil, fmt.Errorf("error depsolving package set %d: %w", idx, err)
+			}
+		}
+
+		slices.SortFunc(trans.added, func(a, b *debPackage) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		pkgs := make(rpmmd.PackageList, 0, len(trans.added))
+		for _, pkg := range trans.added {
+			usedRepos[pkg.repo.Hash()] = true
+			pkgs = append(pkgs, pkg.toRPMMD())
+		}
+		allPkgs = append(allPkgs, trans.added...)
+		transactions = append(transactions, pkgs)
+	}
+
+	// only report the repositories that provided packages, the package
+	// Repo pointers need to point into this slice
+	var repos []rpmmd.RepoConfig
+	for _, repo := range collectRepos(pkgSets) {
+		if usedRepos[repo.Hash()] {
+			repos = append(repos, repo)
+		}
+	}
+	for _, pkgs := range transactions {
+		for i := range pkgs {
+			for r := range repos {
+				if repos[r].Hash() == pkgs[i].RepoID {
+					pkgs[i].Repo = &repos[r]
+				}
+			}
+		}
+	}
+
+	var sbomDoc *sbom.Document
+	if sbomType != sbom.StandardTypeNone {
+		var err error
+		sbomDoc, err = s.sbomDocument(sbomType, allPkgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &depsolvednf.DepsolveResult{
+		Transactions: transactions,
+		Repos:        repos,
+		SBOM:         sbomDoc,
+		Solver:       SolverName,
+	}, nil
+}
+
+// FetchMetadata returns the list of all the available packages in repos
+// for the solver architecture.
+func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	return s.search(repos, nil)
+}
+
+// SearchMetadata searches for packages (shell glob patterns are supported)
+// and returns a list of the info for matches.
+func (s *Solver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	return s.search(repos, packages)
+}
+
+func (s *Solver) search(repos []rpmmd.RepoConfig, patterns []string) (rpmmd.PackageList, error) {
+	available, err := s.loadRepos(repos)
+	if err != nil {
+		return nil, err
+	}
+	debArch := DebianArch(s.arch)
+
+	var res rpmmd.PackageList
+	for _, pkg := range available {
+		if pkg.Architecture != debArch && pkg.Architecture != "all" {
+			continue
+		}
+		if patterns != nil && !slices.ContainsFunc(patterns, func(pattern string) bool {
+			ok, _ := path.Match(pattern, pkg.Name)
+			return ok
+		}) {
+			continue
+		}
+		res = append(res, pkg.toRPMMD())
+	}
+	slices.SortFunc(res, func(a, b rpmmd.Package) int {
+		return cmp.Compare(a.NVR(), b.NVR())
+	})
+	return res, nil
+}
+
+// toRPMMD converts the package to the generic package representation, the
+// Debian upstream version and revision map to Version and Release.
+func (p *debPackage) toRPMMD() rpmmd.Package {
+	epoch, upstream, revision := splitVersion(p.Version)
+	summary, description, _ := strings.Cut(p.Description, "\n")
+
+	var requires rpmmd.RelDepList
+	for _, group := range slices.Concat(p.PreDepends, p.Depends) {
+		// only the first alternative is recorded
+		requires = append(requires, rpmmd.RelDep{
+			Name:         group[0].Name,
+			Relationship: group[0].Op,
+			Version:      group[0].Version,
+		})
+	}
+	var provides rpmmd.RelDepList
+	for _, prov := range p.Provides {
+		provides = append(provides, rpmmd.RelDep{
+			Name:         prov.Name,
+			Relationship: prov.Op,
+			Version:      prov.Version,
+		})
+	}
+
+	pkg := rpmmd.Package{
+		Name:            p.Name,
+		Epoch:           epoch,
+		Version:         upstream,
+		Release:         revision,
+		Arch:            p.Architecture,
+		DownloadSize:    p.Size,
+		InstallSize:     p.InstalledSize,
+		SourceRpm:       p.Source,
+		URL:             p.Homepage,
+		Summary:         summary,
+		Description:     description,
+		Provides:        provides,
+		Requires:        requires,
+		RegularRequires: requires,
+		Location:        p.Filename,
+		RemoteLocations: []string{p.URL()},
+		Checksum: rpmmd.Checksum{
+			Type:  "sha256",
+			Value: p.SHA256,
+		},
+		RepoID: p.repo.Hash(),
+		Repo:   p.repo,
+	}
+	if p.repo.IgnoreSSL != nil {
+		pkg.IgnoreSSL = *p.repo.IgnoreSSL
+	}
+	if p.repo.SSLClientKey != "" {
+		pkg.Secrets = "org.osbuild.mtls"
+	}
+	return pkg
+}
+
+// collectRepos extracts unique repos from package sets maintaining order
+func collectRepos(pkgSets []rpmmd.PackageSet) []rpmmd.RepoConfig {
+	seen := make(map[string]bool)
+	var repos []rpmmd.RepoConfig
+	for _, ps := range pkgSets {
+		for _, repo := range ps.Repositories {
+			id := repo.Hash()
+			if !seen[id] {
+				seen[id] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+	return repos
+}
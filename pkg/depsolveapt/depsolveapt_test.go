@@ -0,0 +1,422 @@
+package depsolveapt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+const testPackagesIndex = `Package: base-files
+Version: 12.4+deb12u5
+Architecture: amd64
+Essential: yes
+Priority: required
+Filename: pool/main/b/base-files/base-files_12.4+deb12u5_amd64.deb
+Size: 70000
+Installed-Size: 340
+SHA256: 1111111111111111111111111111111111111111111111111111111111111111
+Description: Debian base system miscellaneous files
+
+Package: libc6
+Version: 2.36-9+deb12u4
+Architecture: amd64
+Source: glibc
+Filename: pool/main/g/glibc/libc6_2.36-9+deb12u4_amd64.deb
+Size: 2756000
+Installed-Size: 12987
+SHA256: 2222222222222222222222222222222222222222222222222222222222222222
+Description: GNU C Library: Shared libraries
+ Contains the standard libraries that are used by nearly all programs on
+ the system.
+
+Package: libc6
+Version: 2.36-9
+Architecture: amd64
+Source: glibc
+Filename: pool/main/g/glibc/libc6_2.36-9_amd64.deb
+Size: 2756000
+Installed-Size: 12987
+SHA256: 3333333333333333333333333333333333333333333333333333333333333333
+Description: GNU C Library: Shared libraries
+
+Package: bash
+Version: 5.2.15-2+b2
+Architecture: amd64
+Essential: yes
+Pre-Depends: libc6 (>= 2.36), libtinfo6 (>= 6)
+Depends: base-files (>= 2.1.12), debianutils (>= 5.6-0.1)
+Recommends: bash-completion (>= 20060301-0)
+Filename: pool/main/b/bash/bash_5.2.15-2+b2_amd64.deb
+Size: 1490000
+Installed-Size: 7160
+SHA256: 4444444444444444444444444444444444444444444444444444444444444444
+Description: GNU Bourne Again SHell
+
+Package: libtinfo6
+Version: 6.4-4
+Architecture: amd64
+Source: ncurses
+Depends: libc6 (>= 2.34)
+Filename: pool/main/n/ncurses/libtinfo6_6.4-4_amd64.deb
+SHA256: 5555555555555555555555555555555555555555555555555555555555555555
+Description: shared low-level terminfo library for terminal handling
+
+Package: debianutils
+Version: 5.7-0.5~deb12u1
+Architecture: amd64
+Depends: libc6 (>= 2.34)
+Filename: pool/main/d/debianutils/debianutils_5.7-0.5~deb12u1_amd64.deb
+SHA256: 6666666666666666666666666666666666666666666666666666666666666666
+Description: Miscellaneous utilities specific to Debian
+
+Package: bash-completion
+Version: 1:2.11-6
+Architecture: all
+Filename: pool/main/b/bash-completion/bash-completion_2.11-6_all.deb
+SHA256: 7777777777777777777777777777777777777777777777777777777777777777
+Description: programmable completion for the bash shell
+
+Package: openssh-server
+Version: 1:9.2p1-2+deb12u3
+Architecture: amd64
+Depends: libc6 (>= 2.36), default-logind | logind
+Filename: pool/main/o/openssh/openssh-server_9.2p1-2+deb12u3_amd64.deb
+SHA256: 8888888888888888888888888888888888888888888888888888888888888888
+Description: secure shell (SSH) server, for secure access from remote machines
+
+Package: systemd
+Version: 252.30-1~deb12u2
+Architecture: amd64
+Depends: libc6 (>= 2.36)
+Provides: default-logind (= 252.30-1~deb12u2), logind (= 252.30-1~deb12u2)
+Filename: pool/main/s/systemd/systemd_252.30-1~deb12u2_amd64.deb
+SHA256: 9999999999999999999999999999999999999999999999999999999999999999
+Description: system and service manager
+
+Package: arm-only
+Version: 1.0
+Architecture: arm64
+Filename: pool/main/a/arm-only/arm-only_1.0_arm64.deb
+SHA256: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+Description: not available on amd64
+
+Package: broken
+Version: 1.0
+Architecture: amd64
+Depends: does-not-exist
+Filename: pool/main/b/broken/broken_1.0_amd64.deb
+SHA256: bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb
+Description: has a missing dependency
+`
+
+// makeTestRepo creates an APT repository with a single "main" component
+// for amd64 in a temporary directory and returns its file:// URL.
+func makeTestRepo(t *testing.T, index string) string {
+	root := t.TempDir()
+	binDir := filepath.Join(root, "dists", "bookworm", "main", "binary-amd64")
+	require.NoError(t, os.MkdirAll(binDir, 0o755))
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(index))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "Packages.gz"), buf.Bytes(), 0o644))
+
+	sum := sha256.Sum256(buf.Bytes())
+	release := fmt.Sprintf(`Origin: Debian
+Suite: stable
+Codename: bookworm
+Architectures: amd64
+Components: main
+SHA256:
+ %s %d main/binary-amd64/Packages.gz
+`, hex.EncodeToString(sum[:]), buf.Len())
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dists", "bookworm", "Release"), []byte(release), 0o644))
+
+	return "file://" + root
+}
+
+func testRepoConfig(baseURL string) rpmmd.RepoConfig {
+	return rpmmd.RepoConfig{
+		Name:     "debian",
+		BaseURLs: []string{baseURL},
+	}
+}
+
+func packageNames(pkgs rpmmd.PackageList) []string {
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	return names
+}
+
+func TestSolverDepsolve(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testPackagesIndex))
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", t.TempDir())
+
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"bash"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+		{
+			Include:      []string{"openssh-server"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+
+	assert.Equal(t, SolverName, res.Solver)
+	require.Len(t, res.Transactions, 2)
+	assert.Equal(t, []string{"base-files", "bash", "debianutils", "libc6", "libtinfo6"}, packageNames(res.Transactions[0]))
+	// libc6 is already installed, logind is provided by systemd
+	assert.Equal(t, []string{"openssh-server", "systemd"}, packageNames(res.Transactions[1]))
+	assert.Nil(t, res.SBOM)
+
+	libc, err := res.Transactions[0].Package("libc6")
+	require.NoError(t, err)
+	assert.Equal(t, "2.36", libc.Version)
+	assert.Equal(t, "9+deb12u4", libc.Release)
+	assert.Equal(t, "amd64", libc.Arch)
+	assert.Equal(t, "glibc", libc.SourceRpm)
+	assert.Equal(t, "GNU C Library: Shared libraries", libc.Summary)
+	assert.Equal(t, uint64(12987*1024), libc.InstallSize)
+	assert.Equal(t, "sha256:2222222222222222222222222222222222222222222222222222222222222222", libc.Checksum.String())
+	assert.Equal(t, []string{repo.BaseURLs[0] + "/pool/main/g/glibc/libc6_2.36-9+deb12u4_amd64.deb"}, libc.RemoteLocations)
+	require.Len(t, res.Repos, 1)
+	assert.Equal(t, res.Repos[0].Hash(), libc.RepoID)
+	assert.Same(t, &res.Repos[0], libc.Repo)
+}
+
+func TestSolverDepsolveWeakDeps(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testPackagesIndex))
+	solver := NewSolver("bookworm", []string{"main"}, "x86_64", "debian-12", "")
+
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:         []string{"bash"},
+			Repositories:    []rpmmd.RepoConfig{repo},
+			InstallWeakDeps: true,
+		},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+	pkg, err := res.Transactions[0].Package("bash-completion")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), pkg.Epoch)
+	assert.Equal(t, "all", pkg.Arch)
+}
+
+func TestSolverDepsolveErrors(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testPackagesIndex))
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", "")
+
+	for _, tc := range []struct {
+		name        string
+		pkgSet      rpmmd.PackageSet
+		expectedErr string
+	}{
+		{
+			name:        "missing-dep",
+			pkgSet:      rpmmd.PackageSet{Include: []string{"broken"}},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "does-not-exist" (required by broken)`,
+		},
+		{
+			name:        "wrong-arch",
+			pkgSet:      rpmmd.PackageSet{Include: []string{"arm-only"}},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "arm-only"`,
+		},
+		{
+			name:        "excluded",
+			pkgSet:      rpmmd.PackageSet{Include: []string{"bash"}, Exclude: []string{"debian*"}},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "debianutils (>= 5.6-0.1)" (required by bash)`,
+		},
+		{
+			name:        "modules",
+			pkgSet:      rpmmd.PackageSet{Include: []string{"bash"}, EnabledModules: []string{"foo"}},
+			expectedErr: `packageSet 0: modules are not supported for APT repositories`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.pkgSet.Repositories = []rpmmd.RepoConfig{repo}
+			_, err := solver.Depsolve([]rpmmd.PackageSet{tc.pkgSet}, sbom.StandardTypeNone)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+// testConflictsIndex adds packages with Conflicts and Breaks to the
+// test packages
+const testConflictsIndex = testPackagesIndex + `
+Package: exim4
+Version: 4.96-15
+Architecture: amd64
+Provides: mail-transport-agent
+Conflicts: mail-transport-agent
+Filename: pool/main/e/exim4/exim4_4.96-15_amd64.deb
+SHA256: cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc
+Description: metapackage to ease Exim MTA installation
+
+Package: postfix
+Version: 3.7.11-0+deb12u1
+Architecture: amd64
+Provides: mail-transport-agent
+Conflicts: mail-transport-agent
+Filename: pool/main/p/postfix/postfix_3.7.11-0+deb12u1_amd64.deb
+SHA256: dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd
+Description: High-performance mail transport agent
+
+Package: mailer
+Version: 1.0
+Architecture: amd64
+Depends: old-mta | postfix
+Filename: pool/main/m/mailer/mailer_1.0_amd64.deb
+SHA256: eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee
+Description: needs any mail transport agent
+
+Package: old-mta
+Version: 1.0
+Architecture: amd64
+Breaks: libc6 (>= 2.36)
+Filename: pool/main/o/old-mta/old-mta_1.0_amd64.deb
+SHA256: ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff
+Description: breaks the current libc6
+`
+
+func TestSolverDepsolveConflicts(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testConflictsIndex))
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", "")
+
+	// the alternative that breaks libc6 is skipped, a package that
+	// conflicts with a virtual package it provides can be installed
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"bash"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+		{
+			Include:      []string{"mailer"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mailer", "postfix"}, packageNames(res.Transactions[1]))
+
+	for _, tc := range []struct {
+		name        string
+		include     []string
+		expectedErr string
+	}{
+		{
+			name:        "conflicts",
+			include:     []string{"exim4", "postfix"},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "postfix": postfix 3.7.11-0+deb12u1 conflicts with mail-transport-agent (exim4 4.96-15 is selected)`,
+		},
+		{
+			name:        "breaks",
+			include:     []string{"bash", "old-mta"},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "old-mta": old-mta 1.0 breaks libc6 (>= 2.36) (libc6 2.36-9+deb12u4 is selected)`,
+		},
+		{
+			// the essential packages are added after the requested ones
+			name:        "broken-by-selected",
+			include:     []string{"old-mta"},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "libc6 (>= 2.36)": old-mta 1.0 (selected) breaks libc6 (>= 2.36), cannot select libc6 2.36-9+deb12u4 (required by bash)`,
+		},
+		{
+			name:        "no-alternative",
+			include:     []string{"bash", "exim4", "mailer"},
+			expectedErr: `error depsolving package set 0: unresolvable dependency "old-mta | postfix": old-mta 1.0 breaks libc6 (>= 2.36) (libc6 2.36-9+deb12u4 is selected) (required by mailer)`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := solver.Depsolve([]rpmmd.PackageSet{
+				{
+					Include:      tc.include,
+					Repositories: []rpmmd.RepoConfig{repo},
+				},
+			}, sbom.StandardTypeNone)
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestSolverDepsolveSBOM(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testPackagesIndex))
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", "")
+
+	res, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"libtinfo6"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+	}, sbom.StandardTypeSpdx)
+	require.NoError(t, err)
+	require.NotNil(t, res.SBOM)
+	assert.Equal(t, sbom.StandardTypeSpdx, res.SBOM.DocType)
+
+	var doc spdxDocument
+	require.NoError(t, json.Unmarshal(res.SBOM.Document, &doc))
+	assert.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+	var purls []string
+	for _, pkg := range doc.Packages {
+		purls = append(purls, pkg.ExternalRefs[0].ReferenceLocator)
+	}
+	assert.Contains(t, purls, "pkg:deb/debian/libtinfo6@6.4-4?arch=amd64")
+}
+
+func TestSolverChecksumMismatch(t *testing.T) {
+	baseURL := makeTestRepo(t, testPackagesIndex)
+	indexPath := filepath.Join(strings.TrimPrefix(baseURL, "file://"), "dists", "bookworm", "main", "binary-amd64", "Packages.gz")
+	require.NoError(t, os.WriteFile(indexPath, []byte("tampered"), 0o644))
+
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", "")
+	_, err := solver.FetchMetadata([]rpmmd.RepoConfig{testRepoConfig(baseURL)})
+	assert.ErrorContains(t, err, "checksum mismatch for "+baseURL+"/dists/bookworm/main/binary-amd64/Packages.gz")
+}
+
+func TestSolverIndexCache(t *testing.T) {
+	baseURL := makeTestRepo(t, testPackagesIndex)
+	cacheDir := t.TempDir()
+
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", cacheDir)
+	pkgs, err := solver.FetchMetadata([]rpmmd.RepoConfig{testRepoConfig(baseURL)})
+	require.NoError(t, err)
+
+	// remove the index from the repo, a new solver must use the cache
+	indexPath := filepath.Join(strings.TrimPrefix(baseURL, "file://"), "dists", "bookworm", "main", "binary-amd64", "Packages.gz")
+	require.NoError(t, os.Remove(indexPath))
+	solver = NewSolver("bookworm", nil, "x86_64", "debian-12", cacheDir)
+	cachedPkgs, err := solver.FetchMetadata([]rpmmd.RepoConfig{testRepoConfig(baseURL)})
+	require.NoError(t, err)
+	assert.Equal(t, pkgs, cachedPkgs)
+}
+
+func TestSolverFetchAndSearchMetadata(t *testing.T) {
+	repo := testRepoConfig(makeTestRepo(t, testPackagesIndex))
+	solver := NewSolver("bookworm", nil, "x86_64", "debian-12", "")
+
+	pkgs, err := solver.FetchMetadata([]rpmmd.RepoConfig{repo})
+	require.NoError(t, err)
+	// all versions but only for the solver architecture
+	assert.Len(t, pkgs, 10)
+	assert.NotContains(t, packageNames(pkgs), "arm-only")
+
+	pkgs, err = solver.SearchMetadata([]rpmmd.RepoConfig{repo}, []string{"bash*", "libc6"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "bash-completion", "libc6", "libc6"}, packageNames(pkgs))
+}
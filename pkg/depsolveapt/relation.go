@@ -0,0 +1,102 @@
+package depsolveapt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relation is a single (possibly versioned) package relationship, e.g.
+// "libc6 (>= 2.36)".
+type relation struct {
+	Name    string
+	Op      string
+	Version string
+}
+
+func (r relation) String() string {
+	if r.Op == "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%s (%s %s)", r.Name, r.Op, r.Version)
+}
+
+// satisfiedBy returns true if the given version satisfies the version
+// constraint of the relation.
+func (r relation) satisfiedBy(version string) bool {
+	if r.Op == "" {
+		return true
+	}
+	c := compareVersions(version, r.Version)
+	switch r.Op {
+	case "<<":
+		return c < 0
+	case "<=", "<":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=", ">":
+		return c >= 0
+	case ">>":
+		return c > 0
+	}
+	return false
+}
+
+// parseRelations parses a relationship field (e.g. "Depends") into a list
+// of alternatives groups, all groups must be satisfied and each group is
+// satisfied by any of its alternatives. Architecture qualifiers (":any")
+// and restriction lists ("[amd64]", "<!nocheck>") are ignored.
+func parseRelations(field string) ([][]relation, error) {
+	var groups [][]relation
+	for _, group := range strings.Split(field, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		var alternatives []relation
+		for _, alt := range strings.Split(group, "|") {
+			rel, err := parseRelation(alt)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, rel)
+		}
+		groups = append(groups, alternatives)
+	}
+	return groups, nil
+}
+
+func parseRelation(s string) (relation, error) {
+	s = strings.TrimSpace(s)
+	// drop architecture restrictions and build profiles
+	if idx := strings.IndexAny(s, "[<"); idx >= 0 && !strings.Contains(s[:idx], "(") {
+		s = strings.TrimSpace(s[:idx])
+	}
+
+	var rel relation
+	name := s
+	if idx := strings.Index(s, "("); idx >= 0 {
+		end := strings.Index(s, ")")
+		if end < idx {
+			return relation{}, fmt.Errorf("cannot parse relation %q: missing ')'", s)
+		}
+		name = strings.TrimSpace(s[:idx])
+		constraint := strings.TrimSpace(s[idx+1 : end])
+		opEnd := strings.IndexFunc(constraint, func(r rune) bool {
+			return !strings.ContainsRune("<>=", r)
+		})
+		if opEnd <= 0 {
+			return relation{}, fmt.Errorf("cannot parse relation %q: invalid version constraint", s)
+		}
+		rel.Op = constraint[:opEnd]
+		rel.Version = strings.TrimSpace(constraint[opEnd:])
+	}
+	if n, _, found := strings.Cut(name, ":"); found {
+		name = n
+	}
+	if name == "" {
+		return relation{}, fmt.Errorf("cannot parse relation %q: empty package name", s)
+	}
+	rel.Name = name
+	return rel, nil
+}
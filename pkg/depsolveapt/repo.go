@@ -0,0 +1,393 @@
+package depsolveapt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+
+	"github.com/osbuild/images/internal/fetch"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// debPackage is a single binary package entry from a "Packages" index.
+type debPackage struct {
+	Name          string
+	Version       string
+	Architecture  string
+	Source        string
+	Essential     bool
+	Priority      string
+	Filename      string
+	Size          uint64
+	InstalledSize uint64
+	SHA256        string
+	Description   string
+	Homepage      string
+
+	PreDepends [][]relation
+	Depends    [][]relation
+	Recommends [][]relation
+	Provides   []relation
+	Conflicts  []relation
+	Breaks     []relation
+
+	// repo is the (deduplicated) repository the package comes from
+	repo *rpmmd.RepoConfig
+	// baseURL of the repository the package was found in
+	baseURL string
+}
+
+func newDebPackage(p paragraph, repo *rpmmd.RepoConfig, baseURL string) (*debPackage, error) {
+	pkg := &debPackage{
+		Name:         p["Package"],
+		Version:      p["Version"],
+		Architecture: p["Architecture"],
+		Source:       p["Source"],
+		Essential:    p["Essential"] == "yes",
+		Priority:     p["Priority"],
+		Filename:     p["Filename"],
+		SHA256:       p["SHA256"],
+		Description:  p["Description"],
+		Homepage:     p["Homepage"],
+		repo:         repo,
+		baseURL:      baseURL,
+	}
+	if pkg.Name == "" || pkg.Version == "" || pkg.Filename == "" {
+		return nil, fmt.Errorf("incomplete package entry %q in %s", pkg.Name, baseURL)
+	}
+	if pkg.SHA256 == "" {
+		return nil, fmt.Errorf("package %s (%s) in %s has no SHA256 checksum", pkg.Name, pkg.Version, baseURL)
+	}
+	if s := p["Size"]; s != "" {
+		size, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q for package %s: %w", s, pkg.Name, err)
+		}
+		pkg.Size = size
+	}
+	if s := p["Installed-Size"]; s != "" {
+		// Installed-Size is given in KiB
+		size, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid installed size %q for package %s: %w", s, pkg.Name, err)
+		}
+		pkg.InstalledSize = size * 1024
+	}
+
+	var err error
+	if pkg.PreDepends, err = parseRelations(p["Pre-Depends"]); err != nil {
+		return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	if pkg.Depends, err = parseRelations(p["Depends"]); err != nil {
+		return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	if pkg.Recommends, err = parseRelations(p["Recommends"]); err != nil {
+		return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+	}
+	// these fields have no alternatives, only a list of relations
+	for _, field := range []struct {
+		name      string
+		relations *[]relation
+	}{
+		{"Provides", &pkg.Provides},
+		{"Conflicts", &pkg.Conflicts},
+		{"Breaks", &pkg.Breaks},
+	} {
+		groups, err := parseRelations(p[field.name])
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %w", pkg.Name, err)
+		}
+		for _, group := range groups {
+			*field.relations = append(*field.relations, group...)
+		}
+	}
+
+	return pkg, nil
+}
+
+// URL returns the full download URL of the package.
+func (p *debPackage) URL() string {
+	return strings.TrimSuffix(p.baseURL, "/") + "/" + strings.TrimPrefix(p.Filename, "/")
+}
+
+// provides returns true if the package satisfies the given relation either
+// directly or via one of its "Provides".
+func (p *debPackage) provides(rel relation) bool {
+	if p.Name == rel.Name && rel.satisfiedBy(p.Version) {
+		return true
+	}
+	for _, prov := range p.Provides {
+		if prov.Name != rel.Name {
+			continue
+		}
+		// unversioned provides only satisfy unversioned relations
+		if rel.Op == "" {
+			return true
+		}
+		if prov.Op == "=" && rel.satisfiedBy(prov.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// conflictsWith returns the relation of the Conflicts or Breaks of the
+// package that the other package satisfies, if any. A package that
+// conflicts with a virtual package it provides itself does not conflict
+// with itself.
+func (p *debPackage) conflictsWith(other *debPackage) (string, *relation) {
+	if p.Name == other.Name {
+		return "", nil
+	}
+	for _, rel := range p.Conflicts {
+		if other.provides(rel) {
+			return "conflicts with", &rel
+		}
+	}
+	for _, rel := range p.Breaks {
+		if other.provides(rel) {
+			return "breaks", &rel
+		}
+	}
+	return "", nil
+}
+
+// releaseFile is the parsed "dists/<suite>/Release" file of a repository
+type releaseFile struct {
+	// sha256 maps the index paths (relative to dists/<suite>) to their
+	// checksums
+	sha256 map[string]string
+}
+
+func parseRelease(data []byte) (*releaseFile, error) {
+	paragraphs, err := parseControl(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(paragraphs) == 0 {
+		return nil, fmt.Errorf("empty Release file")
+	}
+	rel := &releaseFile{
+		sha256: make(map[string]string),
+	}
+	for _, line := range strings.Split(paragraphs[0]["SHA256"], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		rel.sha256[fields[2]] = fields[0]
+	}
+	return rel, nil
+}
+
+// newFetcher returns a fetcher for the files of the repository that
+// honours its TLS options.
+func newFetcher(repo rpmmd.RepoConfig) (*fetch.Fetcher, error) {
+	return fetch.New(fetch.TLSOptions{
+		InsecureSkipVerify: repo.IgnoreSSL != nil && *repo.IgnoreSSL,
+		CACert:             repo.SSLCACert,
+		ClientCert:         repo.SSLClientCert,
+		ClientKey:          repo.SSLClientKey,
+	})
+}
+
+// indexCandidates are the "Packages" index variants that are tried, in
+// order.
+var indexCandidates = []string{"Packages.gz", "Packages"}
+
+// loadRepo fetches the (verified) Release file and the "Packages" indices of all
+// components for the given architecture from the first reachable baseurl
+// of the repository. Verified indices are stored in cacheDir keyed by their
+// checksum so that unchanged indices are not downloaded again.
+func loadRepo(repo *rpmmd.RepoConfig, suite string, components []string, debArch, cacheDir string) ([]*debPackage, error) {
+	if len(repo.BaseURLs) == 0 {
+		return nil, fmt.Errorf("repository %q has no baseurl, metalink and mirrorlist are not supported for APT repositories", repo.Name)
+	}
+	f, err := newFetcher(*repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, baseURL := range repo.BaseURLs {
+		pkgs, err := loadRepoFrom(f, repo, baseURL, suite, components, debArch, cacheDir)
+		if err == nil {
+			return pkgs, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("cannot load repository %q: %s", repo.Name, strings.Join(errs, "; "))
+}
+
+func loadRepoFrom(f *fetch.Fetcher, repo *rpmmd.RepoConfig, baseURL, suite string, components []string, debArch, cacheDir string) ([]*debPackage, error) {
+	distURL := strings.TrimSuffix(baseURL, "/") + "/dists/" + suite
+	releaseData, err := loadRelease(f, repo, distURL)
+	if err != nil {
+		return nil, err
+	}
+	release, err := parseRelease(releaseData)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse Release file of %s: %w", distURL, err)
+	}
+
+	var pkgs []*debPackage
+	for _, component := range components {
+		data, err := loadIndex(f, release, distURL, fmt.Sprintf("%s/binary-%s", component, debArch), cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		paragraphs, err := parseControl(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse index of %s/%s: %w", distURL, component, err)
+		}
+		for _, p := range paragraphs {
+			pkg, err := newDebPackage(p, repo, baseURL)
+			if err != nil {
+				return nil, err
+			}
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// loadRelease returns the content of the Release file in distURL. When the
+// repository has check_gpg set, the signature of the InRelease file (or the
+// detached Release.gpg signature of the Release file) is verified against
+// the GPG keys of the repository and a missing signature is an error.
+func loadRelease(f *fetch.Fetcher, repo *rpmmd.RepoConfig, distURL string) ([]byte, error) {
+	if repo.CheckGPG == nil || !*repo.CheckGPG {
+		return f.Fetch(distURL + "/Release")
+	}
+
+	keyring, err := loadGPGKeys(f, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	inRelease, inReleaseErr := f.Fetch(distURL + "/InRelease")
+	if inReleaseErr == nil {
+		block, _ := clearsign.Decode(inRelease)
+		if block == nil {
+			return nil, fmt.Errorf("%s/InRelease is not signed", distURL)
+		}
+		if _, err := block.VerifySignature(keyring, nil); err != nil {
+			return nil, fmt.Errorf("cannot verify the signature of %s/InRelease: %w", distURL, err)
+		}
+		return block.Plaintext, nil
+	}
+
+	release, err := f.Fetch(distURL + "/Release")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := f.Fetch(distURL + "/Release.gpg")
+	if err != nil {
+		return nil, fmt.Errorf("repository %q has check_gpg set but %s has neither an InRelease file nor a Release.gpg signature: %w", repo.Name, distURL, errors.Join(inReleaseErr, err))
+	}
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(release), bytes.NewReader(signature), nil); err != nil {
+		return nil, fmt.Errorf("cannot verify the signature of %s/Release: %w", distURL, err)
+	}
+	return release, nil
+}
+
+// loadGPGKeys reads the GPG keys of the repository into a keyring. The keys
+// are either given inline (ASCII-armored) or as URLs to fetch them from.
+func loadGPGKeys(f *fetch.Fetcher, repo *rpmmd.RepoConfig) (openpgp.EntityList, error) {
+	if len(repo.GPGKeys) == 0 {
+		return nil, fmt.Errorf("repository %q has check_gpg set but no gpg keys", repo.Name)
+	}
+	var keyring openpgp.EntityList
+	for _, key := range repo.GPGKeys {
+		data := []byte(key)
+		if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+			var err error
+			data, err = f.Fetch(key)
+			if err != nil {
+				return nil, fmt.Errorf("cannot fetch gpg key %q of repository %q: %w", key, repo.Name, err)
+			}
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gpg key in repository %q: %w", repo.Name, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// loadIndex returns the uncompressed content of the "Packages" index in the
+// given directory after verifying its checksum against the Release file.
+func loadIndex(f *fetch.Fetcher, release *releaseFile, distURL, dir, cacheDir string) ([]byte, error) {
+	for _, name := range indexCandidates {
+		relPath := dir + "/" + name
+		checksum, ok := release.sha256[relPath]
+		if !ok {
+			continue
+		}
+
+		var cachePath string
+		if cacheDir != "" {
+			cachePath = filepath.Join(cacheDir, "by-hash", "SHA256", checksum)
+		}
+		data, err := readCached(cachePath, checksum)
+		if err != nil {
+			data, err = f.Fetch(distURL + "/" + relPath)
+			if err != nil {
+				return nil, err
+			}
+			if sha256Hex(data) != checksum {
+				return nil, fmt.Errorf("checksum mismatch for %s/%s", distURL, relPath)
+			}
+			if cachePath != "" {
+				// the cache is only an optimization, ignore errors
+				if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+					_ = os.WriteFile(cachePath, data, 0o644)
+				}
+			}
+		}
+
+		if strings.HasSuffix(name, ".gz") {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("cannot decompress %s/%s: %w", distURL, relPath, err)
+			}
+			defer zr.Close()
+			return io.ReadAll(zr)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no package index for %s found in %s/Release", dir, distURL)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readCached returns the content of the cached file at path if it exists
+// and matches the given checksum.
+func readCached(path, checksum string) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if sha256Hex(data) != checksum {
+		return nil, fmt.Errorf("cached file %s is corrupted", path)
+	}
+	return data, nil
+}
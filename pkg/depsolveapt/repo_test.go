@@ -0,0 +1,148 @@
+package depsolveapt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+// newTestKey returns a new signing key and its ASCII-armored public key
+func newTestKey(t *testing.T) (*openpgp.Entity, string) {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	require.NoError(t, err)
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return entity, armored.String()
+}
+
+// signTestRepo writes the InRelease file or the Release.gpg signature (if
+// inRelease is false) for the Release file of the test repository
+func signTestRepo(t *testing.T, baseURL string, entity *openpgp.Entity, inRelease bool) {
+	distDir := filepath.Join(strings.TrimPrefix(baseURL, "file://"), "dists", "bookworm")
+	release, err := os.ReadFile(filepath.Join(distDir, "Release"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	if inRelease {
+		w, err := clearsign.Encode(&buf, entity.PrivateKey, nil)
+		require.NoError(t, err)
+		_, err = w.Write(release)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, os.WriteFile(filepath.Join(distDir, "InRelease"), buf.Bytes(), 0o644))
+		// the plain Release file must not be used
+		require.NoError(t, os.Remove(filepath.Join(distDir, "Release")))
+		return
+	}
+	require.NoError(t, openpgp.ArmoredDetachSign(&buf, entity, bytes.NewReader(release), nil))
+	require.NoError(t, os.WriteFile(filepath.Join(distDir, "Release.gpg"), buf.Bytes(), 0o644))
+}
+
+func TestLoadRepoSignature(t *testing.T) {
+	entity, pubKey := newTestKey(t)
+	_, otherPubKey := newTestKey(t)
+
+	for _, tc := range []struct {
+		name      string
+		inRelease bool
+		sign      bool
+		checkGPG  bool
+		keys      []string
+		expErr    string
+	}{
+		{name: "inrelease", inRelease: true, sign: true, checkGPG: true, keys: []string{pubKey}},
+		{name: "release-gpg", sign: true, checkGPG: true, keys: []string{otherPubKey, pubKey}},
+		{name: "no-check-gpg", checkGPG: false},
+		{
+			name:     "unsigned",
+			checkGPG: true,
+			keys:     []string{pubKey},
+			expErr:   `repository "debian" has check_gpg set but file://`,
+		},
+		{
+			name:      "inrelease-unknown-key",
+			inRelease: true,
+			sign:      true,
+			checkGPG:  true,
+			keys:      []string{otherPubKey},
+			expErr:    "cannot verify the signature of file://",
+		},
+		{
+			name:     "release-gpg-unknown-key",
+			sign:     true,
+			checkGPG: true,
+			keys:     []string{otherPubKey},
+			expErr:   "cannot verify the signature of file://",
+		},
+		{
+			name:     "no-keys",
+			sign:     true,
+			checkGPG: true,
+			expErr:   `repository "debian" has check_gpg set but no gpg keys`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			baseURL := makeTestRepo(t, testPackagesIndex)
+			if tc.sign {
+				signTestRepo(t, baseURL, entity, tc.inRelease)
+			}
+			repo := testRepoConfig(baseURL)
+			repo.CheckGPG = common.ToPtr(tc.checkGPG)
+			repo.GPGKeys = tc.keys
+
+			pkgs, err := loadRepo(&repo, "bookworm", []string{"main"}, "amd64", "")
+			if tc.expErr != "" {
+				assert.ErrorContains(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, pkgs, 11)
+		})
+	}
+}
+
+func TestLoadRepoTamperedInRelease(t *testing.T) {
+	entity, pubKey := newTestKey(t)
+	baseURL := makeTestRepo(t, testPackagesIndex)
+	signTestRepo(t, baseURL, entity, true)
+
+	inReleasePath := filepath.Join(strings.TrimPrefix(baseURL, "file://"), "dists", "bookworm", "InRelease")
+	inRelease, err := os.ReadFile(inReleasePath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(inReleasePath, bytes.Replace(inRelease, []byte("Codename: bookworm"), []byte("Codename: trixie"), 1), 0o644))
+
+	repo := testRepoConfig(baseURL)
+	repo.CheckGPG = common.ToPtr(true)
+	repo.GPGKeys = []string{pubKey}
+	_, err = loadRepo(&repo, "bookworm", []string{"main"}, "amd64", "")
+	assert.ErrorContains(t, err, "cannot verify the signature of "+baseURL+"/dists/bookworm/InRelease")
+}
+
+func TestLoadRepoGPGKeyURL(t *testing.T) {
+	entity, pubKey := newTestKey(t)
+	baseURL := makeTestRepo(t, testPackagesIndex)
+	signTestRepo(t, baseURL, entity, true)
+
+	keyPath := filepath.Join(t.TempDir(), "key.asc")
+	require.NoError(t, os.WriteFile(keyPath, []byte(pubKey), 0o644))
+
+	repo := testRepoConfig(baseURL)
+	repo.CheckGPG = common.ToPtr(true)
+	repo.GPGKeys = []string{"file://" + keyPath}
+	_, err := loadRepo(&repo, "bookworm", []string{"main"}, "amd64", "")
+	assert.NoError(t, err)
+}
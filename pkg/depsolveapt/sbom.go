@@ -0,0 +1,110 @@
+package depsolveapt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	Summary          string            `json:"summary,omitempty"`
+	Homepage         string            `json:"homepage,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Packages          []spdxPackage    `json:"packages"`
+}
+
+// purl returns the package URL of the package, see
+// https://github.com/package-url/purl-spec/blob/main/PURL-TYPES.rst#deb
+func (s *Solver) purl(pkg *debPackage) string {
+	vendor, _, _ := strings.Cut(s.distro, "-")
+	if vendor == "" {
+		vendor = "debian"
+	}
+	return fmt.Sprintf("pkg:deb/%s/%s@%s?arch=%s", vendor, pkg.Name, url.PathEscape(pkg.Version), pkg.Architecture)
+}
+
+// sbomDocument creates a SBOM document of the given type for the packages,
+// unlike osbuild-depsolve-dnf there is no external tool that generates it
+// so it is created from the package index data.
+func (s *Solver) sbomDocument(sbomType sbom.StandardType, pkgs []*debPackage) (*sbom.Document, error) {
+	if sbomType != sbom.StandardTypeSpdx {
+		return nil, fmt.Errorf("unsupported SBOM document type: %s", sbomType)
+	}
+
+	var checksums []string
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        "sbom-by-osbuild-images",
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: osbuild-images"},
+		},
+		Packages: make([]spdxPackage, 0, len(pkgs)),
+	}
+	for _, pkg := range pkgs {
+		summary, _, _ := strings.Cut(pkg.Description, "\n")
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           "SPDXRef-" + pkg.SHA256,
+			Name:             pkg.Name,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: pkg.URL(),
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA256", ChecksumValue: pkg.SHA256},
+			},
+			Summary:  summary,
+			Homepage: pkg.Homepage,
+			ExternalRefs: []spdxExternalRef{
+				{
+					ReferenceCategory: "PACKAGE-MANAGER",
+					ReferenceType:     "purl",
+					ReferenceLocator:  s.purl(pkg),
+				},
+			},
+		})
+		checksums = append(checksums, pkg.SHA256)
+	}
+	doc.DocumentNamespace = "https://osbuild.org/spdxdocs/sbom-by-osbuild-images-" + sha256Hex([]byte(strings.Join(checksums, "")))
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return sbom.NewDocument(sbomType, raw)
+}
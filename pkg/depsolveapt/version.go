@@ -0,0 +1,111 @@
+package depsolveapt
+
+import (
+	"strconv"
+	"strings"
+)
+
+// splitVersion splits a Debian version string of the form
+// [epoch:]upstream_version[-debian_revision] into its components.
+func splitVersion(v string) (epoch uint, upstream, revision string) {
+	upstream = v
+	if e, rest, found := strings.Cut(v, ":"); found {
+		if n, err := strconv.ParseUint(e, 10, 32); err == nil {
+			epoch = uint(n)
+			upstream = rest
+		}
+	}
+	if idx := strings.LastIndex(upstream, "-"); idx >= 0 {
+		revision = upstream[idx+1:]
+		upstream = upstream[:idx]
+	}
+	return epoch, upstream, revision
+}
+
+// compareVersions compares two Debian version strings following the
+// algorithm described in the Debian policy manual (section 5.6.12). It
+// returns a negative number if a < b, zero if a == b and a positive number
+// if a > b.
+func compareVersions(a, b string) int {
+	ea, ua, ra := splitVersion(a)
+	eb, ub, rb := splitVersion(b)
+	if ea != eb {
+		if ea < eb {
+			return -1
+		}
+		return 1
+	}
+	if c := compareFragment(ua, ub); c != 0 {
+		return c
+	}
+	return compareFragment(ra, rb)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// order returns the sort weight of a non-digit character, "~" sorts before
+// everything (even the end of the string) and letters sort before
+// non-letters.
+func order(s string, idx int) int {
+	if idx >= len(s) {
+		return 0
+	}
+	c := s[idx]
+	switch {
+	case isDigit(c):
+		return 0
+	case isLetter(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+// compareFragment compares either the upstream version or the debian
+// revision part of two versions.
+func compareFragment(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac := order(a, i)
+			bc := order(b, j)
+			if ac != bc {
+				return ac - bc
+			}
+			i++
+			j++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package depsolveapt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1-1", "1.0~rc2-1", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0+b1", "1.0", 1},
+		{"1.0+b1", "1.0a", 1},
+		{"2.36-9+deb12u4", "2.36-9+deb12u10", -1},
+		{"001", "1", 0},
+	} {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			c := compareVersions(tc.a, tc.b)
+			switch {
+			case tc.expected < 0:
+				assert.Less(t, c, 0)
+			case tc.expected > 0:
+				assert.Greater(t, c, 0)
+			default:
+				assert.Equal(t, 0, c)
+			}
+		})
+	}
+}
+
+func TestSplitVersion(t *testing.T) {
+	epoch, upstream, revision := splitVersion("1:2.3.4-5+deb12u1")
+	assert.Equal(t, uint(1), epoch)
+	assert.Equal(t, "2.3.4", upstream)
+	assert.Equal(t, "5+deb12u1", revision)
+
+	epoch, upstream, revision = splitVersion("2.3")
+	assert.Equal(t, uint(0), epoch)
+	assert.Equal(t, "2.3", upstream)
+	assert.Equal(t, "", revision)
+}
+
+func TestParseRelations(t *testing.T) {
+	groups, err := parseRelations("libc6 (>= 2.34), debconf (>= 0.5) | debconf-2.0, python3:any, foo [amd64] <!nocheck>")
+	require.NoError(t, err)
+	assert.Equal(t, [][]relation{
+		{{Name: "libc6", Op: ">=", Version: "2.34"}},
+		{{Name: "debconf", Op: ">=", Version: "0.5"}, {Name: "debconf-2.0"}},
+		{{Name: "python3"}},
+		{{Name: "foo"}},
+	}, groups)
+
+	_, err = parseRelations("libc6 (2.34)")
+	assert.EqualError(t, err, `cannot parse relation "libc6 (2.34)": invalid version constraint`)
+}
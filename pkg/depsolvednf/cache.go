@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,6 +34,10 @@ func CleanupOldCacheDirs(root string, distros []string) {
 			// known distro
 			continue
 		}
+		if strings.HasPrefix(e.Name(), ".") {
+			// internal directory of the cache, e.g. the lock files
+			continue
+		}
 		if e.IsDir() {
 			// Remove the directory and everything under it
 			_ = os.RemoveAll(filepath.Join(root, e.Name()))
@@ -52,9 +57,22 @@ func strSliceContains(slc []string, elem string) bool {
 	return false
 }
 
-// global cache locker
+// global cache state, shared by all rpmCache instances of the same directory
 var cacheLocks sync.Map
 
+// cacheState is the state of a cache directory that is shared within the
+// process
+type cacheState struct {
+	// locker for this cache directory
+	locker *sync.RWMutex
+
+	// inter-process locks of the repositories
+	repoLocks *repoLocks
+
+	// usage statistics
+	counters *cacheCounters
+}
+
 // A collection of directory paths, their total size, and their most recent
 // modification time.
 type pathInfo struct {
@@ -81,6 +99,16 @@ type rpmCache struct {
 
 	// locker for this cache directory
 	locker *sync.RWMutex
+
+	// inter-process locks of the repositories
+	repoLocks *repoLocks
+
+	// usage statistics
+	counters *cacheCounters
+
+	// protects the cache info fields above, updateInfo() is called
+	// concurrently by all users of the cache
+	infoMu sync.Mutex
 }
 
 func newRPMCache(path string, maxSize uint64) *rpmCache {
@@ -89,17 +117,23 @@ func newRPMCache(path string, maxSize uint64) *rpmCache {
 		panic(err) // can only happen if the CWD does not exist and the path isn't already absolute
 	}
 	path = absPath
-	locker := new(sync.RWMutex)
-	if l, loaded := cacheLocks.LoadOrStore(path, locker); loaded {
+	state := &cacheState{
+		locker:    new(sync.RWMutex),
+		repoLocks: newRepoLocks(path),
+		counters:  new(cacheCounters),
+	}
+	if s, loaded := cacheLocks.LoadOrStore(path, state); loaded {
 		// value existed and was loaded
-		locker = l.(*sync.RWMutex)
+		state = s.(*cacheState)
 	}
 	r := &rpmCache{
 		root:         path,
 		repoElements: make(map[string]pathInfo),
 		size:         0,
 		maxSize:      maxSize,
-		locker:       locker,
+		locker:       state.locker,
+		repoLocks:    state.repoLocks,
+		counters:     state.counters,
 	}
 	// collect existing cache paths and timestamps
 	r.updateInfo()
@@ -113,6 +147,9 @@ func newRPMCache(path string, maxSize uint64) *rpmCache {
 // cache creation. Any other errors like permission issues will be caught by
 // later use of the cache. eg. touchRepo
 func (r *rpmCache) updateInfo() {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+
 	// reset rpmCache fields used for accumulation
 	r.size = 0
 	r.repoElements = make(map[string]pathInfo)
@@ -122,6 +159,10 @@ func (r *rpmCache) updateInfo() {
 
 	dirs, _ := os.ReadDir(r.root)
 	for _, d := range dirs {
+		if strings.HasPrefix(d.Name(), ".") {
+			// internal directory of the cache, e.g. the lock files
+			continue
+		}
 		path := filepath.Join(r.root, d.Name())
 
 		// See updateInfo NOTE on error handling
@@ -198,31 +239,100 @@ func (r *rpmCache) shrink() error {
 	r.locker.Lock()
 	defer r.locker.Unlock()
 
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+
 	// start deleting until we drop below r.maxSize
-	nDeleted := 0
-	for idx := 0; idx < len(r.repoRecency) && r.size >= r.maxSize; idx++ {
-		repoID := r.repoRecency[idx]
-		nDeleted++
+	var remaining []string
+	for idx, repoID := range r.repoRecency {
+		if r.size < r.maxSize {
+			remaining = append(remaining, r.repoRecency[idx:]...)
+			break
+		}
 		repo, ok := r.repoElements[repoID]
 		if !ok {
 			// cache inconsistency?
 			// ignore and let the ID be removed from the recency list
 			continue
 		}
+		// never remove a repository that is in use by another process
+		unlock, locked, err := r.repoLocks.tryLockExclusive(repoID)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			r.counters.skipped.Add(1)
+			remaining = append(remaining, repoID)
+			continue
+		}
 		for _, gPath := range repo.paths {
 			if err := os.RemoveAll(gPath); err != nil {
+				unlock()
 				return err
 			}
 		}
+		unlock()
 		r.size -= repo.size
 		delete(r.repoElements, repoID)
 	}
 
 	// update recency list
-	r.repoRecency = r.repoRecency[nDeleted:]
+	r.repoRecency = remaining
 	return nil
 }
 
+// isCached returns true if there are cache entries for the repo ID
+func (r *rpmCache) isCached(repoID string) bool {
+	matches, _ := filepath.Glob(filepath.Join(r.root, "*", repoID+"*"))
+	for _, match := range matches {
+		rel, _ := filepath.Rel(r.root, match)
+		if !strings.HasPrefix(rel, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// useRepos marks the repositories as in use until the returned function is
+// called so that they are not removed from the cache by this or any other
+// process. It also updates the hit and miss counters of the cache.
+func (r *rpmCache) useRepos(repos []rpmmd.RepoConfig) (func(), error) {
+	repoIDs := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		repoID := repo.Hash()
+		if slices.Contains(repoIDs, repoID) {
+			continue
+		}
+		repoIDs = append(repoIDs, repoID)
+	}
+	release, err := r.repoLocks.acquire(repoIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, repoID := range repoIDs {
+		if r.isCached(repoID) {
+			r.counters.hits.Add(1)
+		} else {
+			r.counters.misses.Add(1)
+		}
+	}
+	return release, nil
+}
+
+func (r *rpmCache) stats() CacheStats {
+	r.infoMu.Lock()
+	defer r.infoMu.Unlock()
+	return CacheStats{
+		Hits:         r.counters.hits.Load(),
+		Misses:       r.counters.misses.Load(),
+		ResultHits:   r.counters.resultHits.Load(),
+		ResultMisses: r.counters.resultMisses.Load(),
+		Bytes:        r.size,
+		Repos:        len(r.repoElements),
+		Skipped:      r.counters.skipped.Load(),
+	}
+}
+
 // Update file atime and mtime on the filesystem to time t for all files in the
 // root of the cache that match the repo ID.  This should be called whenever a
 // repository is used.
@@ -239,6 +349,10 @@ func (r *rpmCache) touchRepo(repoID string, t time.Time) error {
 		return err
 	}
 	for _, d := range distroDirs {
+		if strings.HasPrefix(d.Name(), ".") {
+			// internal directory of the cache, e.g. the lock files
+			continue
+		}
 		// we only touch the top-level directories and files of the cache
 		cacheEntries, err := os.ReadDir(filepath.Join(r.root, d.Name()))
 		if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/osbuild/images/pkg/rpmmd"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
 )
 
 func truncate(path string, size int64) {
@@ -414,3 +415,85 @@ func TestCleanupOldCacheDirs(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestCacheCleanupSkipsReposInUse(t *testing.T) {
+	testCacheRoot := t.TempDir()
+	createTestCache(filepath.Join(testCacheRoot, "fake-real"), testCfgs["fake-real"])
+	cache := newRPMCache(testCacheRoot, 1)
+
+	// repo 3 is in use by this process, repo 1 by another one
+	inUse := "3333333333333333333333333333333333333333333333333333333333333333"
+	release, err := cache.repoLocks.acquire([]string{inUse})
+	assert.NoError(t, err)
+	otherProc := "1111111111111111111111111111111111111111111111111111111111111111"
+	f, err := os.Create(filepath.Join(testCacheRoot, lockDirName, otherProc+".lock"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, unix.Flock(int(f.Fd()), unix.LOCK_SH))
+
+	assert.NoError(t, cache.shrink())
+	assert.ElementsMatch(t, []string{inUse, otherProc}, cache.repoRecency)
+	assert.Equal(t, uint64(2), cache.stats().Skipped)
+	_, err = os.Stat(filepath.Join(testCacheRoot, "fake-real", inUse+".solv"))
+	assert.NoError(t, err)
+
+	// the lock directory is not part of the cache
+	cache.updateInfo()
+	assert.Equal(t, 2, len(cache.repoElements))
+
+	// once released, the repos can be removed
+	release()
+	f.Close()
+	assert.NoError(t, cache.shrink())
+	assert.Empty(t, cache.repoRecency)
+	_, err = os.Stat(filepath.Join(testCacheRoot, lockDirName))
+	assert.NoError(t, err)
+}
+
+func TestRepoLocksRefcount(t *testing.T) {
+	locks := newRepoLocks(t.TempDir())
+	release1, err := locks.acquire([]string{"repo1", "repo2", "repo1"})
+	assert.NoError(t, err)
+	release2, err := locks.acquire([]string{"repo1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, locks.refs("repo1"))
+	assert.Equal(t, 1, locks.refs("repo2"))
+
+	release1()
+	assert.Equal(t, 1, locks.refs("repo1"))
+	assert.Equal(t, 0, locks.refs("repo2"))
+	_, ok, err := locks.tryLockExclusive("repo1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	release2()
+	unlock, ok, err := locks.tryLockExclusive("repo1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	unlock()
+}
+
+func TestCacheStats(t *testing.T) {
+	testCacheRoot := t.TempDir()
+	size := createTestCache(filepath.Join(testCacheRoot, "fake-real"), testCfgs["fake-real"])
+	cache := newRPMCache(testCacheRoot, 1048576)
+
+	cached := rpmmd.RepoConfig{Id: "cached", BaseURLs: []string{"https://example.com/cached"}}
+	// put a cache entry for the repo in place
+	assert.NoError(t, os.WriteFile(filepath.Join(testCacheRoot, "fake-real", cached.Hash()+".solv"), []byte("solv"), 0o644))
+	missing := rpmmd.RepoConfig{Id: "missing", BaseURLs: []string{"https://example.com/missing"}}
+
+	release, err := cache.useRepos([]rpmmd.RepoConfig{cached, missing, cached})
+	assert.NoError(t, err)
+	release()
+	cache.updateInfo()
+
+	stats := cache.stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, size+4, stats.Bytes)
+	assert.Equal(t, 4, stats.Repos)
+
+	// the counters are shared by all caches of the same directory
+	assert.Equal(t, stats.Hits, newRPMCache(testCacheRoot, 1).stats().Hits)
+}
@@ -0,0 +1,167 @@
+package depsolvednf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockDirName is the directory in the cache root that contains the lock
+// files of the repositories. It starts with a dot so that it is never
+// mistaken for a distro cache directory.
+const lockDirName = ".locks"
+
+// repoLocks implements the inter-process locking of the repository caches
+// so that a cache directory can be shared by several processes (e.g. on a
+// build farm node). Every depsolve holds a shared flock(2) on the lock file
+// of each repository it uses and the cache cleanup only removes a
+// repository after getting an exclusive lock on it.
+//
+// Within a process the locks are reference counted: concurrent depsolves
+// that use the same repository share a single lock.
+type repoLocks struct {
+	dir string
+
+	mu   sync.Mutex
+	held map[string]*heldRepoLock
+}
+
+type heldRepoLock struct {
+	f    *os.File
+	refs int
+}
+
+func newRepoLocks(root string) *repoLocks {
+	return &repoLocks{
+		dir:  filepath.Join(root, lockDirName),
+		held: make(map[string]*heldRepoLock),
+	}
+}
+
+func (l *repoLocks) openLockFile(repoID string) (*os.File, error) {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create cache lock directory: %w", err)
+	}
+	// lock files are never removed, another process might be waiting
+	// for a lock on the file
+	return os.OpenFile(filepath.Join(l.dir, repoID+".lock"), os.O_RDWR|os.O_CREATE, 0o644)
+}
+
+// acquire takes a shared lock on each of the given repositories and returns
+// a function that releases them again.
+func (l *repoLocks) acquire(repoIDs []string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var acquired []string
+	for _, repoID := range repoIDs {
+		if slices.Contains(acquired, repoID) {
+			continue
+		}
+		lock, ok := l.held[repoID]
+		if !ok {
+			f, err := l.openLockFile(repoID)
+			if err != nil {
+				l.releaseLocked(acquired)
+				return nil, err
+			}
+			if err := unix.Flock(int(f.Fd()), unix.LOCK_SH); err != nil {
+				f.Close()
+				l.releaseLocked(acquired)
+				return nil, fmt.Errorf("cannot lock repository cache %s: %w", repoID, err)
+			}
+			lock = &heldRepoLock{f: f}
+			l.held[repoID] = lock
+		}
+		lock.refs++
+		acquired = append(acquired, repoID)
+	}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.releaseLocked(acquired)
+	}, nil
+}
+
+func (l *repoLocks) releaseLocked(repoIDs []string) {
+	for _, repoID := range repoIDs {
+		lock, ok := l.held[repoID]
+		if !ok {
+			continue
+		}
+		lock.refs--
+		if lock.refs == 0 {
+			// closing the file releases the lock
+			lock.f.Close()
+			delete(l.held, repoID)
+		}
+	}
+}
+
+// refs returns the number of depsolves in this process that currently use
+// the repository
+func (l *repoLocks) refs(repoID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.held[repoID]; ok {
+		return lock.refs
+	}
+	return 0
+}
+
+// tryLockExclusive tries to get an exclusive lock on the repository without
+// blocking. It returns false if the repository is in use by this or
+// another process.
+func (l *repoLocks) tryLockExclusive(repoID string) (func(), bool, error) {
+	if l.refs(repoID) > 0 {
+		return nil, false, nil
+	}
+	f, err := l.openLockFile(repoID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		f.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("cannot lock repository cache %s: %w", repoID, err)
+	}
+	return func() { f.Close() }, true, nil
+}
+
+// CacheStats contains statistics about the use of the repository metadata
+// cache of a BaseSolver (and all other solvers in the process that share
+// the same cache directory).
+type CacheStats struct {
+	// Hits and Misses count the repositories that were (or were not)
+	// already in the cache when they were used.
+	Hits   uint64
+	Misses uint64
+	// ResultHits and ResultMisses count the dump and search requests that
+	// were (or were not) answered from the in-memory result cache.
+	ResultHits   uint64
+	ResultMisses uint64
+	// Bytes is the current size of the cache and Repos the number of
+	// cached repositories (as of the last update of the cache info).
+	Bytes uint64
+	Repos int
+	// Skipped counts the repositories that were not removed by the cache
+	// cleanup because they were in use.
+	Skipped uint64
+}
+
+// cacheCounters are the counters of the CacheStats
+type cacheCounters struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	resultHits   atomic.Uint64
+	resultMisses atomic.Uint64
+	skipped      atomic.Uint64
+}
@@ -0,0 +1,268 @@
+package depsolvednf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+const cycloneDXSpecVersion = "1.5"
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	Name string `json:"name"`
+}
+
+type cdxLicenseChoice struct {
+	License cdxLicense `json:"license"`
+}
+
+type cdxExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxOrganization struct {
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	Type               string                 `json:"type"`
+	BOMRef             string                 `json:"bom-ref"`
+	Supplier           *cdxOrganization       `json:"supplier,omitempty"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version"`
+	Description        string                 `json:"description,omitempty"`
+	Hashes             []cdxHash              `json:"hashes,omitempty"`
+	Licenses           []cdxLicenseChoice     `json:"licenses,omitempty"`
+	PURL               string                 `json:"purl"`
+	ExternalReferences []cdxExternalReference `json:"externalReferences,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     cdxTools `json:"tools"`
+}
+
+type cdxDocument struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+// cdxHashAlgs maps the rpm checksum types to the CycloneDX hash algorithms
+var cdxHashAlgs = map[string]string{
+	"md5":    "MD5",
+	"sha1":   "SHA-1",
+	"sha256": "SHA-256",
+	"sha384": "SHA-384",
+	"sha512": "SHA-512",
+}
+
+// CycloneDX component types of the packages, see
+// https://cyclonedx.org/docs/1.5/json/#components_items_type
+const (
+	cdxTypeApplication     = "application"
+	cdxTypeLibrary         = "library"
+	cdxTypeOperatingSystem = "operating-system"
+	cdxTypeFirmware        = "firmware"
+)
+
+// cdxExecutableDirs are the directories of executables, a package that
+// ships files in them is an application
+var cdxExecutableDirs = []string{
+	"/bin/",
+	"/sbin/",
+	"/usr/bin/",
+	"/usr/sbin/",
+	"/usr/libexec/",
+}
+
+// cdxComponentType returns the CycloneDX component type of the package:
+// the release package of the distribution and the kernel are the
+// "operating-system", firmware packages are "firmware", packages with
+// executables are an "application" and everything else is a "library".
+// The files of the package metadata only include the executables, so this
+// works with the files of the primary metadata.
+func cdxComponentType(pkg rpmmd.Package) string {
+	for _, prov := range pkg.Provides {
+		switch prov.Name {
+		case "system-release", "kernel-uname-r":
+			return cdxTypeOperatingSystem
+		}
+	}
+	switch {
+	case pkg.Name == "kernel" || pkg.Name == "kernel-core":
+		return cdxTypeOperatingSystem
+	case strings.HasSuffix(pkg.Name, "-firmware") || strings.HasPrefix(pkg.Name, "linux-firmware"):
+		return cdxTypeFirmware
+	}
+	for _, file := range pkg.Files {
+		for _, dir := range cdxExecutableDirs {
+			if strings.HasPrefix(file, dir) {
+				return cdxTypeApplication
+			}
+		}
+	}
+	return cdxTypeLibrary
+}
+
+// purlNamespace returns the purl namespace (the vendor) for the given
+// distribution name, e.g. "fedora" for "fedora-42"
+func purlNamespace(distro string) string {
+	vendor, _, _ := strings.Cut(distro, "-")
+	switch vendor {
+	case "rhel":
+		return "redhat"
+	case "":
+		return "rpm"
+	}
+	return vendor
+}
+
+// rpmPURL returns the package URL of the package, see
+// https://github.com/package-url/purl-spec/blob/main/PURL-TYPES.rst#rpm
+func rpmPURL(pkg rpmmd.Package, distro string) string {
+	qualifiers := url.Values{}
+	qualifiers.Set("arch", pkg.Arch)
+	if pkg.Epoch != 0 {
+		qualifiers.Set("epoch", fmt.Sprintf("%d", pkg.Epoch))
+	}
+	if distro != "" {
+		qualifiers.Set("distro", distro)
+	}
+	// url.Values.Encode() sorts the qualifiers by key as required by
+	// the purl spec
+	return fmt.Sprintf("pkg:rpm/%s/%s@%s-%s?%s", purlNamespace(distro), url.PathEscape(pkg.Name), url.PathEscape(pkg.Version), url.PathEscape(pkg.Release), qualifiers.Encode())
+}
+
+func cdxComponentFromPackage(pkg rpmmd.Package, distro string) cdxComponent {
+	ref := rpmPURL(pkg, distro)
+	comp := cdxComponent{
+		Type:        cdxComponentType(pkg),
+		BOMRef:      ref,
+		Name:        pkg.Name,
+		Version:     fmt.Sprintf("%s-%s", pkg.Version, pkg.Release),
+		Description: pkg.Summary,
+		PURL:        ref,
+	}
+	if pkg.Vendor != "" {
+		comp.Supplier = &cdxOrganization{Name: pkg.Vendor}
+	}
+	if alg, ok := cdxHashAlgs[strings.ToLower(pkg.Checksum.Type)]; ok && pkg.Checksum.Value != "" {
+		comp.Hashes = append(comp.Hashes, cdxHash{Alg: alg, Content: pkg.Checksum.Value})
+	}
+	if pkg.License != "" {
+		// rpm license tags are only SPDX expressions for newer
+		// distributions, so they are always recorded as names
+		comp.Licenses = append(comp.Licenses, cdxLicenseChoice{License: cdxLicense{Name: pkg.License}})
+	}
+	if pkg.URL != "" {
+		comp.ExternalReferences = append(comp.ExternalReferences, cdxExternalReference{Type: "website", URL: pkg.URL})
+	}
+	for _, loc := range pkg.RemoteLocations {
+		comp.ExternalReferences = append(comp.ExternalReferences, cdxExternalReference{Type: "distribution", URL: loc})
+	}
+	return comp
+}
+
+// CycloneDXDocument creates a CycloneDX SBOM document for the packages of
+// the given transactions. osbuild-depsolve-dnf can only generate SPDX
+// documents so it is created from the package metadata of the depsolve
+// result. The dependency relationships are the edges of the
+// [DependencyGraph] of the transactions. The distro (e.g. "fedora-42") is
+// used for the package URLs.
+func CycloneDXDocument(transactions TransactionList, distro string) (*sbom.Document, error) {
+	pkgs := transactions.AllPackages()
+
+	doc := cdxDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: cycloneDXSpecVersion,
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{
+					{Type: cdxTypeApplication, Name: "osbuild-images"},
+				},
+			},
+		},
+		Components: make([]cdxComponent, 0, len(pkgs)),
+	}
+
+	refs := make(map[string]string, len(pkgs))
+	var checksums []string
+	for _, pkg := range pkgs {
+		comp := cdxComponentFromPackage(pkg, distro)
+		if _, ok := refs[pkg.Name]; !ok {
+			refs[pkg.Name] = comp.BOMRef
+		}
+		doc.Components = append(doc.Components, comp)
+		checksums = append(checksums, pkg.Checksum.Value)
+	}
+	// the serial number is derived from the packages so that the same
+	// set of packages always results in the same serial number
+	doc.SerialNumber = "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(strings.Join(checksums, ""))).String()
+
+	doc.Dependencies = cdxDependencies(doc.Components, NewDependencyGraph(nil, transactions), refs)
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return sbom.NewDocument(sbom.StandardTypeCycloneDX, raw)
+}
+
+// cdxDependencies returns the dependency relationships of the components
+// from the edges of the dependency graph, refs maps the package names to
+// the references of the components.
+func cdxDependencies(components []cdxComponent, graph *DependencyGraph, refs map[string]string) []cdxDependency {
+	dependsOn := make(map[string][]string, len(components))
+	for _, edge := range graph.Edges {
+		to, ok := refs[edge.To]
+		if !ok || slices.Contains(dependsOn[edge.From], to) {
+			continue
+		}
+		dependsOn[edge.From] = append(dependsOn[edge.From], to)
+	}
+	dependencies := make([]cdxDependency, 0, len(components))
+	for _, comp := range components {
+		deps := dependsOn[comp.Name]
+		if refs[comp.Name] != comp.BOMRef {
+			// only the first package with a given name is
+			// referenced by the dependency graph
+			deps = nil
+		}
+		slices.Sort(deps)
+		if deps == nil {
+			deps = []string{}
+		}
+		dependencies = append(dependencies, cdxDependency{Ref: comp.BOMRef, DependsOn: deps})
+	}
+	return dependencies
+}
@@ -0,0 +1,131 @@
+package depsolvednf
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+func TestRPMPURL(t *testing.T) {
+	pkg := rpmmd.Package{Name: "curl", Epoch: 1, Version: "8.9.1", Release: "2.fc42", Arch: "x86_64"}
+	assert.Equal(t, "pkg:rpm/fedora/curl@8.9.1-2.fc42?arch=x86_64&distro=fedora-42&epoch=1", rpmPURL(pkg, "fedora-42"))
+	assert.Equal(t, "pkg:rpm/redhat/curl@8.9.1-2.fc42?arch=x86_64&distro=rhel-10.0&epoch=1", rpmPURL(pkg, "rhel-10.0"))
+
+	pkg.Epoch = 0
+	assert.Equal(t, "pkg:rpm/rpm/curl@8.9.1-2.fc42?arch=x86_64", rpmPURL(pkg, ""))
+}
+
+func TestCDXComponentType(t *testing.T) {
+	for _, tc := range []struct {
+		pkg      rpmmd.Package
+		expected string
+	}{
+		{rpmmd.Package{Name: "fedora-release-common", Provides: rpmmd.RelDepList{{Name: "system-release"}}}, "operating-system"},
+		{rpmmd.Package{Name: "kernel-core", Provides: rpmmd.RelDepList{{Name: "kernel-uname-r"}}}, "operating-system"},
+		{rpmmd.Package{Name: "kernel"}, "operating-system"},
+		{rpmmd.Package{Name: "linux-firmware-whence"}, "firmware"},
+		{rpmmd.Package{Name: "amd-gpu-firmware"}, "firmware"},
+		{rpmmd.Package{Name: "coreutils", Files: []string{"/usr/bin/ls"}}, "application"},
+		{rpmmd.Package{Name: "dbus-daemon", Files: []string{"/usr/libexec/dbus-daemon-launch-helper"}}, "application"},
+		{rpmmd.Package{Name: "kernel-headers", Files: []string{"/usr/include/linux/types.h"}}, "library"},
+		{rpmmd.Package{Name: "openssl-libs"}, "library"},
+	} {
+		assert.Equal(t, tc.expected, cdxComponentType(tc.pkg), tc.pkg.Name)
+	}
+}
+
+func TestCycloneDXDocument(t *testing.T) {
+	transactions := TransactionList{
+		{
+			{
+				Name:     "bash",
+				Version:  "5.2.37",
+				Release:  "1.fc42",
+				Arch:     "x86_64",
+				License:  "GPL-3.0-or-later",
+				Summary:  "The GNU Bourne Again shell",
+				URL:      "https://www.gnu.org/software/bash",
+				Vendor:   "Fedora Project",
+				Checksum: rpmmd.Checksum{Type: "sha256", Value: "aaaa"},
+				Requires: rpmmd.RelDepList{{Name: "libc.so.6()(64bit)"}},
+				Files:    []string{"/bin/sh"},
+			},
+			{
+				Name:     "glibc",
+				Version:  "2.41",
+				Release:  "3.fc42",
+				Arch:     "x86_64",
+				Checksum: rpmmd.Checksum{Type: "sha512", Value: "bbbb"},
+				Provides: rpmmd.RelDepList{{Name: "libc.so.6()(64bit)"}},
+				RemoteLocations: []string{
+					"https://example.com/glibc-2.41-3.fc42.x86_64.rpm",
+				},
+			},
+		},
+		{
+			{
+				Name:     "vim-minimal",
+				Epoch:    2,
+				Version:  "9.1",
+				Release:  "1.fc42",
+				Arch:     "x86_64",
+				Checksum: rpmmd.Checksum{Type: "sha256", Value: "cccc"},
+				Requires: rpmmd.RelDepList{{Name: "/bin/sh"}, {Name: "libc.so.6()(64bit)"}},
+			},
+		},
+	}
+
+	doc, err := CycloneDXDocument(transactions, "fedora-42")
+	require.NoError(t, err)
+	assert.Equal(t, sbom.StandardTypeCycloneDX, doc.DocType)
+
+	var cdx cdxDocument
+	require.NoError(t, json.Unmarshal(doc.Document, &cdx))
+	assert.Equal(t, "CycloneDX", cdx.BOMFormat)
+	assert.Equal(t, "1.5", cdx.SpecVersion)
+	assert.Regexp(t, "^urn:uuid:[0-9a-f-]{36}$", cdx.SerialNumber)
+
+	bashRef := "pkg:rpm/fedora/bash@5.2.37-1.fc42?arch=x86_64&distro=fedora-42"
+	glibcRef := "pkg:rpm/fedora/glibc@2.41-3.fc42?arch=x86_64&distro=fedora-42"
+	vimRef := "pkg:rpm/fedora/vim-minimal@9.1-1.fc42?arch=x86_64&distro=fedora-42&epoch=2"
+
+	require.Len(t, cdx.Components, 3)
+	assert.Equal(t, cdxComponent{
+		Type:        "application",
+		BOMRef:      bashRef,
+		Supplier:    &cdxOrganization{Name: "Fedora Project"},
+		Name:        "bash",
+		Version:     "5.2.37-1.fc42",
+		Description: "The GNU Bourne Again shell",
+		Hashes:      []cdxHash{{Alg: "SHA-256", Content: "aaaa"}},
+		Licenses:    []cdxLicenseChoice{{License: cdxLicense{Name: "GPL-3.0-or-later"}}},
+		PURL:        bashRef,
+		ExternalReferences: []cdxExternalReference{
+			{Type: "website", URL: "https://www.gnu.org/software/bash"},
+		},
+	}, cdx.Components[0])
+	assert.Equal(t, "library", cdx.Components[1].Type)
+	assert.Equal(t, []cdxHash{{Alg: "SHA-512", Content: "bbbb"}}, cdx.Components[1].Hashes)
+	assert.Equal(t, []cdxExternalReference{
+		{Type: "distribution", URL: "https://example.com/glibc-2.41-3.fc42.x86_64.rpm"},
+	}, cdx.Components[1].ExternalReferences)
+	assert.Equal(t, vimRef, cdx.Components[2].PURL)
+
+	assert.Equal(t, []cdxDependency{
+		{Ref: bashRef, DependsOn: []string{glibcRef}},
+		{Ref: glibcRef, DependsOn: []string{}},
+		{Ref: vimRef, DependsOn: []string{bashRef, glibcRef}},
+	}, cdx.Dependencies)
+
+	// the serial number only depends on the packages
+	doc2, err := CycloneDXDocument(transactions, "fedora-42")
+	require.NoError(t, err)
+	var cdx2 cdxDocument
+	require.NoError(t, json.Unmarshal(doc2.Document, &cdx2))
+	assert.Equal(t, cdx.SerialNumber, cdx2.SerialNumber)
+}
@@ -114,6 +114,13 @@ func (bs *BaseSolver) CleanCache() error {
 	return bs.cache.shrink()
 }
 
+// CacheStats returns the usage statistics of the repository metadata cache.
+// The counters are shared by all solvers in the process that use the same
+// cache directory.
+func (bs *BaseSolver) CacheStats() CacheStats {
+	return bs.cache.stats()
+}
+
 // CleanupOldCacheDirs will remove cache directories for unsupported distros
 // eg. Once support for a fedora release stops and it is removed, this will
 // delete its directory under BaseSolver cache root.
@@ -158,6 +165,8 @@ type Solver struct {
 
 	sbomType sbom.StandardType
 
+	dependencyGraph bool
+
 	// Stderr is the stderr output from osbuild-depsolve-dnf, if unset os.Stderr
 	// will be used.
 	//
@@ -179,6 +188,9 @@ type DepsolveResult struct {
 	Repos        []rpmmd.RepoConfig
 	SBOM         *sbom.Document
 	Solver       string
+	// Dependencies is only set when requested, see
+	// [Solver.SetDependencyGraph]
+	Dependencies *DependencyGraph
 }
 
 // DumpResult contains the results of a dump operation.
@@ -269,6 +281,12 @@ func (s *Solver) SetSBOMType(sbomType sbom.StandardType) {
 	s.sbomType = sbomType
 }
 
+// SetDependencyGraph enables computing the dependency graph of the
+// depsolved packages, which is needed for [DepsolveResult.Explain].
+func (s *Solver) SetDependencyGraph(enabled bool) {
+	s.dependencyGraph = enabled
+}
+
 // Depsolve the list of required package sets with explicit excludes using
 // their associated repositories.  Each package set is depsolved as a separate
 // transactions in a chain.  It returns a list of all packages (with solved
@@ -283,8 +301,15 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 		return nil, err
 	}
 
+	// osbuild-depsolve-dnf only supports SPDX, CycloneDX documents are
+	// created from the depsolve result
+	requestSBOMType := sbomType
+	if sbomType == sbom.StandardTypeCycloneDX {
+		requestSBOMType = sbom.StandardTypeNone
+	}
+
 	cfg := s.solverCfg()
-	reqData, err := activeHandler.makeDepsolveRequest(cfg, pkgSets, sbomType)
+	reqData, err := activeHandler.makeDepsolveRequest(cfg, pkgSets, requestSBOMType)
 	if err != nil {
 		return nil, fmt.Errorf("makeDepsolveRequest failed: %w", err)
 	}
@@ -296,6 +321,13 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
+	// keep other processes from removing the repositories while in use
+	release, err := s.cache.useRepos(allRepos)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	output, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
 		return nil, parseError(output, allRepos, err)
@@ -320,34 +352,37 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType
 	}
 
 	var sbomDoc *sbom.Document
-	if sbomType != sbom.StandardTypeNone {
+	switch sbomType {
+	case sbom.StandardTypeNone:
+	case sbom.StandardTypeCycloneDX:
+		sbomDoc, err = CycloneDXDocument(resultRaw.Transactions, s.distro)
+		if err != nil {
+			return nil, fmt.Errorf("creating SBOM document failed: %w", err)
+		}
+	default:
 		sbomDoc, err = sbom.NewDocument(sbomType, resultRaw.SBOMRaw)
 		if err != nil {
 			return nil, fmt.Errorf("creating SBOM document failed: %w", err)
 		}
 	}
 
-	return &DepsolveResult{
+	res := &DepsolveResult{
 		Transactions: resultRaw.Transactions,
 		Modules:      resultRaw.Modules,
 		Repos:        resultRaw.Repos,
 		SBOM:         sbomDoc,
 		Solver:       resultRaw.Solver,
-	}, nil
+	}
+	if s.dependencyGraph {
+		res.Dependencies = NewDependencyGraph(pkgSets, res.Transactions)
+	}
+	return res, nil
 }
 
 // DepsolveAll calls [Solver.Depsolve] with each package set slice in the map and
 // returns a map of results with the corresponding keys as the input argument.
 func (s *Solver) DepsolveAll(pkgSetsMap map[string][]rpmmd.PackageSet) (map[string]DepsolveResult, error) {
-	results := make(map[string]DepsolveResult, len(pkgSetsMap))
-	for name, pkgSet := range pkgSetsMap {
-		res, err := s.Depsolve(pkgSet, s.sbomType)
-		if err != nil {
-			return nil, fmt.Errorf("error depsolving package sets for %q: %w", name, err)
-		}
-		results[name] = *res
-	}
-	return results, nil
+	return DepsolveAll(s, pkgSetsMap, s.sbomType)
 }
 
 // FetchMetadata returns the list of all the available packages in repos and
@@ -366,8 +401,17 @@ func (s *Solver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, err
 	// Is this cached?
 	reqHash := hashRequest(reqData)
 	if pkgs, ok := s.resultCache.Get(reqHash); ok {
+		s.cache.counters.resultHits.Add(1)
 		return pkgs, nil
 	}
+	s.cache.counters.resultMisses.Add(1)
+
+	// keep other processes from removing the repositories while in use
+	release, err := s.cache.useRepos(repos)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	rawRes, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
@@ -414,8 +458,17 @@ func (s *Solver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rp
 	// Is this cached?
 	reqHash := hashRequest(reqData)
 	if pkgs, ok := s.resultCache.Get(reqHash); ok {
+		s.cache.counters.resultHits.Add(1)
 		return pkgs, nil
 	}
+	s.cache.counters.resultMisses.Add(1)
+
+	// keep other processes from removing the repositories while in use
+	release, err := s.cache.useRepos(repos)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	rawRes, err := run(s.depsolveDNFCmd, reqData, s.Stderr)
 	if err != nil {
@@ -555,9 +608,9 @@ func (err Error) Unwrap() error {
 
 // parseError parses the response from osbuild-depsolve-dnf into the Error type and appends
 // the name and URL of a repository to all detected repository IDs in the
-// message.
-func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) Error {
-	var e Error
+// message. Errors with known structured details are returned as typed
+// errors that wrap the Error (e.g. *PackageNotFoundError).
+func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) error {
 	if len(data) == 0 {
 		return Error{
 			Kind:   "InternalError",
@@ -566,7 +619,11 @@ func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) Error {
 		}
 	}
 
-	if err := json.Unmarshal(data, &e); err != nil {
+	var output struct {
+		Error
+		Details *errorDetails `json:"details"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
 		// dumping the error into the Reason can get noisy, but it's good for troubleshooting
 		return Error{
 			Kind:   "InternalError",
@@ -574,6 +631,7 @@ func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) Error {
 			Err:    cmdError,
 		}
 	}
+	e := output.Error
 
 	// append to any instance of a repository ID the URL (or metalink, mirrorlist, etc)
 	for _, repo := range repos {
@@ -593,7 +651,7 @@ func parseError(data []byte, repos []rpmmd.RepoConfig, cmdError error) Error {
 		e.Reason = strings.ReplaceAll(e.Reason, idstr, fmt.Sprintf("%s [%s]", idstr, nameURL))
 	}
 
-	return e
+	return typedError(e, output.Details, repos)
 }
 
 func run(dnfJsonCmd []string, reqData []byte, stderr io.Writer) ([]byte, error) {
@@ -1088,6 +1088,38 @@ echo '{"solver": "zypper"}'
 	}
 }
 
+func TestSolverCycloneDXWithoutDependencyEdges(t *testing.T) {
+	for _, h := range getTestHandlers() {
+		t.Run(h.name, func(t *testing.T) {
+			restore := mockActiveHandler(h.handler)
+			defer restore()
+
+			// osbuild-depsolve-dnf only returns the packages, the
+			// relationships are derived from their metadata
+			fakeSolver := `#!/bin/sh -e
+cat - > "$0".stdin
+echo '{"solver": "dnf5"}'
+`
+			fakeSolverPath := filepath.Join(t.TempDir(), "fake-solver")
+			err := os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755) //nolint:gosec
+			require.NoError(t, err)
+
+			solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", t.TempDir())
+			solver.depsolveDNFCmd = []string{fakeSolverPath}
+			solver.SetDependencyGraph(true)
+			res, err := solver.Depsolve(nil, sbom.StandardTypeCycloneDX)
+			require.NoError(t, err)
+			require.NotNil(t, res.SBOM)
+			assert.Equal(t, sbom.StandardTypeCycloneDX, res.SBOM.DocType)
+			assert.NotNil(t, res.Dependencies)
+
+			stdin, err := os.ReadFile(fakeSolverPath + ".stdin")
+			require.NoError(t, err)
+			assert.NotContains(t, string(stdin), `"dependencies"`)
+		})
+	}
+}
+
 func TestDepsolverSubscriptionsError(t *testing.T) {
 	if _, err := os.Stat("/etc/yum.repos.d/redhat.repo"); err == nil {
 		t.Skip("Test must run on unsubscribed system")
@@ -0,0 +1,58 @@
+package depsolvednf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// Depsolver is the interface implemented by package dependency resolvers.
+// The Solver type, which runs osbuild-depsolve-dnf, is the default
+// implementation but callers (e.g. manifestgen or distros) only rely on this
+// interface so that alternative resolvers (remote services, recorded fixtures
+// for tests, etc) can be plugged in.
+//
+// Implementations must honour the DepsolveResult contract:
+//   - Transactions contains one package list per input package set, in the
+//     same order, and each list only contains the packages that are new in
+//     that transaction.
+//   - Every package references (via RepoID) one of the repositories in
+//     Repos.
+//   - SBOM is only set when a sbomType other than sbom.StandardTypeNone is
+//     requested.
+type Depsolver interface {
+	// Depsolve the chain of package sets as separate transactions and
+	// return the resolved packages.
+	Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*DepsolveResult, error)
+
+	// FetchMetadata returns the list of all available packages in the
+	// given repositories.
+	FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error)
+
+	// SearchMetadata searches the given repositories for packages and
+	// returns the matches.
+	SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error)
+}
+
+var _ Depsolver = (*Solver)(nil)
+
+// DepsolveAll calls Depsolve on the given depsolver with each package set
+// slice in the map and returns a map of results with the corresponding keys
+// as the input argument.
+func DepsolveAll(solver Depsolver, pkgSetsMap map[string][]rpmmd.PackageSet, sbomType sbom.StandardType) (map[string]DepsolveResult, error) {
+	results := make(map[string]DepsolveResult, len(pkgSetsMap))
+	for name, pkgSet := range pkgSetsMap {
+		res, err := solver.Depsolve(pkgSet, sbomType)
+		if err != nil {
+			var pkgSetErr interface{ setPackageSet(string) }
+			if errors.As(err, &pkgSetErr) {
+				pkgSetErr.setPackageSet(name)
+			}
+			return nil, fmt.Errorf("error depsolving package sets for %q: %w", name, err)
+		}
+		results[name] = *res
+	}
+	return results, nil
+}
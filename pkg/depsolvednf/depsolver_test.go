@@ -0,0 +1,59 @@
+package depsolvednf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+type fakeDepsolver struct {
+	err error
+}
+
+func (fd *fakeDepsolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	if fd.err != nil {
+		return nil, fd.err
+	}
+	var trans depsolvednf.TransactionList
+	for _, ps := range pkgSets {
+		var pkgs rpmmd.PackageList
+		for _, name := range ps.Include {
+			pkgs = append(pkgs, rpmmd.Package{Name: name})
+		}
+		trans = append(trans, pkgs)
+	}
+	return &depsolvednf.DepsolveResult{Transactions: trans, Solver: "fake"}, nil
+}
+
+func (fd *fakeDepsolver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	return nil, nil
+}
+
+func (fd *fakeDepsolver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	return nil, nil
+}
+
+func TestDepsolveAllWithCustomDepsolver(t *testing.T) {
+	res, err := depsolvednf.DepsolveAll(&fakeDepsolver{}, map[string][]rpmmd.PackageSet{
+		"build": {{Include: []string{"bash"}}},
+		"os":    {{Include: []string{"kernel"}}, {Include: []string{"vim"}}},
+	}, sbom.StandardTypeNone)
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, "fake", res["build"].Solver)
+	assert.Len(t, res["os"].Transactions, 2)
+	assert.Equal(t, "vim", res["os"].Transactions[1][0].Name)
+}
+
+func TestDepsolveAllWithCustomDepsolverError(t *testing.T) {
+	_, err := depsolvednf.DepsolveAll(&fakeDepsolver{err: errors.New("boom")}, map[string][]rpmmd.PackageSet{
+		"os": {{Include: []string{"kernel"}}},
+	}, sbom.StandardTypeNone)
+	assert.EqualError(t, err, `error depsolving package sets for "os": boom`)
+}
@@ -0,0 +1,215 @@
+package depsolvednf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Error kinds reported by osbuild-depsolve-dnf
+const (
+	errorKindMarking  = "MarkingErrors"
+	errorKindDepsolve = "DepsolveError"
+	errorKindRepo     = "RepoError"
+	errorKindGPGKey   = "GPGKeyReadError"
+)
+
+// Kinds of the optional structured error details
+const (
+	detailsKindPackageNotFound     = "package-not-found"
+	detailsKindConflictingRequests = "conflicting-requests"
+	detailsKindModuleStream        = "module-stream"
+	detailsKindRepo                = "repo"
+	detailsKindGPG                 = "gpg"
+)
+
+// errorDetails is optional structured information about an error in
+// addition to the kind and the free-text reason. osbuild-depsolve-dnf does
+// not report it (yet), the typed errors are based on the kind alone and
+// only use the details, when present, for a more specific error type and
+// the affected packages, modules, problems and repositories.
+type errorDetails struct {
+	Kind string `json:"kind"`
+	// Packages are the missing packages or the requested packages of
+	// the failed transaction
+	Packages []string `json:"packages,omitempty"`
+	Modules  []string `json:"modules,omitempty"`
+	// Problems are the individual problems reported by the solver
+	Problems []string `json:"problems,omitempty"`
+	// Repos are the ids of the affected repositories as sent in the
+	// request (i.e. their hashes)
+	Repos []string `json:"repos,omitempty"`
+}
+
+// The typed errors below are returned (as pointers) by the Solver for the
+// known kinds of osbuild-depsolve-dnf errors so that callers can tell them
+// apart with errors.As(). They all wrap the generic Error, which provides
+// the message and can still be matched with errors.As() too.
+
+// errorContext contains the information about a depsolve error that is
+// not part of the osbuild-depsolve-dnf output
+type errorContext struct {
+	// PackageSet is the name of the package set that failed to
+	// depsolve, it is only set by DepsolveAll()
+	PackageSet string
+}
+
+func (c *errorContext) setPackageSet(name string) {
+	c.PackageSet = name
+}
+
+// PackageNotFoundError is returned when requested packages do not exist in
+// the enabled repositories.
+type PackageNotFoundError struct {
+	errorContext
+	Err      Error
+	Packages []string
+}
+
+func (e *PackageNotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PackageNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictingRequestsError is returned when the requested packages cannot
+// be installed together, e.g. because of conflicts or unresolvable
+// dependencies.
+type ConflictingRequestsError struct {
+	errorContext
+	Err Error
+	// Packages are the requested packages of the failed transaction
+	Packages []string
+	// Problems are the individual problems reported by the solver, e.g.
+	// "nothing provides libfoo needed by bar-1.0-1.x86_64"
+	Problems []string
+}
+
+func (e *ConflictingRequestsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConflictingRequestsError) Unwrap() error {
+	return e.Err
+}
+
+// ModuleStreamError is returned when module streams cannot be enabled,
+// e.g. because they do not exist or conflict with each other.
+type ModuleStreamError struct {
+	errorContext
+	Err     Error
+	Modules []string
+}
+
+func (e *ModuleStreamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ModuleStreamError) Unwrap() error {
+	return e.Err
+}
+
+// RepoError is returned when the metadata of repositories cannot be
+// loaded, e.g. because they are unreachable.
+type RepoError struct {
+	errorContext
+	Err Error
+	// RepoIDs are the ids of the affected repositories (or their hash
+	// if a repository has no id)
+	RepoIDs []string
+}
+
+func (e *RepoError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RepoError) Unwrap() error {
+	return e.Err
+}
+
+// GPGError is returned when GPG keys cannot be read or the signature
+// verification of repository metadata failed.
+type GPGError struct {
+	errorContext
+	Err     Error
+	RepoIDs []string
+}
+
+func (e *GPGError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GPGError) Unwrap() error {
+	return e.Err
+}
+
+// repoIDs returns the ids of the repositories with the given hashes (or
+// the hash if a repository has no id)
+func repoIDs(hashes []string, repos []rpmmd.RepoConfig) []string {
+	var ids []string
+	for _, hash := range hashes {
+		id := hash
+		if idx := slices.IndexFunc(repos, func(repo rpmmd.RepoConfig) bool { return repo.Hash() == hash }); idx >= 0 && repos[idx].Id != "" {
+			id = repos[idx].Id
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// errorRepoIDs returns the ids of the repositories affected by the error,
+// either from the details or the repositories that the reason refers to
+// (by their hash, as sent in the request)
+func errorRepoIDs(e Error, details *errorDetails, repos []rpmmd.RepoConfig) []string {
+	if len(details.Repos) > 0 {
+		return repoIDs(details.Repos, repos)
+	}
+	var hashes []string
+	for _, repo := range repos {
+		if hash := repo.Hash(); strings.Contains(e.Reason, fmt.Sprintf("'%s'", hash)) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return repoIDs(hashes, repos)
+}
+
+// typedError maps the generic error to a typed error based on the kind
+// reported by osbuild-depsolve-dnf. The optional details select a more
+// specific type (e.g. a module stream or GPG problem) and provide the
+// affected packages and repositories. Unknown kinds are returned as they
+// are.
+func typedError(e Error, details *errorDetails, repos []rpmmd.RepoConfig) error {
+	if details == nil {
+		details = &errorDetails{}
+	}
+	switch details.Kind {
+	case detailsKindPackageNotFound:
+		return &PackageNotFoundError{Err: e, Packages: details.Packages}
+	case detailsKindConflictingRequests:
+		return &ConflictingRequestsError{Err: e, Packages: details.Packages, Problems: details.Problems}
+	case detailsKindModuleStream:
+		return &ModuleStreamError{Err: e, Modules: details.Modules}
+	case detailsKindRepo:
+		return &RepoError{Err: e, RepoIDs: errorRepoIDs(e, details, repos)}
+	case detailsKindGPG:
+		return &GPGError{Err: e, RepoIDs: errorRepoIDs(e, details, repos)}
+	}
+
+	switch e.Kind {
+	case errorKindMarking:
+		return &PackageNotFoundError{Err: e, Packages: details.Packages}
+	case errorKindDepsolve:
+		return &ConflictingRequestsError{Err: e, Packages: details.Packages, Problems: details.Problems}
+	case errorKindRepo:
+		return &RepoError{Err: e, RepoIDs: errorRepoIDs(e, details, repos)}
+	case errorKindGPGKey:
+		return &GPGError{Err: e, RepoIDs: errorRepoIDs(e, details, repos)}
+	}
+	return e
+}
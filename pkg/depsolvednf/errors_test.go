@@ -0,0 +1,134 @@
+package depsolvednf
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+func errorOutput(t *testing.T, kind, reason string, details *errorDetails) []byte {
+	data, err := json.Marshal(map[string]any{"kind": kind, "reason": reason, "details": details})
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseErrorPackageNotFound(t *testing.T) {
+	reason := "Error occurred when marking packages for installation: Failed to resolve the transaction:\nNo match for argument: does-not-exist\nNo match for argument: also-missing"
+	details := &errorDetails{Kind: "package-not-found", Packages: []string{"does-not-exist", "also-missing"}}
+	err := parseError(errorOutput(t, "MarkingErrors", reason, details), nil, nil)
+	var notFound *PackageNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, []string{"does-not-exist", "also-missing"}, notFound.Packages)
+	assert.Equal(t, "DNF error occurred: MarkingErrors: "+reason, err.Error())
+
+	// the generic error can still be used
+	var dnfErr Error
+	require.ErrorAs(t, err, &dnfErr)
+	assert.Equal(t, "MarkingErrors", dnfErr.Kind)
+}
+
+func TestParseErrorConflictingRequests(t *testing.T) {
+	reason := "There was a problem depsolving ['go-toolset', 'kernel']: \n Problem: conflicting requests\n  - nothing provides libfoo needed by bar-1.0-1.x86_64"
+	details := &errorDetails{
+		Kind:     "conflicting-requests",
+		Packages: []string{"go-toolset", "kernel"},
+		Problems: []string{
+			"nothing provides libfoo needed by bar-1.0-1.x86_64",
+			"package baz-2.0-1.x86_64 conflicts with bar",
+		},
+	}
+	err := parseError(errorOutput(t, "DepsolveError", reason, details), nil, nil)
+	var conflict *ConflictingRequestsError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, []string{"go-toolset", "kernel"}, conflict.Packages)
+	assert.Equal(t, details.Problems, conflict.Problems)
+}
+
+func TestParseErrorModuleStream(t *testing.T) {
+	reason := "There was a problem depsolving ['@nodejs:18', '@nodejs:20']: Cannot enable multiple streams for module 'nodejs'"
+	details := &errorDetails{Kind: "module-stream", Modules: []string{"nodejs:18", "nodejs:20"}}
+	err := parseError(errorOutput(t, "DepsolveError", reason, details), nil, nil)
+	var modErr *ModuleStreamError
+	require.ErrorAs(t, err, &modErr)
+	assert.Equal(t, []string{"nodejs:18", "nodejs:20"}, modErr.Modules)
+}
+
+func TestParseErrorRepo(t *testing.T) {
+	repos := []rpmmd.RepoConfig{
+		{Id: "baseos", Name: "BaseOS", BaseURLs: []string{"https://example.com/baseos"}},
+		{BaseURLs: []string{"https://example.com/appstream"}},
+	}
+	reason := "There was a problem reading a repository: Failed to download metadata for repo '" + repos[0].Hash() + "': Cannot download repomd.xml"
+	details := &errorDetails{Kind: "repo", Repos: []string{repos[0].Hash()}}
+	err := parseError(errorOutput(t, "RepoError", reason, details), repos, nil)
+	var repoErr *RepoError
+	require.ErrorAs(t, err, &repoErr)
+	assert.Equal(t, []string{"baseos"}, repoErr.RepoIDs)
+	// the repository name and URL are still added to the message
+	assert.Contains(t, err.Error(), "'"+repos[0].Hash()+"' [BaseOS: https://example.com/baseos]")
+
+	// repositories without an id are identified by their hash
+	reason = "Failed to download metadata for repo '" + repos[1].Hash() + "': repomd.xml GPG signature verification error: Bad GPG signature"
+	details = &errorDetails{Kind: "gpg", Repos: []string{repos[1].Hash()}}
+	err = parseError(errorOutput(t, "RepoError", reason, details), repos, nil)
+	var gpgErr *GPGError
+	require.ErrorAs(t, err, &gpgErr)
+	assert.Equal(t, []string{repos[1].Hash()}, gpgErr.RepoIDs)
+}
+
+func TestParseErrorWithoutDetails(t *testing.T) {
+	// the details are optional, the kind alone selects the typed error
+	err := parseError(errorOutput(t, "MarkingErrors", "missing packages: foo", nil), nil, nil)
+	var notFound *PackageNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Empty(t, notFound.Packages)
+	assert.Equal(t, "MarkingErrors", notFound.Err.Kind)
+
+	err = parseError(errorOutput(t, "DepsolveError", "there was a problem depsolving ['foo']", nil), nil, nil)
+	var conflict *ConflictingRequestsError
+	require.ErrorAs(t, err, &conflict)
+
+	repos := []rpmmd.RepoConfig{
+		{Id: "baseos", BaseURLs: []string{"https://example.com/baseos"}},
+		{Id: "appstream", BaseURLs: []string{"https://example.com/appstream"}},
+	}
+	reason := "Failed to download metadata for repo '" + repos[1].Hash() + "'"
+	err = parseError(errorOutput(t, "RepoError", reason, nil), repos, nil)
+	var repoErr *RepoError
+	require.ErrorAs(t, err, &repoErr)
+	assert.Equal(t, []string{"appstream"}, repoErr.RepoIDs)
+
+	err = parseError(errorOutput(t, "GPGKeyReadError", "error reading gpg key", nil), repos, nil)
+	var gpgErr *GPGError
+	require.ErrorAs(t, err, &gpgErr)
+	assert.Empty(t, gpgErr.RepoIDs)
+
+	// unknown kinds stay generic
+	err = parseError(errorOutput(t, "InvalidRequest", "invalid request", &errorDetails{Kind: "unknown"}), nil, nil)
+	assert.Equal(t, Error{Kind: "InvalidRequest", Reason: "invalid request"}, err)
+}
+
+type errorDepsolver struct {
+	Depsolver
+	err error
+}
+
+func (d *errorDepsolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*DepsolveResult, error) {
+	return nil, d.err
+}
+
+func TestDepsolveAllSetsPackageSet(t *testing.T) {
+	solver := &errorDepsolver{err: parseError(errorOutput(t, "MarkingErrors", "missing packages: foo", &errorDetails{Kind: "package-not-found", Packages: []string{"foo"}}), nil, nil)}
+	_, err := DepsolveAll(solver, map[string][]rpmmd.PackageSet{"os": {{Include: []string{"foo"}}}}, sbom.StandardTypeNone)
+	assert.EqualError(t, err, `error depsolving package sets for "os": DNF error occurred: MarkingErrors: missing packages: foo`)
+	var notFound *PackageNotFoundError
+	require.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "os", notFound.PackageSet)
+	assert.Equal(t, []string{"foo"}, notFound.Packages)
+}
@@ -0,0 +1,364 @@
+package depsolvednf
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// DependencyEdge is a dependency between two packages of a depsolve result:
+// the package From requires (or recommends) a capability that is provided by
+// the package To.
+type DependencyEdge struct {
+	From string
+	To   string
+	// Requirement is the requires (or recommends) entry of From that is
+	// satisfied by To, e.g. "libc.so.6()(64bit)"
+	Requirement string
+	// Weak is set for weak dependencies (recommends, supplements)
+	Weak bool
+}
+
+// DependencyGraph contains the dependency edges between the packages of a
+// depsolve result and the package set entries that were requested.
+type DependencyGraph struct {
+	Edges []DependencyEdge
+	// Requested maps the name of every package that was requested
+	// directly to the package set entry that matched it (e.g. "kernel",
+	// "kernel-*" or "@core" for comps groups).
+	Requested map[string]string
+}
+
+// NewDependencyGraph computes the dependency edges between the packages of
+// the given transactions from the package metadata (requires, recommends,
+// supplements, provides and files) and determines which packages were
+// requested by which entry of the package sets. A requirement is satisfied
+// by every package of the transactions that provides a matching capability
+// (or file), versioned requirements only match providers with a matching
+// version. For rich dependencies (e.g. "(foo if bar)") only the required
+// terms are matched, their conditions are not dependencies.
+func NewDependencyGraph(pkgSets []rpmmd.PackageSet, transactions TransactionList) *DependencyGraph {
+	pkgs := transactions.AllPackages()
+	providers := newProviderIndex(pkgs)
+
+	graph := &DependencyGraph{
+		Requested: make(map[string]string),
+	}
+	seen := make(map[DependencyEdge]bool)
+	addEdge := func(edge DependencyEdge) {
+		if edge.From == edge.To || seen[edge] {
+			return
+		}
+		seen[edge] = true
+		graph.Edges = append(graph.Edges, edge)
+	}
+	addEdges := func(from string, deps rpmmd.RelDepList, weak bool) {
+		for _, dep := range deps {
+			for _, term := range requiredTerms(dep) {
+				for _, to := range providers.find(term) {
+					addEdge(DependencyEdge{From: from, To: to, Requirement: relDepString(dep), Weak: weak})
+				}
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		// Requires already contains RequiresPre
+		addEdges(pkg.Name, pkg.Requires, false)
+		addEdges(pkg.Name, pkg.Recommends, true)
+	}
+	// supplements are reverse weak dependencies: the supplementing package
+	// is pulled in because of the package it supplements
+	for _, pkg := range pkgs {
+		for _, dep := range pkg.Supplements {
+			for _, term := range requiredTerms(dep) {
+				for _, from := range providers.find(term) {
+					addEdge(DependencyEdge{From: from, To: pkg.Name, Requirement: relDepString(dep), Weak: true})
+				}
+			}
+		}
+	}
+
+	for _, ps := range pkgSets {
+		for _, include := range ps.Include {
+			if strings.HasPrefix(include, "@") {
+				continue
+			}
+			for _, pkg := range pkgs {
+				if _, ok := graph.Requested[pkg.Name]; ok {
+					continue
+				}
+				if match, _ := path.Match(include, pkg.Name); match || providesCapability(pkg, include) {
+					graph.Requested[pkg.Name] = include
+				}
+			}
+		}
+	}
+	// packages that nothing depends on and that were not requested by name
+	// are pulled in by the comps groups of their transaction
+	required := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		required[edge.To] = true
+	}
+	for idx, trans := range transactions {
+		if idx >= len(pkgSets) {
+			break
+		}
+		var groups []string
+		for _, include := range pkgSets[idx].Include {
+			if strings.HasPrefix(include, "@") {
+				groups = append(groups, include)
+			}
+		}
+		if len(groups) == 0 {
+			continue
+		}
+		for _, pkg := range trans {
+			if _, ok := graph.Requested[pkg.Name]; !ok && !required[pkg.Name] {
+				graph.Requested[pkg.Name] = strings.Join(groups, ", ")
+			}
+		}
+	}
+
+	return graph
+}
+
+// providerIndex maps capability names (and file paths) to the packages
+// that provide them
+type providerIndex map[string][]provider
+
+type provider struct {
+	pkg string
+	dep rpmmd.RelDep
+}
+
+func newProviderIndex(pkgs rpmmd.PackageList) providerIndex {
+	idx := make(providerIndex)
+	for _, pkg := range pkgs {
+		// every package provides its own name, even without an
+		// explicit entry in its provides
+		self := rpmmd.RelDep{Name: pkg.Name}
+		if pkg.Version != "" {
+			self.Relationship = "="
+			self.Version = fmt.Sprintf("%d:%s-%s", pkg.Epoch, pkg.Version, pkg.Release)
+		}
+		idx[pkg.Name] = append(idx[pkg.Name], provider{pkg.Name, self})
+		for _, prov := range pkg.Provides {
+			idx[prov.Name] = append(idx[prov.Name], provider{pkg.Name, prov})
+		}
+		for _, file := range pkg.Files {
+			idx[file] = append(idx[file], provider{pkg.Name, rpmmd.RelDep{Name: file}})
+		}
+	}
+	return idx
+}
+
+// find returns the names of the packages that satisfy the given (simple)
+// dependency
+func (idx providerIndex) find(dep rpmmd.RelDep) []string {
+	var names []string
+	for _, prov := range idx[dep.Name] {
+		if !slices.Contains(names, prov.pkg) && satisfies(prov.dep, dep) {
+			names = append(names, prov.pkg)
+		}
+	}
+	return names
+}
+
+// satisfies returns true if the version of the provide matches the version
+// range of the requirement. Like in rpm, unversioned provides and
+// requirements match any version.
+func satisfies(prov, req rpmmd.RelDep) bool {
+	if req.Relationship == "" || req.Version == "" || prov.Version == "" {
+		return true
+	}
+	if prov.Relationship != "=" {
+		// provides of version ranges are rare, treat them as
+		// overlapping
+		return true
+	}
+	res := compareEVR(prov.Version, req.Version)
+	switch req.Relationship {
+	case "=":
+		return res == 0
+	case "<":
+		return res < 0
+	case "<=":
+		return res <= 0
+	case ">":
+		return res > 0
+	case ">=":
+		return res >= 0
+	}
+	return true
+}
+
+// compareEVR compares the "[epoch:]version[-release]" strings of a provide
+// and a requirement. The release of the provide is only compared if the
+// requirement has one.
+func compareEVR(prov, req string) int {
+	provEpoch, provVR := splitEpoch(prov)
+	reqEpoch, reqVR := splitEpoch(req)
+	if res := rpmmd.VersionCompare(provEpoch, reqEpoch); res != 0 {
+		return res
+	}
+	provVersion, provRelease, _ := strings.Cut(provVR, "-")
+	reqVersion, reqRelease, hasRelease := strings.Cut(reqVR, "-")
+	if res := rpmmd.VersionCompare(provVersion, reqVersion); res != 0 || !hasRelease {
+		return res
+	}
+	return rpmmd.VersionCompare(provRelease, reqRelease)
+}
+
+func splitEpoch(evr string) (string, string) {
+	if epoch, vr, ok := strings.Cut(evr, ":"); ok {
+		return epoch, vr
+	}
+	return "0", evr
+}
+
+// requiredTerms returns the simple dependencies that must be provided for
+// the given dependency. For rich dependencies these are the terms of the
+// expression except for the conditions of "if" and "unless" and the
+// excluded terms of "without", e.g. "foo" and "baz" for
+// "(foo if bar else baz)".
+func requiredTerms(dep rpmmd.RelDep) []rpmmd.RelDep {
+	if !strings.HasPrefix(dep.Name, "(") {
+		return []rpmmd.RelDep{dep}
+	}
+	tokens := strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(dep.Name))
+	terms, _ := parseRichDep(tokens)
+	return terms
+}
+
+// parseRichDep parses the tokens of a (parenthesized) rich dependency
+// expression and returns its required terms and the remaining tokens.
+func parseRichDep(tokens []string) ([]rpmmd.RelDep, []string) {
+	if len(tokens) == 0 || tokens[0] != "(" {
+		return nil, tokens
+	}
+	tokens = tokens[1:]
+
+	var terms []rpmmd.RelDep
+	required := true
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		switch tok {
+		case ")":
+			return terms, tokens[1:]
+		case "and", "or", "with", "else":
+			required = true
+			tokens = tokens[1:]
+			continue
+		case "if", "unless", "without":
+			required = false
+			tokens = tokens[1:]
+			continue
+		}
+
+		var sub []rpmmd.RelDep
+		if tok == "(" {
+			sub, tokens = parseRichDep(tokens)
+		} else {
+			term := rpmmd.RelDep{Name: tok}
+			tokens = tokens[1:]
+			if len(tokens) >= 2 && slices.Contains([]string{"=", "<", ">", "<=", ">="}, tokens[0]) {
+				term.Relationship, term.Version = tokens[0], tokens[1]
+				tokens = tokens[2:]
+			}
+			sub = []rpmmd.RelDep{term}
+		}
+		if required {
+			terms = append(terms, sub...)
+		}
+	}
+	return terms, tokens
+}
+
+func providesCapability(pkg rpmmd.Package, capability string) bool {
+	return slices.ContainsFunc(pkg.Provides, func(prov rpmmd.RelDep) bool {
+		return prov.Name == capability
+	})
+}
+
+func relDepString(dep rpmmd.RelDep) string {
+	if dep.Relationship == "" {
+		return dep.Name
+	}
+	return fmt.Sprintf("%s %s %s", dep.Name, dep.Relationship, dep.Version)
+}
+
+// Explanation describes why a package is part of a depsolve result.
+type Explanation struct {
+	Package string
+	// Chain is the shortest chain of dependencies from a requested
+	// package to the explained package. It is empty if the package was
+	// requested directly.
+	Chain []DependencyEdge
+	// RequestedBy is the package set entry that requested the first
+	// package of the chain (or the package itself). It is empty if no
+	// requested package depends on the package.
+	RequestedBy string
+}
+
+func (e *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s", e.Package)
+	for idx := len(e.Chain) - 1; idx >= 0; idx-- {
+		edge := e.Chain[idx]
+		verb := "requires"
+		if edge.Weak {
+			verb = "recommends"
+		}
+		fmt.Fprintf(&b, "\n  <- %s %s %q", edge.From, verb, edge.Requirement)
+	}
+	if e.RequestedBy != "" {
+		fmt.Fprintf(&b, "\n  <- requested as %q", e.RequestedBy)
+	} else {
+		fmt.Fprintf(&b, "\n  <- not required by any requested package")
+	}
+	return b.String()
+}
+
+// Explain returns the chain of dependencies that caused the package with the
+// given name to be part of the depsolve result. The result must contain a
+// dependency graph (see [Solver.SetDependencyGraph]).
+func (r *DepsolveResult) Explain(name string) (*Explanation, error) {
+	if r.Dependencies == nil {
+		return nil, fmt.Errorf("depsolve result has no dependency information")
+	}
+	if _, err := r.Transactions.FindPackage(name); err != nil {
+		return nil, err
+	}
+
+	requiredBy := make(map[string][]DependencyEdge)
+	for _, edge := range r.Dependencies.Edges {
+		requiredBy[edge.To] = append(requiredBy[edge.To], edge)
+	}
+
+	// breadth first search from the package back to the closest
+	// requested package
+	via := map[string]*DependencyEdge{name: nil}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if entry, ok := r.Dependencies.Requested[current]; ok {
+			expl := &Explanation{Package: name, RequestedBy: entry}
+			for edge := via[current]; edge != nil; edge = via[edge.To] {
+				expl.Chain = append(expl.Chain, *edge)
+			}
+			return expl, nil
+		}
+		for _, edge := range requiredBy[current] {
+			if _, ok := via[edge.From]; ok {
+				continue
+			}
+			via[edge.From] = &edge
+			queue = append(queue, edge.From)
+		}
+	}
+	return &Explanation{Package: name}, nil
+}
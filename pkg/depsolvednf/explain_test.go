@@ -0,0 +1,193 @@
+package depsolvednf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func testExplainResult() *DepsolveResult {
+	pkgSets := []rpmmd.PackageSet{
+		{Include: []string{"@core", "kernel"}},
+		{Include: []string{"/usr/bin/vim"}},
+	}
+	transactions := TransactionList{
+		{
+			{
+				Name:     "kernel",
+				Requires: rpmmd.RelDepList{{Name: "kernel-core", Relationship: "=", Version: "6.12"}},
+			},
+			{
+				Name:     "kernel-core",
+				Version:  "6.12",
+				Release:  "1.fc42",
+				Requires: rpmmd.RelDepList{{Name: "/bin/sh"}},
+				Recommends: rpmmd.RelDepList{
+					{Name: "(linux-firmware if kernel-core)"},
+				},
+			},
+			{
+				Name:     "linux-firmware",
+				Requires: rpmmd.RelDepList{{Name: "linux-firmware-whence"}},
+			},
+			{Name: "linux-firmware-whence"},
+			{
+				Name:     "bash",
+				Provides: rpmmd.RelDepList{{Name: "bash", Relationship: "=", Version: "5.2"}},
+				Files:    []string{"/bin/sh"},
+			},
+			{Name: "chrony"},
+		},
+		{
+			{
+				Name:     "vim-enhanced",
+				Files:    []string{"/usr/bin/vim"},
+				Provides: rpmmd.RelDepList{{Name: "/usr/bin/vim"}},
+				Requires: rpmmd.RelDepList{{Name: "libc.so.6()(64bit)"}},
+			},
+			{
+				Name:     "glibc",
+				Provides: rpmmd.RelDepList{{Name: "libc.so.6()(64bit)"}},
+			},
+		},
+	}
+	return &DepsolveResult{
+		Transactions: transactions,
+		Dependencies: NewDependencyGraph(pkgSets, transactions),
+	}
+}
+
+func TestNewDependencyGraph(t *testing.T) {
+	res := testExplainResult()
+	assert.ElementsMatch(t, []DependencyEdge{
+		{From: "kernel", To: "kernel-core", Requirement: "kernel-core = 6.12"},
+		{From: "kernel-core", To: "bash", Requirement: "/bin/sh"},
+		{From: "kernel-core", To: "linux-firmware", Requirement: "(linux-firmware if kernel-core)", Weak: true},
+		{From: "linux-firmware", To: "linux-firmware-whence", Requirement: "linux-firmware-whence"},
+		{From: "vim-enhanced", To: "glibc", Requirement: "libc.so.6()(64bit)"},
+	}, res.Dependencies.Edges)
+	assert.Equal(t, map[string]string{
+		"kernel":       "kernel",
+		"vim-enhanced": "/usr/bin/vim",
+		"chrony":       "@core",
+	}, res.Dependencies.Requested)
+}
+
+func TestNewDependencyGraphVersions(t *testing.T) {
+	transactions := TransactionList{
+		{
+			{
+				Name: "app",
+				Requires: rpmmd.RelDepList{
+					{Name: "libfoo", Relationship: ">=", Version: "2.0"},
+					{Name: "(python3-bar or python3-baz)"},
+					{Name: "(tool if libfoo)"},
+				},
+				Supplements: rpmmd.RelDepList{{Name: "(base and libfoo)"}},
+			},
+			// both provide libfoo, only the newer one satisfies the requirement
+			{Name: "libfoo1", Provides: rpmmd.RelDepList{{Name: "libfoo", Relationship: "=", Version: "1.9-1"}}},
+			{Name: "libfoo2", Provides: rpmmd.RelDepList{{Name: "libfoo", Relationship: "=", Version: "2.1-1"}}},
+			{Name: "python3-baz", Version: "1.0", Release: "1"},
+			{Name: "tool"},
+			{Name: "base"},
+		},
+	}
+	graph := NewDependencyGraph(nil, transactions)
+	assert.ElementsMatch(t, []DependencyEdge{
+		{From: "app", To: "libfoo2", Requirement: "libfoo >= 2.0"},
+		{From: "app", To: "python3-baz", Requirement: "(python3-bar or python3-baz)"},
+		{From: "app", To: "tool", Requirement: "(tool if libfoo)"},
+		{From: "base", To: "app", Requirement: "(base and libfoo)", Weak: true},
+		{From: "libfoo1", To: "app", Requirement: "(base and libfoo)", Weak: true},
+		{From: "libfoo2", To: "app", Requirement: "(base and libfoo)", Weak: true},
+	}, graph.Edges)
+}
+
+func TestSatisfies(t *testing.T) {
+	for _, tc := range []struct {
+		prov     rpmmd.RelDep
+		req      rpmmd.RelDep
+		expected bool
+	}{
+		{rpmmd.RelDep{Name: "a"}, rpmmd.RelDep{Name: "a", Relationship: ">=", Version: "1"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-3"}, rpmmd.RelDep{Name: "a"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-3"}, rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-3"}, rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-4"}, false},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-3"}, rpmmd.RelDep{Name: "a", Relationship: "<", Version: "1.2-4"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.2-3"}, rpmmd.RelDep{Name: "a", Relationship: ">", Version: "1.2"}, false},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1:1.0-1"}, rpmmd.RelDep{Name: "a", Relationship: ">=", Version: "2.0"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "1.0-1"}, rpmmd.RelDep{Name: "a", Relationship: "<=", Version: "1:0.1"}, true},
+		{rpmmd.RelDep{Name: "a", Relationship: "=", Version: "0:1.0-1"}, rpmmd.RelDep{Name: "a", Relationship: ">=", Version: "1.0"}, true},
+	} {
+		assert.Equal(t, tc.expected, satisfies(tc.prov, tc.req), "%v %v", tc.prov, tc.req)
+	}
+}
+
+func TestRequiredTerms(t *testing.T) {
+	for _, tc := range []struct {
+		dep      string
+		expected []rpmmd.RelDep
+	}{
+		{"foo", []rpmmd.RelDep{{Name: "foo"}}},
+		{"(foo or bar)", []rpmmd.RelDep{{Name: "foo"}, {Name: "bar"}}},
+		{"(foo >= 1.0 and bar)", []rpmmd.RelDep{{Name: "foo", Relationship: ">=", Version: "1.0"}, {Name: "bar"}}},
+		{"(foo if bar else baz)", []rpmmd.RelDep{{Name: "foo"}, {Name: "baz"}}},
+		{"(foo unless bar)", []rpmmd.RelDep{{Name: "foo"}}},
+		{"(foo without bar)", []rpmmd.RelDep{{Name: "foo"}}},
+		{"((foo or bar) if (baz and qux))", []rpmmd.RelDep{{Name: "foo"}, {Name: "bar"}}},
+		{"(kernel-modules-extra-matched if (kernel-modules-extra and kernel))", []rpmmd.RelDep{{Name: "kernel-modules-extra-matched"}}},
+	} {
+		assert.Equal(t, tc.expected, requiredTerms(rpmmd.RelDep{Name: tc.dep}), tc.dep)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	res := testExplainResult()
+
+	expl, err := res.Explain("linux-firmware-whence")
+	require.NoError(t, err)
+	assert.Equal(t, "kernel", expl.RequestedBy)
+	assert.Len(t, expl.Chain, 3)
+	assert.Equal(t, `linux-firmware-whence
+  <- linux-firmware requires "linux-firmware-whence"
+  <- kernel-core recommends "(linux-firmware if kernel-core)"
+  <- kernel requires "kernel-core = 6.12"
+  <- requested as "kernel"`, expl.String())
+
+	expl, err = res.Explain("glibc")
+	require.NoError(t, err)
+	assert.Equal(t, `glibc
+  <- vim-enhanced requires "libc.so.6()(64bit)"
+  <- requested as "/usr/bin/vim"`, expl.String())
+
+	expl, err = res.Explain("chrony")
+	require.NoError(t, err)
+	assert.Empty(t, expl.Chain)
+	assert.Equal(t, `chrony
+  <- requested as "@core"`, expl.String())
+}
+
+func TestExplainErrors(t *testing.T) {
+	res := testExplainResult()
+	_, err := res.Explain("not-installed")
+	assert.EqualError(t, err, `package "not-installed" not found in transactions`)
+
+	res.Dependencies = nil
+	_, err = res.Explain("kernel")
+	assert.EqualError(t, err, "depsolve result has no dependency information")
+}
+
+func TestExplainNotRequired(t *testing.T) {
+	transactions := TransactionList{{{Name: "orphan"}}}
+	res := &DepsolveResult{
+		Transactions: transactions,
+		Dependencies: NewDependencyGraph([]rpmmd.PackageSet{{Include: []string{"other"}}}, transactions),
+	}
+	expl, err := res.Explain("orphan")
+	require.NoError(t, err)
+	assert.Equal(t, "orphan\n  <- not required by any requested package", expl.String())
+}
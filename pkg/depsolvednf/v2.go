@@ -46,6 +46,8 @@ type v2Repository struct {
 	SSLClientCert  string   `json:"sslclientcert,omitempty"`
 	MetadataExpire string   `json:"metadata_expire,omitempty"`
 	ModuleHotfixes *bool    `json:"module_hotfixes,omitempty"`
+	IncludePkgs    []string `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string `json:"excludepkgs,omitempty"`
 	RHSM           bool     `json:"rhsm,omitempty"`
 }
 
@@ -423,6 +425,8 @@ func (h *v2Handler) reposFromRPMMD(cfg *solverConfig, rpmRepos []rpmmd.RepoConfi
 			SSLClientKey:   rr.SSLClientKey,
 			SSLClientCert:  rr.SSLClientCert,
 			ModuleHotfixes: common.ClonePtr(rr.ModuleHotfixes),
+			IncludePkgs:    slices.Clone(rr.IncludePkgs),
+			ExcludePkgs:    slices.Clone(rr.ExcludePkgs),
 		}
 
 		if rr.IgnoreSSL != nil {
@@ -599,6 +603,8 @@ func (h *v2Handler) toRPMMDRepoConfig(repo v2Repository) rpmmd.RepoConfig {
 		IgnoreSSL:      &ignoreSSL,
 		MetadataExpire: repo.MetadataExpire,
 		ModuleHotfixes: common.ClonePtr(repo.ModuleHotfixes),
+		IncludePkgs:    slices.Clone(repo.IncludePkgs),
+		ExcludePkgs:    slices.Clone(repo.ExcludePkgs),
 		Enabled:        common.ToPtr(true),
 		SSLCACert:      repo.SSLCACert,
 		SSLClientKey:   repo.SSLClientKey,
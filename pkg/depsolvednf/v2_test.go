@@ -223,6 +223,12 @@ func TestV2HandlerMakeDepsolveRequest(t *testing.T) {
 		BaseURLs:       []string{"https://example.org/nginx"},
 		ModuleHotfixes: common.ToPtr(true),
 	}
+	filteredRepo := rpmmd.RepoConfig{
+		Name:        "vendor",
+		BaseURLs:    []string{"https://example.org/vendor"},
+		IncludePkgs: []string{"vendor-*"},
+		ExcludePkgs: []string{"openssl*"},
+	}
 	mtlsRepo := rpmmd.RepoConfig{
 		Name:          "mtls",
 		BaseURLs:      []string{"https://example.org/mtls"},
@@ -453,6 +459,34 @@ func TestV2HandlerMakeDepsolveRequest(t *testing.T) {
 				}
 			}`, baseOS.Hash(), appstream.Hash(), moduleHotfixRepo.Hash()),
 		},
+		{
+			name: "package filters passed",
+			packageSets: []rpmmd.PackageSet{
+				{
+					Include:      []string{"pkg1"},
+					Repositories: []rpmmd.RepoConfig{baseOS, filteredRepo},
+				},
+			},
+			wantJSON: fmt.Sprintf(`{
+				"api_version": 2,
+				"command": "depsolve",
+				"module_platform_id": "platform:el8",
+				"releasever": "8",
+				"arch": "x86_64",
+				"cachedir": "/cache",
+				"arguments": {
+					"repos": [
+						{"id": %[1]q, "name": "baseos", "baseurl": ["https://example.org/baseos"]},
+						{"id": %[2]q, "name": "vendor", "baseurl": ["https://example.org/vendor"], "includepkgs": ["vendor-*"], "excludepkgs": ["openssl*"]}
+					],
+					"transactions": [
+						{"package-specs": ["pkg1"], "repo-ids": [%[1]q, %[2]q], "install_weak_deps": false}
+					],
+					"root_dir": "/root",
+					"optional-metadata": ["filelists"]
+				}
+			}`, baseOS.Hash(), filteredRepo.Hash()),
+		},
 		{
 			name: "mtls certs passed",
 			packageSets: []rpmmd.PackageSet{
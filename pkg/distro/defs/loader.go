@@ -107,6 +107,10 @@ type DistroYAML struct {
 
 	OscapProfilesAllowList []oscap.Profile `yaml:"oscap_profiles_allowlist"`
 
+	// AptComponents are the archive components (e.g. "main",
+	// "contrib") used when depsolving APT based distributions
+	AptComponents []string `yaml:"apt_components"`
+
 	imageTypes map[string]ImageTypeYAML
 	// distro wide default image config
 	DistroImageConfig *distroImageConfig `yaml:"image_config,omitempty"`
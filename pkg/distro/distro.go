@@ -81,6 +81,18 @@ type CustomDepsolverDistro interface {
 	Depsolver(cacheDir string, archi arch.Arch) (solver *depsolvednf.Solver, cleanup func() error, err error)
 }
 
+// DepsolverDistro is a distribution that selects the depsolver for its
+// packages, which does not need to be osbuild-depsolve-dnf based (e.g. the
+// APT based depsolver of Debian like distributions).
+type DepsolverDistro interface {
+	Distro
+
+	// NewDepsolver returns the depsolver for the given architecture and
+	// a cleanup function to call when it is no longer needed. The solver
+	// is never nil when no error is returned.
+	NewDepsolver(cacheDir string, archi arch.Arch) (solver depsolvednf.Depsolver, cleanup func() error, err error)
+}
+
 // An Arch represents a given distribution's support for a given architecture.
 type Arch interface {
 	// Returns the name of the architecture.
@@ -195,6 +207,16 @@ type ImageOptions struct {
 	// empty (nil) the default from the distro is used. When set it overrides
 	// the default.
 	Preview *bool `json:"preview,omitempty"`
+
+	// ImageInfo installs a document into the image that describes how
+	// it was built (see imageinfo.Path).
+	ImageInfo bool `json:"image_info,omitempty"`
+
+	// SourceDateEpoch is the build time that is recorded in the image
+	// info, in seconds since the Unix epoch (like the SOURCE_DATE_EPOCH
+	// environment variable of reproducible builds). The build time is
+	// left out when it is not set so that the manifests are reproducible.
+	SourceDateEpoch *int64 `json:"source_date_epoch,omitempty"`
 }
 
 type BasePartitionTableMap map[string]disk.PartitionTable
@@ -26,6 +26,9 @@ type BootcDistro struct {
 	buildSourceInfo *osinfo.Info
 	unifiedKernel   bool
 	bootloader      *string
+	boundImages     []string
+	rootSize        uint64
+	tpm2LUKS        bool
 
 	id            distro.ID
 	defaultFs     string
@@ -132,6 +135,9 @@ func NewBootcWithLoader(loader *defs.Loader, name string, cinfo *bootc.Info) (*B
 		rootfsMinSize:   cinfo.Size * containerSizeToDiskSizeMultiplier,
 		bootloader:      cinfo.Bootloader,
 		unifiedKernel:   cinfo.UnifiedKernel,
+		boundImages:     cinfo.BoundImages,
+		rootSize:        cinfo.RootSize,
+		tpm2LUKS:        cinfo.BlockSetup == bootc.BlockSetupTPM2LUKS,
 	}
 
 	// load image types from bootc-generic-1
@@ -651,6 +651,15 @@ func TestManifestDirCustomizationsSad(t *testing.T) {
 	assert.EqualError(t, err, `the following custom directories are not allowed: ["/dir/not/allowed"]`)
 }
 
+func TestManifestTPM2LUKSWarning(t *testing.T) {
+	imgType := NewTestBootcImageType(t, "qcow2")
+	imgType.arch.distro.(*BootcDistro).tpm2LUKS = true
+
+	_, warnings, err := imgType.Manifest(&blueprint.Blueprint{}, distro.ImageOptions{}, nil, common.ToPtr(int64(0)))
+	require.NoError(t, err)
+	assert.Contains(t, warnings, "the tpm2-luks block setup of the container is not supported for disk images, the root filesystem is not encrypted")
+}
+
 func TestGenPartitionTableFromOSInfo(t *testing.T) {
 	var bp blueprint.Blueprint
 	imgType := NewTestBootcImageType(t, "qcow2")
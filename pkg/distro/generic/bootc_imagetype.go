@@ -225,6 +225,14 @@ func (t *bootcImageType) manifestForDisk(bp *blueprint.Blueprint, options distro
 
 	img.Bootloader = bd.bootloader
 	img.UnifiedKernel = bd.unifiedKernel
+	for _, ref := range bd.boundImages {
+		// bound images are pulled from their registries, like bootc
+		// does when it installs the container
+		img.BoundImages = append(img.BoundImages, container.SourceSpec{
+			Source: ref,
+			Name:   ref,
+		})
+	}
 
 	img.OSCustomizations.Users = users.UsersFromBP(customizations.GetUsers())
 
@@ -256,7 +264,17 @@ func (t *bootcImageType) manifestForDisk(bp *blueprint.Blueprint, options distro
 		img.OSCustomizations.KernelOptionsAppend = append(img.OSCustomizations.KernelOptionsAppend, kopts.Append)
 	}
 
-	rootfsMinSize := max(bd.rootfsMinSize, options.Size)
+	// bootc binds the LUKS volume of the tpm2-luks block setup to the
+	// TPM2 of the host it installs to, a disk image cannot be bound to the
+	// TPM2 of the machine that boots it when it is built. Encrypting the
+	// root filesystem of disk images is not implemented, they use the
+	// direct block setup.
+	var warnings []string
+	if bd.tpm2LUKS {
+		warnings = append(warnings, "the tpm2-luks block setup of the container is not supported for disk images, the root filesystem is not encrypted")
+	}
+
+	rootfsMinSize := max(bd.rootfsMinSize, options.Size, bd.rootSize)
 
 	pt, err := t.genPartitionTable(customizations, rootfsMinSize, rng)
 	if err != nil {
@@ -303,7 +321,7 @@ func (t *bootcImageType) manifestForDisk(bp *blueprint.Blueprint, options distro
 		return nil, nil, err
 	}
 
-	return &mf, nil, nil
+	return &mf, warnings, nil
 }
 
 func (t *bootcImageType) initAnacondaInstallerBaseFromSourceInfo(img *image.AnacondaInstallerBase, sourceInfo *osinfo.Info, customizations *blueprint.Customizations) error {
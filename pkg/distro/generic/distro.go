@@ -10,6 +10,8 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/depsolveapt"
+	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/defs"
 	"github.com/osbuild/images/pkg/manifest"
@@ -40,6 +42,9 @@ var (
 // distribution implements the distro.Distro interface
 var _ = distro.Distro(&distribution{})
 
+// distribution implements the distro.DepsolverDistro interface
+var _ = distro.DepsolverDistro(&distribution{})
+
 type distribution struct {
 	defs.DistroYAML
 
@@ -171,6 +176,16 @@ func (d *distribution) Runner() runner.RunnerConf {
 	return d.DistroYAML.Runner
 }
 
+// NewDepsolver returns the APT based depsolver for Debian like
+// distributions and the osbuild-depsolve-dnf based one for all others.
+func (d *distribution) NewDepsolver(cacheDir string, archi arch.Arch) (depsolvednf.Depsolver, func() error, error) {
+	noop := func() error { return nil }
+	if d.DistroLike == manifest.DISTRO_DEBIAN {
+		return depsolveapt.NewSolver(d.Codename(), d.AptComponents, archi.String(), d.Name(), cacheDir), noop, nil
+	}
+	return depsolvednf.NewSolver(d.ModulePlatformID(), d.Releasever(), archi.String(), d.Name(), cacheDir), noop, nil
+}
+
 func (d *distribution) BootstrapContainer(a string) (string, error) {
 	aa, err := arch.FromString(a)
 	if err != nil {
@@ -6,6 +6,9 @@ import (
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/depsolveapt"
+	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/generic"
 	testrepos "github.com/osbuild/images/test/data/repositories"
@@ -127,3 +130,26 @@ func TestManifestError(t *testing.T) {
 		}
 	}
 }
+
+func TestNewDepsolver(t *testing.T) {
+	for _, tc := range []struct {
+		distroName string
+		solverType any
+	}{
+		{"fedora-43", &depsolvednf.Solver{}},
+		{"rhel-10.0", &depsolvednf.Solver{}},
+		{"debian-12", &depsolveapt.Solver{}},
+	} {
+		t.Run(tc.distroName, func(t *testing.T) {
+			d := generic.DistroFactory(tc.distroName)
+			require.NotNil(t, d)
+
+			dd, ok := d.(distro.DepsolverDistro)
+			require.True(t, ok)
+			solver, cleanup, err := dd.NewDepsolver(t.TempDir(), arch.ARCH_X86_64)
+			require.NoError(t, err)
+			defer func() { assert.NoError(t, cleanup()) }()
+			assert.IsType(t, tc.solverType, solver)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/pkg/arch"
@@ -23,6 +24,7 @@ import (
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/flatpak"
 	"github.com/osbuild/images/pkg/image"
+	"github.com/osbuild/images/pkg/imageinfo"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
@@ -379,9 +381,48 @@ func osCustomizations(t *imageType, osPackageSet rpmmd.PackageSet, options distr
 		}
 	}
 
+	if options.ImageInfo {
+		osc.ImageInfo, err = newImageInfo(t, bp, options, slices.Concat(osc.BasePackages, osc.BlueprintPackages))
+		if err != nil {
+			return osc, err
+		}
+	}
+
 	return osc, nil
 }
 
+// newImageInfo returns the description of the image that is installed
+// into the image when ImageOptions.ImageInfo is set
+func newImageInfo(t *imageType, bp *blueprint.Blueprint, options distro.ImageOptions, packages []string) (*imageinfo.ImageInfo, error) {
+	distroName := t.arch.distro.Name()
+	inputsDigest, err := imageinfo.InputsDigest(distroName, t.arch.Name(), t.Name(), bp, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var topLevel []string
+	for _, pkg := range packages {
+		if !slices.Contains(topLevel, pkg) {
+			topLevel = append(topLevel, pkg)
+		}
+	}
+
+	info := &imageinfo.ImageInfo{
+		BlueprintName:    bp.Name,
+		BlueprintVersion: bp.Version,
+		Distro:           distroName,
+		Arch:             t.arch.Name(),
+		ImageType:        t.Name(),
+		InputsDigest:     inputsDigest,
+		LibraryVersion:   imageinfo.LibraryVersion(),
+		Packages:         topLevel,
+	}
+	if options.SourceDateEpoch != nil {
+		info.BuildTime = time.Unix(*options.SourceDateEpoch, 0).UTC()
+	}
+	return info, nil
+}
+
 func ociContainerCustomizations(t *imageType) manifest.OCIContainerCustomizations {
 	imageConfig := t.getDefaultImageConfig()
 
@@ -1203,6 +1244,7 @@ func ostreeDiskImage(t *imageType,
 	if t.ImageTypeYAML.UseOstreeRemotes {
 		img.Remote.URL = options.OSTree.URL
 		img.Remote.ContentURL = options.OSTree.ContentURL
+		img.Remote.GPGKeys = options.OSTree.GPGKeys
 	}
 
 	img.OSName = t.ImageTypeYAML.OSTree.Name
@@ -1250,6 +1292,7 @@ func ostreeSimplifiedInstallerImage(t *imageType,
 	if t.ImageTypeYAML.UseOstreeRemotes {
 		rawImg.Remote.URL = options.OSTree.URL
 		rawImg.Remote.ContentURL = options.OSTree.ContentURL
+		rawImg.Remote.GPGKeys = options.OSTree.GPGKeys
 	}
 	rawImg.OSName = t.OSTree.Name
 
@@ -1421,9 +1464,11 @@ func makeOSTreeParentCommit(options *ostree.ImageOptions, defaultRef string) (*o
 
 	}
 	parentCommit = &ostree.SourceSpec{
-		URL:  options.URL,
-		Ref:  parentRef,
-		RHSM: options.RHSM,
+		URL:         options.URL,
+		Ref:         parentRef,
+		RHSM:        options.RHSM,
+		GPGKeys:     options.GPGKeys,
+		Ed25519Keys: options.Ed25519Keys,
 	}
 	return parentCommit, commitRef
 }
@@ -1450,16 +1495,16 @@ func makeOSTreePayloadCommit(options *ostree.ImageOptions, defaultURL, defaultRe
 		return ostree.SourceSpec{}, fmt.Errorf("ostree commit ref required")
 	}
 
-	rhsm := false
+	source := ostree.SourceSpec{
+		URL: url,
+		Ref: ref,
+	}
 	if options != nil {
-		rhsm = options.RHSM
+		source.RHSM = options.RHSM
+		source.GPGKeys = options.GPGKeys
+		source.Ed25519Keys = options.Ed25519Keys
 	}
-
-	return ostree.SourceSpec{
-		URL:  url,
-		Ref:  ref,
-		RHSM: rhsm,
-	}, nil
+	return source, nil
 }
 
 // replace basic variables that might come from blueprint(s), these are not intended to be
@@ -3,6 +3,7 @@ package generic
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -12,6 +13,7 @@ import (
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/defs"
+	"github.com/osbuild/images/pkg/rpmmd"
 )
 
 func isoTestImageType() *imageType {
@@ -134,3 +136,50 @@ func TestReplaceBasictemplate(t *testing.T) {
 		assert.Equal(t, replaceBasicTemplate(tc.input, tc.arch), tc.expected)
 	}
 }
+
+func TestOSCustomizationsImageInfo(t *testing.T) {
+	d := common.Must(New("rhel-10.0"))
+	a := common.Must(d.GetArch("x86_64"))
+	it := common.Must(a.GetImageType("qcow2")).(*imageType)
+	bp := &blueprint.Blueprint{
+		Name:     "web",
+		Version:  "1.0.0",
+		Packages: []blueprint.Package{{Name: "nginx"}, {Name: "bash"}},
+	}
+	pkgSet := rpmmd.PackageSet{Include: []string{"bash", "kernel"}}
+
+	osc, err := osCustomizations(it, pkgSet, distro.ImageOptions{}, nil, bp)
+	require.NoError(t, err)
+	assert.Nil(t, osc.ImageInfo)
+
+	osc, err = osCustomizations(it, pkgSet, distro.ImageOptions{ImageInfo: true}, nil, bp)
+	require.NoError(t, err)
+	require.NotNil(t, osc.ImageInfo)
+	assert.Equal(t, "web", osc.ImageInfo.BlueprintName)
+	assert.Equal(t, "1.0.0", osc.ImageInfo.BlueprintVersion)
+	assert.Equal(t, "rhel-10.0", osc.ImageInfo.Distro)
+	assert.Equal(t, "x86_64", osc.ImageInfo.Arch)
+	assert.Equal(t, "qcow2", osc.ImageInfo.ImageType)
+	// the build time is only recorded when it is given
+	assert.True(t, osc.ImageInfo.BuildTime.IsZero())
+	assert.Len(t, osc.ImageInfo.InputsDigest, 64)
+	assert.NotEmpty(t, osc.ImageInfo.LibraryVersion)
+	// duplicates are only listed once
+	assert.Equal(t, []string{"bash", "kernel", "nginx"}, osc.ImageInfo.Packages)
+
+	// the digest changes with the inputs
+	bp.Version = "1.0.1"
+	osc2, err := osCustomizations(it, pkgSet, distro.ImageOptions{ImageInfo: true}, nil, bp)
+	require.NoError(t, err)
+	assert.NotEqual(t, osc.ImageInfo.InputsDigest, osc2.ImageInfo.InputsDigest)
+
+	// identical inputs give an identical image info
+	osc3, err := osCustomizations(it, pkgSet, distro.ImageOptions{ImageInfo: true}, nil, bp)
+	require.NoError(t, err)
+	assert.Equal(t, osc2.ImageInfo, osc3.ImageInfo)
+
+	epoch := int64(1760868000)
+	osc, err = osCustomizations(it, pkgSet, distro.ImageOptions{ImageInfo: true, SourceDateEpoch: &epoch}, nil, bp)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 19, 10, 0, 0, 0, time.UTC), osc.ImageInfo.BuildTime)
+}
@@ -289,6 +289,9 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 
 	containerSources := make([]container.SourceSpec, len(bp.Containers))
 	for idx, cont := range bp.Containers {
+		if _, _, isArchive := container.ParseArchiveSource(cont.Source); isArchive && cont.LocalStorage {
+			return nil, nil, fmt.Errorf("container %q is read from a file and cannot use the local storage", cont.Source)
+		}
 		containerSources[idx] = container.SourceSpec{
 			Source:    cont.Source,
 			Name:      cont.Name,
@@ -341,7 +344,7 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 
 	d := t.Arch().Distro()
 	switch idLike := d.IDLike(); idLike {
-	case manifest.DISTRO_FEDORA, manifest.DISTRO_ELN, manifest.DISTRO_EL7, manifest.DISTRO_EL10:
+	case manifest.DISTRO_FEDORA, manifest.DISTRO_ELN, manifest.DISTRO_EL7, manifest.DISTRO_EL10, manifest.DISTRO_DEBIAN:
 		// no specific options checkers
 	case manifest.DISTRO_EL8:
 		if err := checkOptionsRhel8(t, bp); err != nil {
@@ -273,6 +273,8 @@ func (t *TestImageType) Manifest(b *blueprint.Blueprint, options distro.ImageOpt
 			// copy any other options that might be specified
 			ostreeSource.URL = options.OSTree.URL
 			ostreeSource.RHSM = options.OSTree.RHSM
+			ostreeSource.GPGKeys = options.OSTree.GPGKeys
+			ostreeSource.Ed25519Keys = options.OSTree.Ed25519Keys
 		}
 		ostreeSources = []ostree.SourceSpec{ostreeSource}
 	}
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"github.com/osbuild/images/pkg/container"
-	"github.com/osbuild/images/pkg/ostree"
 )
 
 type SourceSpec struct {
@@ -13,11 +12,9 @@ type SourceSpec struct {
 	Reference Reference
 }
 
-// A flatpak source can return (based on the type of the registry in the `SourceSpec`) either a
-// container or an ostree commit.
+// A flatpak source returns (based on the type of the registry in the `SourceSpec`) a container.
 type Spec struct {
 	ContainerSpec *container.Spec
-	CommitSpec    *ostree.CommitSpec
 }
 
 func Resolve(source SourceSpec) (Spec, error) {
@@ -21,6 +21,11 @@ type BootcDiskImage struct {
 	ContainerSource      *container.SourceSpec
 	BuildContainerSource *container.SourceSpec
 
+	// BoundImages are the logically bound images of the container,
+	// they are copied into the container storage of bootc so that they
+	// are available without network access on the first boot
+	BoundImages []container.SourceSpec
+
 	Bootloader    *string
 	UnifiedKernel bool
 
@@ -112,6 +117,7 @@ func (img *BootcDiskImage) InstantiateManifestFromContainers(m *manifest.Manifes
 	}
 	rawImage.Bootloader = img.Bootloader
 	rawImage.UnifiedKernel = img.UnifiedKernel
+	rawImage.BoundImages = img.BoundImages
 	rawImage.PartitionTable = img.PartitionTable
 	rawImage.OSCustomizations = img.OSCustomizations
 	rawImage.DiskCustomizations = img.DiskCustomizations
@@ -0,0 +1,123 @@
+// Package imageinfo describes how an image was built. The description is
+// installed into the image (see Path) so that it can be answered from
+// inside a running system what built it.
+package imageinfo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// Path is the location of the image info document in the image
+const Path = "/usr/lib/osbuild/image-info.json"
+
+const imagesModulePath = "github.com/osbuild/images"
+
+// ImageInfo is the document that is installed into the image
+type ImageInfo struct {
+	BlueprintName    string `json:"blueprint_name,omitempty"`
+	BlueprintVersion string `json:"blueprint_version,omitempty"`
+
+	Distro    string `json:"distro"`
+	Arch      string `json:"arch"`
+	ImageType string `json:"image_type"`
+
+	// BuildTime is the time of the build as given by the caller (see
+	// distro.ImageOptions.SourceDateEpoch), it is left out when unset
+	// so that the document does not change between identical builds
+	BuildTime time.Time `json:"build_time,omitzero"`
+
+	// InputsDigest identifies the inputs of the manifest generation
+	// (see InputsDigest()). The manifest cannot contain its own digest
+	// so this is what is recorded instead.
+	InputsDigest string `json:"inputs_digest"`
+
+	// LibraryVersion is the version of the images library that
+	// generated the manifest
+	LibraryVersion string `json:"library_version"`
+
+	// Packages are the top-level packages of the image: the packages
+	// of the image type and the blueprint, not their dependencies.
+	// They are the NEVRAs of the installed packages when the image is
+	// built from a depsolved manifest.
+	Packages []string `json:"packages"`
+}
+
+// LibraryVersion returns the version of the images library that is built
+// into the running binary, "(devel)" when the library itself is the main
+// module.
+func LibraryVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == imagesModulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != imagesModulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
+
+// InputsDigest returns the hex encoded sha256 digest of the JSON encoding
+// of the inputs, e.g. the blueprint and the image options.
+func InputsDigest(inputs ...any) (string, error) {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, input := range inputs {
+		if err := enc.Encode(input); err != nil {
+			return "", fmt.Errorf("cannot compute digest of image inputs: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FsNodes returns the directory and the file that install the image info
+// document at Path.
+func (info *ImageInfo) FsNodes() (*fsnode.Directory, *fsnode.File, error) {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	// add a trailing newline to be nice to people that cat the file
+	data = append(data, '\n')
+
+	// no explicit mode or owner so that an existing directory is
+	// accepted, see osbuild.GenDirectoryNodesStages()
+	dir, err := fsnode.NewDirectory(filepath.Dir(Path), nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileMode := os.FileMode(0644)
+	file, err := fsnode.NewFile(Path, &fileMode, nil, nil, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dir, file, nil
+}
+
+// Read parses an image info document
+func Read(r io.Reader) (*ImageInfo, error) {
+	var info ImageInfo
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&info); err != nil {
+		return nil, fmt.Errorf("cannot parse image info: %w", err)
+	}
+	return &info, nil
+}
@@ -0,0 +1,75 @@
+package imageinfo_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/imageinfo"
+)
+
+func TestFsNodesRoundTrip(t *testing.T) {
+	info := &imageinfo.ImageInfo{
+		BlueprintName:    "web",
+		BlueprintVersion: "1.0.0",
+		Distro:           "fedora-42",
+		Arch:             "x86_64",
+		ImageType:        "qcow2",
+		BuildTime:        time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC),
+		InputsDigest:     "1234",
+		LibraryVersion:   "v0.200.0",
+		Packages:         []string{"bash-0:5.2.26-3.fc42.x86_64", "nginx"},
+	}
+
+	dir, file, err := info.FsNodes()
+	require.NoError(t, err)
+	assert.Equal(t, "/usr/lib/osbuild", dir.Path())
+	assert.Equal(t, imageinfo.Path, file.Path())
+	assert.True(t, strings.HasSuffix(string(file.Data()), "}\n"))
+
+	read, err := imageinfo.Read(bytes.NewReader(file.Data()))
+	require.NoError(t, err)
+	assert.Equal(t, info, read)
+}
+
+func TestFsNodesWithoutBuildTime(t *testing.T) {
+	info := &imageinfo.ImageInfo{
+		Distro:    "fedora-42",
+		Arch:      "x86_64",
+		ImageType: "qcow2",
+	}
+
+	_, file, err := info.FsNodes()
+	require.NoError(t, err)
+	assert.NotContains(t, string(file.Data()), "build_time")
+}
+
+func TestReadError(t *testing.T) {
+	_, err := imageinfo.Read(strings.NewReader("not json"))
+	assert.ErrorContains(t, err, "cannot parse image info: ")
+}
+
+func TestInputsDigest(t *testing.T) {
+	digest1, err := imageinfo.InputsDigest("fedora-42", map[string]string{"name": "web"})
+	require.NoError(t, err)
+	assert.Len(t, digest1, 64)
+
+	digest2, err := imageinfo.InputsDigest("fedora-42", map[string]string{"name": "web"})
+	require.NoError(t, err)
+	assert.Equal(t, digest1, digest2)
+
+	digest3, err := imageinfo.InputsDigest("fedora-42", map[string]string{"name": "db"})
+	require.NoError(t, err)
+	assert.NotEqual(t, digest1, digest3)
+
+	_, err = imageinfo.InputsDigest(func() {})
+	assert.ErrorContains(t, err, "cannot compute digest of image inputs")
+}
+
+func TestLibraryVersion(t *testing.T) {
+	assert.NotEmpty(t, imageinfo.LibraryVersion())
+}
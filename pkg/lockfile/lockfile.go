@@ -0,0 +1,386 @@
+// Package lockfile implements a lockfile format that records the result of
+// depsolving all package sets of a manifest (the exact packages, their
+// checksums and repositories and the module selections). A lockfile can
+// later be used to generate a manifest with the exact same packages without
+// depsolving again.
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Version is the version of the lockfile format
+const Version = 1
+
+// Lockfile contains the locked packages and module selections for each
+// package set chain (keyed by the pipeline name) of a manifest.
+type Lockfile struct {
+	Version     int                       `json:"version"`
+	PackageSets map[string]PackageSetLock `json:"package_sets"`
+}
+
+// PackageSetLock contains the locked packages of a single package set
+// chain. There is one transaction for each package set in the chain.
+type PackageSetLock struct {
+	// Solver is the name of the solver (e.g. "dnf5") that depsolved the
+	// package set chain
+	Solver       string      `json:"solver,omitempty"`
+	Transactions [][]Package `json:"transactions"`
+	Modules      []Module    `json:"modules,omitempty"`
+}
+
+// Package is a single locked package
+type Package struct {
+	Name     string `json:"name"`
+	Epoch    uint   `json:"epoch"`
+	Version  string `json:"version"`
+	Release  string `json:"release"`
+	Arch     string `json:"arch"`
+	Checksum string `json:"checksum"`
+	// Repo is the id (or the name if there is no id) of the repository
+	// the package was found in. It is informational only, on replay the
+	// package can be found in any of the configured repositories.
+	Repo string `json:"repo,omitempty"`
+}
+
+// NEVRA returns the Name-Epoch:Version-Release.Arch string of the package
+func (p Package) NEVRA() string {
+	return fmt.Sprintf("%s-%d:%s-%s.%s", p.Name, p.Epoch, p.Version, p.Release, p.Arch)
+}
+
+func (p Package) String() string {
+	if p.Checksum == "" {
+		return p.NEVRA()
+	}
+	return fmt.Sprintf("%s (%s)", p.NEVRA(), p.Checksum)
+}
+
+// Module is a locked module stream selection, it contains everything that
+// is needed to recreate the rpmmd.ModuleSpec.
+type Module struct {
+	Name         string   `json:"name"`
+	Stream       string   `json:"stream"`
+	Profiles     []string `json:"profiles,omitempty"`
+	State        string   `json:"state,omitempty"`
+	ConfigPath   string   `json:"config_path,omitempty"`
+	FailsafePath string   `json:"failsafe_path,omitempty"`
+	FailsafeData string   `json:"failsafe_data,omitempty"`
+}
+
+func packageFromRPMMD(pkg rpmmd.Package) Package {
+	var checksum string
+	if pkg.Checksum.Value != "" {
+		checksum = pkg.Checksum.String()
+	}
+	var repo string
+	if pkg.Repo != nil {
+		repo = pkg.Repo.Id
+		if repo == "" {
+			repo = pkg.Repo.Name
+		}
+	}
+	return Package{
+		Name:     pkg.Name,
+		Epoch:    pkg.Epoch,
+		Version:  pkg.Version,
+		Release:  pkg.Release,
+		Arch:     pkg.Arch,
+		Checksum: checksum,
+		Repo:     repo,
+	}
+}
+
+func moduleFromRPMMD(mod rpmmd.ModuleSpec) Module {
+	return Module{
+		Name:         mod.ModuleConfigFile.Data.Name,
+		Stream:       mod.ModuleConfigFile.Data.Stream,
+		Profiles:     mod.ModuleConfigFile.Data.Profiles,
+		State:        mod.ModuleConfigFile.Data.State,
+		ConfigPath:   mod.ModuleConfigFile.Path,
+		FailsafePath: mod.FailsafeFile.Path,
+		FailsafeData: mod.FailsafeFile.Data,
+	}
+}
+
+func (m Module) toRPMMD() rpmmd.ModuleSpec {
+	return rpmmd.ModuleSpec{
+		ModuleConfigFile: rpmmd.ModuleConfigFile{
+			Path: m.ConfigPath,
+			Data: rpmmd.ModuleConfigData{
+				Name:     m.Name,
+				Stream:   m.Stream,
+				Profiles: m.Profiles,
+				State:    m.State,
+			},
+		},
+		FailsafeFile: rpmmd.ModuleFailsafeFile{
+			Path: m.FailsafePath,
+			Data: m.FailsafeData,
+		},
+	}
+}
+
+// New creates a new lockfile from the depsolve results of all package set
+// chains of a manifest (as returned by depsolvednf.DepsolveAll()).
+func New(depsolved map[string]depsolvednf.DepsolveResult) *Lockfile {
+	lf := &Lockfile{
+		Version:     Version,
+		PackageSets: make(map[string]PackageSetLock, len(depsolved)),
+	}
+	for name, res := range depsolved {
+		psl := PackageSetLock{
+			Solver:       res.Solver,
+			Transactions: make([][]Package, 0, len(res.Transactions)),
+		}
+		for _, trans := range res.Transactions {
+			pkgs := make([]Package, 0, len(trans))
+			for _, pkg := range trans {
+				pkgs = append(pkgs, packageFromRPMMD(pkg))
+			}
+			psl.Transactions = append(psl.Transactions, pkgs)
+		}
+		for _, mod := range res.Modules {
+			psl.Modules = append(psl.Modules, moduleFromRPMMD(mod))
+		}
+		lf.PackageSets[name] = psl
+	}
+	return lf
+}
+
+// Read reads a lockfile
+func Read(r io.Reader) (*Lockfile, error) {
+	var lf Lockfile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&lf); err != nil {
+		return nil, fmt.Errorf("cannot decode lockfile: %w", err)
+	}
+	if lf.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version %d (expected %d)", lf.Version, Version)
+	}
+	return &lf, nil
+}
+
+// Write writes the lockfile as indented JSON
+func (lf *Lockfile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lf)
+}
+
+// Replay returns the depsolve results for the given package set chains
+// from the lockfile without depsolving. Every locked package is looked up
+// in the repositories of its package set chain via the solver so that the
+// results contain the current download locations and repository
+// configurations. If any locked package is not available a *VerifyError
+// with the details is returned. Replaying fails if a package that is
+// requested by a package set is not part of the lockfile (i.e. the package
+// sets changed since the lockfile was created).
+func (lf *Lockfile) Replay(solver depsolvednf.Depsolver, packageSets map[string][]rpmmd.PackageSet) (map[string]depsolvednf.DepsolveResult, error) {
+	if solver == nil {
+		return nil, fmt.Errorf("need a valid solver, got nil")
+	}
+
+	results := make(map[string]depsolvednf.DepsolveResult, len(packageSets))
+	verifyErr := &VerifyError{}
+	for _, name := range slices.Sorted(maps.Keys(packageSets)) {
+		pkgSets := packageSets[name]
+		psl, ok := lf.PackageSets[name]
+		if !ok {
+			return nil, fmt.Errorf("lockfile has no packages for package set %q", name)
+		}
+		if len(psl.Transactions) != len(pkgSets) {
+			return nil, fmt.Errorf("lockfile has %d transactions for package set %q but %d are required", len(psl.Transactions), name, len(pkgSets))
+		}
+
+		res, missing, err := replayPackageSet(solver, psl, pkgSets)
+		if err != nil {
+			return nil, fmt.Errorf("cannot replay package set %q: %w", name, err)
+		}
+		for _, m := range missing {
+			m.PackageSet = name
+			verifyErr.Missing = append(verifyErr.Missing, m)
+		}
+		results[name] = *res
+	}
+	if len(verifyErr.Missing) > 0 {
+		return nil, verifyErr
+	}
+	return results, nil
+}
+
+func replayPackageSet(solver depsolvednf.Depsolver, psl PackageSetLock, pkgSets []rpmmd.PackageSet) (*depsolvednf.DepsolveResult, []MissingPackage, error) {
+	// locked packages can come from the repositories of any package set
+	// in the chain
+	var repos []rpmmd.RepoConfig
+	seen := make(map[string]bool)
+	for _, ps := range pkgSets {
+		for _, repo := range ps.Repositories {
+			if hash := repo.Hash(); !seen[hash] {
+				seen[hash] = true
+				repos = append(repos, repo)
+			}
+		}
+	}
+
+	var names []string
+	for _, trans := range psl.Transactions {
+		for _, pkg := range trans {
+			if !slices.Contains(names, pkg.Name) {
+				names = append(names, pkg.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	var available rpmmd.PackageList
+	if len(names) > 0 {
+		var err error
+		available, err = solver.SearchMetadata(repos, names)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	byNEVRA := make(map[string][]rpmmd.Package)
+	byName := make(map[string][]rpmmd.Package)
+	for _, pkg := range available {
+		byNEVRA[pkg.FullNEVRA()] = append(byNEVRA[pkg.FullNEVRA()], pkg)
+		byName[pkg.Name] = append(byName[pkg.Name], pkg)
+	}
+
+	if err := checkRequested(psl, pkgSets, byNEVRA); err != nil {
+		return nil, nil, err
+	}
+
+	res := &depsolvednf.DepsolveResult{
+		Transactions: make(depsolvednf.TransactionList, 0, len(psl.Transactions)),
+		Solver:       psl.Solver,
+	}
+	var missing []MissingPackage
+	usedRepos := make(map[string]bool)
+	for _, trans := range psl.Transactions {
+		pkgs := make(rpmmd.PackageList, 0, len(trans))
+		for _, locked := range trans {
+			idx := slices.IndexFunc(byNEVRA[locked.NEVRA()], func(pkg rpmmd.Package) bool {
+				return locked.Checksum == "" || pkg.Checksum.String() == locked.Checksum
+			})
+			if idx < 0 {
+				m := MissingPackage{Locked: locked}
+				for _, pkg := range byName[locked.Name] {
+					m.Available = append(m.Available, packageFromRPMMD(pkg))
+				}
+				missing = append(missing, m)
+				continue
+			}
+			pkg := byNEVRA[locked.NEVRA()][idx]
+			pkgs = append(pkgs, pkg)
+			if pkg.Repo != nil && !usedRepos[pkg.Repo.Hash()] {
+				usedRepos[pkg.Repo.Hash()] = true
+				res.Repos = append(res.Repos, *pkg.Repo)
+			}
+		}
+		res.Transactions = append(res.Transactions, pkgs)
+	}
+	// point the packages to the repositories of the result, like the
+	// depsolver does
+	for _, trans := range res.Transactions {
+		for idx := range trans {
+			if trans[idx].Repo == nil {
+				continue
+			}
+			hash := trans[idx].Repo.Hash()
+			repoIdx := slices.IndexFunc(res.Repos, func(repo rpmmd.RepoConfig) bool {
+				return repo.Hash() == hash
+			})
+			trans[idx].Repo = &res.Repos[repoIdx]
+		}
+	}
+	for _, mod := range psl.Modules {
+		res.Modules = append(res.Modules, mod.toRPMMD())
+	}
+	return res, missing, nil
+}
+
+// checkRequested returns an error if a package requested by one of the
+// package sets is neither locked in the corresponding transaction nor in
+// one of the previous transactions of the chain. Requested packages can be
+// given by name (optionally with version, release and arch) or by one of
+// the capabilities they provide. Groups, file paths, globs and versioned
+// capabilities cannot be checked without depsolving and are skipped.
+func checkRequested(psl PackageSetLock, pkgSets []rpmmd.PackageSet, byNEVRA map[string][]rpmmd.Package) error {
+	satisfied := make(map[string]bool)
+	var errs []string
+	for idx, ps := range pkgSets {
+		for _, locked := range psl.Transactions[idx] {
+			nvr := fmt.Sprintf("%s-%s-%s", locked.Name, locked.Version, locked.Release)
+			for _, spec := range []string{
+				locked.Name,
+				locked.Name + "." + locked.Arch,
+				locked.Name + "-" + locked.Version,
+				nvr,
+				nvr + "." + locked.Arch,
+				locked.NEVRA(),
+			} {
+				satisfied[spec] = true
+			}
+			for _, pkg := range byNEVRA[locked.NEVRA()] {
+				for _, prov := range pkg.Provides {
+					satisfied[prov.Name] = true
+				}
+			}
+		}
+		for _, spec := range ps.Include {
+			if strings.HasPrefix(spec, "@") || strings.HasPrefix(spec, "/") || strings.ContainsAny(spec, "*?[ ") {
+				continue
+			}
+			if !satisfied[spec] {
+				errs = append(errs, fmt.Sprintf("%q (transaction %d)", spec, idx+1))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("requested packages not in the lockfile: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// MissingPackage is a locked package that is not available in the
+// configured repositories.
+type MissingPackage struct {
+	PackageSet string
+	Locked     Package
+	// Available are the packages with the same name that are available
+	// instead (if any)
+	Available []Package
+}
+
+// VerifyError is returned when locked packages are not available in the
+// configured repositories.
+type VerifyError struct {
+	Missing []MissingPackage
+}
+
+func (e *VerifyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d locked package(s) not available in the configured repositories:", len(e.Missing))
+	var pkgSet string
+	for idx, m := range e.Missing {
+		if idx == 0 || m.PackageSet != pkgSet {
+			pkgSet = m.PackageSet
+			fmt.Fprintf(&b, "\n%s:", pkgSet)
+		}
+		fmt.Fprintf(&b, "\n- %s", m.Locked)
+		for _, avail := range m.Available {
+			fmt.Fprintf(&b, "\n+ %s", avail)
+		}
+	}
+	return b.String()
+}
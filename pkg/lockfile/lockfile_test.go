@@ -0,0 +1,257 @@
+package lockfile_test
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/lockfile"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+var testRepo = rpmmd.RepoConfig{
+	Id:       "baseos",
+	BaseURLs: []string{"https://example.com/baseos"},
+}
+
+func testPkg(name, version, checksum string) rpmmd.Package {
+	return rpmmd.Package{
+		Name:            name,
+		Version:         version,
+		Release:         "1.el9",
+		Arch:            "x86_64",
+		Checksum:        rpmmd.Checksum{Type: "sha256", Value: checksum},
+		RemoteLocations: []string{fmt.Sprintf("https://example.com/baseos/%s-%s-1.el9.x86_64.rpm", name, version)},
+		RepoID:          testRepo.Hash(),
+		Repo:            &testRepo,
+	}
+}
+
+// fakeSolver implements SearchMetadata on a fixed list of packages
+type fakeSolver struct {
+	available rpmmd.PackageList
+	repos     []rpmmd.RepoConfig
+}
+
+func (s *fakeSolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	return nil, fmt.Errorf("depsolve must not be called when replaying a lockfile")
+}
+
+func (s *fakeSolver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	return s.available, nil
+}
+
+func (s *fakeSolver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	s.repos = repos
+	var res rpmmd.PackageList
+	for _, pkg := range s.available {
+		for _, pattern := range packages {
+			if match, _ := path.Match(pattern, pkg.Name); match {
+				res = append(res, pkg)
+			}
+		}
+	}
+	return res, nil
+}
+
+func testDepsolved() map[string]depsolvednf.DepsolveResult {
+	return map[string]depsolvednf.DepsolveResult{
+		"build": {
+			Transactions: depsolvednf.TransactionList{
+				{testPkg("rpm", "4.16", "aaa"), testPkg("bash", "5.1", "bbb")},
+			},
+			Solver: "dnf5",
+		},
+		"os": {
+			Solver: "dnf5",
+			Transactions: depsolvednf.TransactionList{
+				{testPkg("bash", "5.1", "bbb")},
+				{testPkg("nodejs", "20.1", "ccc")},
+			},
+			Modules: []rpmmd.ModuleSpec{
+				{
+					ModuleConfigFile: rpmmd.ModuleConfigFile{
+						Path: "/etc/dnf/modules.d/nodejs.module",
+						Data: rpmmd.ModuleConfigData{Name: "nodejs", Stream: "20", Profiles: []string{"common"}, State: "enabled"},
+					},
+					FailsafeFile: rpmmd.ModuleFailsafeFile{
+						Path: "/var/lib/dnf/modulefailsafe/nodejs:20.x86_64.yaml",
+						Data: "document: modulemd\n",
+					},
+				},
+			},
+		},
+	}
+}
+
+func testPackageSets() map[string][]rpmmd.PackageSet {
+	return map[string][]rpmmd.PackageSet{
+		"build": {{Include: []string{"rpm"}, Repositories: []rpmmd.RepoConfig{testRepo}}},
+		"os": {
+			{Include: []string{"bash"}, Repositories: []rpmmd.RepoConfig{testRepo}},
+			{Include: []string{"nodejs"}, EnabledModules: []string{"nodejs:20"}, Repositories: []rpmmd.RepoConfig{testRepo}},
+		},
+	}
+}
+
+func TestLockfileNew(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	assert.Equal(t, lockfile.Version, lf.Version)
+	assert.Equal(t, [][]lockfile.Package{
+		{
+			// the order of the depsolve result is kept
+			{Name: "rpm", Version: "4.16", Release: "1.el9", Arch: "x86_64", Checksum: "sha256:aaa", Repo: "baseos"},
+			{Name: "bash", Version: "5.1", Release: "1.el9", Arch: "x86_64", Checksum: "sha256:bbb", Repo: "baseos"},
+		},
+	}, lf.PackageSets["build"].Transactions)
+	assert.Equal(t, "dnf5", lf.PackageSets["build"].Solver)
+	assert.Equal(t, []lockfile.Module{
+		{
+			Name:         "nodejs",
+			Stream:       "20",
+			Profiles:     []string{"common"},
+			State:        "enabled",
+			ConfigPath:   "/etc/dnf/modules.d/nodejs.module",
+			FailsafePath: "/var/lib/dnf/modulefailsafe/nodejs:20.x86_64.yaml",
+			FailsafeData: "document: modulemd\n",
+		},
+	}, lf.PackageSets["os"].Modules)
+}
+
+func TestLockfileWriteRead(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+
+	var buf bytes.Buffer
+	require.NoError(t, lf.Write(&buf))
+	lf2, err := lockfile.Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, lf, lf2)
+}
+
+func TestLockfileReadErrors(t *testing.T) {
+	_, err := lockfile.Read(bytes.NewBufferString(`{"version": 99, "package_sets": {}}`))
+	assert.EqualError(t, err, "unsupported lockfile version 99 (expected 1)")
+
+	_, err = lockfile.Read(bytes.NewBufferString(`{"version": 1, "unknown": true}`))
+	assert.ErrorContains(t, err, `cannot decode lockfile: json: unknown field "unknown"`)
+}
+
+func TestLockfileReplay(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	solver := &fakeSolver{
+		available: rpmmd.PackageList{
+			testPkg("bash", "5.1", "bbb"),
+			testPkg("bash", "5.2", "bbb2"),
+			testPkg("nodejs", "20.1", "ccc"),
+			testPkg("rpm", "4.16", "aaa"),
+			testPkg("rpm", "4.18", "aaa2"),
+		},
+	}
+
+	res, err := lf.Replay(solver, testPackageSets())
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, []rpmmd.RepoConfig{testRepo}, solver.repos)
+
+	os := res["os"]
+	require.Len(t, os.Transactions, 2)
+	assert.Equal(t, rpmmd.PackageList{testPkg("bash", "5.1", "bbb")}, os.Transactions[0])
+	assert.Equal(t, rpmmd.PackageList{testPkg("nodejs", "20.1", "ccc")}, os.Transactions[1])
+	assert.Equal(t, testDepsolved()["os"].Modules, os.Modules)
+	assert.Equal(t, []rpmmd.RepoConfig{testRepo}, os.Repos)
+	assert.Equal(t, "dnf5", os.Solver)
+	// the packages point to the repositories of the result
+	for _, trans := range os.Transactions {
+		for _, pkg := range trans {
+			assert.Same(t, &os.Repos[0], pkg.Repo)
+		}
+	}
+
+	build := res["build"]
+	require.Len(t, build.Transactions, 1)
+	assert.Equal(t, []string{"rpm-4.16-1.el9", "bash-5.1-1.el9"}, []string{build.Transactions[0][0].NVR(), build.Transactions[0][1].NVR()})
+}
+
+func TestLockfileReplayMissingPackages(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	solver := &fakeSolver{
+		available: rpmmd.PackageList{
+			// same NEVRA, different checksum
+			testPkg("bash", "5.1", "rebuilt"),
+			testPkg("rpm", "4.16", "aaa"),
+			// only a newer version is available
+			testPkg("nodejs", "20.2", "ddd"),
+		},
+	}
+
+	_, err := lf.Replay(solver, testPackageSets())
+	var verifyErr *lockfile.VerifyError
+	require.ErrorAs(t, err, &verifyErr)
+	assert.Len(t, verifyErr.Missing, 3)
+	assert.Equal(t, `3 locked package(s) not available in the configured repositories:
+build:
+- bash-0:5.1-1.el9.x86_64 (sha256:bbb)
++ bash-0:5.1-1.el9.x86_64 (sha256:rebuilt)
+os:
+- bash-0:5.1-1.el9.x86_64 (sha256:bbb)
++ bash-0:5.1-1.el9.x86_64 (sha256:rebuilt)
+- nodejs-0:20.1-1.el9.x86_64 (sha256:ccc)
++ nodejs-0:20.2-1.el9.x86_64 (sha256:ddd)`, err.Error())
+}
+
+func TestLockfileReplayRequested(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	bash := testPkg("bash", "5.1", "bbb")
+	bash.Provides = rpmmd.RelDepList{{Name: "sh"}}
+	solver := &fakeSolver{
+		available: rpmmd.PackageList{
+			bash,
+			testPkg("nodejs", "20.1", "ccc"),
+			testPkg("rpm", "4.16", "aaa"),
+		},
+	}
+
+	// packages can be requested by name, nevra or a provided capability
+	// and they can be locked in a previous transaction of the chain,
+	// groups and globs are not checked
+	pkgSets := testPackageSets()
+	pkgSets["os"][1].Include = []string{"nodejs-20.1-1.el9.x86_64", "sh", "bash.x86_64", "@core", "vim-*"}
+	_, err := lf.Replay(solver, pkgSets)
+	require.NoError(t, err)
+
+	pkgSets = testPackageSets()
+	pkgSets["os"][0].Include = []string{"bash", "vim"}
+	pkgSets["os"][1].Include = []string{"nodejs", "nodejs-devel"}
+	_, err = lf.Replay(solver, pkgSets)
+	assert.EqualError(t, err, `cannot replay package set "os": requested packages not in the lockfile: "vim" (transaction 1), "nodejs-devel" (transaction 2)`)
+
+	// packages of later transactions do not satisfy earlier requests
+	pkgSets = testPackageSets()
+	pkgSets["os"][0].Include = []string{"nodejs"}
+	_, err = lf.Replay(solver, pkgSets)
+	assert.EqualError(t, err, `cannot replay package set "os": requested packages not in the lockfile: "nodejs" (transaction 1)`)
+}
+
+func TestLockfileReplayMismatch(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	solver := &fakeSolver{}
+
+	pkgSets := testPackageSets()
+	pkgSets["installer"] = pkgSets["build"]
+	_, err := lf.Replay(solver, pkgSets)
+	assert.EqualError(t, err, `lockfile has no packages for package set "installer"`)
+
+	pkgSets = testPackageSets()
+	pkgSets["os"] = pkgSets["os"][:1]
+	_, err = lf.Replay(solver, pkgSets)
+	assert.EqualError(t, err, `lockfile has 2 transactions for package set "os" but 1 are required`)
+
+	_, err = lf.Replay(nil, pkgSets)
+	assert.EqualError(t, err, "need a valid solver, got nil")
+}
@@ -0,0 +1,96 @@
+package lockfile
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// SecurityUpdate depsolves the package set chains in "security-only" mode:
+// every package of the lockfile is pinned to its locked version unless a
+// security advisory applies to it, in which case it is updated to the
+// oldest version that fixes all its security advisories (like "dnf
+// upgrade-minimal --security"). Packages that are not part of the lockfile
+// (e.g. new dependencies of the security updates or packages that were
+// added to the package sets) are depsolved as usual.
+//
+// The advisories are typically read from the updateinfo metadata of the
+// repositories, see rpmmd.MetadataReader.FetchAdvisories(). They are
+// attached to the packages of the results.
+func (lf *Lockfile) SecurityUpdate(solver depsolvednf.Depsolver, packageSets map[string][]rpmmd.PackageSet, advisories []rpmmd.Advisory) (map[string]depsolvednf.DepsolveResult, error) {
+	if solver == nil {
+		return nil, fmt.Errorf("need a valid solver, got nil")
+	}
+
+	results := make(map[string]depsolvednf.DepsolveResult, len(packageSets))
+	for _, name := range slices.Sorted(maps.Keys(packageSets)) {
+		pkgSets := packageSets[name]
+		psl, ok := lf.PackageSets[name]
+		if !ok {
+			return nil, fmt.Errorf("lockfile has no packages for package set %q", name)
+		}
+		if len(psl.Transactions) != len(pkgSets) {
+			return nil, fmt.Errorf("lockfile has %d transactions for package set %q but %d are required", len(psl.Transactions), name, len(pkgSets))
+		}
+
+		res, err := solver.Depsolve(pinPackageSets(psl, pkgSets, advisories), sbom.StandardTypeNone)
+		if err != nil {
+			return nil, fmt.Errorf("error depsolving package sets for %q: %w", name, err)
+		}
+		for _, trans := range res.Transactions {
+			rpmmd.AttachAdvisories(trans, advisories)
+		}
+		results[name] = *res
+	}
+	return results, nil
+}
+
+// pinPackageSets returns a copy of the package sets that request the exact
+// locked (or security updated) versions of the locked packages
+func pinPackageSets(psl PackageSetLock, pkgSets []rpmmd.PackageSet, advisories []rpmmd.Advisory) []rpmmd.PackageSet {
+	locked := make(map[string]bool)
+	for _, trans := range psl.Transactions {
+		for _, pkg := range trans {
+			locked[pkg.Name] = true
+		}
+	}
+
+	pinned := make([]rpmmd.PackageSet, 0, len(pkgSets))
+	for idx, ps := range pkgSets {
+		var include []string
+		for _, pkg := range psl.Transactions[idx] {
+			spec := pkg.NEVRA()
+			if update, ok := rpmmd.SecurityUpdate(pkg.toRPMMD(), advisories); ok {
+				spec = update.FullNEVRA()
+			}
+			include = append(include, spec)
+		}
+		// requested packages that are locked are replaced by the
+		// pinned versions, everything else (e.g. groups or packages
+		// that were not requested when the lockfile was created) is
+		// kept
+		for _, spec := range ps.Include {
+			if !locked[spec] && !slices.Contains(include, spec) {
+				include = append(include, spec)
+			}
+		}
+		ps.Include = include
+		pinned = append(pinned, ps)
+	}
+	return pinned
+}
+
+// toRPMMD returns a Package with the NEVRA of the locked package
+func (p Package) toRPMMD() rpmmd.Package {
+	return rpmmd.Package{
+		Name:    p.Name,
+		Epoch:   p.Epoch,
+		Version: p.Version,
+		Release: p.Release,
+		Arch:    p.Arch,
+	}
+}
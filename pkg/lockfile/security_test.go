@@ -0,0 +1,79 @@
+package lockfile_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/lockfile"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/sbom"
+)
+
+// recordingSolver records the package sets of Depsolve() and returns a
+// fixed result
+type recordingSolver struct {
+	fakeSolver
+	pkgSets [][]rpmmd.PackageSet
+	result  depsolvednf.DepsolveResult
+}
+
+func (s *recordingSolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	s.pkgSets = append(s.pkgSets, pkgSets)
+	res := s.result
+	return &res, nil
+}
+
+func TestLockfileSecurityUpdate(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	advisories := []rpmmd.Advisory{
+		{
+			ID:       "RHSA-2024:0001",
+			Type:     rpmmd.AdvisoryTypeSecurity,
+			CVEs:     []string{"CVE-2024-0001"},
+			Packages: []rpmmd.AdvisoryPackage{{Name: "bash", Version: "5.1", Release: "2.el9", Arch: "x86_64"}},
+		},
+		{
+			ID:       "RHBA-2024:0002",
+			Type:     rpmmd.AdvisoryTypeBugfix,
+			Packages: []rpmmd.AdvisoryPackage{{Name: "nodejs", Version: "20.2", Release: "1.el9", Arch: "x86_64"}},
+		},
+	}
+	bash := testPkg("bash", "5.1", "ddd")
+	bash.Release = "2.el9"
+	solver := &recordingSolver{
+		result: depsolvednf.DepsolveResult{
+			Transactions: depsolvednf.TransactionList{{bash}},
+		},
+	}
+
+	pkgSets := testPackageSets()
+	// a package that was added after the lockfile was created
+	pkgSets["os"][1].Include = append(pkgSets["os"][1].Include, "vim-minimal")
+	results, err := lf.SecurityUpdate(solver, pkgSets, advisories)
+	require.NoError(t, err)
+
+	// the package sets are depsolved in the order of their names
+	require.Len(t, solver.pkgSets, 2)
+	assert.Equal(t, []string{"rpm-0:4.16-1.el9.x86_64", "bash-0:5.1-2.el9.x86_64"}, solver.pkgSets[0][0].Include)
+	assert.Equal(t, []string{"bash-0:5.1-2.el9.x86_64"}, solver.pkgSets[1][0].Include)
+	// bugfix advisories are ignored
+	assert.Equal(t, []string{"nodejs-0:20.1-1.el9.x86_64", "vim-minimal"}, solver.pkgSets[1][1].Include)
+	assert.Equal(t, []string{"nodejs:20"}, solver.pkgSets[1][1].EnabledModules)
+	// the original package sets are not modified
+	assert.Equal(t, []string{"bash"}, pkgSets["os"][0].Include)
+
+	assert.Equal(t, []rpmmd.PackageAdvisory{
+		{ID: "RHSA-2024:0001", Type: "security", CVEs: []string{"CVE-2024-0001"}},
+	}, results["os"].Transactions[0][0].Advisories)
+}
+
+func TestLockfileSecurityUpdateMismatch(t *testing.T) {
+	lf := lockfile.New(testDepsolved())
+	pkgSets := testPackageSets()
+	pkgSets["os"] = pkgSets["os"][:1]
+	_, err := lf.SecurityUpdate(&recordingSolver{}, pkgSets, nil)
+	assert.EqualError(t, err, `lockfile has 2 transactions for package set "os" but 1 are required`)
+}
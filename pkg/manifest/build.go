@@ -137,11 +137,20 @@ func (p *BuildrootFromPackages) addDependent(dep Pipeline) {
 func (p *BuildrootFromPackages) getPackageSetChain(distro Distro) ([]rpmmd.PackageSet, error) {
 	// TODO: make the /usr/bin/cp dependency conditional
 	// TODO: make the /usr/bin/xz dependency conditional
-	policyPackage := fmt.Sprintf("selinux-policy-%s", p.selinuxPolicy)
-	packages := []string{
-		policyPackage, // needed to build the build pipeline
-		"coreutils",   // /usr/bin/cp - used all over
-		"xz",          // usage unclear
+	var packages []string
+	switch distro {
+	case DISTRO_DEBIAN:
+		packages = []string{
+			"coreutils", // /usr/bin/cp - used all over
+			"xz-utils",  // usage unclear
+		}
+	default:
+		policyPackage := fmt.Sprintf("selinux-policy-%s", p.selinuxPolicy)
+		packages = []string{
+			policyPackage, // needed to build the build pipeline
+			"coreutils",   // /usr/bin/cp - used all over
+			"xz",          // usage unclear
+		}
 	}
 
 	packages = append(packages, p.runner.GetBuildPackages()...)
@@ -202,11 +211,15 @@ func (p *BuildrootFromPackages) serialize() (osbuild.Pipeline, error) {
 			IgnoreImportFailures: true,
 		}
 	}
-	rpmStages, err := osbuild.GenRPMStagesFromTransactions(p.depsolveResult.Transactions, &baseOptions)
-	if err != nil {
-		return osbuild.Pipeline{}, err
+	if p.Manifest().Distro == DISTRO_DEBIAN {
+		pipeline.AddStages(osbuild.GenAptStagesFromTransactions(p.depsolveResult.Transactions)...)
+	} else {
+		rpmStages, err := osbuild.GenRPMStagesFromTransactions(p.depsolveResult.Transactions, &baseOptions)
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		pipeline.AddStages(rpmStages...)
 	}
-	pipeline.AddStages(rpmStages...)
 
 	if !p.disableSelinux {
 		pipeline.AddStage(osbuild.NewSELinuxStage(&osbuild.SELinuxStageOptions{
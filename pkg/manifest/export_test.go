@@ -11,8 +11,9 @@ import (
 )
 
 var (
-	DistroNames  = distroNames
-	DISTRO_COUNT = _distro_count
+	DistroNames     = distroNames
+	DISTRO_COUNT    = _distro_count
+	DebianKernelVer = debianKernelVer
 )
 
 func (p *OS) GetBuildPackages(d Distro) ([]string, error) {
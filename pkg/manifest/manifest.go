@@ -35,6 +35,7 @@ const (
 	DISTRO_EL7
 	DISTRO_FEDORA
 	DISTRO_ELN
+	DISTRO_DEBIAN
 	_distro_count
 )
 
@@ -46,6 +47,7 @@ var distroNames = map[Distro]string{
 	DISTRO_EL7:    "rhel-7",
 	DISTRO_FEDORA: "fedora",
 	DISTRO_ELN:    "eln",
+	DISTRO_DEBIAN: "debian",
 }
 
 func (d Distro) String() string {
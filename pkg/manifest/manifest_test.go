@@ -22,6 +22,7 @@ func TestDistroUnmarshal(t *testing.T) {
 		{`"rhel-8"`, manifest.DISTRO_EL8},
 		{`"rhel-7"`, manifest.DISTRO_EL7},
 		{`"fedora"`, manifest.DISTRO_FEDORA},
+		{`"debian"`, manifest.DISTRO_DEBIAN},
 	} {
 		err := distro.UnmarshalJSON([]byte(tc.inp))
 		assert.NoError(t, err)
@@ -23,6 +23,7 @@ import (
 	"github.com/osbuild/images/pkg/customizations/users"
 	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/imageinfo"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/platform"
@@ -179,6 +180,11 @@ type OSCustomizations struct {
 	// instead of BLS. Required for legacy systems like RHEL 7.
 	NoBLS bool
 
+	// ImageInfo is installed into the image at imageinfo.Path when
+	// set. The top-level packages are resolved to the NEVRAs of the
+	// depsolved packages.
+	ImageInfo *imageinfo.ImageInfo
+
 	// InstallWeakDeps enables installation of weak dependencies for packages
 	// that are statically defined for the pipeline.
 	// Defaults to True.
@@ -408,7 +414,12 @@ func (p *OS) getBuildPackages(distro Distro) ([]string, error) {
 	if p.PartitionTable != nil {
 		packages = append(packages, p.PartitionTable.GetBuildPackages()...)
 	}
-	packages = append(packages, "rpm")
+	switch distro {
+	case DISTRO_DEBIAN:
+		packages = append(packages, "dpkg")
+	default:
+		packages = append(packages, "rpm")
+	}
 	if p.OSTreeRef != "" {
 		packages = append(packages, "rpm-ostree")
 	}
@@ -516,11 +527,32 @@ func (p *OS) serializeStart(inputs Inputs) error {
 			return fmt.Errorf("OS: %w", err)
 		}
 		p.kernelVer = kernelPkg.EVRA()
+		if p.Manifest().Distro == DISTRO_DEBIAN {
+			p.kernelVer, err = debianKernelVer(p.depsolveResult.Transactions.AllPackages())
+			if err != nil {
+				return fmt.Errorf("OS: %w", err)
+			}
+		}
 	}
 
 	return nil
 }
 
+// debianKernelVer returns the kernel release of the installed Debian
+// kernel. Unlike on rpm based distributions the kernel release is not the
+// version of the package but part of the package name, e.g. the
+// "linux-image-amd64" meta package pulls in "linux-image-6.1.0-28-amd64"
+// which installs /boot/vmlinuz-6.1.0-28-amd64.
+func debianKernelVer(pkgs rpmmd.PackageList) (string, error) {
+	for _, pkg := range pkgs {
+		release, ok := strings.CutPrefix(pkg.Name, "linux-image-")
+		if ok && release != "" && release[0] >= '0' && release[0] <= '9' {
+			return release, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find a versioned linux-image package")
+}
+
 func (p *OS) serializeEnd() {
 	if p.depsolveResult == nil {
 		panic("serializeEnd() call when serialization not in progress")
@@ -605,11 +637,17 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		}
 	}
 
-	rpmStages, err := osbuild.GenRPMStagesFromTransactions(p.depsolveResult.Transactions, baseRPMOptions)
-	if err != nil {
-		return osbuild.Pipeline{}, err
+	if p.Manifest().Distro == DISTRO_DEBIAN {
+		// .deb packages are installed with apt, the rpm specific options
+		// do not apply
+		pipeline.AddStages(osbuild.GenAptStagesFromTransactions(p.depsolveResult.Transactions)...)
+	} else {
+		rpmStages, err := osbuild.GenRPMStagesFromTransactions(p.depsolveResult.Transactions, baseRPMOptions)
+		if err != nil {
+			return osbuild.Pipeline{}, err
+		}
+		pipeline.AddStages(rpmStages...)
 	}
-	pipeline.AddStages(rpmStages...)
 
 	if !p.OSCustomizations.NoBLS {
 		fixBLSOptions := &osbuild.FixBLSStageOptions{}
@@ -1035,6 +1073,17 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 		}))
 	}
 
+	if p.OSCustomizations.ImageInfo != nil {
+		info := *p.OSCustomizations.ImageInfo
+		info.Packages = resolveTopLevelPackages(info.Packages, p.depsolveResult.Transactions.AllPackages())
+		dir, file, err := info.FsNodes()
+		if err != nil {
+			return osbuild.Pipeline{}, fmt.Errorf("cannot create image info: %w", err)
+		}
+		pipeline.AddStages(osbuild.GenDirectoryNodesStages([]*fsnode.Directory{dir})...)
+		p.addStagesForAllFilesAndInlineData(&pipeline, []*fsnode.File{file})
+	}
+
 	if p.OSCustomizations.SELinux != "" {
 		pipeline.AddStage(osbuild.NewSELinuxStage(&osbuild.SELinuxStageOptions{
 			FileContexts:     fmt.Sprintf("etc/selinux/%s/contexts/files/file_contexts", p.OSCustomizations.SELinux),
@@ -1053,9 +1102,10 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 			pipeline.AddStage(osbuild.NewBootupdGenMetadataStage())
 		}
 		if cfg := p.BootcConfig; cfg != nil {
-			pipeline.AddStage(osbuild.NewBootcInstallConfigStage(
-				osbuild.GenBootcInstallOptions(cfg.Filename, cfg.RootFilesystemType),
-			))
+			opts := osbuild.GenBootcInstallOptions(cfg.Filename, cfg.RootFilesystemType)
+			opts.Config.Install.KernelArgs = cfg.KernelArgs
+			opts.Config.Install.Block = cfg.Block
+			pipeline.AddStage(osbuild.NewBootcInstallConfigStage(opts))
 		}
 	} else {
 		if p.Bootupd {
@@ -1069,6 +1119,24 @@ func (p *OS) serialize() (osbuild.Pipeline, error) {
 	return pipeline, nil
 }
 
+// resolveTopLevelPackages returns the NEVRAs of the depsolved packages
+// with the given names, names that are not in the depsolved packages
+// (e.g. because they are provides) are returned as they are
+func resolveTopLevelPackages(names []string, depsolved rpmmd.PackageList) []string {
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		idx := slices.IndexFunc(depsolved, func(pkg rpmmd.Package) bool {
+			return pkg.Name == name
+		})
+		if idx < 0 {
+			res = append(res, name)
+			continue
+		}
+		res = append(res, depsolved[idx].FullNEVRA())
+	}
+	return res
+}
+
 func prependKernelCmdlineStage(pipeline osbuild.Pipeline, rootUUID string, kernelOptions []string) osbuild.Pipeline {
 	kernelStage := osbuild.NewKernelCmdlineStage(osbuild.NewKernelCmdlineStageOptions(rootUUID, strings.Join(kernelOptions, " ")))
 	pipeline.Stages = append([]*osbuild.Stage{kernelStage}, pipeline.Stages...)
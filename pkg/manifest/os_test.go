@@ -3,6 +3,7 @@ package manifest_test
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,6 +18,7 @@ import (
 	"github.com/osbuild/images/pkg/customizations/subscription"
 	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/disk"
+	"github.com/osbuild/images/pkg/imageinfo"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
@@ -200,6 +202,26 @@ func TestTomlLibUsedNoneByDefault(t *testing.T) {
 	}
 }
 
+func TestDebianBuildPackagesUseDpkg(t *testing.T) {
+	os := manifest.NewTestOS()
+	buildPkgs, err := os.GetBuildPackages(manifest.DISTRO_DEBIAN)
+	assert.NoError(t, err)
+	assert.Contains(t, buildPkgs, "dpkg")
+	assert.NotContains(t, buildPkgs, "rpm")
+}
+
+func TestDebianKernelVer(t *testing.T) {
+	kernelVer, err := manifest.DebianKernelVer(rpmmd.PackageList{
+		{Name: "linux-image-amd64", Version: "6.1.119", Release: "1"},
+		{Name: "linux-image-6.1.0-28-amd64", Version: "6.1.119", Release: "1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "6.1.0-28-amd64", kernelVer)
+
+	_, err = manifest.DebianKernelVer(rpmmd.PackageList{{Name: "linux-image-amd64"}})
+	assert.EqualError(t, err, "cannot find a versioned linux-image package")
+}
+
 func TestTomlLibUsedForContainer(t *testing.T) {
 	os := manifest.NewTestOS()
 	os.OSCustomizations.Containers = []container.SourceSpec{
@@ -545,6 +567,54 @@ func collectCopyDestinationPaths(stages []*osbuild.Stage) []string {
 	return destinationPaths
 }
 
+func TestOSPipelineImageInfo(t *testing.T) {
+	os := manifest.NewTestOS()
+	os.OSCustomizations.ImageInfo = &imageinfo.ImageInfo{
+		BlueprintName: "web",
+		Distro:        "fedora-42",
+		Arch:          "x86_64",
+		ImageType:     "qcow2",
+		Packages:      []string{"bash", "not-a-package-name"},
+	}
+
+	inputs := manifest.Inputs{
+		Depsolved: depsolvednf.DepsolveResult{
+			Transactions: depsolvednf.TransactionList{
+				{
+					{
+						Name:     "bash",
+						Version:  "5.2.26",
+						Release:  "3.fc42",
+						Arch:     "x86_64",
+						Checksum: rpmmd.Checksum{Type: "sha256", Value: "7777777777777777777777777777777777777777777777777777777777777777"},
+						RepoID:   "dummy-repo-id",
+						Repo:     &rpmmd.RepoConfig{Id: "dummy-repo-id"},
+					},
+				},
+			},
+		},
+	}
+	pipeline, err := manifest.SerializeWith(os, inputs)
+	require.NoError(t, err)
+
+	assert.Contains(t, collectCopyDestinationPaths(pipeline.Stages), "tree://"+imageinfo.Path)
+	mkdir := findStage("org.osbuild.mkdir", pipeline.Stages)
+	require.NotNil(t, mkdir)
+
+	var inline []string
+	for _, data := range manifest.GetInline(os) {
+		if strings.Contains(data, `"blueprint_name": "web"`) {
+			inline = append(inline, data)
+		}
+	}
+	require.Len(t, inline, 1)
+	info, err := imageinfo.Read(strings.NewReader(inline[0]))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash-0:5.2.26-3.fc42.x86_64", "not-a-package-name"}, info.Packages)
+	// the customization itself is not changed
+	assert.Equal(t, []string{"bash", "not-a-package-name"}, os.OSCustomizations.ImageInfo.Packages)
+}
+
 func TestHMACStageInclusion(t *testing.T) {
 	repos := []rpmmd.RepoConfig{}
 	runner := &runner.CentOS{Version: 9}
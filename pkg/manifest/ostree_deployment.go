@@ -233,6 +233,7 @@ func (p *OSTreeDeployment) doOSTreeSpec(pipeline *osbuild.Pipeline, repoPath str
 						Name:        p.Remote.Name,
 						URL:         p.Remote.URL,
 						ContentURL:  p.Remote.ContentURL,
+						GPGKeys:     p.Remote.GPGKeys,
 						GPGKeyPaths: p.Remote.GPGKeyPaths,
 					},
 				},
@@ -3,6 +3,7 @@ package manifest_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/internal/testdisk"
@@ -120,3 +121,21 @@ func TestAddInlineOSTreeDeployment(t *testing.T) {
 	// order doesn't matter
 	require.ElementsMatch(expectedContents, fileContents)
 }
+
+func TestOSTreeDeploymentPipelineRemoteGPGKeys(t *testing.T) {
+	pipeline := NewTestOSTreeDeployment()
+	pipeline.PartitionTable = testdisk.MakeFakePartitionTable("/")
+	pipeline.Remote = ostree.Remote{
+		Name:    "edge",
+		URL:     "https://ostree.example.com/repo",
+		GPGKeys: []string{"gpg-key"},
+	}
+
+	osbuildPipeline, err := manifest.SerializeWith(pipeline, testCommitInputs())
+	require.NoError(t, err)
+	stage := findStage("org.osbuild.ostree.remotes", osbuildPipeline.Stages)
+	require.NotNil(t, stage)
+	remotes := stage.Options.(*osbuild.OSTreeRemotesStageOptions).Remotes
+	require.Len(t, remotes, 1)
+	assert.Equal(t, []string{"gpg-key"}, remotes[0].GPGKeys)
+}
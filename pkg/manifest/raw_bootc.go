@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/osbuild/images/internal/common"
@@ -39,6 +40,10 @@ type RawBootcImage struct {
 	UnifiedKernel bool
 	Bootloader    *string
 
+	// BoundImages are the logically bound images of the container that
+	// are copied into the container storage of bootc
+	BoundImages []container.SourceSpec
+
 	// customizations go here because there is no intermediate
 	// tree, with `bootc install to-filesystem` we can only work
 	// with the image itself
@@ -73,7 +78,7 @@ func NewRawBootcImage(buildPipeline Build, containers []container.SourceSpec, pl
 }
 
 func (p *RawBootcImage) getContainerSources() []container.SourceSpec {
-	return p.containers
+	return append(slices.Clone(p.containers), p.BoundImages...)
 }
 
 func (p *RawBootcImage) getContainerSpecs() []container.Spec {
@@ -95,6 +100,34 @@ func (p *RawBootcImage) serializeEnd() {
 	p.containerSpecs = nil
 }
 
+// bootcStoragePath is the container storage of bootc for the logically
+// bound images on the physical root, /usr/lib/bootc/storage links to it
+const bootcStoragePath = "/ostree/bootc/storage"
+
+// splitContainerSpecs returns the resolved spec of the bootc container
+// and the ones of the bound images. The resolved containers are sorted
+// by digest, the bootc container is found by its name.
+func (p *RawBootcImage) splitContainerSpecs() (container.Spec, []container.Spec, error) {
+	if len(p.BoundImages) == 0 || len(p.containers) == 0 {
+		if len(p.containerSpecs) != 1 {
+			return container.Spec{}, nil, fmt.Errorf("expected a single container input got %v", p.containerSpecs)
+		}
+		return p.containerSpecs[0], nil, nil
+	}
+
+	if len(p.containerSpecs) != len(p.BoundImages)+1 {
+		return container.Spec{}, nil, fmt.Errorf("expected %d container inputs got %v", len(p.BoundImages)+1, p.containerSpecs)
+	}
+	idx := slices.IndexFunc(p.containerSpecs, func(spec container.Spec) bool {
+		return spec.LocalName == p.containers[0].Name
+	})
+	if idx < 0 {
+		return container.Spec{}, nil, fmt.Errorf("cannot find the container %s in the container inputs %v", p.containers[0].Name, p.containerSpecs)
+	}
+	boundImageSpecs := slices.Delete(slices.Clone(p.containerSpecs), idx, idx+1)
+	return p.containerSpecs[idx], boundImageSpecs, nil
+}
+
 func buildHomedirPaths(users []users.User) []osbuild.MkdirStagePath {
 	var containsRootUser, containsNormalUser bool
 
@@ -143,8 +176,9 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 		pipeline.AddStage(stage)
 	}
 
-	if len(p.containerSpecs) != 1 {
-		return osbuild.Pipeline{}, fmt.Errorf("expected a single container input got %v", p.containerSpecs)
+	containerSpec, boundImageSpecs, err := p.splitContainerSpecs()
+	if err != nil {
+		return osbuild.Pipeline{}, err
 	}
 	opts := &osbuild.BootcInstallToFilesystemOptions{}
 	// Unified kernels cannot have custom kernel options
@@ -162,7 +196,7 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 		opts.TargetImgref = p.containers[0].Name
 	}
 	inputs := osbuild.ContainerDeployInputs{
-		Images: osbuild.NewContainersInputForSingleSource(p.containerSpecs[0]),
+		Images: osbuild.NewContainersInputForSingleSource(containerSpec),
 	}
 	devices, mounts, err := osbuild.GenBootupdDevicesMounts(p.filename, p.PartitionTable, p.platform)
 	if err != nil {
@@ -174,6 +208,22 @@ func (p *RawBootcImage) serialize() (osbuild.Pipeline, error) {
 	}
 	pipeline.AddStage(st)
 
+	if len(boundImageSpecs) > 0 {
+		// the bound images go into the storage of bootc on the physical
+		// root, which is the tree of the stages without the deployment
+		// mount
+		devices, mounts, err := osbuild.GenBootupdDevicesMounts(p.filename, p.PartitionTable, p.platform)
+		if err != nil {
+			return osbuild.Pipeline{}, fmt.Errorf("gen devices stage failed %w", err)
+		}
+		mounts = append(mounts, *osbuild.NewBindMount("bind-physical-root-to-tree", "mount://", "tree://"))
+		for _, stage := range osbuild.GenSkopeoContainersStorageStages(bootcStoragePath, boundImageSpecs) {
+			stage.Mounts = mounts
+			stage.Devices = devices
+			pipeline.AddStage(stage)
+		}
+	}
+
 	for _, stage := range osbuild.GenImageFinishStages(pt, p.filename) {
 		pipeline.AddStage(stage)
 	}
@@ -409,3 +409,59 @@ func TestRawBootcPXE(t *testing.T) {
 	assert.Contains(t, mkdirPaths, "/usr")
 	assert.Contains(t, mkdirPaths, "/proc")
 }
+
+func TestRawBootcImageSerializeBoundImages(t *testing.T) {
+	mani := manifest.New()
+	runner := &runner.Linux{}
+	build := manifest.NewBuildFromContainer(&mani, runner, nil, nil)
+	pf := &platform.Data{
+		Arch:       arch.ARCH_X86_64,
+		UEFIVendor: "test",
+	}
+
+	rawBootcPipeline := manifest.NewRawBootcImage(build, containers, pf)
+	rawBootcPipeline.PartitionTable = testdisk.MakeFakePartitionTable("/", "/boot", "/boot/efi")
+	rawBootcPipeline.BoundImages = []container.SourceSpec{
+		{Source: "quay.io/example/app:latest", Name: "quay.io/example/app:latest"},
+	}
+	assert.Equal(t, []container.SourceSpec{
+		{Name: "quay.io/centos-bootc/centos-bootc-dev:stream9"},
+		{Source: "quay.io/example/app:latest", Name: "quay.io/example/app:latest"},
+	}, mani.GetContainerSourceSpecs()["image"])
+
+	// the resolved containers are sorted by digest
+	err := rawBootcPipeline.SerializeStart(manifest.Inputs{Containers: []container.Spec{
+		{Source: "quay.io/example/app:latest", LocalName: "quay.io/example/app:latest", ImageID: "sha256:aaaa"},
+		{Source: "quay.io/centos-bootc/centos-bootc-dev:stream9", LocalName: "quay.io/centos-bootc/centos-bootc-dev:stream9", ImageID: "sha256:bbbb"},
+	}})
+	require.NoError(t, err)
+	pipeline, err := rawBootcPipeline.Serialize()
+	require.NoError(t, err)
+
+	bootcInst := findStage("org.osbuild.bootc.install-to-filesystem", pipeline.Stages)
+	require.NotNil(t, bootcInst)
+	images := bootcInst.Inputs.(osbuild.ContainerDeployInputs).Images
+	assert.Equal(t, map[string]osbuild.ContainersInputSourceRef{
+		"sha256:bbbb": {Name: "quay.io/centos-bootc/centos-bootc-dev:stream9"},
+	}, images.References)
+
+	skopeo := findStage("org.osbuild.skopeo", pipeline.Stages)
+	require.NotNil(t, skopeo)
+	dest := skopeo.Options.(*osbuild.SkopeoStageOptions).Destination.(osbuild.SkopeoDestinationContainersStorage)
+	assert.Equal(t, "/ostree/bootc/storage", dest.StoragePath)
+	assert.Equal(t, map[string]osbuild.ContainersInputSourceRef{
+		"sha256:aaaa": {Name: "quay.io/example/app:latest"},
+	}, skopeo.Inputs.(osbuild.SkopeoStageInputs).Images.References)
+	// the storage of bootc is on the physical root, not in the deployment
+	assert.Equal(t, -1, findMountIdx(skopeo.Mounts, "org.osbuild.ostree.deployment"))
+	assert.NotEqual(t, -1, findMountIdx(skopeo.Mounts, "org.osbuild.bind"))
+}
+
+func TestRawBootcImageSerializeBoundImagesMissing(t *testing.T) {
+	rawBootcPipeline := makeFakeRawBootcPipeline()
+	rawBootcPipeline.BoundImages = []container.SourceSpec{
+		{Source: "quay.io/example/app:latest", Name: "quay.io/example/app:latest"},
+	}
+	_, err := rawBootcPipeline.Serialize()
+	assert.ErrorContains(t, err, "expected 2 container inputs got")
+}
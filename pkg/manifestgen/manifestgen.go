@@ -2,34 +2,40 @@ package manifestgen
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 
+	"github.com/opencontainers/go-digest"
+
 	"github.com/osbuild/blueprint/pkg/blueprint"
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/bootc"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/depsolvednf"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/flatpak"
+	"github.com/osbuild/images/pkg/lockfile"
 	"github.com/osbuild/images/pkg/manifest"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/reporegistry"
+	"github.com/osbuild/images/pkg/rhsm"
 	"github.com/osbuild/images/pkg/rpmlist"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/osbuild/images/pkg/sbom"
 )
 
 const (
-	defaultDepsolverSBOMType = sbom.StandardTypeSpdx
-	defaultSBOMExt           = "spdx.json"
+	defaultSBOMType = sbom.StandardTypeSpdx
 
 	defaultDepsolveCacheDir = "osbuild-depsolve-dnf"
 )
@@ -55,6 +61,19 @@ type Options struct {
 	// content can be read
 	SBOMWriter SBOMWriterFunc
 
+	// SBOMType selects the standard of the SBOM documents that are
+	// passed to the SBOMWriter, the default is SPDX. CycloneDX
+	// documents are created from the depsolved packages, so unlike
+	// SPDX they are also available when a Lockfile is used.
+	SBOMType sbom.StandardType
+
+	// ImageSBOM makes the SBOMWriter also write a single SBOM of the
+	// image that describes all of its content: the packages of the
+	// payload pipelines and the embedded containers (with the packages
+	// of their own SBOMs), ostree commits and flatpaks, see
+	// sbom.MergeImage().
+	ImageSBOM bool
+
 	// WarningsOutput will receive any warnings that are part of
 	// the manifest generation. If it is unset any warnings will
 	// generate an error.
@@ -73,18 +92,85 @@ type Options struct {
 	// This is mostly useful for testing
 	OverrideRepos []rpmmd.RepoConfig
 
+	// Depsolver overrides the depsolver that is passed to the
+	// SolverDepsolve function (e.g. to use a remote solving service or
+	// recorded fixtures). If unset, the distro specific depsolver
+	// or the default osbuild-depsolve-dnf based one is used.
+	Depsolver depsolvednf.Depsolver
+
+	// SolverDepsolve depsolves with any depsolvednf.Depsolver, if
+	// unset DefaultSolverDepsolve is used. It is ignored when
+	// Depsolve is set.
+	SolverDepsolve SolverDepsolveFunc
+
 	// Custom "solver" functions, if unset the defaults will be
 	// used. Only needed for specialized use-cases.
+	//
+	// Depsolve only works with the osbuild-depsolve-dnf based
+	// solver, use SolverDepsolve for other depsolvers.
 	Depsolve          DepsolveFunc
 	ContainerResolver ContainerResolverFunc
 	CommitResolver    CommitResolverFunc
 	FlatpakResolver   FlatpakResolverFunc
+	BootstrapSolver   BootstrapSolverFunc
+
+	// ContainerSBOMResolver returns the SBOMs of the embedded
+	// containers for the ImageSBOM, if unset
+	// DefaultContainerSBOMResolver is used.
+	ContainerSBOMResolver ContainerSBOMResolverFunc
+
+	// ContainerSignatures configures the verification of container
+	// signatures by the default ContainerResolver.
+	ContainerSignatures container.SignatureOptions
 
 	// Use the a bootstrap container to buildroot (useful for e.g.
 	// cross-arch or cross-distro builds)
 	UseBootstrapContainer bool
 
+	// Run the depsolver inside the bootstrap container of the distro
+	// (see distro.Distro.BootstrapContainer()) instead of on the
+	// host. This is useful when the host's dnf cannot read the
+	// repositories of the target distribution.
+	DepsolveInBootstrapContainer bool
+
 	RPMListWriter RPMListWriterFunc
+
+	// LockfileWriter will be called with the lockfile of the
+	// depsolved packages after depsolving.
+	LockfileWriter LockfileWriterFunc
+
+	// Lockfile bypasses depsolving, the locked packages are used
+	// instead after verifying that they are still available in
+	// the configured repositories. No SBOMs are generated when a
+	// lockfile is used.
+	Lockfile *lockfile.Lockfile
+
+	// SecurityOnly depsolves in "security-only" mode when a Lockfile
+	// is set: packages are pinned to their locked versions unless a
+	// security advisory from the updateinfo of the repositories
+	// applies to them, see lockfile.Lockfile.SecurityUpdate().
+	SecurityOnly bool
+
+	// Advisories attaches the advisories from the updateinfo metadata
+	// of the repositories to the depsolved packages, see
+	// rpmmd.AttachAdvisories(). They are always attached in
+	// SecurityOnly mode.
+	Advisories bool
+
+	// ResolvedContentCallback will be called with the depsolved
+	// packages and the resolved containers, commits and flatpaks
+	// after the manifest is serialized (e.g. to create a provenance
+	// attestation of the build).
+	ResolvedContentCallback ResolvedContentFunc
+}
+
+// ResolvedContent is the resolved content of a manifest, indexed by
+// the name of the pipeline that uses it.
+type ResolvedContent struct {
+	Depsolved  map[string]depsolvednf.DepsolveResult
+	Containers map[string][]container.Spec
+	Commits    map[string][]ostree.CommitSpec
+	Flatpaks   map[string][]flatpak.Spec
 }
 
 // Generator can generate an osbuild manifest from a given repository
@@ -92,11 +178,17 @@ type Options struct {
 type Generator struct {
 	cacheDir string
 
+	depsolver              depsolvednf.Depsolver
 	depsolve               DepsolveFunc
+	solverDepsolve         SolverDepsolveFunc
 	containerResolver      ContainerResolverFunc
 	commitResolver         CommitResolverFunc
 	flatpakResolver        FlatpakResolverFunc
+	bootstrapSolver        BootstrapSolverFunc
+	containerSBOMResolver  ContainerSBOMResolverFunc
 	sbomWriter             SBOMWriterFunc
+	sbomType               sbom.StandardType
+	imageSBOM              bool
 	warningsOutput         io.Writer
 	depsolveWarningsOutput io.Writer
 
@@ -108,7 +200,13 @@ type Generator struct {
 	overrideRepos []rpmmd.RepoConfig
 
 	useBootstrapContainer bool
+	bootstrapDepsolve     bool
 	rpmlistWriter         RPMListWriterFunc
+	lockfileWriter        LockfileWriterFunc
+	lockfile              *lockfile.Lockfile
+	securityOnly          bool
+	advisories            bool
+	resolvedContentFunc   ResolvedContentFunc
 }
 
 // New will create a new manifest generator
@@ -120,24 +218,40 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 		reporegistry: reporegistry,
 
 		cacheDir:               opts.Cachedir,
+		depsolver:              opts.Depsolver,
 		depsolve:               opts.Depsolve,
+		solverDepsolve:         opts.SolverDepsolve,
 		containerResolver:      opts.ContainerResolver,
 		commitResolver:         opts.CommitResolver,
+		bootstrapSolver:        opts.BootstrapSolver,
+		containerSBOMResolver:  opts.ContainerSBOMResolver,
 		rpmDownloader:          opts.RpmDownloader,
 		sbomWriter:             opts.SBOMWriter,
+		sbomType:               opts.SBOMType,
+		imageSBOM:              opts.ImageSBOM,
 		warningsOutput:         opts.WarningsOutput,
 		depsolveWarningsOutput: opts.DepsolveWarningsOutput,
 		customSeed:             opts.CustomSeed,
 		overrideRepos:          opts.OverrideRepos,
 		useBootstrapContainer:  opts.UseBootstrapContainer,
+		bootstrapDepsolve:      opts.DepsolveInBootstrapContainer,
 		rpmlistWriter:          opts.RPMListWriter,
+		lockfileWriter:         opts.LockfileWriter,
+		lockfile:               opts.Lockfile,
+		securityOnly:           opts.SecurityOnly,
+		advisories:             opts.Advisories,
+		resolvedContentFunc:    opts.ResolvedContentCallback,
 	}
-	if mg.depsolve == nil {
-		mg.depsolve = DefaultDepsolve
+	if mg.sbomType == sbom.StandardTypeNone {
+		mg.sbomType = defaultSBOMType
+	}
+	if mg.solverDepsolve == nil {
+		mg.solverDepsolve = DefaultSolverDepsolve
 	}
 	if mg.containerResolver == nil {
+		signatures := opts.ContainerSignatures
 		mg.containerResolver = func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
-			return container.NewBlockingResolver(archName).ResolveAll(containerSources)
+			return container.NewBlockingResolverWithSignatures(archName, signatures).ResolveAll(containerSources)
 		}
 	}
 	if mg.commitResolver == nil {
@@ -146,6 +260,12 @@ func New(reporegistry *reporegistry.RepoRegistry, opts *Options) (*Generator, er
 	if mg.flatpakResolver == nil {
 		mg.flatpakResolver = flatpak.ResolveAll
 	}
+	if mg.bootstrapSolver == nil {
+		mg.bootstrapSolver = DefaultBootstrapSolver
+	}
+	if mg.containerSBOMResolver == nil {
+		mg.containerSBOMResolver = DefaultContainerSBOMResolver
+	}
 	if mg.cacheDir == "" {
 		xdgCacheHomeDir, err := xdgCacheHome()
 		if err != nil {
@@ -197,8 +317,48 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	if err != nil {
 		return nil, err
 	}
-	solver := depsolvednf.NewSolver(dist.ModulePlatformID(), dist.Releasever(), a.Name(), dist.Name(), mg.cacheDir)
-	if dd, ok := dist.(distro.CustomDepsolverDistro); ok {
+	var solver depsolvednf.Depsolver = depsolvednf.NewSolver(dist.ModulePlatformID(), dist.Releasever(), a.Name(), dist.Name(), mg.cacheDir)
+	if mg.depsolver != nil {
+		solver = mg.depsolver
+	} else if mg.bootstrapDepsolve {
+		ref, err := dist.BootstrapContainer(a.Name())
+		if err != nil {
+			return nil, err
+		}
+		if ref == "" {
+			return nil, fmt.Errorf("cannot depsolve in bootstrap container: no bootstrap container for %s on %s", dist.Name(), a.Name())
+		}
+		var repos []rpmmd.RepoConfig
+		for _, pkgSets := range pkgSetChains {
+			for _, pkgSet := range pkgSets {
+				repos = append(repos, pkgSet.Repositories...)
+			}
+		}
+		bootstrapSolver, cleanupFunc, err := mg.bootstrapSolver(ref, mg.cacheDir, dist, a.Name(), repos)
+		if err != nil {
+			return nil, fmt.Errorf("cannot start bootstrap container %s: %w", ref, err)
+		}
+		solver = bootstrapSolver
+		defer func() {
+			if err := cleanupFunc(); err != nil {
+				fmt.Fprintf(mg.warningsOutput, "WARNING: cleanup failed: %v\n", err)
+			}
+		}()
+	} else if dd, ok := dist.(distro.DepsolverDistro); ok {
+		// XXX: it would be nice to have access to arch.Arch
+		// from distro.Arch but we dont so we have to do without.
+		archi := common.Must(arch.FromString(a.Name()))
+		distroSolver, cleanupFunc, err := dd.NewDepsolver(mg.cacheDir, archi)
+		if err != nil {
+			return nil, err
+		}
+		solver = distroSolver
+		defer func() {
+			if err := cleanupFunc(); err != nil {
+				fmt.Fprintf(mg.warningsOutput, "WARNING: cleanup failed: %v\n", err)
+			}
+		}()
+	} else if dd, ok := dist.(distro.CustomDepsolverDistro); ok {
 		// XXX: it would be nice to have access to arch.Arch
 		// from distro.Arch but we dont so we have to do without.
 		archi := common.Must(arch.FromString(a.Name()))
@@ -215,10 +375,59 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 			}
 		}()
 	}
-	depsolved, err := mg.depsolve(solver, mg.cacheDir, mg.depsolveWarningsOutput, pkgSetChains, dist, a.Name())
+	securityOnly := mg.lockfile != nil && mg.securityOnly
+	var advisories []rpmmd.Advisory
+	if mg.advisories || securityOnly {
+		advisories, err = fetchAdvisories(mg.cacheDir, pkgSetChains, dist, a.Name())
+		if err != nil {
+			return nil, err
+		}
+	}
+	var depsolved map[string]depsolvednf.DepsolveResult
+	if securityOnly {
+		depsolved, err = mg.lockfile.SecurityUpdate(solver, pkgSetChains, advisories)
+	} else if mg.lockfile != nil {
+		depsolved, err = mg.lockfile.Replay(solver, pkgSetChains)
+	} else {
+		// only request the SBOMs that are written
+		depsolveSBOMType := sbom.StandardTypeNone
+		if mg.sbomWriter != nil {
+			depsolveSBOMType = mg.sbomType
+		}
+		depsolved, err = mg.runDepsolve(solver, pkgSetChains, dist, a.Name(), depsolveSBOMType)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if mg.advisories {
+		for _, res := range depsolved {
+			for _, trans := range res.Transactions {
+				rpmmd.AttachAdvisories(trans, advisories)
+			}
+		}
+	}
+	if mg.sbomWriter != nil && mg.sbomType == sbom.StandardTypeCycloneDX {
+		for name, res := range depsolved {
+			if res.SBOM != nil && res.SBOM.DocType == sbom.StandardTypeCycloneDX {
+				continue
+			}
+			res.SBOM, err = depsolvednf.CycloneDXDocument(res.Transactions, dist.Name())
+			if err != nil {
+				return nil, fmt.Errorf("cannot create SBOM for %s: %w", name, err)
+			}
+			depsolved[name] = res
+		}
+	}
+	if mg.lockfileWriter != nil {
+		var buf bytes.Buffer
+		if err := lockfile.New(depsolved).Write(&buf); err != nil {
+			return nil, err
+		}
+		imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
+		if err := mg.lockfileWriter(imageName+".lock.json", &buf); err != nil {
+			return nil, err
+		}
+	}
 	containerSpecs, err := mg.containerResolver(preManifest.GetContainerSourceSpecs(), a.Name())
 	if err != nil {
 		return nil, err
@@ -236,7 +445,8 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 		return nil, err
 	}
 
-	flatpakSpecs, err := mg.flatpakResolver(preManifest.GetFlatpakSourceSpecs())
+	flatpakSources := preManifest.GetFlatpakSourceSpecs()
+	flatpakSpecs, err := mg.flatpakResolver(flatpakSources)
 	if err != nil {
 		return nil, err
 	}
@@ -249,6 +459,17 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 	if err != nil {
 		return nil, err
 	}
+	if mg.resolvedContentFunc != nil {
+		err := mg.resolvedContentFunc(&ResolvedContent{
+			Depsolved:  depsolved,
+			Containers: containerSpecs,
+			Commits:    commitSpecs,
+			Flatpaks:   flatpakSpecs,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if mg.sbomWriter != nil || mg.rpmlistWriter != nil {
 		uniquePackages := make(map[string]rpmmd.Package)
@@ -265,8 +486,10 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 			}
 			// XXX: sync with image-builder-cli:build.go name generation - can we have a shared helper?
 			imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
-			if mg.sbomWriter != nil {
-				sbomDocOutputFilename := fmt.Sprintf("%s.%s-%s.%s", imageName, pipelinePurpose, plName, defaultSBOMExt)
+			// there is no SPDX SBOM when the packages are taken from a
+			// lockfile
+			if mg.sbomWriter != nil && depsolvedPipeline.SBOM != nil {
+				sbomDocOutputFilename := fmt.Sprintf("%s.%s-%s.%s", imageName, pipelinePurpose, plName, sbomExt(depsolvedPipeline.SBOM.DocType))
 				var buf bytes.Buffer
 				enc := json.NewEncoder(&buf)
 				if err := enc.Encode(depsolvedPipeline.SBOM.Document); err != nil {
@@ -289,9 +512,85 @@ func (mg *Generator) Generate(bp *blueprint.Blueprint, imgType distro.ImageType,
 		}
 	}
 
+	if mg.sbomWriter != nil && mg.imageSBOM {
+		imageName := fmt.Sprintf("%s-%s-%s", dist.Name(), imgType.Name(), a.Name())
+		content := sbom.ImageContent{Name: imageName}
+		for _, plName := range preManifest.PayloadPipelines() {
+			// there is no SPDX SBOM when the packages are taken
+			// from a lockfile
+			if res, ok := depsolved[plName]; ok && res.SBOM != nil {
+				content.Packages = append(content.Packages, res.SBOM)
+			}
+			for _, spec := range containerSpecs[plName] {
+				cnt, err := mg.sbomContainer(spec)
+				if err != nil {
+					return nil, err
+				}
+				content.Containers = append(content.Containers, cnt)
+			}
+			for _, spec := range commitSpecs[plName] {
+				content.Commits = append(content.Commits, sbom.Commit{Ref: spec.Ref, URL: spec.URL, Checksum: spec.Checksum})
+			}
+			for idx, spec := range flatpakSpecs[plName] {
+				if idx >= len(flatpakSources[plName]) {
+					return nil, fmt.Errorf("internal error: more flatpaks than sources resolved for pipeline %s", plName)
+				}
+				fp, err := mg.sbomFlatpak(spec, flatpakSources[plName][idx])
+				if err != nil {
+					return nil, err
+				}
+				content.Flatpaks = append(content.Flatpaks, fp)
+			}
+		}
+		doc, err := sbom.MergeImage(mg.sbomType, content)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create SBOM of image %s: %w", imageName, err)
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(doc.Document); err != nil {
+			return nil, err
+		}
+		if err := mg.sbomWriter(fmt.Sprintf("%s.%s", imageName, sbomExt(doc.DocType)), &buf, doc.DocType); err != nil {
+			return nil, err
+		}
+	}
+
 	return mf, nil
 }
 
+// sbomContainer returns the container of the image SBOM for the spec
+func (mg *Generator) sbomContainer(spec container.Spec) (sbom.Container, error) {
+	doc, err := mg.containerSBOMResolver(spec, mg.sbomType)
+	if err != nil {
+		return sbom.Container{}, err
+	}
+	name := spec.LocalName
+	if name == "" {
+		name = spec.Source
+	}
+	return sbom.Container{
+		Name:   name,
+		Source: spec.Source,
+		Digest: spec.Digest,
+		Arch:   spec.Arch.GoArch(),
+		SBOM:   doc,
+	}, nil
+}
+
+// sbomFlatpak returns the flatpak of the image SBOM for the spec that was
+// resolved from the source
+func (mg *Generator) sbomFlatpak(spec flatpak.Spec, source flatpak.SourceSpec) (sbom.Flatpak, error) {
+	fp := sbom.Flatpak{Ref: source.Reference.String()}
+	if spec.ContainerSpec != nil {
+		cnt, err := mg.sbomContainer(*spec.ContainerSpec)
+		if err != nil {
+			return sbom.Flatpak{}, err
+		}
+		fp.Container = &cnt
+	}
+	return fp, nil
+}
+
 func addUniquePackagesFromPipeline(unique map[string]rpmmd.Package, pipeline depsolvednf.DepsolveResult) {
 	for _, pkg := range pipeline.Transactions.AllPackages() {
 		var key string
@@ -305,6 +604,39 @@ func addUniquePackagesFromPipeline(unique map[string]rpmmd.Package, pipeline dep
 	}
 }
 
+// fetchAdvisories reads the advisories from the updateinfo metadata of all
+// repositories of the package set chains. The subscriptions of the host are
+// used for RHSM repositories.
+func fetchAdvisories(cacheDir string, pkgSetChains map[string][]rpmmd.PackageSet, d distro.Distro, arch string) ([]rpmmd.Advisory, error) {
+	var repos []rpmmd.RepoConfig
+	seen := make(map[string]bool)
+	rhsmRepos := false
+	for _, name := range slices.Sorted(maps.Keys(pkgSetChains)) {
+		for _, ps := range pkgSetChains[name] {
+			for _, repo := range ps.Repositories {
+				if hash := repo.Hash(); !seen[hash] {
+					seen[hash] = true
+					repos = append(repos, repo)
+					rhsmRepos = rhsmRepos || repo.RHSM
+				}
+			}
+		}
+	}
+	reader := rpmmd.NewMetadataReader(cacheDir)
+	if rhsmRepos {
+		subscriptions, err := rhsm.LoadSystemSubscriptions()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read security advisories of RHSM repositories: %w", err)
+		}
+		reader.SetSubscriptions(subscriptions, arch, d.Releasever())
+	}
+	advisories, err := reader.FetchAdvisories(repos)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read security advisories: %w", err)
+	}
+	return advisories, nil
+}
+
 func writeRPMList(writer RPMListWriterFunc, unique map[string]rpmmd.Package) error {
 	var packages rpmmd.PackageList
 	for _, pkg := range unique {
@@ -329,6 +661,67 @@ func xdgCacheHome() (string, error) {
 	return filepath.Join(home, ".cache"), nil
 }
 
+// sbomExt returns the file extension for SBOM documents of the given type
+func sbomExt(docType sbom.StandardType) string {
+	if docType == sbom.StandardTypeCycloneDX {
+		return "cdx.json"
+	}
+	return "spdx.json"
+}
+
+// DefaultContainerSBOMResolver returns the SBOM of the given type that is
+// attached to the container as an OCI referrer, see
+// container.Client.GetSBOMReferrer(). It returns nil for containers
+// without an SBOM and for containers from the local storage or an archive,
+// which have no referrers.
+func DefaultContainerSBOMResolver(spec container.Spec, docType sbom.StandardType) (*sbom.Document, error) {
+	if spec.LocalStorage || spec.Transport != "" {
+		return nil, nil
+	}
+	artifactType := container.SPDXArtifactType
+	if docType == sbom.StandardTypeCycloneDX {
+		artifactType = container.CycloneDXArtifactType
+	}
+	client, err := container.NewClient(spec.Source)
+	if err != nil {
+		return nil, err
+	}
+	client.SetTLSVerify(spec.TLSVerify)
+	artifact, err := client.GetSBOMReferrer(context.Background(), digest.Digest(spec.Digest), artifactType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get SBOM of container %s: %w", spec.Source, err)
+	}
+	if artifact == nil {
+		return nil, nil
+	}
+	return sbom.NewDocument(docType, artifact.Data)
+}
+
+// DefaultBootstrapSolver starts the bootstrap container ref and returns a
+// solver that depsolves inside of it, see bootc.NewBootstrapSolver(). It
+// is used by default by manifestgen when depsolving in the bootstrap
+// container (unless overriden).
+func DefaultBootstrapSolver(ref, cacheDir string, d distro.Distro, arch string, repos []rpmmd.RepoConfig) (depsolvednf.Depsolver, func() error, error) {
+	solver, cleanupFunc, err := bootc.NewBootstrapSolver(ref, cacheDir, d.ModulePlatformID(), d.Releasever(), arch, d.Name(), repos)
+	if err != nil {
+		return nil, nil, err
+	}
+	return solver, cleanupFunc, nil
+}
+
+// runDepsolve depsolves the package sets with the custom Depsolve
+// function if one is set and with the SolverDepsolve function otherwise
+func (mg *Generator) runDepsolve(solver depsolvednf.Depsolver, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error) {
+	if mg.depsolve == nil {
+		return mg.solverDepsolve(solver, mg.cacheDir, mg.depsolveWarningsOutput, packageSets, d, arch, sbomType)
+	}
+	dnfSolver, ok := solver.(*depsolvednf.Solver)
+	if !ok {
+		return nil, fmt.Errorf("the Depsolve option needs the dnf solver but the depsolver is a %T, use SolverDepsolve instead", solver)
+	}
+	return mg.depsolve(dnfSolver, mg.cacheDir, mg.depsolveWarningsOutput, packageSets, d, arch)
+}
+
 // DefaultDepsolve provides a default implementation for depsolving.
 // It should rarely be necessary to use it directly and will be used
 // by default by manifestgen (unless overriden)
@@ -345,22 +738,49 @@ func DefaultDepsolve(solver *depsolvednf.Solver, cacheDir string, depsolveWarnin
 	// Always generate Spdx SBOMs for now, this makes the
 	// default depsolve slightly slower but it means we
 	// need no extra argument here to select the SBOM
-	// type. Once we have more types than Spdx of course
-	// we need to add a option to select the type.
+	// type. DefaultSolverDepsolve selects the type.
 	solver.SetSBOMType(sbom.StandardTypeSpdx)
 	return solver.DepsolveAll(packageSets)
 }
 
+// DefaultSolverDepsolve is the default SolverDepsolveFunc, it works
+// with any depsolvednf.Depsolver. It will be used by default by
+// manifestgen (unless overriden)
+//
+// The depsolveWarningsOutput is only used when the solver is a
+// *depsolvednf.Solver. The sbomType selects the SBOM documents of the
+// results, sbom.StandardTypeNone skips them.
+func DefaultSolverDepsolve(solver depsolvednf.Depsolver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error) {
+	if solver == nil {
+		return nil, fmt.Errorf("need a valid solver, got nil")
+	}
+	if dnfSolver, ok := solver.(*depsolvednf.Solver); ok && depsolveWarningsOutput != nil {
+		dnfSolver.Stderr = depsolveWarningsOutput
+	}
+
+	return depsolvednf.DepsolveAll(solver, packageSets, sbomType)
+}
+
 type (
 	DepsolveFunc func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error)
 
+	SolverDepsolveFunc func(solver depsolvednf.Depsolver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error)
+
 	ContainerResolverFunc func(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error)
 
 	CommitResolverFunc func(commitSources map[string][]ostree.SourceSpec) (map[string][]ostree.CommitSpec, error)
 
 	FlatpakResolverFunc func(flatpakSources map[string][]flatpak.SourceSpec) (map[string][]flatpak.Spec, error)
 
+	ContainerSBOMResolverFunc func(spec container.Spec, docType sbom.StandardType) (*sbom.Document, error)
+
+	BootstrapSolverFunc func(ref, cacheDir string, d distro.Distro, arch string, repos []rpmmd.RepoConfig) (depsolvednf.Depsolver, func() error, error)
+
 	SBOMWriterFunc func(filename string, content io.Reader, docType sbom.StandardType) error
 
 	RPMListWriterFunc func(filename string, content io.Reader) error
+
+	LockfileWriterFunc func(filename string, content io.Reader) error
+
+	ResolvedContentFunc func(content *ResolvedContent) error
 )
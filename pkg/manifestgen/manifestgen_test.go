@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"regexp"
@@ -21,6 +22,7 @@ import (
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
 	"github.com/osbuild/images/pkg/imagefilter"
+	"github.com/osbuild/images/pkg/lockfile"
 	"github.com/osbuild/images/pkg/manifestgen"
 	"github.com/osbuild/images/pkg/manifestgen/manifestmock"
 	"github.com/osbuild/images/pkg/osbuild"
@@ -57,7 +59,7 @@ func TestManifestGeneratorDepsolve(t *testing.T) {
 			}
 
 			opts := &manifestgen.Options{
-				Depsolve:          fakeDepsolve,
+				SolverDepsolve:    fakeDepsolve,
 				CommitResolver:    panicCommitResolver,
 				ContainerResolver: panicContainerResolver,
 
@@ -106,7 +108,7 @@ func TestManifestGeneratorWithOstreeCommit(t *testing.T) {
 	assert.Equal(t, 1, len(res))
 
 	opts := &manifestgen.Options{
-		Depsolve:          fakeDepsolve,
+		SolverDepsolve:    fakeDepsolve,
 		CommitResolver:    fakeCommitResolver,
 		ContainerResolver: panicContainerResolver,
 	}
@@ -135,11 +137,11 @@ func TestManifestGeneratorWithOstreeCommit(t *testing.T) {
 	assert.Regexp(t, sourcesPattern, string(osbuildManifest))
 }
 
-func fakeDepsolve(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+func fakeDepsolve(solver depsolvednf.Depsolver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error) {
 	if depsolveWarningsOutput != nil {
 		_, _ = depsolveWarningsOutput.Write([]byte(`fake depsolve output`))
 	}
-	depsolvedSets, err := manifestmock.Depsolve(packageSets, arch, nil, true)
+	depsolvedSets, err := manifestmock.Depsolve(packageSets, arch, nil, sbomType == sbom.StandardTypeSpdx)
 	if err != nil {
 		return nil, err
 	}
@@ -204,7 +206,7 @@ func TestManifestGeneratorContainers(t *testing.T) {
 	assert.Equal(t, 1, len(res))
 
 	opts := &manifestgen.Options{
-		Depsolve:          fakeDepsolve,
+		SolverDepsolve:    fakeDepsolve,
 		CommitResolver:    panicCommitResolver,
 		ContainerResolver: fakeContainerResolver,
 	}
@@ -226,6 +228,47 @@ func TestManifestGeneratorContainers(t *testing.T) {
 	assert.Contains(t, string(osbuildManifest), "resolved-cnt-"+fakeContainerSource)
 }
 
+func TestManifestGeneratorResolvedContentCallback(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	var resolved *manifestgen.ResolvedContent
+	opts := &manifestgen.Options{
+		SolverDepsolve:    fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+		ResolvedContentCallback: func(content *manifestgen.ResolvedContent) error {
+			resolved = content
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	assert.NoError(t, err)
+	fakeContainerSource := "registry.example.org/fedora-minimal"
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{
+				Source: fakeContainerSource,
+			},
+		},
+	}
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+
+	require.NotNil(t, resolved)
+	assert.Contains(t, resolved.Depsolved, "os")
+	assert.Contains(t, resolved.Depsolved, "build")
+	require.Len(t, resolved.Containers["os"], 1)
+	assert.Equal(t, "resolved-cnt-"+fakeContainerSource, resolved.Containers["os"][0].Source)
+}
+
 func TestManifestGeneratorDepsolveWithSbomWriter(t *testing.T) {
 	repos, err := testrepos.New()
 	assert.NoError(t, err)
@@ -239,7 +282,7 @@ func TestManifestGeneratorDepsolveWithSbomWriter(t *testing.T) {
 
 	generatedSboms := map[string]string{}
 	opts := &manifestgen.Options{
-		Depsolve:          fakeDepsolve,
+		SolverDepsolve:    fakeDepsolve,
 		CommitResolver:    panicCommitResolver,
 		ContainerResolver: panicContainerResolver,
 
@@ -268,6 +311,111 @@ func TestManifestGeneratorDepsolveWithSbomWriter(t *testing.T) {
 	assert.Equal(t, expected, generatedSboms)
 }
 
+func TestManifestGeneratorDepsolveWithCycloneDXSbomWriter(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	generatedSboms := map[string]map[string]any{}
+	opts := &manifestgen.Options{
+		SolverDepsolve:    fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+		SBOMType:          sbom.StandardTypeCycloneDX,
+
+		SBOMWriter: func(filename string, content io.Reader, docType sbom.StandardType) error {
+			assert.Equal(t, sbom.StandardTypeCycloneDX, docType)
+
+			var doc map[string]any
+			assert.NoError(t, json.NewDecoder(content).Decode(&doc))
+			generatedSboms[filename] = doc
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	assert.NoError(t, err)
+	var bp blueprint.Blueprint
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+
+	assert.Len(t, generatedSboms, 2)
+	for _, name := range []string{
+		"centos-9-qcow2-x86_64.buildroot-build.cdx.json",
+		"centos-9-qcow2-x86_64.image-os.cdx.json",
+	} {
+		require.Contains(t, generatedSboms, name)
+		assert.Equal(t, "CycloneDX", generatedSboms[name]["bomFormat"])
+		assert.NotEmpty(t, generatedSboms[name]["components"])
+	}
+}
+
+func TestManifestGeneratorImageSBOM(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	fakeContainerSource := "registry.example.org/fedora-minimal"
+	var resolvedSBOMs []string
+	generatedSboms := map[string]map[string]any{}
+	opts := &manifestgen.Options{
+		SolverDepsolve:    fakeDepsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: fakeContainerResolver,
+		SBOMType:          sbom.StandardTypeCycloneDX,
+		ImageSBOM:         true,
+		ContainerSBOMResolver: func(spec container.Spec, docType sbom.StandardType) (*sbom.Document, error) {
+			resolvedSBOMs = append(resolvedSBOMs, spec.Source)
+			assert.Equal(t, sbom.StandardTypeCycloneDX, docType)
+			return sbom.NewDocument(docType, []byte(`{"bomFormat":"CycloneDX","components":[{"type":"library","bom-ref":"pkg:rpm/fedora/bash","name":"bash"}]}`))
+		},
+		SBOMWriter: func(filename string, content io.Reader, docType sbom.StandardType) error {
+			var doc map[string]any
+			assert.NoError(t, json.NewDecoder(content).Decode(&doc))
+			generatedSboms[filename] = doc
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	bp := blueprint.Blueprint{
+		Containers: []blueprint.Container{
+			{
+				Source: fakeContainerSource,
+			},
+		},
+	}
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"resolved-cnt-" + fakeContainerSource}, resolvedSBOMs)
+	require.Contains(t, generatedSboms, "centos-9-qcow2-x86_64.cdx.json")
+	// the packages of the payload and the embedded container with the
+	// packages of its SBOM
+	doc := generatedSboms["centos-9-qcow2-x86_64.cdx.json"]
+	assert.Equal(t, "CycloneDX", doc["bomFormat"])
+	var containers []map[string]any
+	for _, comp := range doc["components"].([]any) {
+		if comp := comp.(map[string]any); comp["type"] == "container" {
+			containers = append(containers, comp)
+		}
+	}
+	require.Len(t, containers, 1)
+	assert.Equal(t, "resolved-cnt-"+fakeContainerSource, containers[0]["name"])
+	assert.Len(t, containers[0]["components"], 1)
+}
+
 func TestManifestGeneratorWithRPMListWriter(t *testing.T) {
 	repos, err := testrepos.New()
 	assert.NoError(t, err)
@@ -281,7 +429,7 @@ func TestManifestGeneratorWithRPMListWriter(t *testing.T) {
 
 	generated := map[string]string{}
 	opts := &manifestgen.Options{
-		Depsolve:          fakeDepsolve,
+		SolverDepsolve:    fakeDepsolve,
 		CommitResolver:    panicCommitResolver,
 		ContainerResolver: panicContainerResolver,
 		RPMListWriter: func(filename string, content io.Reader) error {
@@ -318,7 +466,7 @@ func TestManifestGeneratorSeed(t *testing.T) {
 
 	for _, withCustomSeed := range []bool{false, true} {
 		opts := &manifestgen.Options{
-			Depsolve: fakeDepsolve,
+			SolverDepsolve: fakeDepsolve,
 		}
 		if withCustomSeed {
 			customSeed := int64(123)
@@ -356,7 +504,7 @@ func TestManifestGeneratorDepsolveOutput(t *testing.T) {
 
 	var depsolveWarningsOutput bytes.Buffer
 	opts := &manifestgen.Options{
-		Depsolve:               fakeDepsolve,
+		SolverDepsolve:         fakeDepsolve,
 		DepsolveWarningsOutput: &depsolveWarningsOutput,
 	}
 
@@ -384,7 +532,7 @@ func TestManifestGeneratorOverrideRepos(t *testing.T) {
 	for _, withOverrideRepos := range []bool{false, true} {
 		t.Run(fmt.Sprintf("withOverrideRepos: %v", withOverrideRepos), func(t *testing.T) {
 			opts := &manifestgen.Options{
-				Depsolve: fakeDepsolve,
+				SolverDepsolve: fakeDepsolve,
 			}
 			if withOverrideRepos {
 				opts.OverrideRepos = []rpmmd.RepoConfig{
@@ -424,7 +572,7 @@ func TestManifestGeneratorUseBootstrapContainer(t *testing.T) {
 	for _, useBootstrapContainer := range []bool{false, true} {
 		t.Run(fmt.Sprintf("useBootstrapContainer: %v", useBootstrapContainer), func(t *testing.T) {
 			opts := &manifestgen.Options{
-				Depsolve:              fakeDepsolve,
+				SolverDepsolve:        fakeDepsolve,
 				ContainerResolver:     fakeContainerResolver,
 				UseBootstrapContainer: useBootstrapContainer,
 			}
@@ -449,3 +597,297 @@ func TestManifestGeneratorUseBootstrapContainer(t *testing.T) {
 		})
 	}
 }
+
+type fakeDepsolver struct {
+	sbomTypes []sbom.StandardType
+	err       error
+}
+
+func (fd *fakeDepsolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	fd.sbomTypes = append(fd.sbomTypes, sbomType)
+	if fd.err != nil {
+		return nil, fd.err
+	}
+	res, err := manifestmock.Depsolve(map[string][]rpmmd.PackageSet{"fake": pkgSets}, "x86_64", nil, true)
+	if err != nil {
+		return nil, err
+	}
+	result := res["fake"]
+	return &result, nil
+}
+
+func (fd *fakeDepsolver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	panic("FetchMetadata should not be called")
+}
+
+func (fd *fakeDepsolver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	panic("SearchMetadata should not be called")
+}
+
+func TestManifestGeneratorCustomDepsolver(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	for _, tc := range []struct {
+		sbomWriter bool
+		sbomType   sbom.StandardType
+		expected   sbom.StandardType
+	}{
+		// no SBOMs are requested when they are not written
+		{false, sbom.StandardTypeNone, sbom.StandardTypeNone},
+		{true, sbom.StandardTypeNone, sbom.StandardTypeSpdx},
+		{true, sbom.StandardTypeCycloneDX, sbom.StandardTypeCycloneDX},
+	} {
+		t.Run(fmt.Sprintf("writer: %v, type: %v", tc.sbomWriter, tc.sbomType), func(t *testing.T) {
+			depsolver := &fakeDepsolver{}
+			opts := &manifestgen.Options{
+				Depsolver:         depsolver,
+				CommitResolver:    panicCommitResolver,
+				ContainerResolver: panicContainerResolver,
+				SBOMType:          tc.sbomType,
+			}
+			if tc.sbomWriter {
+				opts.SBOMWriter = func(filename string, content io.Reader, docType sbom.StandardType) error {
+					assert.Equal(t, tc.expected, docType)
+					return nil
+				}
+			}
+			mg, err := manifestgen.New(repos, opts)
+			require.NoError(t, err)
+
+			var bp blueprint.Blueprint
+			osbuildManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+			require.NoError(t, err)
+			assert.NotEmpty(t, osbuildManifest)
+
+			// one depsolve for each of the "build" and "os" pipelines
+			assert.Equal(t, []sbom.StandardType{tc.expected, tc.expected}, depsolver.sbomTypes)
+		})
+	}
+}
+
+func TestManifestGeneratorDnfDepsolveFunc(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	called := false
+	depsolve := func(solver *depsolvednf.Solver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string]depsolvednf.DepsolveResult, error) {
+		called = true
+		assert.NotNil(t, solver)
+		return fakeDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch, sbom.StandardTypeSpdx)
+	}
+
+	// the Depsolve function gets the default dnf solver
+	mg, err := manifestgen.New(repos, &manifestgen.Options{
+		Depsolve:          depsolve,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+	})
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	assert.True(t, called)
+
+	// but cannot be used with other depsolvers
+	called = false
+	mg, err = manifestgen.New(repos, &manifestgen.Options{
+		Depsolve:          depsolve,
+		Depsolver:         &fakeDepsolver{},
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+	})
+	require.NoError(t, err)
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	assert.EqualError(t, err, "the Depsolve option needs the dnf solver but the depsolver is a *manifestgen_test.fakeDepsolver, use SolverDepsolve instead")
+	assert.False(t, called)
+}
+
+func TestManifestGeneratorDepsolveInBootstrapContainer(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	for _, tc := range []struct {
+		name         string
+		startErr     error
+		depsolveErr  error
+		cleanupErr   error
+		expectedErr  string
+		expectedWarn string
+		cleanups     int
+	}{
+		{
+			name:     "success",
+			cleanups: 1,
+		},
+		{
+			name:        "container start failure",
+			startErr:    errors.New("podman run failed"),
+			expectedErr: "cannot start bootstrap container quay.io/toolbx-images/centos-toolbox:stream9: podman run failed",
+		},
+		{
+			name:        "depsolve failure",
+			depsolveErr: errors.New("depsolve failed"),
+			expectedErr: "depsolve failed",
+			cleanups:    1,
+		},
+		{
+			name:         "cleanup failure",
+			cleanupErr:   errors.New("podman stop failed"),
+			expectedWarn: "WARNING: cleanup failed: podman stop failed\n",
+			cleanups:     1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			depsolver := &fakeDepsolver{err: tc.depsolveErr}
+			var startedRef string
+			var startedRepos []rpmmd.RepoConfig
+			cleanups := 0
+			var warnings bytes.Buffer
+			opts := &manifestgen.Options{
+				DepsolveInBootstrapContainer: true,
+				BootstrapSolver: func(ref, cacheDir string, d distro.Distro, arch string, repos []rpmmd.RepoConfig) (depsolvednf.Depsolver, func() error, error) {
+					startedRef = ref
+					startedRepos = repos
+					if tc.startErr != nil {
+						return nil, nil, tc.startErr
+					}
+					return depsolver, func() error {
+						cleanups++
+						return tc.cleanupErr
+					}, nil
+				},
+				CommitResolver:    panicCommitResolver,
+				ContainerResolver: panicContainerResolver,
+				WarningsOutput:    &warnings,
+			}
+			mg, err := manifestgen.New(repos, opts)
+			require.NoError(t, err)
+
+			var bp blueprint.Blueprint
+			osbuildManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.NotEmpty(t, osbuildManifest)
+			}
+			assert.Equal(t, "quay.io/toolbx-images/centos-toolbox:stream9", startedRef)
+			assert.NotEmpty(t, startedRepos)
+			assert.Equal(t, tc.cleanups, cleanups)
+			assert.Equal(t, tc.expectedWarn, warnings.String())
+		})
+	}
+}
+
+// lockfileDepsolver serves a fixed list of packages via SearchMetadata
+type lockfileDepsolver struct {
+	available rpmmd.PackageList
+}
+
+func (ld *lockfileDepsolver) Depsolve(pkgSets []rpmmd.PackageSet, sbomType sbom.StandardType) (*depsolvednf.DepsolveResult, error) {
+	panic("Depsolve should not be called when a lockfile is used")
+}
+
+func (ld *lockfileDepsolver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	panic("FetchMetadata should not be called")
+}
+
+func (ld *lockfileDepsolver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	return ld.available, nil
+}
+
+func TestManifestGeneratorLockfile(t *testing.T) {
+	repos, err := testrepos.New()
+	assert.NoError(t, err)
+	fac := distrofactory.NewDefault()
+
+	filter, err := imagefilter.New(fac, repos)
+	assert.NoError(t, err)
+	res, err := filter.Filter("distro:centos-9", "type:qcow2", "arch:x86_64")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(res))
+
+	var available rpmmd.PackageList
+	generated := map[string]string{}
+	opts := &manifestgen.Options{
+		SolverDepsolve: func(solver depsolvednf.Depsolver, cacheDir string, depsolveWarningsOutput io.Writer, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string, sbomType sbom.StandardType) (map[string]depsolvednf.DepsolveResult, error) {
+			depsolved, err := fakeDepsolve(solver, cacheDir, depsolveWarningsOutput, packageSets, d, arch, sbomType)
+			for _, res := range depsolved {
+				available = append(available, res.Transactions.AllPackages()...)
+			}
+			return depsolved, err
+		},
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+		LockfileWriter: func(filename string, content io.Reader) error {
+			b, err := io.ReadAll(content)
+			assert.NoError(t, err)
+			generated[filename] = string(b)
+			return nil
+		},
+	}
+	mg, err := manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	var bp blueprint.Blueprint
+	depsolvedManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	require.Contains(t, generated, "centos-9-qcow2-x86_64.lock.json")
+
+	lf, err := lockfile.Read(strings.NewReader(generated["centos-9-qcow2-x86_64.lock.json"]))
+	require.NoError(t, err)
+
+	// generating from the lockfile gives the same packages
+	opts = &manifestgen.Options{
+		Depsolver:         &lockfileDepsolver{available: available},
+		Lockfile:          lf,
+		CommitResolver:    panicCommitResolver,
+		ContainerResolver: panicContainerResolver,
+	}
+	mg, err = manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	lockedManifest, err := mg.Generate(&bp, res[0].ImgType, nil)
+	require.NoError(t, err)
+	var depsolvedSources, lockedSources struct {
+		Sources json.RawMessage `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(depsolvedManifest, &depsolvedSources))
+	require.NoError(t, json.Unmarshal(lockedManifest, &lockedSources))
+	assert.JSONEq(t, string(depsolvedSources.Sources), string(lockedSources.Sources))
+
+	// packages that are no longer available are reported
+	var reduced rpmmd.PackageList
+	for _, pkg := range available {
+		if pkg.Name != available[0].Name {
+			reduced = append(reduced, pkg)
+		}
+	}
+	opts.Depsolver = &lockfileDepsolver{available: reduced}
+	mg, err = manifestgen.New(repos, opts)
+	require.NoError(t, err)
+	_, err = mg.Generate(&bp, res[0].ImgType, nil)
+	var verifyErr *lockfile.VerifyError
+	require.ErrorAs(t, err, &verifyErr)
+	assert.NotEmpty(t, verifyErr.Missing)
+}
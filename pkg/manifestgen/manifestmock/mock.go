@@ -40,6 +40,12 @@ func ResolveContainers(containerSources map[string][]container.SourceSpec) map[s
 				ListDigest:   listDigest,
 				LocalStorage: src.Local,
 			}
+			if transport, path, ok := container.ParseArchiveSource(src.Source); ok {
+				// archives are loaded into the local storage
+				spec.Source = path
+				spec.Transport = transport
+				spec.LocalStorage = true
+			}
 			specs[idx] = spec
 		}
 		containerSpecs[plName] = specs
@@ -193,6 +199,23 @@ func Depsolve(
 					fmt.Sprintf("%s/%s", pkg.Repo.BaseURLs[0], pkg.Location),
 				}
 				transactionPackages = append(transactionPackages, pkg)
+
+				// NOTE: Debian kernel meta packages (e.g. "linux-image-amd64") pull in a package that
+				// carries the kernel release in its name (e.g. "linux-image-6.1.0-28-amd64"), which the
+				// OS pipeline uses to find the kernel. Simulate it with a predictable release.
+				if flavor, ok := strings.CutPrefix(pkgName, "linux-image-"); ok && flavor != "" && (flavor[0] < '0' || flavor[0] > '9') {
+					kernelPkg := pkg
+					kernelPkg.Name = fmt.Sprintf("linux-image-%s.0.0-%d-%s", pkg.Version, int(checksum[2])%9, flavor)
+					kernelPkg.Checksum = rpmmd.Checksum{
+						Type:  "sha256",
+						Value: fmt.Sprintf("%x", sha256.Sum256([]byte(checksum+kernelPkg.Name))),
+					}
+					kernelPkg.Location = fmt.Sprintf("packages/%s.rpm", kernelPkg.FullNEVRA())
+					kernelPkg.RemoteLocations = []string{
+						fmt.Sprintf("%s/%s", kernelPkg.Repo.BaseURLs[0], kernelPkg.Location),
+					}
+					transactionPackages = append(transactionPackages, kernelPkg)
+				}
 			}
 
 			for _, pkgName := range pkgSet.Exclude {
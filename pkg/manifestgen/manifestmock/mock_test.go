@@ -1,7 +1,9 @@
 package manifestmock_test
 
 import (
+	"regexp"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,6 +49,31 @@ func TestResolveContainers_Smoke(t *testing.T) {
 	}, result)
 }
 
+func TestResolveContainers_Archive(t *testing.T) {
+	input := map[string][]container.SourceSpec{
+		"os": {
+			{
+				Name:   "localhost/fedora",
+				Source: "oci-archive:/srv/fedora.tar",
+			},
+		},
+	}
+	result := manifestmock.ResolveContainers(input)
+	assert.Equal(t, map[string][]container.Spec{
+		"os": []container.Spec{
+			{
+				Source:       "/srv/fedora.tar",
+				Transport:    "oci-archive",
+				Digest:       "sha256:89f244a86eccf21505d244e21d71a016eecd94c048bddafd00cdb2c3d5a18b40",
+				ListDigest:   "sha256:1e80b6a383188cca7da69cd3f60f76d73d86b27d26aea8c74b9e55b0cc6e39a3",
+				ImageID:      "sha256:bb60e18a883b283ea4cb2cfdf44194b4f83219505a2969e0a4de282833956edf",
+				LocalName:    "localhost/fedora",
+				LocalStorage: true,
+			},
+		},
+	}, result)
+}
+
 func TestResolveCommits_EmptyInput(t *testing.T) {
 	result := manifestmock.ResolveCommits(nil)
 	assert.Equal(t, map[string][]ostree.CommitSpec{}, result)
@@ -341,3 +368,33 @@ func TestDepsolve_Smoke(t *testing.T) {
 		},
 	}, result)
 }
+
+func TestDepsolve_DebianKernel(t *testing.T) {
+	packageSets := map[string][]rpmmd.PackageSet{
+		"os": {
+			{
+				Include: []string{"linux-image-amd64", "linux-image-6.1.0-28-amd64"},
+				Repositories: []rpmmd.RepoConfig{
+					{Id: "debian", Name: "debian", BaseURLs: []string{"https://example.com/debian"}},
+				},
+			},
+		},
+	}
+
+	result, err := manifestmock.Depsolve(packageSets, "x86_64", nil, false)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, pkg := range result["os"].Transactions.AllPackages() {
+		if strings.HasPrefix(pkg.Name, "linux-image-") {
+			names = append(names, pkg.Name)
+		}
+	}
+	// a versioned package is only added for the meta package
+	assert.Len(t, names, 3)
+	assert.Contains(t, names, "linux-image-amd64")
+	assert.Contains(t, names, "linux-image-6.1.0-28-amd64")
+	assert.Len(t, slices.DeleteFunc(names, func(name string) bool {
+		return !regexp.MustCompile(`^linux-image-[0-9.]+-[0-9]+-amd64$`).MatchString(name)
+	}), 2)
+}
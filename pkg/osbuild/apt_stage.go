@@ -0,0 +1,48 @@
+package osbuild
+
+import (
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// AptStageInputs defines a collection of .deb packages to be installed by
+// the apt stage.
+type AptStageInputs struct {
+	// Packages to install
+	Packages *FilesInput `json:"packages"`
+}
+
+func (AptStageInputs) isStageInputs() {}
+
+// NewAptStage creates a new apt stage that installs the given .deb
+// packages. Unlike the rpm stage it has no options to exclude documentation
+// or locales.
+func NewAptStage(inputs *AptStageInputs) *Stage {
+	return &Stage{
+		Type:   "org.osbuild.apt",
+		Inputs: inputs,
+	}
+}
+
+// NewAptStageSourceFilesInputs creates the inputs for the apt stage from
+// the given (depsolved) packages.
+func NewAptStageSourceFilesInputs(pkgs rpmmd.PackageList) *AptStageInputs {
+	refs := make([]FilesInputSourceArrayRefEntry, len(pkgs))
+	for idx, pkg := range pkgs {
+		refs[idx] = NewFilesInputSourceArrayRefEntry(pkg.Checksum.String(), nil)
+	}
+	return &AptStageInputs{Packages: NewFilesInput(NewFilesInputSourceArrayRef(refs))}
+}
+
+// GenAptStagesFromTransactions creates apt stages for each transaction,
+// each stage installs only the packages of its transaction.
+func GenAptStagesFromTransactions(transactions depsolvednf.TransactionList) []*Stage {
+	stages := make([]*Stage, 0, len(transactions))
+	for _, pkgs := range transactions {
+		if len(pkgs) == 0 {
+			continue
+		}
+		stages = append(stages, NewAptStage(NewAptStageSourceFilesInputs(pkgs)))
+	}
+	return stages
+}
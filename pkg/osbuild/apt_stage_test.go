@@ -0,0 +1,51 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestNewAptStage(t *testing.T) {
+	pkgs := rpmmd.PackageList{
+		{Name: "bash", Checksum: rpmmd.Checksum{Type: "sha256", Value: "aaa"}},
+		{Name: "libc6", Checksum: rpmmd.Checksum{Type: "sha256", Value: "bbb"}},
+	}
+	stage := NewAptStage(NewAptStageSourceFilesInputs(pkgs))
+
+	data, err := json.Marshal(stage)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "type": "org.osbuild.apt",
+  "inputs": {
+    "packages": {
+      "type": "org.osbuild.files",
+      "origin": "org.osbuild.source",
+      "references": [{"id": "sha256:aaa"}, {"id": "sha256:bbb"}]
+    }
+  }
+}`, string(data))
+}
+
+func TestGenAptStagesFromTransactions(t *testing.T) {
+	transactions := depsolvednf.TransactionList{
+		{{Name: "bash", Checksum: rpmmd.Checksum{Type: "sha256", Value: "aaa"}}},
+		{},
+		{{Name: "vim", Checksum: rpmmd.Checksum{Type: "sha256", Value: "bbb"}}},
+	}
+	stages := GenAptStagesFromTransactions(transactions)
+	require.Len(t, stages, 2)
+	for _, stage := range stages {
+		assert.Equal(t, "org.osbuild.apt", stage.Type)
+		assert.Nil(t, stage.Options)
+	}
+	refs := stages[1].Inputs.(*AptStageInputs).Packages.References.(*FilesInputSourceArrayRef)
+	assert.Equal(t, "sha256:bbb", (*refs)[0].ID)
+
+	assert.Empty(t, GenAptStagesFromTransactions(nil))
+}
@@ -8,13 +8,13 @@ type BootcInstallConfigStageOptions struct {
 func (BootcInstallConfigStageOptions) isStageOptions() {}
 
 type BootcInstallConfig struct {
-	Install    *BootcInstallConfigInstall `json:"install,omitempty"`
-	KernelArgs []string                   `json:"kargs,omitempty"`
-	Block      []string                   `json:"block,omitempty"`
+	Install *BootcInstallConfigInstall `json:"install,omitempty"`
 }
 
 type BootcInstallConfigInstall struct {
 	Filesystem BootcInstallConfigFilesystem `json:"filesystem"`
+	KernelArgs []string                     `json:"kargs,omitempty"`
+	Block      []string                     `json:"block,omitempty"`
 }
 
 type BootcInstallConfigFilesystem struct {
@@ -0,0 +1,34 @@
+package osbuild_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/osbuild"
+)
+
+func TestBootcInstallConfigStageJSON(t *testing.T) {
+	opts := osbuild.GenBootcInstallOptions("10-images.toml", "xfs")
+	opts.Config.Install.KernelArgs = []string{"console=ttyS0"}
+	opts.Config.Install.Block = []string{"tpm2-luks", "direct"}
+	stage := osbuild.NewBootcInstallConfigStage(opts)
+
+	data, err := json.Marshal(stage)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "org.osbuild.bootc.install.config",
+		"options": {
+			"filename": "10-images.toml",
+			"config": {
+				"install": {
+					"filesystem": {"root": {"type": "xfs"}},
+					"kargs": ["console=ttyS0"],
+					"block": ["tpm2-luks", "direct"]
+				}
+			}
+		}
+	}`, string(data))
+}
@@ -12,6 +12,13 @@ func GenContainerStorageStages(storagePath string, containerSpecs []container.Sp
 		stages = append(stages, NewContainersStorageConfStage(containerStoreOpts))
 	}
 
+	return append(stages, GenSkopeoContainersStorageStages(storagePath, containerSpecs)...)
+}
+
+// GenSkopeoContainersStorageStages copies the containers into the
+// containers-storage at storagePath of the tree without configuring the
+// storage, e.g. for storages that are not used by podman
+func GenSkopeoContainersStorageStages(storagePath string, containerSpecs []container.Spec) (stages []*Stage) {
 	images := NewContainersInputForSources(containerSpecs)
 	localImages := NewLocalContainersInputForSources(containerSpecs)
 
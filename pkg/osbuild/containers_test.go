@@ -74,6 +74,21 @@ func TestGenContainerStorageStagesSkopeoWithStoragePath(t *testing.T) {
 	assert.Equal(t, stages[2].Inputs.(osbuild.SkopeoStageInputs).Images.Type, "org.osbuild.containers-storage")
 }
 
+func TestGenSkopeoContainersStorageStagesNoStorageConf(t *testing.T) {
+	storagePath := "/ostree/bootc/storage"
+	containerSpecs := []container.Spec{
+		{
+			LocalName: "some-name",
+			ImageID:   "sha256:1851d5f64ebaeac67c5c2d9e4adc1e73aa6433b44a167268a3510c3d056062db",
+		},
+	}
+	stages := osbuild.GenSkopeoContainersStorageStages(storagePath, containerSpecs)
+	assert.Equal(t, len(stages), 1)
+	assert.Equal(t, stages[0].Type, "org.osbuild.skopeo")
+	dest := stages[0].Options.(*osbuild.SkopeoStageOptions).Destination.(osbuild.SkopeoDestinationContainersStorage)
+	assert.Equal(t, dest.StoragePath, "/ostree/bootc/storage")
+}
+
 func TestGenContainerStorageStagesIntegration(t *testing.T) {
 	storagePath := "/some/storage/path.conf"
 	containerSpecs := []container.Spec{
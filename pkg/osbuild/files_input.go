@@ -289,10 +289,12 @@ func NewFilesInputSourceObjectRef(entries map[string]FilesInputRefMetadata) File
 
 // NewFilesInputForManifestLists creates a FilesInput for container manifest
 // lists. If there are no list digests in the container specs, it returns nil.
+// The lists of containers in the local storage are skipped, they are not
+// provided by the skopeo-index source.
 func NewFilesInputForManifestLists(containers []container.Spec) *FilesInput {
 	refs := make([]string, 0, len(containers))
 	for _, c := range containers {
-		if c.ListDigest != "" {
+		if c.ListDigest != "" && !c.LocalStorage {
 			refs = append(refs, c.ListDigest)
 		}
 	}
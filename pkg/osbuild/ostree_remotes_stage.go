@@ -27,8 +27,8 @@ type OSTreeRemote struct {
 	// Configured branches for the remote
 	Branches []string `json:"branches,omitempty"`
 
-	// GPG keys to verify the commits
-	GPGKeys []string `json:"secrets,omitempty"`
+	// ASCII-armored GPG keys to verify the commits
+	GPGKeys []string `json:"gpgkeys,omitempty"`
 
 	// Paths to ASCII-armored GPG key or directories containing ASCII-armored
 	// GPG keys to import
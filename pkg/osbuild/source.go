@@ -130,18 +130,9 @@ func GenSources(inputs SourceInputs, rpmDownloader RpmDownloader) (Sources, erro
 	}
 
 	// collect ostree commit sources
-	if len(inputs.Commits) > 0 || len(inputs.Flatpaks) > 0 {
-		commits := inputs.Commits
-
-		// flatpaks might be commits, any that are we add to a copy of our inputs.Commits
-		for _, f := range inputs.Flatpaks {
-			if f.CommitSpec != nil {
-				commits = append(commits, *f.CommitSpec)
-			}
-		}
-
+	if len(inputs.Commits) > 0 {
 		ostree := NewOSTreeSource()
-		for _, commit := range commits {
+		for _, commit := range inputs.Commits {
 			ostree.AddItem(commit)
 		}
 		if len(ostree.Items) > 0 {
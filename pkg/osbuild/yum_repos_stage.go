@@ -3,6 +3,7 @@ package osbuild
 import (
 	"fmt"
 	"regexp"
+	"slices"
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -26,6 +27,8 @@ type YumRepository struct {
 	GPGCheck       *bool    `json:"gpgcheck,omitempty" yaml:"gpgcheck,omitempty"`
 	RepoGPGCheck   *bool    `json:"repo_gpgcheck,omitempty" yaml:"repo_gpgcheck,omitempty"`
 	SSLVerify      *bool    `json:"sslverify,omitempty"`
+	IncludePkgs    []string `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string `json:"excludepkgs,omitempty"`
 }
 
 func (r YumRepository) validate() error {
@@ -59,6 +62,18 @@ func (r YumRepository) validate() error {
 		}
 	}
 
+	for idx, pkg := range r.IncludePkgs {
+		if pkg == "" {
+			return fmt.Errorf("includepkgs must not contain an empty string (idx %d)", idx)
+		}
+	}
+
+	for idx, pkg := range r.ExcludePkgs {
+		if pkg == "" {
+			return fmt.Errorf("excludepkgs must not contain an empty string (idx %d)", idx)
+		}
+	}
+
 	return nil
 }
 
@@ -111,6 +126,8 @@ func repoConfigToYumRepository(repo rpmmd.RepoConfig) YumRepository {
 		Priority:       repo.Priority,
 		SSLVerify:      sslVerify,
 		ModuleHotfixes: repo.ModuleHotfixes,
+		IncludePkgs:    slices.Clone(repo.IncludePkgs),
+		ExcludePkgs:    slices.Clone(repo.ExcludePkgs),
 	}
 
 	return yumRepo
@@ -107,6 +107,35 @@ func TestYumReposStageOptionsValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "empty-excludepkgs-item",
+			options: YumReposStageOptions{
+				Filename: "test.repo",
+				Repos: []YumRepository{
+					{
+						Id:          "cool-id",
+						BaseURLs:    []string{"http://example.org/repo"},
+						ExcludePkgs: []string{"openssl*", ""},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			name: "good-options-pkg-filters",
+			options: YumReposStageOptions{
+				Filename: "test.repo",
+				Repos: []YumRepository{
+					{
+						Id:          "cool-id",
+						BaseURLs:    []string{"http://example.org/repo"},
+						IncludePkgs: []string{"vendor-*"},
+						ExcludePkgs: []string{"openssl*"},
+					},
+				},
+			},
+			err: false,
+		},
 		{
 			name: "invalid-repo-id",
 			options: YumReposStageOptions{
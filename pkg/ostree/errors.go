@@ -45,3 +45,19 @@ func (e ParameterComboError) Error() string {
 func NewParameterComboError(msg string, args ...interface{}) ParameterComboError {
 	return ParameterComboError{msg: fmt.Sprintf(msg, args...)}
 }
+
+// CommitSignatureError is returned when a commit is not signed with one of
+// the keys that are required to verify it.
+type CommitSignatureError struct {
+	msg string
+}
+
+func (e CommitSignatureError) Error() string {
+	return e.msg
+}
+
+// NewCommitSignatureError creates and returns a new CommitSignatureError
+// with a given formatted message.
+func NewCommitSignatureError(msg string, args ...interface{}) CommitSignatureError {
+	return CommitSignatureError{msg: fmt.Sprintf(msg, args...)}
+}
@@ -34,6 +34,12 @@ type SourceSpec struct {
 	MTLS *MTLS
 	// Proxy as HTTP proxy to use when fetching the ref.
 	Proxy string
+	// GPGKeys are ASCII-armored GPG public keys. If set, the resolved
+	// commit must be signed with one of them.
+	GPGKeys []string
+	// Ed25519Keys are base64 encoded ed25519 public keys. If set, the
+	// resolved commit must be signed with one of them.
+	Ed25519Keys []string
 }
 
 // MTLS contains the options for resolving an ostree source.
@@ -85,6 +91,15 @@ type ImageOptions struct {
 	// Indicate if the 'org.osbuild.rhsm.consumer' secret should be added when pulling from the
 	// remote.
 	RHSM bool `json:"rhsm"`
+
+	// ASCII-armored GPG public keys of the remote. If set, the commit must
+	// be signed with one of them and the keys are imported for the remote
+	// of ostree images.
+	GPGKeys []string `json:"gpgkeys,omitempty"`
+
+	// Base64 encoded ed25519 public keys of the remote. If set, the commit
+	// must be signed with one of them.
+	Ed25519Keys []string `json:"ed25519keys,omitempty"`
 }
 
 // Validate the image options. This doesn't verify the existence of any remote
@@ -97,6 +112,7 @@ type ImageOptions struct {
 // - The ParentRef, if specified, must be a valid ref or a checksum.
 // - If the ParentRef is specified, the URL must also be specified.
 // - URLs must be valid.
+// - Keys, if specified, must be valid and the URL must also be specified.
 func (options ImageOptions) Validate() error {
 	if ref := options.ImageRef; ref != "" {
 		// image ref must not look like a checksum
@@ -132,6 +148,18 @@ func (options ImageOptions) Validate() error {
 		}
 	}
 
+	if len(options.GPGKeys) > 0 || len(options.Ed25519Keys) > 0 {
+		if options.URL == "" {
+			return NewParameterComboError("ostree keys specified, but no URL to verify the commit from")
+		}
+		if _, err := parseGPGKeys(options.GPGKeys); err != nil {
+			return err
+		}
+		if _, err := parseEd25519Keys(options.Ed25519Keys); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -141,6 +169,8 @@ type Remote struct {
 	URL         string
 	ContentURL  string
 	GPGKeyPaths []string
+	// ASCII-armored GPG public keys to verify the commits of the remote
+	GPGKeys []string
 }
 
 func verifyRef(ref string) bool {
@@ -319,6 +349,10 @@ func resolveRef(ss SourceSpec) (string, string, error) {
 // If the ref is already a checksum (64 alphanumeric characters), it is not
 // resolved or checked against the repository.
 //
+// If GPG or ed25519 keys are defined in the source specification, the commit
+// must be signed with one of the keys of each kind, even if the ref is
+// already a checksum. Unsigned commits result in a CommitSignatureError.
+//
 // If the ref is malformed, the function returns with a RefError.
 func Resolve(source SourceSpec) (CommitSpec, error) {
 	commit := CommitSpec{
@@ -353,9 +387,19 @@ func Resolve(source SourceSpec) (CommitSpec, error) {
 		commit.Secrets = "org.osbuild.mtls"
 	}
 
+	verify := len(source.GPGKeys) > 0 || len(source.Ed25519Keys) > 0
+	if verify && source.URL == "" {
+		return CommitSpec{}, NewParameterComboError("ostree keys specified, but no URL to verify the commit from")
+	}
+
 	if verifyChecksum(source.Ref) {
 		// the ref is a commit: return as is
 		commit.Checksum = source.Ref
+		if verify {
+			if err := verifyCommit(source, commit.Checksum); err != nil {
+				return CommitSpec{}, err
+			}
+		}
 		return commit, nil
 	}
 
@@ -373,6 +417,15 @@ func Resolve(source SourceSpec) (CommitSpec, error) {
 		}
 		commit.Checksum = checksum
 		commit.URL = url
+
+		if verify {
+			// verify against the repository the ref was resolved
+			// from, e.g. the one a mirrorlist pointed to
+			source.URL = url
+			if err := verifyCommit(source, checksum); err != nil {
+				return CommitSpec{}, err
+			}
+		}
 	}
 	return commit, nil
 }
@@ -90,11 +90,10 @@ func TestOstreeResolveRef(t *testing.T) {
 		}
 		for in, expOut := range validCases {
 			url, out, err := resolveRef(SourceSpec{
-				in.location,
-				in.ref,
-				srvConf.RHSM,
-				&MTLS{mTLSSrv.CAPath, mTLSSrv.ClientCrtPath, mTLSSrv.ClientKeyPath},
-				"",
+				URL:  in.location,
+				Ref:  in.ref,
+				RHSM: srvConf.RHSM,
+				MTLS: &MTLS{mTLSSrv.CAPath, mTLSSrv.ClientCrtPath, mTLSSrv.ClientKeyPath},
 			})
 			require.NoError(t, err)
 			assert.Equal(t, expOut, out)
@@ -115,11 +114,10 @@ func TestOstreeResolveRef(t *testing.T) {
 		}
 		for in, expMsg := range errCases {
 			url, _, err := resolveRef(SourceSpec{
-				in.location,
-				in.ref,
-				srvConf.RHSM,
-				&MTLS{mTLSSrv.CAPath, mTLSSrv.ClientCrtPath, mTLSSrv.ClientKeyPath},
-				"",
+				URL:  in.location,
+				Ref:  in.ref,
+				RHSM: srvConf.RHSM,
+				MTLS: &MTLS{mTLSSrv.CAPath, mTLSSrv.ClientCrtPath, mTLSSrv.ClientKeyPath},
 			})
 			assert.EqualError(t, err, expMsg)
 			assert.Equal(t, url, "")
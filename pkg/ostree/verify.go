@@ -0,0 +1,217 @@
+package ostree
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/osbuild/images/internal/gvariant"
+)
+
+// The keys of the signatures in the detached metadata of a commit
+const (
+	gpgSignaturesKey     = "ostree.gpgsigs"
+	ed25519SignaturesKey = "ostree.sign.ed25519"
+)
+
+// parseGPGKeys reads the ASCII-armored GPG public keys into a keyring
+func parseGPGKeys(keys []string) (openpgp.EntityList, error) {
+	var keyring openpgp.EntityList
+	for idx, key := range keys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ostree GPG key %d: %w", idx, err)
+		}
+		keyring = append(keyring, entities...)
+	}
+	return keyring, nil
+}
+
+// parseEd25519Keys decodes the base64 encoded ed25519 public keys, the
+// format ostree uses for the verification-ed25519-key remote option
+func parseEd25519Keys(keys []string) ([]ed25519.PublicKey, error) {
+	pubKeys := make([]ed25519.PublicKey, 0, len(keys))
+	for idx, key := range keys {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ostree ed25519 key %d: %w", idx, err)
+		}
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ostree ed25519 key %d: expected %d bytes, got %d", idx, ed25519.PublicKeySize, len(data))
+		}
+		pubKeys = append(pubKeys, ed25519.PublicKey(data))
+	}
+	return pubKeys, nil
+}
+
+// fetchObject fetches the ostree object with the given checksum and type
+// (e.g. "commit") from the repository at the URL of the source spec. A
+// missing object is returned as nil data without an error.
+func fetchObject(ss SourceSpec, checksum, objType string) ([]byte, error) {
+	u, err := url.Parse(ss.URL)
+	if err != nil {
+		return nil, NewResolveRefError("error parsing ostree repository location: %v", err)
+	}
+	u.Path = path.Join(u.Path, "objects", checksum[:2], checksum[2:]+"."+objType)
+
+	client, err := httpClientForRef(u.Scheme, ss)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, NewResolveRefError("error preparing ostree object request: %s", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, NewResolveRefError("error sending request to ostree repository %q: %v", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewResolveRefError("ostree repository %q returned status: %s", u.String(), resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewResolveRefError("error reading response from ostree repository %q: %v", u.String(), err)
+	}
+	return body, nil
+}
+
+// verifyCommit checks that the commit with the given checksum in the
+// repository at the URL of the source spec is signed with one of the GPG
+// keys and one of the ed25519 keys of the source spec, for each kind of key
+// that is set. The signatures are read from the detached metadata of the
+// commit like "ostree pull" does with gpg-verify and sign-verify. If the
+// commit cannot be verified, the error is a CommitSignatureError.
+func verifyCommit(ss SourceSpec, checksum string) error {
+	if len(ss.GPGKeys) == 0 && len(ss.Ed25519Keys) == 0 {
+		return nil
+	}
+	if !verifyChecksum(checksum) {
+		return NewCommitSignatureError("cannot verify ostree commit %q: not a commit checksum", checksum)
+	}
+
+	keyring, err := parseGPGKeys(ss.GPGKeys)
+	if err != nil {
+		return err
+	}
+	pubKeys, err := parseEd25519Keys(ss.Ed25519Keys)
+	if err != nil {
+		return err
+	}
+
+	if mirrorlist, isMirrorList := strings.CutPrefix(ss.URL, "mirrorlist="); isMirrorList {
+		ss.URL, err = fetchMirrorlistURL(mirrorlist, ss)
+		if err != nil {
+			return err
+		}
+	}
+
+	commit, err := fetchObject(ss, checksum, "commit")
+	if err != nil {
+		return err
+	}
+	if commit == nil {
+		return NewResolveRefError("ostree commit %s not found in repository %q", checksum, ss.URL)
+	}
+	// the signatures are detached, make sure that they are checked
+	// against the commit that was resolved
+	if sum := sha256.Sum256(commit); hex.EncodeToString(sum[:]) != checksum {
+		return NewCommitSignatureError("ostree commit %s from repository %q does not match its checksum", checksum, ss.URL)
+	}
+
+	commitmeta, err := fetchObject(ss, checksum, "commitmeta")
+	if err != nil {
+		return err
+	}
+	if commitmeta == nil {
+		return NewCommitSignatureError("ostree commit %s is not signed", checksum)
+	}
+	metadata, err := gvariant.ParseDict(commitmeta)
+	if err != nil {
+		return NewCommitSignatureError("cannot read detached metadata of ostree commit %s: %v", checksum, err)
+	}
+
+	if len(keyring) > 0 {
+		if err := verifyGPGSignatures(commit, metadata, keyring); err != nil {
+			return NewCommitSignatureError("ostree commit %s: %v", checksum, err)
+		}
+	}
+	if len(pubKeys) > 0 {
+		if err := verifyEd25519Signatures(commit, metadata, pubKeys); err != nil {
+			return NewCommitSignatureError("ostree commit %s: %v", checksum, err)
+		}
+	}
+	return nil
+}
+
+// byteStrings returns the byte strings of an "aay" value, the type of the
+// signatures in the detached metadata
+func byteStrings(value gvariant.Variant) ([][]byte, error) {
+	if value.Type != "aay" {
+		return nil, fmt.Errorf("unexpected gvariant type %q, expected \"aay\"", value.Type)
+	}
+	elements := value.Value.([]any)
+	byteStrings := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		byteStrings = append(byteStrings, element.([]byte))
+	}
+	return byteStrings, nil
+}
+
+// verifyGPGSignatures checks that one of the GPG signatures in the detached
+// metadata of the commit was made by a key in the keyring
+func verifyGPGSignatures(commit []byte, metadata map[string]gvariant.Variant, keyring openpgp.EntityList) error {
+	value, ok := metadata[gpgSignaturesKey]
+	if !ok {
+		return fmt.Errorf("no GPG signatures")
+	}
+	signatures, err := byteStrings(value)
+	if err != nil {
+		return fmt.Errorf("cannot read GPG signatures: %w", err)
+	}
+	for _, signature := range signatures {
+		_, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(commit), bytes.NewReader(signature), nil)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no valid GPG signature from the configured keys")
+}
+
+// verifyEd25519Signatures checks that one of the ed25519 signatures in the
+// detached metadata of the commit was made by one of the keys
+func verifyEd25519Signatures(commit []byte, metadata map[string]gvariant.Variant, pubKeys []ed25519.PublicKey) error {
+	value, ok := metadata[ed25519SignaturesKey]
+	if !ok {
+		return fmt.Errorf("no ed25519 signatures")
+	}
+	signatures, err := byteStrings(value)
+	if err != nil {
+		return fmt.Errorf("cannot read ed25519 signatures: %w", err)
+	}
+	for _, signature := range signatures {
+		for _, pubKey := range pubKeys {
+			if ed25519.Verify(pubKey, commit, signature) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no valid ed25519 signature from the configured keys")
+}
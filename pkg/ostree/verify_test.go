@@ -0,0 +1,213 @@
+package ostree
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/gvariant"
+)
+
+// makeCommitMeta serializes an "a{sv}" dictionary with "aay" values
+func makeCommitMeta(metadata map[string][][]byte) []byte {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	dict := make([]any, 0, len(keys))
+	for _, key := range keys {
+		values := make([]any, 0, len(metadata[key]))
+		for _, value := range metadata[key] {
+			values = append(values, value)
+		}
+		dict = append(dict, gvariant.DictEntry{
+			Key:   key,
+			Value: gvariant.Variant{Type: "aay", Value: values},
+		})
+	}
+	data, err := gvariant.Serialize("a{sv}", dict)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestByteStrings(t *testing.T) {
+	dict, err := gvariant.ParseDict(makeCommitMeta(map[string][][]byte{
+		"ostree.gpgsigs": {bytes.Repeat([]byte{0x42}, 300), {0x01, 0x02}},
+		"x":              {{}},
+	}))
+	require.NoError(t, err)
+
+	values, err := byteStrings(dict["ostree.gpgsigs"])
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{bytes.Repeat([]byte{0x42}, 300), {0x01, 0x02}}, values)
+	values, err = byteStrings(dict["x"])
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{{}}, values)
+
+	_, err = byteStrings(gvariant.Variant{Type: "as", Value: []any{"a"}})
+	assert.EqualError(t, err, `unexpected gvariant type "as", expected "aay"`)
+}
+
+type testSigner struct {
+	gpgKey     string
+	gpgEntity  *openpgp.Entity
+	ed25519Key string
+	ed25519    ed25519.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	require.NoError(t, err)
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return &testSigner{
+		gpgKey:     armored.String(),
+		gpgEntity:  entity,
+		ed25519Key: base64.StdEncoding.EncodeToString(pub),
+		ed25519:    priv,
+	}
+}
+
+func (s *testSigner) gpgSign(t *testing.T, data []byte) []byte {
+	var sig bytes.Buffer
+	require.NoError(t, openpgp.DetachSign(&sig, s.gpgEntity, bytes.NewReader(data), nil))
+	return sig.Bytes()
+}
+
+// serveCommit serves a ref pointing to a commit with the given detached
+// metadata, no metadata if it is nil, and returns the checksum of the commit
+func serveCommit(mux *http.ServeMux, ref string, commit []byte, metadata map[string][][]byte) string {
+	sum := sha256.Sum256(commit)
+	checksum := hex.EncodeToString(sum[:])
+	objPath := fmt.Sprintf("/objects/%s/%s", checksum[:2], checksum[2:])
+	mux.HandleFunc("/refs/heads/"+ref, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, checksum)
+	})
+	mux.HandleFunc(objPath+".commit", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(commit)
+	})
+	if metadata != nil {
+		mux.HandleFunc(objPath+".commitmeta", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(makeCommitMeta(metadata))
+		})
+	}
+	return checksum
+}
+
+func TestResolveVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+
+	commit := []byte("a commit object")
+	mux := http.NewServeMux()
+	signed := serveCommit(mux, "signed", commit, map[string][][]byte{
+		gpgSignaturesKey:     {other.gpgSign(t, commit), signer.gpgSign(t, commit)},
+		ed25519SignaturesKey: {ed25519.Sign(signer.ed25519, commit)},
+	})
+	unsigned := serveCommit(mux, "unsigned", []byte("an unsigned commit"), nil)
+	gpgOnly := serveCommit(mux, "gpg-only", []byte("a gpg signed commit"), map[string][][]byte{
+		gpgSignaturesKey: {signer.gpgSign(t, []byte("a gpg signed commit"))},
+	})
+	tampered := serveCommit(mux, "tampered", []byte("a tampered commit"), map[string][][]byte{
+		ed25519SignaturesKey: {ed25519.Sign(signer.ed25519, []byte("the original commit"))},
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, ref := range []string{"signed", signed} {
+		commitSpec, err := Resolve(SourceSpec{
+			URL:         srv.URL,
+			Ref:         ref,
+			GPGKeys:     []string{signer.gpgKey},
+			Ed25519Keys: []string{other.ed25519Key, signer.ed25519Key},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, signed, commitSpec.Checksum)
+	}
+
+	_, err := Resolve(SourceSpec{URL: srv.URL, Ref: "gpg-only", GPGKeys: []string{signer.gpgKey}})
+	assert.NoError(t, err)
+
+	type errCase struct {
+		ss     SourceSpec
+		errMsg string
+	}
+	for name, tc := range map[string]errCase{
+		"unsigned": {
+			ss:     SourceSpec{Ref: "unsigned", GPGKeys: []string{signer.gpgKey}},
+			errMsg: fmt.Sprintf("ostree commit %s is not signed", unsigned),
+		},
+		"wrong-gpg-key": {
+			ss:     SourceSpec{Ref: "gpg-only", GPGKeys: []string{other.gpgKey}},
+			errMsg: fmt.Sprintf("ostree commit %s: no valid GPG signature from the configured keys", gpgOnly),
+		},
+		"wrong-ed25519-key": {
+			ss:     SourceSpec{Ref: "signed", Ed25519Keys: []string{other.ed25519Key}},
+			errMsg: fmt.Sprintf("ostree commit %s: no valid ed25519 signature from the configured keys", signed),
+		},
+		"missing-ed25519": {
+			ss:     SourceSpec{Ref: "gpg-only", GPGKeys: []string{signer.gpgKey}, Ed25519Keys: []string{signer.ed25519Key}},
+			errMsg: fmt.Sprintf("ostree commit %s: no ed25519 signatures", gpgOnly),
+		},
+		"tampered-checksum-ref": {
+			ss:     SourceSpec{Ref: tampered, Ed25519Keys: []string{signer.ed25519Key}},
+			errMsg: fmt.Sprintf("ostree commit %s: no valid ed25519 signature from the configured keys", tampered),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tc.ss.URL = srv.URL
+			_, err := Resolve(tc.ss)
+			assert.IsType(t, CommitSignatureError{}, err)
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+
+	_, err = Resolve(SourceSpec{Ref: "signed", GPGKeys: []string{signer.gpgKey}})
+	assert.EqualError(t, err, "ostree keys specified, but no URL to verify the commit from")
+	_, err = Resolve(SourceSpec{URL: srv.URL, Ref: "signed", Ed25519Keys: []string{"bm90IGEga2V5"}})
+	assert.EqualError(t, err, "invalid ostree ed25519 key 0: expected 32 bytes, got 9")
+}
+
+func TestValidateKeys(t *testing.T) {
+	signer := newTestSigner(t)
+
+	options := ImageOptions{
+		URL:         "https://repo.example.com",
+		GPGKeys:     []string{signer.gpgKey},
+		Ed25519Keys: []string{signer.ed25519Key},
+	}
+	assert.NoError(t, options.Validate())
+
+	options.URL = ""
+	assert.EqualError(t, options.Validate(), "ostree keys specified, but no URL to verify the commit from")
+
+	options = ImageOptions{URL: "https://repo.example.com", GPGKeys: []string{"not a key"}}
+	assert.ErrorContains(t, options.Validate(), "invalid ostree GPG key 0")
+
+	options = ImageOptions{URL: "https://repo.example.com", Ed25519Keys: []string{"not base64!"}}
+	assert.ErrorContains(t, options.Validate(), "invalid ostree ed25519 key 0")
+}
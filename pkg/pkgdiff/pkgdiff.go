@@ -0,0 +1,252 @@
+// Package pkgdiff compares two sets of packages (e.g. the depsolve results
+// or the installed packages of two builds of an image) and reports the
+// added, removed, upgraded and downgraded packages.
+package pkgdiff
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// maxChangelogEntries is the maximum number of changelog entries that are
+// included for an upgraded package
+const maxChangelogEntries = 10
+
+type ChangeType string
+
+const (
+	Added      ChangeType = "added"
+	Removed    ChangeType = "removed"
+	Upgraded   ChangeType = "upgraded"
+	Downgraded ChangeType = "downgraded"
+)
+
+// ChangelogEntry is a changelog entry of an upgraded package
+type ChangelogEntry struct {
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+	Text   string    `json:"text"`
+}
+
+// Change is a single package change
+type Change struct {
+	Type ChangeType `json:"type"`
+	Name string     `json:"name"`
+	Arch string     `json:"arch"`
+	// Source is the name of the source package, it is empty if the
+	// package metadata has no source RPM
+	Source string `json:"source,omitempty"`
+	// Old and New are the [epoch:]version-release of the package before
+	// and after the change, Old is empty for added and New for removed
+	// packages
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Changelog contains the changelog entries of an upgraded package
+	// that are newer than the old package (only if the package metadata
+	// contains changelogs)
+	Changelog []ChangelogEntry `json:"changelog,omitempty"`
+}
+
+// SourceGroup groups the changes of all packages that are built from the
+// same source package.
+type SourceGroup struct {
+	Source  string   `json:"source"`
+	Changes []Change `json:"changes"`
+}
+
+// Advisory is an update advisory that is fixed or still unpatched, see
+// Diff.SetAdvisories()
+type Advisory struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Severity string   `json:"severity,omitempty"`
+	CVEs     []string `json:"cves,omitempty"`
+}
+
+// Diff is the difference between two package lists
+type Diff struct {
+	Changes []Change `json:"changes"`
+
+	// Fixed are the advisories that are fixed by the new packages and
+	// Unpatched the advisories that still apply to the new packages.
+	// Both are only set by SetAdvisories().
+	Fixed     []Advisory `json:"fixed_advisories,omitempty"`
+	Unpatched []Advisory `json:"unpatched_advisories,omitempty"`
+}
+
+// Compare returns the difference between the old and the new package list.
+// Packages are matched by name and architecture. If a package list contains
+// several versions of the same package (e.g. kernels) only the newest one is
+// compared.
+func Compare(oldPkgs, newPkgs rpmmd.PackageList) *Diff {
+	oldByKey := newestByKey(oldPkgs)
+	newByKey := newestByKey(newPkgs)
+
+	diff := &Diff{
+		Changes: []Change{},
+	}
+	for key, oldPkg := range oldByKey {
+		newPkg, ok := newByKey[key]
+		if !ok {
+			diff.Changes = append(diff.Changes, Change{
+				Type:   Removed,
+				Name:   oldPkg.Name,
+				Arch:   oldPkg.Arch,
+				Source: sourceName(oldPkg),
+				Old:    evr(oldPkg),
+			})
+			continue
+		}
+		res := newPkg.CompareEVR(oldPkg)
+		if res == 0 {
+			continue
+		}
+		change := Change{
+			Type:   Upgraded,
+			Name:   newPkg.Name,
+			Arch:   newPkg.Arch,
+			Source: sourceName(newPkg),
+			Old:    evr(oldPkg),
+			New:    evr(newPkg),
+		}
+		if res < 0 {
+			change.Type = Downgraded
+		} else {
+			change.Changelog = changelogSince(newPkg, oldPkg)
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	for key, newPkg := range newByKey {
+		if _, ok := oldByKey[key]; ok {
+			continue
+		}
+		diff.Changes = append(diff.Changes, Change{
+			Type:   Added,
+			Name:   newPkg.Name,
+			Arch:   newPkg.Arch,
+			Source: sourceName(newPkg),
+			New:    evr(newPkg),
+		})
+	}
+	slices.SortFunc(diff.Changes, func(a, b Change) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.Arch, b.Arch))
+	})
+	return diff
+}
+
+// CompareDepsolveResults returns the difference between the packages of
+// all transactions of the old and the new depsolve result.
+func CompareDepsolveResults(oldRes, newRes depsolvednf.DepsolveResult) *Diff {
+	return Compare(oldRes.Transactions.AllPackages(), newRes.Transactions.AllPackages())
+}
+
+// SetAdvisories sets the fixed and unpatched advisories of the diff based on
+// the given advisories (e.g. from the updateinfo metadata of the
+// repositories, see rpmmd.MetadataReader.FetchAdvisories()).
+func (d *Diff) SetAdvisories(oldPkgs, newPkgs rpmmd.PackageList, advisories []rpmmd.Advisory) {
+	d.Fixed = toAdvisories(rpmmd.FixedAdvisories(oldPkgs, newPkgs, advisories))
+	d.Unpatched = toAdvisories(rpmmd.UnpatchedAdvisories(newPkgs, advisories))
+}
+
+func toAdvisories(advisories []rpmmd.Advisory) []Advisory {
+	var res []Advisory
+	for _, adv := range advisories {
+		res = append(res, Advisory{ID: adv.ID, Type: adv.Type, Severity: adv.Severity, CVEs: adv.CVEs})
+	}
+	return res
+}
+
+// Count returns the number of changes of the given type
+func (d *Diff) Count(changeType ChangeType) int {
+	count := 0
+	for _, change := range d.Changes {
+		if change.Type == changeType {
+			count++
+		}
+	}
+	return count
+}
+
+// BySource returns the changes grouped by their source package, sorted by
+// the source package name. Changes of packages without a known source
+// package are grouped by the package name.
+func (d *Diff) BySource() []SourceGroup {
+	var groups []SourceGroup
+	for _, change := range d.Changes {
+		source := change.Source
+		if source == "" {
+			source = change.Name
+		}
+		idx := slices.IndexFunc(groups, func(g SourceGroup) bool { return g.Source == source })
+		if idx < 0 {
+			groups = append(groups, SourceGroup{Source: source})
+			idx = len(groups) - 1
+		}
+		groups[idx].Changes = append(groups[idx].Changes, change)
+	}
+	slices.SortFunc(groups, func(a, b SourceGroup) int {
+		return strings.Compare(a.Source, b.Source)
+	})
+	return groups
+}
+
+func newestByKey(pkgs rpmmd.PackageList) map[string]rpmmd.Package {
+	byKey := make(map[string]rpmmd.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		key := pkg.Name + "." + pkg.Arch
+		if prev, ok := byKey[key]; ok && prev.CompareEVR(pkg) >= 0 {
+			continue
+		}
+		byKey[key] = pkg
+	}
+	return byKey
+}
+
+func evr(pkg rpmmd.Package) string {
+	if pkg.Epoch == 0 {
+		return fmt.Sprintf("%s-%s", pkg.Version, pkg.Release)
+	}
+	return fmt.Sprintf("%d:%s-%s", pkg.Epoch, pkg.Version, pkg.Release)
+}
+
+// sourceName returns the name of the source package from the source RPM
+// filename, e.g. "bash" for "bash-5.2.26-3.fc41.src.rpm"
+func sourceName(pkg rpmmd.Package) string {
+	nvr := strings.TrimSuffix(pkg.SourceRpm, ".src.rpm")
+	nvr = strings.TrimSuffix(nvr, ".nosrc.rpm")
+	for range 2 {
+		idx := strings.LastIndex(nvr, "-")
+		if idx < 0 {
+			return ""
+		}
+		nvr = nvr[:idx]
+	}
+	return nvr
+}
+
+// changelogSince returns the changelog entries of newPkg that are newer
+// than the newest changelog entry (or the build time) of oldPkg.
+func changelogSince(newPkg, oldPkg rpmmd.Package) []ChangelogEntry {
+	since := oldPkg.BuildTime
+	if len(oldPkg.Changelogs) > 0 {
+		since = oldPkg.Changelogs[0].Date
+	}
+	var entries []ChangelogEntry
+	for _, cl := range newPkg.Changelogs {
+		if !cl.Date.After(since) || len(entries) == maxChangelogEntries {
+			break
+		}
+		entries = append(entries, ChangelogEntry{
+			Author: cl.Author,
+			Date:   cl.Date,
+			Text:   cl.Text,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,192 @@
+package pkgdiff_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/pkgdiff"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func pkg(name string, epoch uint, version, release, sourceRpm string) rpmmd.Package {
+	return rpmmd.Package{
+		Name:      name,
+		Epoch:     epoch,
+		Version:   version,
+		Release:   release,
+		Arch:      "x86_64",
+		SourceRpm: sourceRpm,
+	}
+}
+
+func testDiff() *pkgdiff.Diff {
+	oldBash := pkg("bash", 0, "5.2.26", "1.fc41", "bash-5.2.26-1.fc41.src.rpm")
+	oldBash.Changelogs = []rpmmd.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 5.2.26-1", Date: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), Text: "- Update to 5.2.26"},
+	}
+	newBash := pkg("bash", 0, "5.2.26", "3.fc41", "bash-5.2.26-3.fc41.src.rpm")
+	newBash.Changelogs = []rpmmd.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 5.2.26-3", Date: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC), Text: "- Fix CVE-2024-0001\n- Fix a crash"},
+		{Author: "Jane Doe <jane@example.com> - 5.2.26-1", Date: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), Text: "- Update to 5.2.26"},
+	}
+
+	oldPkgs := rpmmd.PackageList{
+		oldBash,
+		pkg("kernel", 0, "6.11.4", "301.fc41", "kernel-6.11.4-301.fc41.src.rpm"),
+		pkg("kernel-core", 0, "6.11.4", "301.fc41", "kernel-6.11.4-301.fc41.src.rpm"),
+		pkg("shadow-utils", 2, "4.15.1", "12.fc41", "shadow-utils-4.15.1-12.fc41.src.rpm"),
+		pkg("nano", 0, "8.1", "1.fc41", "nano-8.1-1.fc41.src.rpm"),
+	}
+	newPkgs := rpmmd.PackageList{
+		newBash,
+		// installonly packages, only the newest is compared
+		pkg("kernel", 0, "6.11.4", "301.fc41", "kernel-6.11.4-301.fc41.src.rpm"),
+		pkg("kernel", 0, "6.12.1", "200.fc41", "kernel-6.12.1-200.fc41.src.rpm"),
+		pkg("kernel-core", 0, "6.12.1", "200.fc41", "kernel-6.12.1-200.fc41.src.rpm"),
+		pkg("shadow-utils", 2, "4.14.0", "1.fc41", "shadow-utils-4.14.0-1.fc41.src.rpm"),
+		pkg("vim-minimal", 2, "9.1.825", "1.fc41", "vim-9.1.825-1.fc41.src.rpm"),
+	}
+	return pkgdiff.Compare(oldPkgs, newPkgs)
+}
+
+func TestCompare(t *testing.T) {
+	diff := testDiff()
+	assert.Equal(t, []pkgdiff.Change{
+		{
+			Type: pkgdiff.Upgraded, Name: "bash", Arch: "x86_64", Source: "bash", Old: "5.2.26-1.fc41", New: "5.2.26-3.fc41",
+			Changelog: []pkgdiff.ChangelogEntry{
+				{Author: "Jane Doe <jane@example.com> - 5.2.26-3", Date: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC), Text: "- Fix CVE-2024-0001\n- Fix a crash"},
+			},
+		},
+		{Type: pkgdiff.Upgraded, Name: "kernel", Arch: "x86_64", Source: "kernel", Old: "6.11.4-301.fc41", New: "6.12.1-200.fc41"},
+		{Type: pkgdiff.Upgraded, Name: "kernel-core", Arch: "x86_64", Source: "kernel", Old: "6.11.4-301.fc41", New: "6.12.1-200.fc41"},
+		{Type: pkgdiff.Removed, Name: "nano", Arch: "x86_64", Source: "nano", Old: "8.1-1.fc41"},
+		{Type: pkgdiff.Downgraded, Name: "shadow-utils", Arch: "x86_64", Source: "shadow-utils", Old: "2:4.15.1-12.fc41", New: "2:4.14.0-1.fc41"},
+		{Type: pkgdiff.Added, Name: "vim-minimal", Arch: "x86_64", Source: "vim", New: "2:9.1.825-1.fc41"},
+	}, diff.Changes)
+	assert.Equal(t, 3, diff.Count(pkgdiff.Upgraded))
+	assert.Equal(t, 1, diff.Count(pkgdiff.Added))
+}
+
+func TestCompareDepsolveResults(t *testing.T) {
+	oldRes := depsolvednf.DepsolveResult{
+		Transactions: depsolvednf.TransactionList{{pkg("bash", 0, "5.2.26", "1.fc41", "")}},
+	}
+	newRes := depsolvednf.DepsolveResult{
+		Transactions: depsolvednf.TransactionList{{pkg("bash", 0, "5.2.26", "1.fc41", "")}, {pkg("rpm", 0, "4.20.0", "1.fc41", "")}},
+	}
+	diff := pkgdiff.CompareDepsolveResults(oldRes, newRes)
+	assert.Equal(t, []pkgdiff.Change{
+		{Type: pkgdiff.Added, Name: "rpm", Arch: "x86_64", New: "4.20.0-1.fc41"},
+	}, diff.Changes)
+
+	diff = pkgdiff.CompareDepsolveResults(oldRes, oldRes)
+	assert.Empty(t, diff.Changes)
+}
+
+func TestBySource(t *testing.T) {
+	groups := testDiff().BySource()
+	var sources []string
+	for _, g := range groups {
+		sources = append(sources, g.Source)
+	}
+	assert.Equal(t, []string{"bash", "kernel", "nano", "shadow-utils", "vim"}, sources)
+	assert.Len(t, groups[1].Changes, 2)
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testDiff().Write(&buf, pkgdiff.FormatText))
+	assert.Equal(t, `1 added, 1 removed, 3 upgraded, 1 downgraded
+
+bash:
+  upgraded   bash.x86_64 5.2.26-1.fc41 -> 5.2.26-3.fc41
+    * Wed Jul 03 2024 Jane Doe <jane@example.com> - 5.2.26-3
+      - Fix CVE-2024-0001
+      - Fix a crash
+
+kernel:
+  upgraded   kernel.x86_64 6.11.4-301.fc41 -> 6.12.1-200.fc41
+  upgraded   kernel-core.x86_64 6.11.4-301.fc41 -> 6.12.1-200.fc41
+
+nano:
+  removed    nano.x86_64 8.1-1.fc41
+
+shadow-utils:
+  downgraded shadow-utils.x86_64 2:4.15.1-12.fc41 -> 2:4.14.0-1.fc41
+
+vim:
+  added      vim-minimal.x86_64 2:9.1.825-1.fc41
+`, buf.String())
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testDiff().Write(&buf, pkgdiff.FormatMarkdown))
+	assert.Contains(t, buf.String(), "## Package changes\n\n1 added, 1 removed, 3 upgraded, 1 downgraded\n")
+	assert.Contains(t, buf.String(), `### bash
+
+- **upgraded** `+"`bash.x86_64`"+` 5.2.26-1.fc41 → 5.2.26-3.fc41
+  - Jane Doe <jane@example.com> - 5.2.26-3 (2024-07-03)
+    - Fix CVE-2024-0001
+    - Fix a crash
+`)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, pkgdiff.Compare(nil, rpmmd.PackageList{pkg("rpm", 0, "4.20.0", "1.fc41", "rpm-4.20.0-1.fc41.src.rpm")}).Write(&buf, pkgdiff.FormatJSON))
+	assert.JSONEq(t, `{
+  "summary": {"added": 1, "removed": 0, "upgraded": 0, "downgraded": 0},
+  "sources": [
+    {
+      "source": "rpm",
+      "changes": [{"type": "added", "name": "rpm", "arch": "x86_64", "source": "rpm", "new": "4.20.0-1.fc41"}]
+    }
+  ]
+}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, pkgdiff.Compare(nil, nil).Write(&buf, pkgdiff.FormatJSON))
+	assert.JSONEq(t, `{"summary": {"added": 0, "removed": 0, "upgraded": 0, "downgraded": 0}, "sources": []}`, buf.String())
+
+	assert.EqualError(t, testDiff().Write(&buf, "yaml"), `unsupported report format "yaml"`)
+}
+
+func TestSetAdvisories(t *testing.T) {
+	oldPkgs := rpmmd.PackageList{pkg("openssl", 1, "3.2.1", "1.fc41", ""), pkg("bash", 0, "5.2.26", "1.fc41", "")}
+	newPkgs := rpmmd.PackageList{pkg("openssl", 1, "3.2.2", "1.fc41", ""), pkg("bash", 0, "5.2.26", "1.fc41", "")}
+	advisories := []rpmmd.Advisory{
+		{
+			ID: "FEDORA-2024-0001", Type: rpmmd.AdvisoryTypeSecurity, Severity: "Important", CVEs: []string{"CVE-2024-0001", "CVE-2024-0002"},
+			Packages: []rpmmd.AdvisoryPackage{{Name: "openssl", Epoch: 1, Version: "3.2.2", Release: "1.fc41", Arch: "x86_64"}},
+		},
+		{
+			ID: "FEDORA-2024-0002", Type: rpmmd.AdvisoryTypeBugfix,
+			Packages: []rpmmd.AdvisoryPackage{{Name: "bash", Version: "5.2.26", Release: "3.fc41", Arch: "x86_64"}},
+		},
+	}
+	diff := pkgdiff.Compare(oldPkgs, newPkgs)
+	diff.SetAdvisories(oldPkgs, newPkgs, advisories)
+	assert.Equal(t, []pkgdiff.Advisory{{ID: "FEDORA-2024-0001", Type: "security", Severity: "Important", CVEs: []string{"CVE-2024-0001", "CVE-2024-0002"}}}, diff.Fixed)
+	assert.Equal(t, []pkgdiff.Advisory{{ID: "FEDORA-2024-0002", Type: "bugfix"}}, diff.Unpatched)
+
+	var buf bytes.Buffer
+	require.NoError(t, diff.Write(&buf, pkgdiff.FormatText))
+	assert.Contains(t, buf.String(), `
+fixed advisories:
+  FEDORA-2024-0001 (security, Important): CVE-2024-0001, CVE-2024-0002
+
+unpatched advisories:
+  FEDORA-2024-0002 (bugfix)
+`)
+
+	buf.Reset()
+	require.NoError(t, diff.Write(&buf, pkgdiff.FormatMarkdown))
+	assert.Contains(t, buf.String(), "\n## Fixed advisories\n\n- FEDORA-2024-0001 (security, Important): CVE-2024-0001, CVE-2024-0002\n")
+}
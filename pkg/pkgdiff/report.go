@@ -0,0 +1,157 @@
+package pkgdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the output format of a diff report
+type Format string
+
+const (
+	FormatText     Format = "text"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+)
+
+var changeTypes = []ChangeType{Added, Removed, Upgraded, Downgraded}
+
+// Write writes a report of the diff in the given format
+func (d *Diff) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		return d.WriteText(w)
+	case FormatJSON:
+		return d.WriteJSON(w)
+	case FormatMarkdown:
+		return d.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+func (d *Diff) summary() string {
+	counts := make([]string, 0, len(changeTypes))
+	for _, changeType := range changeTypes {
+		counts = append(counts, fmt.Sprintf("%d %s", d.Count(changeType), changeType))
+	}
+	return strings.Join(counts, ", ")
+}
+
+func (a Advisory) String() string {
+	details := a.Type
+	if a.Severity != "" {
+		details += ", " + a.Severity
+	}
+	s := fmt.Sprintf("%s (%s)", a.ID, details)
+	if len(a.CVEs) > 0 {
+		s += ": " + strings.Join(a.CVEs, ", ")
+	}
+	return s
+}
+
+func (c Change) versions() string {
+	switch c.Type {
+	case Added:
+		return c.New
+	case Removed:
+		return c.Old
+	default:
+		return fmt.Sprintf("%s -> %s", c.Old, c.New)
+	}
+}
+
+// WriteText writes a plain text report grouped by source package
+func (d *Diff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", d.summary())
+	for _, group := range d.BySource() {
+		fmt.Fprintf(&b, "\n%s:\n", group.Source)
+		for _, change := range group.Changes {
+			fmt.Fprintf(&b, "  %-10s %s.%s %s\n", change.Type, change.Name, change.Arch, change.versions())
+			for _, cl := range change.Changelog {
+				fmt.Fprintf(&b, "    * %s %s\n", cl.Date.Format("Mon Jan 02 2006"), cl.Author)
+				for _, line := range strings.Split(strings.TrimSpace(cl.Text), "\n") {
+					fmt.Fprintf(&b, "      %s\n", line)
+				}
+			}
+		}
+	}
+	for _, section := range []struct {
+		title      string
+		advisories []Advisory
+	}{{"fixed advisories", d.Fixed}, {"unpatched advisories", d.Unpatched}} {
+		if len(section.advisories) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", section.title)
+		for _, adv := range section.advisories {
+			fmt.Fprintf(&b, "  %s\n", adv)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type jsonReport struct {
+	Summary   map[ChangeType]int `json:"summary"`
+	Sources   []SourceGroup      `json:"sources"`
+	Fixed     []Advisory         `json:"fixed_advisories,omitempty"`
+	Unpatched []Advisory         `json:"unpatched_advisories,omitempty"`
+}
+
+// WriteJSON writes a JSON report with a summary and the changes grouped
+// by source package
+func (d *Diff) WriteJSON(w io.Writer) error {
+	report := jsonReport{
+		Summary:   make(map[ChangeType]int, len(changeTypes)),
+		Sources:   d.BySource(),
+		Fixed:     d.Fixed,
+		Unpatched: d.Unpatched,
+	}
+	for _, changeType := range changeTypes {
+		report.Summary[changeType] = d.Count(changeType)
+	}
+	if report.Sources == nil {
+		report.Sources = []SourceGroup{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// WriteMarkdown writes a Markdown report (e.g. for release notes) grouped
+// by source package
+func (d *Diff) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Package changes\n\n%s\n", d.summary())
+	for _, group := range d.BySource() {
+		fmt.Fprintf(&b, "\n### %s\n\n", group.Source)
+		for _, change := range group.Changes {
+			versions := strings.ReplaceAll(change.versions(), "->", "→")
+			fmt.Fprintf(&b, "- **%s** `%s.%s` %s\n", change.Type, change.Name, change.Arch, versions)
+			for _, cl := range change.Changelog {
+				fmt.Fprintf(&b, "  - %s (%s)\n", cl.Author, cl.Date.Format("2006-01-02"))
+				for _, line := range strings.Split(strings.TrimSpace(cl.Text), "\n") {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+		}
+	}
+	for _, section := range []struct {
+		title      string
+		advisories []Advisory
+	}{{"Fixed advisories", d.Fixed}, {"Unpatched advisories", d.Unpatched}} {
+		if len(section.advisories) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", section.title)
+		for _, adv := range section.advisories {
+			fmt.Fprintf(&b, "- %s\n", adv)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
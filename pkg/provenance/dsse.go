@@ -0,0 +1,181 @@
+package provenance
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+)
+
+// PayloadType is the DSSE payload type of in-toto statements
+const PayloadType = "application/vnd.in-toto+json"
+
+// Envelope is a DSSE envelope, see
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+// The payload and the signatures are base64 encoded when marshaled.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   []byte `json:"sig"`
+}
+
+var ErrVerify = errors.New("cannot verify DSSE envelope")
+
+// pae returns the pre-authentication encoding of the payload, which is
+// what is signed
+func pae(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
+
+// ecdsaHash returns the hash that is used for signatures with keys on
+// the given curve
+func ecdsaHash(curve elliptic.Curve) (crypto.Hash, func() hash.Hash) {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384, sha512.New384
+	case 521:
+		return crypto.SHA512, sha512.New
+	default:
+		return crypto.SHA256, sha256.New
+	}
+}
+
+// KeyID returns the id of the public key: the hex encoded sha256 of its
+// PKIX encoding
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sign signs the payload with the ed25519 or ECDSA key and returns the
+// DSSE envelope.
+func Sign(payloadType string, payload []byte, key crypto.Signer) (*Envelope, error) {
+	keyID, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	msg := pae(payloadType, payload)
+	var sig []byte
+	switch pub := key.Public().(type) {
+	case ed25519.PublicKey:
+		sig, err = key.Sign(rand.Reader, msg, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		hashType, newHash := ecdsaHash(pub.Curve)
+		h := newHash()
+		h.Write(msg)
+		sig, err = key.Sign(rand.Reader, h.Sum(nil), hashType)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", pub)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign payload: %w", err)
+	}
+
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     payload,
+		Signatures: []Signature{
+			{KeyID: keyID, Sig: sig},
+		},
+	}, nil
+}
+
+// Verify checks that the envelope has a valid signature of the given
+// ed25519 or ECDSA public key.
+func (env *Envelope) Verify(pub crypto.PublicKey) error {
+	msg := pae(env.PayloadType, env.Payload)
+	for _, sig := range env.Signatures {
+		switch pub := pub.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(pub, msg, sig.Sig) {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			_, newHash := ecdsaHash(pub.Curve)
+			h := newHash()
+			h.Write(msg)
+			if ecdsa.VerifyASN1(pub, h.Sum(nil), sig.Sig) {
+				return nil
+			}
+		default:
+			return fmt.Errorf("%w: unsupported public key type %T", ErrVerify, pub)
+		}
+	}
+	return fmt.Errorf("%w: no valid signature", ErrVerify)
+}
+
+// Sign signs the statement with the key, see Sign()
+func (s *Statement) Sign(key crypto.Signer) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return Sign(PayloadType, payload, key)
+}
+
+// ParsePrivateKey parses a PEM encoded ed25519 or ECDSA private key in
+// PKCS #8 or SEC 1 ("EC PRIVATE KEY") form.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("cannot find PEM encoded private key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %w", err)
+	}
+
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// LoadPrivateKey reads the private key from the given file, see
+// ParsePrivateKey()
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load signing key %s: %w", path, err)
+	}
+	return key, nil
+}
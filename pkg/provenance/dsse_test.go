@@ -0,0 +1,106 @@
+package provenance_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/provenance"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	for name, key := range map[string]crypto.Signer{
+		"ed25519":    edKey,
+		"ecdsa-p256": p256Key,
+		"ecdsa-p384": p384Key,
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+			env, err := provenance.Sign(provenance.PayloadType, payload, key)
+			require.NoError(t, err)
+			assert.Equal(t, provenance.PayloadType, env.PayloadType)
+			assert.Equal(t, payload, env.Payload)
+			require.Len(t, env.Signatures, 1)
+			keyID, err := provenance.KeyID(key.Public())
+			require.NoError(t, err)
+			assert.Equal(t, keyID, env.Signatures[0].KeyID)
+
+			// the envelope survives a round trip through JSON
+			data, err := json.Marshal(env)
+			require.NoError(t, err)
+			var decoded provenance.Envelope
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.NoError(t, decoded.Verify(key.Public()))
+
+			decoded.Payload = []byte(`{"_type":"tampered"}`)
+			assert.ErrorIs(t, decoded.Verify(key.Public()), provenance.ErrVerify)
+		})
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	env, err := provenance.Sign(provenance.PayloadType, []byte("{}"), key)
+	require.NoError(t, err)
+	assert.ErrorIs(t, env.Verify(otherPub), provenance.ErrVerify)
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	tmpdir := t.TempDir()
+	for _, tc := range []struct {
+		name     string
+		block    *pem.Block
+		expected crypto.Signer
+	}{
+		{"ed25519.pem", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}, edKey},
+		{"ecdsa.pem", &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}, ecKey},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tmpdir, tc.name)
+			require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(tc.block), 0600))
+			key, err := provenance.LoadPrivateKey(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, key)
+		})
+	}
+}
+
+func TestParsePrivateKeyErrors(t *testing.T) {
+	_, err := provenance.ParsePrivateKey([]byte("not a key"))
+	assert.EqualError(t, err, "cannot find PEM encoded private key")
+
+	_, err = provenance.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("x")}))
+	assert.EqualError(t, err, `unsupported PEM block type "RSA PRIVATE KEY"`)
+}
@@ -0,0 +1,438 @@
+// Package provenance creates in-toto statements with SLSA v1 provenance
+// for the artifacts of an image build, see https://slsa.dev/spec/v1.0/provenance
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/imageinfo"
+	"github.com/osbuild/images/pkg/manifestgen"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/ostree"
+)
+
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://slsa.dev/provenance/v1"
+
+	// BuildType describes how the artifacts are built from the
+	// parameters: a manifest is generated by the images library and
+	// built by osbuild
+	BuildType = "https://github.com/osbuild/images/buildtypes/manifest/v1"
+
+	// DefaultBuilderID is used when Inputs.BuilderID is unset
+	DefaultBuilderID = "https://github.com/osbuild/images/cmd/build"
+)
+
+// Statement is an in-toto v1 statement with a SLSA v1 provenance
+// predicate
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Predicate            `json:"predicate"`
+}
+
+// ResourceDescriptor describes an artifact or an input of the build, see
+// https://github.com/in-toto/attestation/blob/main/spec/v1/resource_descriptor.md
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	MediaType   string            `json:"mediaType,omitempty"`
+	Annotations map[string]any    `json:"annotations,omitempty"`
+}
+
+type Predicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	InternalParameters   InternalParameters   `json:"internalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+// ExternalParameters are the parameters of the build that are under the
+// control of the user. Secrets (the passwords and ssh keys of the users and
+// the subscription organization and activation key) are replaced by their
+// "sha256:<digest>" so that they are not disclosed.
+type ExternalParameters struct {
+	Distro       string               `json:"distro"`
+	Arch         string               `json:"arch"`
+	ImageType    string               `json:"imageType"`
+	Blueprint    *blueprint.Blueprint `json:"blueprint,omitempty"`
+	ImageOptions *distro.ImageOptions `json:"imageOptions,omitempty"`
+}
+
+// InternalParameters are the parameters of the build that are set by the
+// builder
+type InternalParameters struct {
+	LibraryVersion string `json:"libraryVersion"`
+	OSBuildVersion string `json:"osbuildVersion,omitempty"`
+}
+
+type RunDetails struct {
+	Builder    Builder              `json:"builder"`
+	Metadata   *BuildMetadata       `json:"metadata,omitempty"`
+	Byproducts []ResourceDescriptor `json:"byproducts,omitempty"`
+}
+
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// Inputs are the inputs of the manifest generation that are recorded in
+// the provenance
+type Inputs struct {
+	Distro       string
+	Arch         string
+	ImageType    string
+	Blueprint    *blueprint.Blueprint
+	ImageOptions *distro.ImageOptions
+
+	// Content is the resolved content of the manifest, see
+	// manifestgen.Options.ResolvedContentCallback
+	Content *manifestgen.ResolvedContent
+	// Manifest is the serialized osbuild manifest
+	Manifest []byte
+
+	// BuilderID identifies the builder, DefaultBuilderID is used if
+	// it is unset
+	BuilderID string
+	// OSBuildVersion is the version of osbuild that built the manifest
+	OSBuildVersion string
+	StartedOn      time.Time
+	FinishedOn     time.Time
+}
+
+// New creates the provenance statement for the subjects (the artifacts,
+// see SubjectsFromDir()) that were built from the inputs. The result of
+// osbuild is optional, it is only available when osbuild was run with
+// JSON output.
+func New(inputs Inputs, result *osbuild.Result, subjects []ResourceDescriptor) (*Statement, error) {
+	if len(subjects) == 0 {
+		return nil, fmt.Errorf("cannot create provenance without subjects")
+	}
+	if len(inputs.Manifest) == 0 {
+		return nil, fmt.Errorf("cannot create provenance without a manifest")
+	}
+	if result != nil && result.Type != "" && !result.Success {
+		return nil, fmt.Errorf("cannot create provenance for a failed build")
+	}
+
+	builderID := inputs.BuilderID
+	if builderID == "" {
+		builderID = DefaultBuilderID
+	}
+	libraryVersion := LibraryVersion()
+
+	var metadata *BuildMetadata
+	if !inputs.StartedOn.IsZero() || !inputs.FinishedOn.IsZero() {
+		metadata = &BuildMetadata{}
+		if !inputs.StartedOn.IsZero() {
+			startedOn := inputs.StartedOn.UTC()
+			metadata.StartedOn = &startedOn
+		}
+		if !inputs.FinishedOn.IsZero() {
+			finishedOn := inputs.FinishedOn.UTC()
+			metadata.FinishedOn = &finishedOn
+		}
+	}
+
+	resolved := []ResourceDescriptor{
+		{
+			Name:      "manifest.json",
+			Digest:    map[string]string{"sha256": sha256Hex(inputs.Manifest)},
+			MediaType: "application/json",
+		},
+	}
+	resolved = append(resolved, resolvedDependencies(inputs.Content)...)
+
+	builderVersion := map[string]string{"github.com/osbuild/images": libraryVersion}
+	if inputs.OSBuildVersion != "" {
+		builderVersion["osbuild"] = inputs.OSBuildVersion
+	}
+
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			BuildDefinition: BuildDefinition{
+				BuildType: BuildType,
+				ExternalParameters: ExternalParameters{
+					Distro:       inputs.Distro,
+					Arch:         inputs.Arch,
+					ImageType:    inputs.ImageType,
+					Blueprint:    redactBlueprint(inputs.Blueprint),
+					ImageOptions: redactImageOptions(inputs.ImageOptions),
+				},
+				InternalParameters: InternalParameters{
+					LibraryVersion: libraryVersion,
+					OSBuildVersion: inputs.OSBuildVersion,
+				},
+				ResolvedDependencies: resolved,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{
+					ID:      builderID,
+					Version: builderVersion,
+				},
+				Metadata:   metadata,
+				Byproducts: byproducts(result),
+			},
+		},
+	}, nil
+}
+
+// redactedDigest returns the digest that replaces a secret in the
+// external parameters
+func redactedDigest(secret string) string {
+	return "sha256:" + sha256Hex([]byte(secret))
+}
+
+// redactBlueprint returns a copy of the blueprint with the passwords and
+// ssh keys of the users replaced by their digests
+func redactBlueprint(bp *blueprint.Blueprint) *blueprint.Blueprint {
+	if bp == nil || bp.Customizations == nil {
+		return bp
+	}
+	redacted := *bp
+	customizations := *bp.Customizations
+	customizations.User = slices.Clone(customizations.User)
+	for idx, user := range customizations.User {
+		if user.Password != nil && *user.Password != "" {
+			customizations.User[idx].Password = common.ToPtr(redactedDigest(*user.Password))
+		}
+		if user.Key != nil && *user.Key != "" {
+			customizations.User[idx].Key = common.ToPtr(redactedDigest(*user.Key))
+		}
+	}
+	customizations.SSHKey = slices.Clone(customizations.SSHKey)
+	for idx, key := range customizations.SSHKey {
+		customizations.SSHKey[idx].Key = redactedDigest(key.Key)
+	}
+	redacted.Customizations = &customizations
+	return &redacted
+}
+
+// redactImageOptions returns a copy of the image options with the
+// subscription organization and activation key replaced by their digests
+func redactImageOptions(opts *distro.ImageOptions) *distro.ImageOptions {
+	if opts == nil || opts.Subscription == nil {
+		return opts
+	}
+	redacted := *opts
+	subscription := *opts.Subscription
+	if subscription.Organization != "" {
+		subscription.Organization = redactedDigest(subscription.Organization)
+	}
+	if subscription.ActivationKey != "" {
+		subscription.ActivationKey = redactedDigest(subscription.ActivationKey)
+	}
+	redacted.Subscription = &subscription
+	return &redacted
+}
+
+// LibraryVersion returns the version of the images library that is built
+// into the running binary, see imageinfo.LibraryVersion()
+func LibraryVersion() string {
+	return imageinfo.LibraryVersion()
+}
+
+// SubjectsFromDir returns the subjects for all regular files in the given
+// directories below root, the names of the subjects are relative to root.
+func SubjectsFromDir(root string, dirs ...string) ([]ResourceDescriptor, error) {
+	var subjects []ResourceDescriptor
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			digest, err := fileSHA256(path)
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			subjects = append(subjects, ResourceDescriptor{
+				Name:   filepath.ToSlash(name),
+				Digest: map[string]string{"sha256": digest},
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot collect artifacts in %s: %w", dir, err)
+		}
+	}
+	return subjects, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// digestMap converts a "<algorithm>:<value>" digest into the digest set
+// of a resource descriptor
+func digestMap(digest string) map[string]string {
+	alg, value, found := strings.Cut(digest, ":")
+	if !found || value == "" {
+		return nil
+	}
+	return map[string]string{alg: value}
+}
+
+func containerDescriptor(spec container.Spec) ResourceDescriptor {
+	desc := ResourceDescriptor{
+		Name:   spec.LocalName,
+		URI:    fmt.Sprintf("docker://%s@%s", spec.Source, spec.Digest),
+		Digest: digestMap(spec.Digest),
+		Annotations: map[string]any{
+			"imageId": spec.ImageID,
+		},
+	}
+	if spec.LocalStorage {
+		desc.URI = fmt.Sprintf("containers-storage:%s@%s", spec.Source, spec.Digest)
+	}
+	if spec.Transport != "" {
+		// archives cannot be addressed by digest, the digest is
+		// recorded separately
+		desc.URI = fmt.Sprintf("%s:%s", spec.Transport, spec.Source)
+	}
+	if spec.ListDigest != "" {
+		desc.Annotations["listDigest"] = spec.ListDigest
+	}
+	return desc
+}
+
+func commitDescriptor(spec ostree.CommitSpec) ResourceDescriptor {
+	return ResourceDescriptor{
+		Name: spec.Ref,
+		URI:  spec.URL,
+		// ostree commit checksums are sha256 checksums
+		Digest: map[string]string{"sha256": spec.Checksum},
+	}
+}
+
+// resolvedDependencies returns the packages, containers, commits and
+// flatpaks of the resolved content in a stable order
+func resolvedDependencies(content *manifestgen.ResolvedContent) []ResourceDescriptor {
+	if content == nil {
+		return nil
+	}
+
+	var deps []ResourceDescriptor
+	seen := make(map[string]bool)
+	add := func(key string, desc ResourceDescriptor) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		deps = append(deps, desc)
+	}
+
+	for _, plName := range slices.Sorted(maps.Keys(content.Depsolved)) {
+		for _, pkg := range content.Depsolved[plName].Transactions.AllPackages() {
+			desc := ResourceDescriptor{
+				Name:      pkg.FullNEVRA(),
+				MediaType: "application/x-rpm",
+			}
+			if pkg.Checksum.Value != "" {
+				desc.Digest = map[string]string{pkg.Checksum.Type: pkg.Checksum.Value}
+			}
+			if len(pkg.RemoteLocations) > 0 {
+				desc.URI = pkg.RemoteLocations[0]
+			}
+			add("rpm:"+pkg.FullNEVRA()+":"+pkg.Checksum.String(), desc)
+		}
+	}
+	for _, plName := range slices.Sorted(maps.Keys(content.Containers)) {
+		for _, spec := range content.Containers[plName] {
+			add("container:"+spec.Source+"@"+spec.Digest, containerDescriptor(spec))
+		}
+	}
+	for _, plName := range slices.Sorted(maps.Keys(content.Commits)) {
+		for _, spec := range content.Commits[plName] {
+			add("commit:"+spec.Checksum, commitDescriptor(spec))
+		}
+	}
+	for _, plName := range slices.Sorted(maps.Keys(content.Flatpaks)) {
+		for _, spec := range content.Flatpaks[plName] {
+			if spec.ContainerSpec == nil {
+				continue
+			}
+			desc := containerDescriptor(*spec.ContainerSpec)
+			desc.Annotations["flatpak"] = true
+			add("flatpak:container:"+spec.ContainerSpec.Source+"@"+spec.ContainerSpec.Digest, desc)
+		}
+	}
+	return deps
+}
+
+// byproducts returns the pipelines and their stages that were run by
+// osbuild according to the result
+func byproducts(result *osbuild.Result) []ResourceDescriptor {
+	if result == nil {
+		return nil
+	}
+	var res []ResourceDescriptor
+	for _, plName := range slices.Sorted(maps.Keys(result.Log)) {
+		var stages []any
+		for _, stage := range result.Log[plName] {
+			stages = append(stages, map[string]any{
+				"id":   stage.ID,
+				"type": stage.Type,
+			})
+		}
+		res = append(res, ResourceDescriptor{
+			Name: "pipeline:" + plName,
+			Annotations: map[string]any{
+				"stages": stages,
+			},
+		})
+	}
+	return res
+}
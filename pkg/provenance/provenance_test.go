@@ -0,0 +1,259 @@
+package provenance_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/blueprint/pkg/blueprint"
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/subscription"
+	"github.com/osbuild/images/pkg/depsolvednf"
+	"github.com/osbuild/images/pkg/distro"
+	"github.com/osbuild/images/pkg/flatpak"
+	"github.com/osbuild/images/pkg/manifestgen"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/provenance"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+const (
+	testDigest   = "sha256:505fe73a6102a624a46a1732e14e47034b9cca6f1ceaa3ef728aefbb2390f026"
+	testChecksum = "02604b2da6e954bd34b8b82a835e5a77d2b60ffa4d0ae8e5ac6d8f3ac91a4f94"
+)
+
+func testContent() *manifestgen.ResolvedContent {
+	bash := rpmmd.Package{
+		Name:            "bash",
+		Version:         "5.2.26",
+		Release:         "3.fc42",
+		Arch:            "x86_64",
+		Checksum:        rpmmd.Checksum{Type: "sha256", Value: "aaaa"},
+		RemoteLocations: []string{"https://example.org/packages/bash-5.2.26-3.fc42.x86_64.rpm"},
+	}
+	return &manifestgen.ResolvedContent{
+		Depsolved: map[string]depsolvednf.DepsolveResult{
+			// bash is in both pipelines but only listed once
+			"os":    {Transactions: depsolvednf.TransactionList{{bash}}},
+			"build": {Transactions: depsolvednf.TransactionList{{bash}}},
+		},
+		Containers: map[string][]container.Spec{
+			"os": {
+				{
+					Source:    "registry.example.org/fedora",
+					Digest:    testDigest,
+					ImageID:   "sha256:1234",
+					LocalName: "registry.example.org/fedora:latest",
+				},
+			},
+		},
+		Commits: map[string][]ostree.CommitSpec{
+			"ostree-deployment": {
+				{Ref: "fedora/x86_64/iot", URL: "https://ostree.example.org/repo", Checksum: testChecksum},
+			},
+		},
+		Flatpaks: map[string][]flatpak.Spec{
+			"os": {
+				{ContainerSpec: &container.Spec{
+					Source:    "registry.example.org/app",
+					Digest:    testDigest,
+					ImageID:   "sha256:5678",
+					LocalName: "registry.example.org/app:stable",
+				}},
+			},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	subjects := []provenance.ResourceDescriptor{
+		{Name: "qcow2/disk.qcow2", Digest: map[string]string{"sha256": "bbbb"}},
+	}
+	started := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	inputs := provenance.Inputs{
+		Distro:         "fedora-42",
+		Arch:           "x86_64",
+		ImageType:      "qcow2",
+		Blueprint:      &blueprint.Blueprint{Name: "test"},
+		Content:        testContent(),
+		Manifest:       []byte(`{"version":"2"}`),
+		OSBuildVersion: "160",
+		StartedOn:      started,
+		FinishedOn:     started.Add(time.Hour),
+	}
+	result := &osbuild.Result{
+		Type:    "result",
+		Success: true,
+		Log: map[string]osbuild.PipelineResult{
+			"os": {{ID: "1234", Type: "org.osbuild.rpm", Success: true}},
+		},
+	}
+
+	st, err := provenance.New(inputs, result, subjects)
+	require.NoError(t, err)
+
+	assert.Equal(t, provenance.StatementType, st.Type)
+	assert.Equal(t, provenance.PredicateType, st.PredicateType)
+	assert.Equal(t, subjects, st.Subject)
+
+	def := st.Predicate.BuildDefinition
+	assert.Equal(t, provenance.BuildType, def.BuildType)
+	assert.Equal(t, provenance.ExternalParameters{
+		Distro:    "fedora-42",
+		Arch:      "x86_64",
+		ImageType: "qcow2",
+		Blueprint: &blueprint.Blueprint{Name: "test"},
+	}, def.ExternalParameters)
+	assert.Equal(t, "160", def.InternalParameters.OSBuildVersion)
+	assert.NotEmpty(t, def.InternalParameters.LibraryVersion)
+
+	assert.Equal(t, []provenance.ResourceDescriptor{
+		{
+			Name:      "manifest.json",
+			Digest:    map[string]string{"sha256": "77da9a7ed3026aba89bb78dac4b72501c10b9ae1f0689d3c4b31e27c3667940d"},
+			MediaType: "application/json",
+		},
+		{
+			Name:      "bash-0:5.2.26-3.fc42.x86_64",
+			URI:       "https://example.org/packages/bash-5.2.26-3.fc42.x86_64.rpm",
+			Digest:    map[string]string{"sha256": "aaaa"},
+			MediaType: "application/x-rpm",
+		},
+		{
+			Name:        "registry.example.org/fedora:latest",
+			URI:         "docker://registry.example.org/fedora@" + testDigest,
+			Digest:      map[string]string{"sha256": testDigest[len("sha256:"):]},
+			Annotations: map[string]any{"imageId": "sha256:1234"},
+		},
+		{
+			Name:   "fedora/x86_64/iot",
+			URI:    "https://ostree.example.org/repo",
+			Digest: map[string]string{"sha256": testChecksum},
+		},
+		{
+			Name:        "registry.example.org/app:stable",
+			URI:         "docker://registry.example.org/app@" + testDigest,
+			Digest:      map[string]string{"sha256": testDigest[len("sha256:"):]},
+			Annotations: map[string]any{"imageId": "sha256:5678", "flatpak": true},
+		},
+	}, def.ResolvedDependencies)
+
+	run := st.Predicate.RunDetails
+	assert.Equal(t, provenance.DefaultBuilderID, run.Builder.ID)
+	assert.Equal(t, "160", run.Builder.Version["osbuild"])
+	require.NotNil(t, run.Metadata)
+	assert.Equal(t, started, *run.Metadata.StartedOn)
+	assert.Equal(t, started.Add(time.Hour), *run.Metadata.FinishedOn)
+	require.Len(t, run.Byproducts, 1)
+	assert.Equal(t, "pipeline:os", run.Byproducts[0].Name)
+
+	// the signed statement can be verified and decoded again
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	env, err := st.Sign(key)
+	require.NoError(t, err)
+	assert.NoError(t, env.Verify(key.Public()))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(env.Payload, &decoded))
+	assert.Equal(t, provenance.PredicateType, decoded["predicateType"])
+}
+
+func TestNewRedactsSecrets(t *testing.T) {
+	bp := &blueprint.Blueprint{
+		Name: "test",
+		Customizations: &blueprint.Customizations{
+			Hostname: common.ToPtr("myhost"),
+			User: []blueprint.UserCustomization{
+				{
+					Name:     "alice",
+					Password: common.ToPtr("$6$salt$hash"),
+					Key:      common.ToPtr("ssh-ed25519 AAAA alice"),
+				},
+				{
+					Name: "bob",
+				},
+			},
+			SSHKey: []blueprint.SSHKeyCustomization{
+				{User: "root", Key: "ssh-ed25519 AAAA root"},
+			},
+		},
+	}
+	opts := &distro.ImageOptions{
+		Subscription: &subscription.ImageOptions{
+			Organization:  "12345",
+			ActivationKey: "secret-key",
+			ServerUrl:     "subscription.example.org",
+		},
+	}
+	inputs := provenance.Inputs{
+		Blueprint:    bp,
+		ImageOptions: opts,
+		Manifest:     []byte(`{"version":"2"}`),
+	}
+	subjects := []provenance.ResourceDescriptor{{Name: "disk.qcow2"}}
+
+	st, err := provenance.New(inputs, nil, subjects)
+	require.NoError(t, err)
+
+	params := st.Predicate.BuildDefinition.ExternalParameters
+	customizations := params.Blueprint.Customizations
+	assert.Equal(t, "myhost", *customizations.Hostname)
+	assert.Equal(t, "sha256:d722f0abfd853428937eca95ec5c7c6fb589a9aef596fbd89aee20f0fc2cafd4", *customizations.User[0].Password)
+	assert.Equal(t, "sha256:d3a89a61ceaa841b92afb2925d0a93a58b77a985125721d153146f19f6aef417", *customizations.User[0].Key)
+	assert.Nil(t, customizations.User[1].Password)
+	assert.Equal(t, "sha256:4b9792d5c01f6875d2e47c1448161146280cbc7292f6dee962e7e221257557bb", customizations.SSHKey[0].Key)
+	assert.Equal(t, "sha256:5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5", params.ImageOptions.Subscription.Organization)
+	assert.Equal(t, "sha256:85dbe15d75ef9308c7ae0f33c7a324cc6f4bf519a2ed2f3027bd33c140a4f9aa", params.ImageOptions.Subscription.ActivationKey)
+	assert.Equal(t, "subscription.example.org", params.ImageOptions.Subscription.ServerUrl)
+
+	// the inputs are not modified
+	assert.Equal(t, "$6$salt$hash", *bp.Customizations.User[0].Password)
+	assert.Equal(t, "ssh-ed25519 AAAA root", bp.Customizations.SSHKey[0].Key)
+	assert.Equal(t, "secret-key", opts.Subscription.ActivationKey)
+}
+
+func TestNewErrors(t *testing.T) {
+	subjects := []provenance.ResourceDescriptor{{Name: "disk.qcow2"}}
+	inputs := provenance.Inputs{Manifest: []byte("{}")}
+
+	_, err := provenance.New(inputs, nil, nil)
+	assert.EqualError(t, err, "cannot create provenance without subjects")
+
+	_, err = provenance.New(provenance.Inputs{}, nil, subjects)
+	assert.EqualError(t, err, "cannot create provenance without a manifest")
+
+	_, err = provenance.New(inputs, &osbuild.Result{Type: "result", Success: false}, subjects)
+	assert.EqualError(t, err, "cannot create provenance for a failed build")
+
+	// without JSON output osbuild returns an empty result
+	_, err = provenance.New(inputs, &osbuild.Result{}, subjects)
+	assert.NoError(t, err)
+}
+
+func TestSubjectsFromDir(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "qcow2"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "qcow2", "disk.qcow2"), []byte("disk"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "manifest.json"), []byte("{}"), 0644))
+
+	subjects, err := provenance.SubjectsFromDir(root, "qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, []provenance.ResourceDescriptor{
+		{
+			Name:   "qcow2/disk.qcow2",
+			Digest: map[string]string{"sha256": "1044dec7206e8d7c9fbb4ae8f766668406d2567fc7fc1a160a9d4700fcf8f8e9"},
+		},
+	}, subjects)
+
+	_, err = provenance.SubjectsFromDir(root, "missing")
+	assert.ErrorContains(t, err, "cannot collect artifacts in missing")
+}
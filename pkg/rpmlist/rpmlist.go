@@ -3,6 +3,9 @@ package rpmlist
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"time"
 
 	"github.com/osbuild/images/pkg/rpmmd"
 )
@@ -59,3 +62,29 @@ func EncodePackages(packages rpmmd.PackageList) (*bytes.Buffer, error) {
 	}
 	return &buf, nil
 }
+
+// DecodePackages reads a package list in the format written by
+// EncodePackages. The payload hash is not restored as the checksum type is
+// not part of the format.
+func DecodePackages(r io.Reader) (rpmmd.PackageList, error) {
+	var entries []kojiRpmListEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("cannot decode rpmlist: %w", err)
+	}
+	packages := make(rpmmd.PackageList, 0, len(entries))
+	for _, e := range entries {
+		p := rpmmd.Package{
+			Name:         e.Name,
+			Version:      e.Version,
+			Release:      e.Release,
+			Epoch:        e.Epoch,
+			Arch:         e.Arch,
+			DownloadSize: e.Size,
+		}
+		if e.BuildTime != 0 {
+			p.BuildTime = time.Unix(e.BuildTime, 0).UTC()
+		}
+		packages = append(packages, p)
+	}
+	return packages, nil
+}
@@ -1,6 +1,7 @@
 package rpmlist
 
 import (
+	"bytes"
 	"encoding/json"
 	"testing"
 	"time"
@@ -86,3 +87,26 @@ func TestEncodePackages(t *testing.T) {
 	})
 
 }
+
+func TestDecodePackages(t *testing.T) {
+	pkgs := rpmmd.PackageList{
+		{
+			Name: "bash", Version: "5.2", Release: "1.fc43", Epoch: 0, Arch: "x86_64",
+			DownloadSize: 12345,
+			BuildTime:    time.Unix(1700000000, 0).UTC(),
+			Checksum:     rpmmd.Checksum{Type: "sha256", Value: "a250e0b22938f0630f64b0b534141ba0"},
+		},
+		{Name: "dnf5", Version: "1.12", Release: "1.fc43", Epoch: 1, Arch: "riscv64"},
+	}
+	b, err := EncodePackages(pkgs)
+	require.NoError(t, err)
+
+	decoded, err := DecodePackages(b)
+	require.NoError(t, err)
+	// the payload hash is not restored
+	pkgs[0].Checksum = rpmmd.Checksum{}
+	assert.Equal(t, pkgs, decoded)
+
+	_, err = DecodePackages(bytes.NewBufferString(`{"name": "bash"}`))
+	assert.ErrorContains(t, err, "cannot decode rpmlist: json: cannot unmarshal object")
+}
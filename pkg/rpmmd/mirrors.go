@@ -0,0 +1,166 @@
+package rpmmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// metalink is the subset of a metalink document (as served e.g. by
+// mirrors.fedoraproject.org) that is needed to find the mirrors of a
+// repository and to verify its repomd.xml.
+type metalink struct {
+	Files []metalinkFile `xml:"files>file"`
+}
+
+type metalinkFile struct {
+	Name   string           `xml:"name,attr"`
+	Hashes []repomdChecksum `xml:"verification>hash"`
+	URLs   []metalinkURL    `xml:"resources>url"`
+}
+
+type metalinkURL struct {
+	Protocol   string `xml:"protocol,attr"`
+	Preference int    `xml:"preference,attr"`
+	URL        string `xml:",chardata"`
+}
+
+// parseMetalink returns the base URLs of the mirrors in the metalink
+// document, ordered by their preference, and the checksums of the current
+// repomd.xml.
+func parseMetalink(data []byte) ([]string, []repomdChecksum, error) {
+	var ml metalink
+	if err := xml.Unmarshal(data, &ml); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse metalink: %w", err)
+	}
+	idx := slices.IndexFunc(ml.Files, func(f metalinkFile) bool {
+		return f.Name == "repomd.xml"
+	})
+	if idx < 0 {
+		return nil, nil, fmt.Errorf("metalink has no repomd.xml")
+	}
+	file := ml.Files[idx]
+
+	urls := slices.Clone(file.URLs)
+	slices.SortStableFunc(urls, func(a, b metalinkURL) int {
+		return b.Preference - a.Preference
+	})
+	var baseURLs []string
+	for _, u := range urls {
+		if u.Protocol != "http" && u.Protocol != "https" {
+			continue
+		}
+		baseURL, ok := strings.CutSuffix(strings.TrimSpace(u.URL), "repodata/repomd.xml")
+		if !ok {
+			continue
+		}
+		baseURLs = append(baseURLs, baseURL)
+	}
+	if len(baseURLs) == 0 {
+		return nil, nil, fmt.Errorf("metalink has no http or https mirrors")
+	}
+	return baseURLs, file.Hashes, nil
+}
+
+// parseMirrorlist returns the base URLs of a mirrorlist, which contains one
+// base URL per line.
+func parseMirrorlist(data []byte) ([]string, error) {
+	var baseURLs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		baseURLs = append(baseURLs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read mirrorlist: %w", err)
+	}
+	if len(baseURLs) == 0 {
+		return nil, fmt.Errorf("mirrorlist has no mirrors")
+	}
+	return baseURLs, nil
+}
+
+// openRepo returns the fetcher and the base URLs of the repository. The
+// mirrors of metalink and mirrorlist repositories are resolved first, for
+// metalink repositories the fetcher verifies the repomd.xml of the mirrors
+// against the checksums of the metalink. RHSM repositories use the
+// entitlement certificates of the subscriptions (see SetSubscriptions).
+func (r *MetadataReader) openRepo(repo *RepoConfig) (*repoFetcher, []string, error) {
+	tlsRepo := *repo
+	if repo.RHSM {
+		if r.subscriptions == nil {
+			return nil, nil, fmt.Errorf("repository %q requires RHSM subscriptions but none are set", repoName(repo))
+		}
+		secrets, err := r.subscriptions.GetSecretsForBaseurl(repo.BaseURLs, r.arch, r.releaseVer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot get the RHSM secrets of repository %q: %w", repoName(repo), err)
+		}
+		tlsRepo.SSLCACert = secrets.SSLCACert
+		tlsRepo.SSLClientKey = secrets.SSLClientKey
+		tlsRepo.SSLClientCert = secrets.SSLClientCert
+	}
+	f, err := newRepoFetcher(&tlsRepo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case len(repo.BaseURLs) > 0:
+		return f, repo.BaseURLs, nil
+	case repo.Metalink != "":
+		data, err := f.Fetch(repo.Metalink)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot fetch metalink of repository %q: %w", repoName(repo), err)
+		}
+		baseURLs, checksums, err := parseMetalink(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("repository %q: %w", repoName(repo), err)
+		}
+		f.repomdChecksums = checksums
+		return f, baseURLs, nil
+	case repo.MirrorList != "":
+		data, err := f.Fetch(repo.MirrorList)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot fetch mirrorlist of repository %q: %w", repoName(repo), err)
+		}
+		baseURLs, err := parseMirrorlist(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("repository %q: %w", repoName(repo), err)
+		}
+		return f, baseURLs, nil
+	}
+	return nil, nil, fmt.Errorf("repository %q has no baseurl, metalink or mirrorlist", repoName(repo))
+}
+
+// fetchRepomd fetches and parses the repomd.xml of the repository at
+// baseURL (which must end with a "/"). If the fetcher has checksums from a
+// metalink the repomd.xml must match one of them.
+func (f *repoFetcher) fetchRepomd(baseURL string) (*repomd, error) {
+	data, err := f.Fetch(baseURL + "repodata/repomd.xml")
+	if err != nil {
+		return nil, err
+	}
+	if len(f.repomdChecksums) > 0 {
+		verified := false
+		for _, c := range f.repomdChecksums {
+			if c.verify(data) == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, fmt.Errorf("repomd.xml of %s does not match the metalink", baseURL)
+		}
+	}
+	var md repomd
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return nil, fmt.Errorf("cannot parse repomd.xml of %s: %w", baseURL, err)
+	}
+	return &md, nil
+}
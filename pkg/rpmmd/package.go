@@ -30,6 +30,13 @@ func (c Checksum) String() string {
 	return fmt.Sprintf("%s:%s", c.Type, c.Value)
 }
 
+// ChangelogEntry is a single entry of the changelog of an RPM package
+type ChangelogEntry struct {
+	Author string
+	Date   time.Time
+	Text   string
+}
+
 // RPM package representation
 //
 // Based on libdnf5: https://github.com/rpm-software-management/dnf5/blob/main/include/libdnf5/rpm/package.hpp
@@ -101,6 +108,14 @@ type Package struct {
 	// List of files and directories the RPM package contains
 	Files []string
 
+	// Changelog entries of the RPM package, newest first. Only set when
+	// the changelogs were requested from the metadata.
+	Changelogs []ChangelogEntry
+
+	// Advisories that were released with this version of the package,
+	// see AttachAdvisories()
+	Advisories []PackageAdvisory
+
 	// Repodata
 	// RPM package relative path/location from repodata
 	Location string
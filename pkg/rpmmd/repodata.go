@@ -0,0 +1,709 @@
+package rpmmd
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha1" // #nosec G505 -- older repositories still use sha1 checksums
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/osbuild/images/internal/fetch"
+	"github.com/osbuild/images/pkg/rhsm"
+)
+
+// MetadataReader reads the package metadata of rpm-md repositories
+// (repomd.xml, primary.xml and optionally filelists.xml and other.xml)
+// without the need for dnf or osbuild-depsolve-dnf. It is intended for
+// tooling that only needs to inspect the available packages of a
+// repository, it cannot depsolve.
+//
+// Repositories can be given by their baseurl, a metalink or a mirrorlist.
+// Both remote ("http://", "https://") and local ("file://") baseurls can be
+// used. RHSM repositories require the subscriptions of the host, see
+// SetSubscriptions().
+type MetadataReader struct {
+	// cacheDir is the (distro specific) cache directory, see
+	// depsolvednf.Solver.GetCacheDir(). Each repository uses a directory
+	// prefixed by its Hash() so that the entries are accounted for by the
+	// depsolvednf cache cleanup.
+	cacheDir string
+
+	filelists  bool
+	changelogs bool
+
+	// packages are the parsed package lists keyed by the repository hash
+	packages map[string]PackageList
+
+	// advisories are the parsed advisories keyed by the repository hash
+	advisories map[string][]Advisory
+
+	// subscriptions provide the secrets of RHSM repositories, arch and
+	// releaseVer are used to find the subscription of a repository
+	subscriptions *rhsm.Subscriptions
+	arch          string
+	releaseVer    string
+}
+
+// NewMetadataReader creates a new MetadataReader that stores the metadata
+// files in cacheDir. If cacheDir is empty nothing is cached.
+func NewMetadataReader(cacheDir string) *MetadataReader {
+	return &MetadataReader{
+		cacheDir:   cacheDir,
+		packages:   make(map[string]PackageList),
+		advisories: make(map[string][]Advisory),
+	}
+}
+
+// SetFilelists enables or disables reading the filelists metadata. When
+// enabled the Files of each Package contain the full list of files and
+// directories of the package, otherwise only the (incomplete) file list
+// from the primary metadata is available.
+func (r *MetadataReader) SetFilelists(enabled bool) {
+	if r.filelists != enabled {
+		// the cached package lists are incomplete or too detailed now
+		r.packages = make(map[string]PackageList)
+	}
+	r.filelists = enabled
+}
+
+// SetChangelogs enables or disables reading the "other" metadata which
+// contains the changelogs. When enabled the Changelogs of each Package
+// contain its changelog entries.
+func (r *MetadataReader) SetChangelogs(enabled bool) {
+	if r.changelogs != enabled {
+		r.packages = make(map[string]PackageList)
+	}
+	r.changelogs = enabled
+}
+
+// SetSubscriptions sets the subscriptions that provide the entitlement
+// certificates of RHSM repositories (see RepoConfig.RHSM). The arch and
+// releasever are used to match the baseurls of the repositories with the
+// subscriptions. Without subscriptions RHSM repositories are rejected.
+func (r *MetadataReader) SetSubscriptions(subscriptions *rhsm.Subscriptions, arch, releaseVer string) {
+	r.subscriptions = subscriptions
+	r.arch = arch
+	r.releaseVer = releaseVer
+}
+
+// FetchMetadata returns all packages of the given repositories sorted by
+// NVR.
+func (r *MetadataReader) FetchMetadata(repos []RepoConfig) (PackageList, error) {
+	var pkgs PackageList
+	for idx := range repos {
+		repoPkgs, err := r.readRepository(&repos[idx])
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, repoPkgs...)
+	}
+	sortByNVR(pkgs)
+	return pkgs, nil
+}
+
+// SearchMetadata returns the packages of the given repositories whose name
+// matches any of the given packages, glob patterns (e.g. "kernel*") are
+// supported. The result is sorted by NVR.
+func (r *MetadataReader) SearchMetadata(repos []RepoConfig, packages []string) (PackageList, error) {
+	for _, pattern := range packages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+
+	all, err := r.FetchMetadata(repos)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs PackageList
+	for _, pkg := range all {
+		if slices.ContainsFunc(packages, func(pattern string) bool {
+			// the patterns were validated above
+			match, _ := path.Match(pattern, pkg.Name)
+			return match
+		}) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// FetchAdvisories returns the advisories from the updateinfo metadata of the
+// given repositories. Repositories without updateinfo metadata are skipped.
+func (r *MetadataReader) FetchAdvisories(repos []RepoConfig) ([]Advisory, error) {
+	var advisories []Advisory
+	seen := make(map[string]bool)
+	for idx := range repos {
+		repoAdvisories, err := r.readAdvisories(&repos[idx])
+		if err != nil {
+			return nil, err
+		}
+		for _, adv := range repoAdvisories {
+			// the same advisory can be part of several repositories
+			if seen[adv.ID] {
+				continue
+			}
+			seen[adv.ID] = true
+			advisories = append(advisories, adv)
+		}
+	}
+	slices.SortFunc(advisories, func(a, b Advisory) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return advisories, nil
+}
+
+func (r *MetadataReader) readAdvisories(repo *RepoConfig) ([]Advisory, error) {
+	hash := repo.Hash()
+	if advisories, ok := r.advisories[hash]; ok {
+		return advisories, nil
+	}
+
+	f, baseURLs, err := r.openRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	var cacheDir string
+	if r.cacheDir != "" {
+		cacheDir = filepath.Join(r.cacheDir, hash+"-rpmmd")
+	}
+
+	var errs []string
+	for _, baseURL := range baseURLs {
+		advisories, err := readAdvisoriesFrom(f, baseURL, cacheDir)
+		if err == nil {
+			r.advisories[hash] = advisories
+			return advisories, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("cannot read advisories of repository %q: %s", repoName(repo), strings.Join(errs, "; "))
+}
+
+func readAdvisoriesFrom(f *repoFetcher, baseURL, cacheDir string) ([]Advisory, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+	md, err := f.fetchRepomd(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	updateinfo := md.find("updateinfo")
+	if updateinfo == nil {
+		return nil, nil
+	}
+	data, err := loadRepoData(f, baseURL, updateinfo, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	advisories, err := ReadUpdateInfo(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", baseURL, err)
+	}
+	return advisories, nil
+}
+
+func sortByNVR(pkgs PackageList) {
+	slices.SortStableFunc(pkgs, func(a, b Package) int {
+		return strings.Compare(a.NVR(), b.NVR())
+	})
+}
+
+func (r *MetadataReader) readRepository(repo *RepoConfig) (PackageList, error) {
+	hash := repo.Hash()
+	if pkgs, ok := r.packages[hash]; ok {
+		return pkgs, nil
+	}
+
+	f, baseURLs, err := r.openRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+	var cacheDir string
+	if r.cacheDir != "" {
+		cacheDir = filepath.Join(r.cacheDir, hash+"-rpmmd")
+	}
+
+	var errs []string
+	for _, baseURL := range baseURLs {
+		pkgs, err := r.readRepositoryFrom(f, repo, baseURL, cacheDir)
+		if err == nil {
+			r.packages[hash] = pkgs
+			return pkgs, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("cannot read metadata of repository %q: %s", repoName(repo), strings.Join(errs, "; "))
+}
+
+func repoName(repo *RepoConfig) string {
+	if repo.Name != "" {
+		return repo.Name
+	}
+	return repo.Id
+}
+
+func (r *MetadataReader) readRepositoryFrom(f *repoFetcher, repo *RepoConfig, baseURL, cacheDir string) (PackageList, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+	md, err := f.fetchRepomd(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	primary := md.find("primary")
+	if primary == nil {
+		return nil, fmt.Errorf("no primary metadata in repomd.xml of %s", baseURL)
+	}
+	data, err := loadRepoData(f, baseURL, primary, cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	var pm primaryMetadata
+	if err := xml.Unmarshal(data, &pm); err != nil {
+		return nil, fmt.Errorf("cannot parse primary metadata of %s: %w", baseURL, err)
+	}
+
+	pkgs := make(PackageList, 0, len(pm.Packages))
+	byPkgID := make(map[string]int, len(pm.Packages))
+	for _, p := range pm.Packages {
+		byPkgID[p.Checksum.Value] = len(pkgs)
+		pkgs = append(pkgs, p.toPackage(repo, baseURL))
+	}
+
+	if r.filelists {
+		fl := md.find("filelists")
+		if fl == nil {
+			return nil, fmt.Errorf("no filelists metadata in repomd.xml of %s", baseURL)
+		}
+		data, err := loadRepoData(f, baseURL, fl, cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		var flm filelistsMetadata
+		if err := xml.Unmarshal(data, &flm); err != nil {
+			return nil, fmt.Errorf("cannot parse filelists metadata of %s: %w", baseURL, err)
+		}
+		for _, p := range flm.Packages {
+			idx, ok := byPkgID[p.PkgID]
+			if !ok {
+				continue
+			}
+			files := make([]string, 0, len(p.Files))
+			for _, file := range p.Files {
+				files = append(files, file.Path)
+			}
+			pkgs[idx].Files = files
+		}
+	}
+
+	if r.changelogs {
+		other := md.find("other")
+		if other == nil {
+			return nil, fmt.Errorf("no other metadata in repomd.xml of %s", baseURL)
+		}
+		data, err := loadRepoData(f, baseURL, other, cacheDir)
+		if err != nil {
+			return nil, err
+		}
+		var om otherMetadata
+		if err := xml.Unmarshal(data, &om); err != nil {
+			return nil, fmt.Errorf("cannot parse other metadata of %s: %w", baseURL, err)
+		}
+		for _, p := range om.Packages {
+			idx, ok := byPkgID[p.PkgID]
+			if !ok {
+				continue
+			}
+			changelogs := make([]ChangelogEntry, 0, len(p.Changelogs))
+			for _, cl := range p.Changelogs {
+				changelogs = append(changelogs, ChangelogEntry{
+					Author: cl.Author,
+					Date:   time.Unix(cl.Date, 0).UTC(),
+					Text:   cl.Text,
+				})
+			}
+			// the metadata lists the oldest entry first
+			slices.Reverse(changelogs)
+			pkgs[idx].Changelogs = changelogs
+		}
+	}
+
+	return pkgs, nil
+}
+
+// loadRepoData returns the uncompressed content of the given repomd data
+// entry after verifying its checksum. Verified files are cached by their
+// location.
+func loadRepoData(f *repoFetcher, baseURL string, d *repomdData, cacheDir string) ([]byte, error) {
+	var cachePath string
+	if cacheDir != "" {
+		cachePath = filepath.Join(cacheDir, "repodata", path.Base(d.Location.Href))
+	}
+
+	data, err := readCachedRepoData(cachePath, d.Checksum)
+	if err != nil {
+		location := baseURL + d.Location.Href
+		if d.Location.Base != "" {
+			location = strings.TrimSuffix(d.Location.Base, "/") + "/" + d.Location.Href
+		}
+		data, err = f.Fetch(location)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.Checksum.verify(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+		if cachePath != "" {
+			// the cache is only an optimization, ignore errors
+			_ = writeFileAtomic(cachePath, data)
+		}
+	}
+
+	return decompress(d.Location.Href, data)
+}
+
+// writeFileAtomic writes the data to a temporary file next to path and
+// renames it so that concurrent readers (possibly in other processes
+// sharing the cache) never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func readCachedRepoData(path string, checksum repomdChecksum) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checksum.verify(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decompress decompresses the data based on the file extension of name.
+func decompress(name string, data []byte) ([]byte, error) {
+	var rd io.Reader
+	switch filepath.Ext(name) {
+	case ".gz":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		defer zr.Close()
+		rd = zr
+	case ".zst":
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		defer zr.Close()
+		rd = zr
+	case ".xz":
+		zr, err := xz.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+		}
+		rd = zr
+	case ".bz2":
+		rd = bzip2.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+	}
+	return out, nil
+}
+
+// repoFetcher retrieves repository files honouring the TLS options of the
+// repository.
+type repoFetcher struct {
+	*fetch.Fetcher
+	// repomdChecksums are the checksums of the repomd.xml from the
+	// metalink of the repository (if any)
+	repomdChecksums []repomdChecksum
+}
+
+func newRepoFetcher(repo *RepoConfig) (*repoFetcher, error) {
+	f, err := fetch.New(fetch.TLSOptions{
+		InsecureSkipVerify: repo.IgnoreSSL != nil && *repo.IgnoreSSL,
+		CACert:             repo.SSLCACert,
+		ClientCert:         repo.SSLClientCert,
+		ClientKey:          repo.SSLClientKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &repoFetcher{Fetcher: f}, nil
+}
+
+// repomd is the repodata/repomd.xml index of a repository
+type repomd struct {
+	Data []repomdData `xml:"data"`
+}
+
+type repomdData struct {
+	Type     string         `xml:"type,attr"`
+	Checksum repomdChecksum `xml:"checksum"`
+	Location repomdLocation `xml:"location"`
+}
+
+type repomdChecksum struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type repomdLocation struct {
+	Href string `xml:"href,attr"`
+	Base string `xml:"base,attr"`
+}
+
+func (md *repomd) find(dataType string) *repomdData {
+	for idx := range md.Data {
+		if md.Data[idx].Type == dataType {
+			return &md.Data[idx]
+		}
+	}
+	return nil
+}
+
+func (c repomdChecksum) verify(data []byte) error {
+	var h hash.Hash
+	switch c.Type {
+	case "sha", "sha1":
+		h = sha1.New() // #nosec G401
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported checksum type %q", c.Type)
+	}
+	h.Write(data)
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.TrimSpace(c.Value) {
+		return fmt.Errorf("checksum mismatch, expected %s:%s got %s:%s", c.Type, c.Value, c.Type, sum)
+	}
+	return nil
+}
+
+// primaryMetadata is the content of the primary.xml metadata file
+type primaryMetadata struct {
+	Packages []primaryPackage `xml:"package"`
+}
+
+type primaryPackage struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch   uint   `xml:"epoch,attr"`
+		Version string `xml:"ver,attr"`
+		Release string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum    repomdChecksum `xml:"checksum"`
+	Summary     string         `xml:"summary"`
+	Description string         `xml:"description"`
+	Packager    string         `xml:"packager"`
+	URL         string         `xml:"url"`
+	Time        struct {
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+	Size struct {
+		Package   uint64 `xml:"package,attr"`
+		Installed uint64 `xml:"installed,attr"`
+	} `xml:"size"`
+	Location repomdLocation `xml:"location"`
+	Format   struct {
+		License     string          `xml:"license"`
+		Vendor      string          `xml:"vendor"`
+		Group       string          `xml:"group"`
+		SourceRpm   string          `xml:"sourcerpm"`
+		Provides    []primaryRelDep `xml:"provides>entry"`
+		Requires    []primaryRelDep `xml:"requires>entry"`
+		Conflicts   []primaryRelDep `xml:"conflicts>entry"`
+		Obsoletes   []primaryRelDep `xml:"obsoletes>entry"`
+		Recommends  []primaryRelDep `xml:"recommends>entry"`
+		Suggests    []primaryRelDep `xml:"suggests>entry"`
+		Enhances    []primaryRelDep `xml:"enhances>entry"`
+		Supplements []primaryRelDep `xml:"supplements>entry"`
+		Files       []string        `xml:"file"`
+	} `xml:"format"`
+}
+
+type primaryRelDep struct {
+	Name    string `xml:"name,attr"`
+	Flags   string `xml:"flags,attr"`
+	Epoch   string `xml:"epoch,attr"`
+	Version string `xml:"ver,attr"`
+	Release string `xml:"rel,attr"`
+	Pre     string `xml:"pre,attr"`
+}
+
+var relDepFlags = map[string]string{
+	"EQ": "=",
+	"LT": "<",
+	"LE": "<=",
+	"GT": ">",
+	"GE": ">=",
+}
+
+func (d primaryRelDep) toRelDep() RelDep {
+	dep := RelDep{
+		Name:         d.Name,
+		Relationship: relDepFlags[d.Flags],
+	}
+	if d.Version != "" {
+		dep.Version = d.Version
+		if d.Epoch != "" && d.Epoch != "0" {
+			dep.Version = d.Epoch + ":" + dep.Version
+		}
+		if d.Release != "" {
+			dep.Version += "-" + d.Release
+		}
+	}
+	return dep
+}
+
+func toRelDepList(deps []primaryRelDep) RelDepList {
+	if len(deps) == 0 {
+		return nil
+	}
+	l := make(RelDepList, 0, len(deps))
+	for _, d := range deps {
+		l = append(l, d.toRelDep())
+	}
+	return l
+}
+
+func (p primaryPackage) toPackage(repo *RepoConfig, baseURL string) Package {
+	remote := baseURL + p.Location.Href
+	if p.Location.Base != "" {
+		remote = strings.TrimSuffix(p.Location.Base, "/") + "/" + p.Location.Href
+	}
+
+	pkg := Package{
+		Name:            p.Name,
+		Epoch:           p.Version.Epoch,
+		Version:         p.Version.Version,
+		Release:         p.Version.Release,
+		Arch:            p.Arch,
+		Group:           p.Format.Group,
+		DownloadSize:    p.Size.Package,
+		InstallSize:     p.Size.Installed,
+		License:         p.Format.License,
+		SourceRpm:       p.Format.SourceRpm,
+		Packager:        p.Packager,
+		Vendor:          p.Format.Vendor,
+		URL:             p.URL,
+		Summary:         p.Summary,
+		Description:     p.Description,
+		Provides:        toRelDepList(p.Format.Provides),
+		Requires:        toRelDepList(p.Format.Requires),
+		Conflicts:       toRelDepList(p.Format.Conflicts),
+		Obsoletes:       toRelDepList(p.Format.Obsoletes),
+		Recommends:      toRelDepList(p.Format.Recommends),
+		Suggests:        toRelDepList(p.Format.Suggests),
+		Enhances:        toRelDepList(p.Format.Enhances),
+		Supplements:     toRelDepList(p.Format.Supplements),
+		Files:           p.Format.Files,
+		Location:        p.Location.Href,
+		RemoteLocations: []string{remote},
+		Checksum: Checksum{
+			Type:  normalizeChecksumType(p.Checksum.Type),
+			Value: strings.TrimSpace(p.Checksum.Value),
+		},
+		RepoID: repo.Hash(),
+		Repo:   repo,
+	}
+	if p.Time.Build != 0 {
+		pkg.BuildTime = time.Unix(p.Time.Build, 0).UTC()
+	}
+	for _, req := range p.Format.Requires {
+		if req.Pre == "1" {
+			pkg.RequiresPre = append(pkg.RequiresPre, req.toRelDep())
+		} else {
+			pkg.RegularRequires = append(pkg.RegularRequires, req.toRelDep())
+		}
+	}
+	if repo.CheckGPG != nil {
+		pkg.CheckGPG = *repo.CheckGPG
+	}
+	if repo.IgnoreSSL != nil {
+		pkg.IgnoreSSL = *repo.IgnoreSSL
+	}
+	switch {
+	case repo.RHSM:
+		pkg.Secrets = "org.osbuild.rhsm"
+	case repo.SSLClientKey != "":
+		pkg.Secrets = "org.osbuild.mtls"
+	}
+	return pkg
+}
+
+// normalizeChecksumType maps the legacy "sha" checksum type to "sha1"
+func normalizeChecksumType(t string) string {
+	if t == "sha" {
+		return "sha1"
+	}
+	return t
+}
+
+// filelistsMetadata is the content of the filelists.xml metadata file
+type filelistsMetadata struct {
+	Packages []struct {
+		PkgID string `xml:"pkgid,attr"`
+		Files []struct {
+			Type string `xml:"type,attr"`
+			Path string `xml:",chardata"`
+		} `xml:"file"`
+	} `xml:"package"`
+}
+
+// otherMetadata is the content of the other.xml metadata file
+type otherMetadata struct {
+	Packages []struct {
+		PkgID      string `xml:"pkgid,attr"`
+		Changelogs []struct {
+			Author string `xml:"author,attr"`
+			Date   int64  `xml:"date,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"changelog"`
+	} `xml:"package"`
+}
@@ -0,0 +1,430 @@
+package rpmmd_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/rhsm"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+const testPrimaryXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="3">
+<package type="rpm">
+  <name>bash</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="5.2.26" rel="3.fc41"/>
+  <checksum type="sha256" pkgid="YES">1111</checksum>
+  <summary>The GNU Bourne Again shell</summary>
+  <description>The GNU Bourne Again shell (Bash) is a shell.</description>
+  <packager>Fedora Project</packager>
+  <url>https://www.gnu.org/software/bash</url>
+  <time file="1720000000" build="1710000000"/>
+  <size package="1800000" installed="8000000" archive="8100000"/>
+  <location href="Packages/b/bash-5.2.26-3.fc41.x86_64.rpm"/>
+  <format>
+    <rpm:license>GPL-3.0-or-later</rpm:license>
+    <rpm:vendor>Fedora Project</rpm:vendor>
+    <rpm:group>Unspecified</rpm:group>
+    <rpm:sourcerpm>bash-5.2.26-3.fc41.src.rpm</rpm:sourcerpm>
+    <rpm:provides>
+      <rpm:entry name="bash" flags="EQ" epoch="0" ver="5.2.26" rel="3.fc41"/>
+      <rpm:entry name="/bin/sh"/>
+    </rpm:provides>
+    <rpm:requires>
+      <rpm:entry name="filesystem" pre="1"/>
+      <rpm:entry name="glibc" flags="GE" epoch="0" ver="2.38"/>
+    </rpm:requires>
+    <file>/usr/bin/bash</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>kernel</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="6.11.4" rel="301.fc41"/>
+  <checksum type="sha256" pkgid="YES">2222</checksum>
+  <summary>The Linux kernel</summary>
+  <location href="Packages/k/kernel-6.11.4-301.fc41.x86_64.rpm"/>
+  <format>
+    <rpm:license>GPL-2.0-only</rpm:license>
+  </format>
+</package>
+<package type="rpm">
+  <name>kernel-core</name>
+  <arch>x86_64</arch>
+  <version epoch="1" ver="6.11.4" rel="301.fc41"/>
+  <checksum type="sha256" pkgid="YES">3333</checksum>
+  <summary>The Linux kernel core</summary>
+  <location href="Packages/k/kernel-core-6.11.4-301.fc41.x86_64.rpm"/>
+  <format>
+    <rpm:recommends>
+      <rpm:entry name="linux-firmware" flags="GE" epoch="0" ver="20150904" rel="56.git6ebf5d57"/>
+    </rpm:recommends>
+  </format>
+</package>
+</metadata>
+`
+
+const testFilelistsXML = `<?xml version="1.0" encoding="UTF-8"?>
+<filelists xmlns="http://linux.duke.edu/metadata/filelists" packages="1">
+<package pkgid="1111" name="bash" arch="x86_64">
+  <version epoch="0" ver="5.2.26" rel="3.fc41"/>
+  <file>/usr/bin/bash</file>
+  <file>/usr/bin/sh</file>
+  <file type="dir">/usr/share/doc/bash</file>
+</package>
+</filelists>
+`
+
+const testOtherXML = `<?xml version="1.0" encoding="UTF-8"?>
+<otherdata xmlns="http://linux.duke.edu/metadata/other" packages="1">
+<package pkgid="1111" name="bash" arch="x86_64">
+  <version epoch="0" ver="5.2.26" rel="3.fc41"/>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 5.2.26-1" date="1710000000">- Update to 5.2.26</changelog>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 5.2.26-3" date="1720000000">- Fix CVE-2024-0001</changelog>
+</package>
+</otherdata>
+`
+
+const testUpdateInfoXML = `<?xml version="1.0" encoding="UTF-8"?>
+<updates>
+  <update from="updates@fedoraproject.org" status="stable" type="security" version="2.0">
+    <id>FEDORA-2024-0001</id>
+    <title>bash-5.2.26-3.fc41</title>
+    <severity>Important</severity>
+    <issued date="2024-07-03 10:00:00"/>
+    <references>
+      <reference href="https://bugzilla.redhat.com/1" id="1" type="bugzilla"/>
+      <reference href="https://www.cve.org/CVERecord?id=CVE-2024-0001" id="CVE-2024-0001" type="cve"/>
+    </references>
+    <pkglist>
+      <collection short="F41">
+        <package name="bash" version="5.2.26" release="3.fc41" epoch="0" arch="x86_64" src="bash-5.2.26-3.fc41.src.rpm">
+          <filename>bash-5.2.26-3.fc41.x86_64.rpm</filename>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+</updates>
+`
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// makeTestRepodata writes a rpm-md repository with a gzip compressed
+// primary.xml and a zstd compressed filelists.xml to dir
+func makeTestRepodata(t *testing.T, dir string) {
+	t.Helper()
+
+	var primary bytes.Buffer
+	gw := gzip.NewWriter(&primary)
+	_, err := gw.Write([]byte(testPrimaryXML))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	filelists := zw.EncodeAll([]byte(testFilelistsXML), nil)
+	require.NoError(t, zw.Close())
+
+	repodata := filepath.Join(dir, "repodata")
+	require.NoError(t, os.MkdirAll(repodata, 0o755))
+	primaryName := sha256Hex(primary.Bytes()) + "-primary.xml.gz"
+	filelistsName := sha256Hex(filelists) + "-filelists.xml.zst"
+	otherName := sha256Hex([]byte(testOtherXML)) + "-other.xml"
+	updateInfoName := sha256Hex([]byte(testUpdateInfoXML)) + "-updateinfo.xml"
+	require.NoError(t, os.WriteFile(filepath.Join(repodata, primaryName), primary.Bytes(), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repodata, filelistsName), filelists, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repodata, otherName), []byte(testOtherXML), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repodata, updateInfoName), []byte(testUpdateInfoXML), 0o644))
+
+	repomd := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm">
+  <revision>1720000000</revision>
+  <data type="primary">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/%s"/>
+  </data>
+  <data type="filelists">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/%s"/>
+  </data>
+  <data type="other">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/%s"/>
+  </data>
+  <data type="updateinfo">
+    <checksum type="sha256">%s</checksum>
+    <location href="repodata/%s"/>
+  </data>
+</repomd>
+`, sha256Hex(primary.Bytes()), primaryName, sha256Hex(filelists), filelistsName, sha256Hex([]byte(testOtherXML)), otherName, sha256Hex([]byte(testUpdateInfoXML)), updateInfoName)
+	require.NoError(t, os.WriteFile(filepath.Join(repodata, "repomd.xml"), []byte(repomd), 0o644))
+}
+
+func TestMetadataReaderFetchMetadata(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+
+	repo := rpmmd.RepoConfig{
+		Id:       "test",
+		BaseURLs: []string{"file://" + repoDir},
+		CheckGPG: common.ToPtr(true),
+	}
+	reader := rpmmd.NewMetadataReader("")
+	pkgs, err := reader.FetchMetadata([]rpmmd.RepoConfig{repo})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+
+	assert.Equal(t, []string{"bash", "kernel", "kernel-core"}, []string{pkgs[0].Name, pkgs[1].Name, pkgs[2].Name})
+	bash := pkgs[0]
+	assert.Equal(t, "5.2.26-3.fc41.x86_64", bash.EVRA())
+	assert.Equal(t, rpmmd.Checksum{Type: "sha256", Value: "1111"}, bash.Checksum)
+	assert.Equal(t, "GPL-3.0-or-later", bash.License)
+	assert.Equal(t, "bash-5.2.26-3.fc41.src.rpm", bash.SourceRpm)
+	assert.Equal(t, uint64(1800000), bash.DownloadSize)
+	assert.Equal(t, uint64(8000000), bash.InstallSize)
+	assert.Equal(t, time.Unix(1710000000, 0).UTC(), bash.BuildTime)
+	assert.Equal(t, []string{"file://" + repoDir + "/Packages/b/bash-5.2.26-3.fc41.x86_64.rpm"}, bash.RemoteLocations)
+	assert.Equal(t, rpmmd.RelDepList{
+		{Name: "bash", Relationship: "=", Version: "5.2.26-3.fc41"},
+		{Name: "/bin/sh"},
+	}, bash.Provides)
+	assert.Equal(t, rpmmd.RelDepList{{Name: "filesystem"}}, bash.RequiresPre)
+	assert.Equal(t, rpmmd.RelDepList{{Name: "glibc", Relationship: ">=", Version: "2.38"}}, bash.RegularRequires)
+	assert.Len(t, bash.Requires, 2)
+	assert.Equal(t, []string{"/usr/bin/bash"}, bash.Files)
+	assert.Equal(t, repo.Hash(), bash.RepoID)
+	assert.True(t, bash.CheckGPG)
+
+	assert.Equal(t, uint(1), pkgs[2].Epoch)
+	assert.Equal(t, rpmmd.RelDepList{
+		{Name: "linux-firmware", Relationship: ">=", Version: "20150904-56.git6ebf5d57"},
+	}, pkgs[2].Recommends)
+}
+
+func TestMetadataReaderFilelists(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+
+	reader := rpmmd.NewMetadataReader("")
+	reader.SetFilelists(true)
+	pkgs, err := reader.SearchMetadata([]rpmmd.RepoConfig{{BaseURLs: []string{"file://" + repoDir}}}, []string{"bash"})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	assert.Equal(t, []string{"/usr/bin/bash", "/usr/bin/sh", "/usr/share/doc/bash"}, pkgs[0].Files)
+}
+
+func TestMetadataReaderChangelogs(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+
+	reader := rpmmd.NewMetadataReader("")
+	reader.SetChangelogs(true)
+	pkgs, err := reader.SearchMetadata([]rpmmd.RepoConfig{{BaseURLs: []string{"file://" + repoDir}}}, []string{"bash", "kernel"})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, []rpmmd.ChangelogEntry{
+		{Author: "Jane Doe <jane@example.com> - 5.2.26-3", Date: time.Unix(1720000000, 0).UTC(), Text: "- Fix CVE-2024-0001"},
+		{Author: "Jane Doe <jane@example.com> - 5.2.26-1", Date: time.Unix(1710000000, 0).UTC(), Text: "- Update to 5.2.26"},
+	}, pkgs[0].Changelogs)
+	assert.Empty(t, pkgs[1].Changelogs)
+}
+
+func TestMetadataReaderFetchAdvisories(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+
+	reader := rpmmd.NewMetadataReader(t.TempDir())
+	repos := []rpmmd.RepoConfig{{BaseURLs: []string{"file://" + repoDir}}}
+	advisories, err := reader.FetchAdvisories(repos)
+	require.NoError(t, err)
+	assert.Equal(t, []rpmmd.Advisory{
+		{
+			ID:       "FEDORA-2024-0001",
+			Type:     rpmmd.AdvisoryTypeSecurity,
+			Severity: "Important",
+			Title:    "bash-5.2.26-3.fc41",
+			Issued:   time.Date(2024, 7, 3, 10, 0, 0, 0, time.UTC),
+			CVEs:     []string{"CVE-2024-0001"},
+			Packages: []rpmmd.AdvisoryPackage{{Name: "bash", Version: "5.2.26", Release: "3.fc41", Arch: "x86_64"}},
+		},
+	}, advisories)
+
+	pkgs, err := reader.FetchMetadata(repos)
+	require.NoError(t, err)
+	rpmmd.AttachAdvisories(pkgs, advisories)
+	assert.Equal(t, []rpmmd.PackageAdvisory{
+		{ID: "FEDORA-2024-0001", Type: "security", Severity: "Important", CVEs: []string{"CVE-2024-0001"}},
+	}, pkgs[0].Advisories)
+	assert.Empty(t, pkgs[1].Advisories)
+}
+
+func TestMetadataReaderSearchMetadata(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+	repos := []rpmmd.RepoConfig{{BaseURLs: []string{"file://" + repoDir}}}
+
+	reader := rpmmd.NewMetadataReader("")
+	pkgs, err := reader.SearchMetadata(repos, []string{"kernel*"})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 2)
+	assert.Equal(t, "kernel", pkgs[0].Name)
+	assert.Equal(t, "kernel-core", pkgs[1].Name)
+
+	pkgs, err = reader.SearchMetadata(repos, []string{"not-there"})
+	require.NoError(t, err)
+	assert.Empty(t, pkgs)
+
+	_, err = reader.SearchMetadata(repos, []string{"[kernel"})
+	assert.ErrorContains(t, err, `invalid package pattern "[kernel"`)
+}
+
+func TestMetadataReaderCache(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+	cacheDir := t.TempDir()
+	repo := rpmmd.RepoConfig{BaseURLs: []string{"file://" + repoDir}}
+
+	pkgs, err := rpmmd.NewMetadataReader(cacheDir).FetchMetadata([]rpmmd.RepoConfig{repo})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 3)
+	cached, err := filepath.Glob(filepath.Join(cacheDir, repo.Hash()+"-rpmmd", "repodata", "*-primary.xml.gz"))
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+
+	// the primary metadata is read from the cache now
+	require.NoError(t, os.Remove(filepath.Join(repoDir, "repodata", filepath.Base(cached[0]))))
+	pkgs, err = rpmmd.NewMetadataReader(cacheDir).FetchMetadata([]rpmmd.RepoConfig{repo})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 3)
+
+	// a corrupted cache entry is not used
+	require.NoError(t, os.WriteFile(cached[0], []byte("garbage"), 0o644))
+	_, err = rpmmd.NewMetadataReader(cacheDir).FetchMetadata([]rpmmd.RepoConfig{repo})
+	assert.ErrorContains(t, err, "no such file or directory")
+}
+
+func TestMetadataReaderChecksumMismatch(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+	primary, err := filepath.Glob(filepath.Join(repoDir, "repodata", "*-primary.xml.gz"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(primary[0], []byte("garbage"), 0o644))
+
+	_, err = rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{Name: "test", BaseURLs: []string{"file://" + repoDir}}})
+	assert.ErrorContains(t, err, `cannot read metadata of repository "test"`)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestMetadataReaderNoBaseURL(t *testing.T) {
+	_, err := rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{Name: "test"}})
+	assert.EqualError(t, err, `repository "test" has no baseurl, metalink or mirrorlist`)
+}
+
+func TestMetadataReaderMetalink(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+	repomd, err := os.ReadFile(filepath.Join(repoDir, "repodata", "repomd.xml"))
+	require.NoError(t, err)
+
+	var metalink string
+	mux := http.NewServeMux()
+	mux.Handle("/repo/", http.StripPrefix("/repo/", http.FileServer(http.Dir(repoDir))))
+	mux.HandleFunc("/metalink", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, metalink)
+	})
+	mux.HandleFunc("/mirrorlist", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "# mirrors\nhttp://%s/broken/\n\nhttp://%s/repo/\n", r.Host, r.Host)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	makeMetalink := func(checksum string) string {
+		return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+ <files>
+  <file name="repomd.xml">
+   <verification>
+    <hash type="md5">0000</hash>
+    <hash type="sha256">%s</hash>
+   </verification>
+   <resources maxconnections="1">
+    <url protocol="rsync" type="rsync" preference="100">rsync://example.com/repo/repodata/repomd.xml</url>
+    <url protocol="http" type="http" preference="90">%s/broken/repodata/repomd.xml</url>
+    <url protocol="http" type="http" preference="99">%s/repo/repodata/repomd.xml</url>
+   </resources>
+  </file>
+ </files>
+</metalink>`, checksum, srv.URL, srv.URL)
+	}
+
+	// the mirror with the highest preference is used
+	metalink = makeMetalink(sha256Hex(repomd))
+	pkgs, err := rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{Name: "test", Metalink: srv.URL + "/metalink"}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+	assert.Equal(t, []string{srv.URL + "/repo/Packages/b/bash-5.2.26-3.fc41.x86_64.rpm"}, pkgs[0].RemoteLocations)
+
+	// the repomd.xml of the mirrors must match the metalink
+	metalink = makeMetalink(sha256Hex([]byte("outdated")))
+	_, err = rpmmd.NewMetadataReader("").FetchAdvisories([]rpmmd.RepoConfig{{Name: "test", Metalink: srv.URL + "/metalink"}})
+	assert.ErrorContains(t, err, fmt.Sprintf("repomd.xml of %s/repo/ does not match the metalink", srv.URL))
+
+	// unreachable mirrors of the mirrorlist are skipped
+	advisories, err := rpmmd.NewMetadataReader("").FetchAdvisories([]rpmmd.RepoConfig{{Name: "test", MirrorList: srv.URL + "/mirrorlist"}})
+	require.NoError(t, err)
+	assert.Len(t, advisories, 1)
+}
+
+func TestMetadataReaderRHSM(t *testing.T) {
+	repo := rpmmd.RepoConfig{Name: "rhel", BaseURLs: []string{"https://cdn.redhat.com/content/baseos"}, RHSM: true}
+
+	reader := rpmmd.NewMetadataReader("")
+	_, err := reader.FetchAdvisories([]rpmmd.RepoConfig{repo})
+	assert.EqualError(t, err, `repository "rhel" requires RHSM subscriptions but none are set`)
+
+	reader.SetSubscriptions(&rhsm.Subscriptions{}, "x86_64", "9")
+	_, err = reader.FetchAdvisories([]rpmmd.RepoConfig{repo})
+	assert.EqualError(t, err, `cannot get the RHSM secrets of repository "rhel": no such baseurl in the available subscriptions`)
+}
+
+func TestMetadataReaderHTTPS(t *testing.T) {
+	repoDir := t.TempDir()
+	makeTestRepodata(t, repoDir)
+	srv := httptest.NewTLSServer(http.FileServer(http.Dir(repoDir)))
+	defer srv.Close()
+
+	// without the CA certificate the server is not trusted
+	_, err := rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{BaseURLs: []string{srv.URL}}})
+	assert.ErrorContains(t, err, "certificate")
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caPath, caPEM, 0o644))
+	pkgs, err := rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{BaseURLs: []string{srv.URL}, SSLCACert: caPath}})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+	assert.Equal(t, []string{srv.URL + "/Packages/b/bash-5.2.26-3.fc41.x86_64.rpm"}, pkgs[0].RemoteLocations)
+
+	pkgs, err = rpmmd.NewMetadataReader("").FetchMetadata([]rpmmd.RepoConfig{{BaseURLs: []string{srv.URL}, IgnoreSSL: common.ToPtr(true)}})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 3)
+}
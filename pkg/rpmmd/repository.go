@@ -26,6 +26,8 @@ type repository struct {
 	RHSM           bool     `json:"rhsm,omitempty"`
 	ModuleHotfixes *bool    `json:"module_hotfixes,omitempty"`
 	MetadataExpire string   `json:"metadata_expire,omitempty"`
+	IncludePkgs    []string `json:"includepkgs,omitempty"`
+	ExcludePkgs    []string `json:"excludepkgs,omitempty"`
 	ImageTypeTags  []string `json:"image_type_tags,omitempty"`
 	PackageSets    []string `json:"package_sets,omitempty"`
 }
@@ -87,6 +89,12 @@ type RepoConfig struct {
 	ImageTypeTags  []string `json:"image_type_tags,omitempty"`
 	PackageSets    []string `json:"package_sets,omitempty"`
 
+	// IncludePkgs and ExcludePkgs limit the packages that are used from
+	// the repository (dnf's includepkgs and excludepkgs options). The
+	// values are package names or globs.
+	IncludePkgs []string `json:"includepkgs,omitempty"`
+	ExcludePkgs []string `json:"excludepkgs,omitempty"`
+
 	// These fields are only filled out by the worker during the
 	// depsolve job for certain baseurls.
 	SSLCACert     string `json:"sslcacert,omitempty"`
@@ -110,6 +118,15 @@ func (r *RepoConfig) Hash() string {
 	ats := func(s []string) string {
 		return strings.Join(s, "")
 	}
+	// the package filters are prefixed so that the include and exclude
+	// lists can be told apart; empty lists keep the hash of existing
+	// configurations stable
+	fts := func(prefix string, s []string) string {
+		if len(s) == 0 {
+			return ""
+		}
+		return prefix + strings.Join(s, ",")
+	}
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(ats(r.BaseURLs)+
 		r.Metalink+
 		r.MirrorList+
@@ -122,7 +139,9 @@ func (r *RepoConfig) Hash() string {
 		bpts(r.ModuleHotfixes)+
 		r.SSLCACert+
 		r.SSLClientKey+
-		r.SSLClientCert)))
+		r.SSLClientCert+
+		fts("includepkgs:", r.IncludePkgs)+
+		fts("excludepkgs:", r.ExcludePkgs))))
 }
 
 type DistrosRepoConfigs map[string]map[string][]RepoConfig
@@ -168,6 +187,8 @@ func LoadRepositoriesFromReader(r io.Reader) (map[string][]RepoConfig, error) {
 				ModuleHotfixes: repo.ModuleHotfixes,
 				ImageTypeTags:  repo.ImageTypeTags,
 				PackageSets:    repo.PackageSets,
+				IncludePkgs:    repo.IncludePkgs,
+				ExcludePkgs:    repo.ExcludePkgs,
 			}
 
 			repoConfigs[arch] = append(repoConfigs[arch], config)
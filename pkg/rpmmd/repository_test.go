@@ -79,3 +79,17 @@ func TestRepoConfigUnmarshalSad(t *testing.T) {
 		})
 	}
 }
+
+func TestRepoConfigHashPackageFilters(t *testing.T) {
+	repo := rpmmd.RepoConfig{BaseURLs: []string{"https://example.com/vendor"}}
+	// the hash of repositories without filters does not change
+	assert.Equal(t, "7f06640bc92790f7ffca0dcda8aaa045e4d3865b3406628631b65e7bb01bc039", repo.Hash())
+
+	include := repo
+	include.IncludePkgs = []string{"openssl"}
+	exclude := repo
+	exclude.ExcludePkgs = []string{"openssl"}
+	assert.NotEqual(t, repo.Hash(), include.Hash())
+	assert.NotEqual(t, repo.Hash(), exclude.Hash())
+	assert.NotEqual(t, include.Hash(), exclude.Hash())
+}
@@ -0,0 +1,286 @@
+package rpmmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Advisory types as used in the updateinfo metadata
+const (
+	AdvisoryTypeSecurity    = "security"
+	AdvisoryTypeBugfix      = "bugfix"
+	AdvisoryTypeEnhancement = "enhancement"
+	AdvisoryTypeNewPackage  = "newpackage"
+)
+
+// Advisory is an update advisory (erratum) from the updateinfo metadata of
+// a repository, e.g. a security advisory that lists the fixed CVEs and the
+// packages that contain the fixes.
+type Advisory struct {
+	// ID of the advisory, e.g. "RHSA-2024:1234" or "FEDORA-2024-0123456789"
+	ID string `json:"id"`
+	// Type of the advisory, see the AdvisoryType* constants
+	Type string `json:"type"`
+	// Severity of the advisory, e.g. "Important" (only set for some
+	// advisories)
+	Severity string    `json:"severity,omitempty"`
+	Title    string    `json:"title,omitempty"`
+	Issued   time.Time `json:"issued"`
+	// CVEs are the ids of the CVEs that are fixed by the advisory
+	CVEs []string `json:"cves,omitempty"`
+	// Packages are the (binary) packages that contain the fixes
+	Packages []AdvisoryPackage `json:"packages"`
+}
+
+// IsSecurity returns true if the advisory is a security advisory
+func (a Advisory) IsSecurity() bool {
+	return a.Type == AdvisoryTypeSecurity
+}
+
+// AdvisoryPackage is a package that is fixed by an advisory
+type AdvisoryPackage struct {
+	Name    string `json:"name"`
+	Epoch   uint   `json:"epoch"`
+	Version string `json:"version"`
+	Release string `json:"release"`
+	Arch    string `json:"arch"`
+}
+
+// pkg returns a Package with the NEVRA of the advisory package
+func (p AdvisoryPackage) pkg() Package {
+	return Package{Name: p.Name, Epoch: p.Epoch, Version: p.Version, Release: p.Release, Arch: p.Arch}
+}
+
+// FullNEVRA returns the package's Name-Epoch:Version-Release.Arch string.
+func (p AdvisoryPackage) FullNEVRA() string {
+	return p.pkg().FullNEVRA()
+}
+
+// isSource returns true for source package entries, which never apply to
+// installed packages
+func (p AdvisoryPackage) isSource() bool {
+	return p.Arch == "src" || p.Arch == "nosrc"
+}
+
+// matches returns true if the advisory package applies to the given
+// package, i.e. it is a binary package with the same name and a compatible
+// architecture. See fixedVersion() for the preference of exact matches.
+func (p AdvisoryPackage) matches(pkg Package) bool {
+	if p.Name != pkg.Name || p.isSource() {
+		return false
+	}
+	return p.Arch == pkg.Arch || p.Arch == "noarch" || pkg.Arch == "noarch"
+}
+
+// PackageAdvisory is the reference to an advisory that is attached to a
+// package, see AttachAdvisories()
+type PackageAdvisory struct {
+	ID       string
+	Type     string
+	Severity string
+	CVEs     []string
+}
+
+// updateInfoMetadata is the content of the updateinfo.xml metadata file
+type updateInfoMetadata struct {
+	Updates []struct {
+		Type     string `xml:"type,attr"`
+		ID       string `xml:"id"`
+		Title    string `xml:"title"`
+		Severity string `xml:"severity"`
+		Issued   struct {
+			Date string `xml:"date,attr"`
+		} `xml:"issued"`
+		References []struct {
+			ID   string `xml:"id,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"references>reference"`
+		Packages []struct {
+			Name    string `xml:"name,attr"`
+			Epoch   string `xml:"epoch,attr"`
+			Version string `xml:"version,attr"`
+			Release string `xml:"release,attr"`
+			Arch    string `xml:"arch,attr"`
+		} `xml:"pkglist>collection>package"`
+	} `xml:"update"`
+}
+
+// parseIssued parses the issued date of an advisory, repositories use
+// either a date string or the seconds since the epoch
+func parseIssued(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(date, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.DateTime, time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", date)
+}
+
+// ReadUpdateInfo reads the advisories from the (uncompressed) updateinfo.xml
+// metadata of a repository.
+func ReadUpdateInfo(r io.Reader) ([]Advisory, error) {
+	var md updateInfoMetadata
+	if err := xml.NewDecoder(r).Decode(&md); err != nil {
+		return nil, fmt.Errorf("cannot parse updateinfo: %w", err)
+	}
+
+	advisories := make([]Advisory, 0, len(md.Updates))
+	for _, u := range md.Updates {
+		issued, err := parseIssued(strings.TrimSpace(u.Issued.Date))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse issued date of advisory %s: %w", u.ID, err)
+		}
+		adv := Advisory{
+			ID:       strings.TrimSpace(u.ID),
+			Type:     u.Type,
+			Severity: strings.TrimSpace(u.Severity),
+			Title:    strings.TrimSpace(u.Title),
+			Issued:   issued,
+		}
+		for _, ref := range u.References {
+			if ref.Type == "cve" && !slices.Contains(adv.CVEs, ref.ID) {
+				adv.CVEs = append(adv.CVEs, ref.ID)
+			}
+		}
+		for _, p := range u.Packages {
+			var epoch uint64
+			if p.Epoch != "" {
+				epoch, err = strconv.ParseUint(p.Epoch, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid epoch %q of package %s in advisory %s", p.Epoch, p.Name, adv.ID)
+				}
+			}
+			ap := AdvisoryPackage{
+				Name:    p.Name,
+				Epoch:   uint(epoch),
+				Version: p.Version,
+				Release: p.Release,
+				Arch:    p.Arch,
+			}
+			// the same package is often listed in several collections
+			if !slices.Contains(adv.Packages, ap) {
+				adv.Packages = append(adv.Packages, ap)
+			}
+		}
+		advisories = append(advisories, adv)
+	}
+	return advisories, nil
+}
+
+// AttachAdvisories sets the Advisories of each package to the advisories
+// that list the exact version of the package, i.e. the advisories that
+// were released with the package.
+func AttachAdvisories(pkgs PackageList, advisories []Advisory) {
+	byNEVRA := make(map[string][]PackageAdvisory)
+	for _, adv := range advisories {
+		ref := PackageAdvisory{ID: adv.ID, Type: adv.Type, Severity: adv.Severity, CVEs: adv.CVEs}
+		for _, p := range adv.Packages {
+			byNEVRA[p.FullNEVRA()] = append(byNEVRA[p.FullNEVRA()], ref)
+		}
+	}
+	for idx := range pkgs {
+		pkgs[idx].Advisories = byNEVRA[pkgs[idx].FullNEVRA()]
+	}
+}
+
+// fixedVersion returns the oldest version of the package that contains the
+// fix for the advisory, the returned bool is false if the advisory does
+// not apply to the package. Entries with the exact architecture of the
+// package are preferred over noarch ones (e.g. when a package changed
+// between noarch and arch specific builds).
+func (a Advisory) fixedVersion(pkg Package) (Package, bool) {
+	exact := slices.ContainsFunc(a.Packages, func(p AdvisoryPackage) bool {
+		return p.matches(pkg) && p.Arch == pkg.Arch
+	})
+	var fixed Package
+	found := false
+	for _, p := range a.Packages {
+		if !p.matches(pkg) || (exact && p.Arch != pkg.Arch) {
+			continue
+		}
+		if !found || p.pkg().CompareEVR(fixed) < 0 {
+			fixed = p.pkg()
+			found = true
+		}
+	}
+	return fixed, found
+}
+
+// UnpatchedAdvisories returns the advisories that apply to the given
+// packages but are not fixed because an older version of a package is
+// used. The result is sorted by the advisory id.
+func UnpatchedAdvisories(pkgs PackageList, advisories []Advisory) []Advisory {
+	var unpatched []Advisory
+	for _, adv := range advisories {
+		if slices.ContainsFunc(pkgs, func(pkg Package) bool {
+			fixed, ok := adv.fixedVersion(pkg)
+			return ok && pkg.CompareEVR(fixed) < 0
+		}) {
+			unpatched = append(unpatched, adv)
+		}
+	}
+	slices.SortFunc(unpatched, func(a, b Advisory) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return unpatched
+}
+
+// FixedAdvisories returns the advisories that are unpatched in the old
+// package list but not in the new one, i.e. the advisories that are fixed
+// by updating from the old to the new packages. Advisories of packages that
+// were removed are not included. The result is sorted by
+// the advisory id.
+func FixedAdvisories(oldPkgs, newPkgs PackageList, advisories []Advisory) []Advisory {
+	stillUnpatched := make(map[string]bool)
+	for _, adv := range UnpatchedAdvisories(newPkgs, advisories) {
+		stillUnpatched[adv.ID] = true
+	}
+	var fixed []Advisory
+	for _, adv := range UnpatchedAdvisories(oldPkgs, advisories) {
+		// packages that were removed do not fix anything
+		applies := slices.ContainsFunc(newPkgs, func(pkg Package) bool {
+			_, ok := adv.fixedVersion(pkg)
+			return ok
+		})
+		if applies && !stillUnpatched[adv.ID] {
+			fixed = append(fixed, adv)
+		}
+	}
+	return fixed
+}
+
+// SecurityUpdate returns the oldest version of the package that fixes all
+// security advisories that apply to it. The returned bool is false if the
+// package is not affected by any security advisory.
+func SecurityUpdate(pkg Package, advisories []Advisory) (AdvisoryPackage, bool) {
+	var update Package
+	found := false
+	for _, adv := range advisories {
+		if !adv.IsSecurity() {
+			continue
+		}
+		fixed, ok := adv.fixedVersion(pkg)
+		if !ok || pkg.CompareEVR(fixed) >= 0 {
+			continue
+		}
+		if !found || fixed.CompareEVR(update) > 0 {
+			update = fixed
+			found = true
+		}
+	}
+	if !found {
+		return AdvisoryPackage{}, false
+	}
+	return AdvisoryPackage{Name: pkg.Name, Epoch: update.Epoch, Version: update.Version, Release: update.Release, Arch: update.Arch}, true
+}
@@ -0,0 +1,145 @@
+package rpmmd_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func advisory(id, advType string, pkgs ...rpmmd.AdvisoryPackage) rpmmd.Advisory {
+	return rpmmd.Advisory{ID: id, Type: advType, Packages: pkgs}
+}
+
+func advPkg(name, version, release, arch string) rpmmd.AdvisoryPackage {
+	return rpmmd.AdvisoryPackage{Name: name, Version: version, Release: release, Arch: arch}
+}
+
+func rpmPkg(name, version, release, arch string) rpmmd.Package {
+	return rpmmd.Package{Name: name, Version: version, Release: release, Arch: arch}
+}
+
+func TestReadUpdateInfo(t *testing.T) {
+	advisories, err := rpmmd.ReadUpdateInfo(strings.NewReader(`<updates>
+  <update type="bugfix">
+    <id>RHBA-2024:0002</id>
+    <issued date="1720000000"/>
+    <pkglist>
+      <collection><package name="rpm" epoch="" version="4.20.0" release="1.el10" arch="x86_64"/></collection>
+      <collection><package name="rpm" epoch="" version="4.20.0" release="1.el10" arch="x86_64"/></collection>
+    </pkglist>
+  </update>
+  <update type="security">
+    <id>RHSA-2024:0001</id>
+    <issued date="2024-07-03"/>
+    <references>
+      <reference id="CVE-2024-0001" type="cve"/>
+      <reference id="CVE-2024-0002" type="cve"/>
+      <reference id="CVE-2024-0001" type="cve"/>
+    </references>
+  </update>
+</updates>`))
+	require.NoError(t, err)
+	assert.Equal(t, []rpmmd.Advisory{
+		{
+			ID:       "RHBA-2024:0002",
+			Type:     rpmmd.AdvisoryTypeBugfix,
+			Issued:   time.Unix(1720000000, 0).UTC(),
+			Packages: []rpmmd.AdvisoryPackage{advPkg("rpm", "4.20.0", "1.el10", "x86_64")},
+		},
+		{
+			ID:     "RHSA-2024:0001",
+			Type:   rpmmd.AdvisoryTypeSecurity,
+			Issued: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC),
+			CVEs:   []string{"CVE-2024-0001", "CVE-2024-0002"},
+		},
+	}, advisories)
+
+	_, err = rpmmd.ReadUpdateInfo(strings.NewReader(`<updates><update><id>X</id><issued date="yesterday"/></update></updates>`))
+	assert.EqualError(t, err, `cannot parse issued date of advisory X: unsupported date "yesterday"`)
+}
+
+func TestUnpatchedAndFixedAdvisories(t *testing.T) {
+	advisories := []rpmmd.Advisory{
+		advisory("SA-1", rpmmd.AdvisoryTypeSecurity, advPkg("openssl", "3.2.2", "1", "x86_64")),
+		advisory("SA-2", rpmmd.AdvisoryTypeSecurity, advPkg("openssl", "3.2.4", "1", "x86_64"), advPkg("openssl", "3.2.4", "1", "aarch64")),
+		advisory("SA-3", rpmmd.AdvisoryTypeSecurity, advPkg("python3-foo", "1.1", "1", "noarch")),
+		advisory("BA-1", rpmmd.AdvisoryTypeBugfix, advPkg("bash", "5.2", "2", "x86_64")),
+		advisory("SA-4", rpmmd.AdvisoryTypeSecurity, advPkg("nano", "8.2", "1", "x86_64")),
+	}
+	oldPkgs := rpmmd.PackageList{
+		rpmPkg("openssl", "3.2.1", "1", "x86_64"),
+		rpmPkg("python3-foo", "1.0", "1", "noarch"),
+		rpmPkg("bash", "5.2", "1", "x86_64"),
+		rpmPkg("nano", "8.1", "1", "x86_64"),
+	}
+	newPkgs := rpmmd.PackageList{
+		rpmPkg("openssl", "3.2.2", "1", "x86_64"),
+		rpmPkg("python3-foo", "1.1", "1", "noarch"),
+		rpmPkg("bash", "5.2", "1", "x86_64"),
+	}
+
+	ids := func(advisories []rpmmd.Advisory) []string {
+		var ids []string
+		for _, adv := range advisories {
+			ids = append(ids, adv.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"BA-1", "SA-1", "SA-2", "SA-3", "SA-4"}, ids(rpmmd.UnpatchedAdvisories(oldPkgs, advisories)))
+	assert.Equal(t, []string{"BA-1", "SA-2"}, ids(rpmmd.UnpatchedAdvisories(newPkgs, advisories)))
+	// nano was removed, SA-4 is not fixed by the update
+	assert.Equal(t, []string{"SA-1", "SA-3"}, ids(rpmmd.FixedAdvisories(oldPkgs, newPkgs, advisories)))
+}
+
+func TestSecurityUpdate(t *testing.T) {
+	advisories := []rpmmd.Advisory{
+		advisory("SA-1", rpmmd.AdvisoryTypeSecurity, advPkg("openssl", "3.2.2", "1", "x86_64")),
+		advisory("SA-2", rpmmd.AdvisoryTypeSecurity, advPkg("openssl", "3.2.3", "1", "x86_64")),
+		advisory("BA-1", rpmmd.AdvisoryTypeBugfix, advPkg("openssl", "3.2.5", "1", "x86_64"), advPkg("bash", "5.2", "2", "x86_64")),
+	}
+
+	// the oldest version that fixes all security advisories
+	update, ok := rpmmd.SecurityUpdate(rpmPkg("openssl", "3.2.1", "1", "x86_64"), advisories)
+	assert.True(t, ok)
+	assert.Equal(t, advPkg("openssl", "3.2.3", "1", "x86_64"), update)
+
+	_, ok = rpmmd.SecurityUpdate(rpmPkg("openssl", "3.2.3", "1", "x86_64"), advisories)
+	assert.False(t, ok)
+	// bugfix advisories are ignored
+	_, ok = rpmmd.SecurityUpdate(rpmPkg("bash", "5.2", "1", "x86_64"), advisories)
+	assert.False(t, ok)
+}
+
+func TestSecurityUpdateArch(t *testing.T) {
+	advisories := []rpmmd.Advisory{
+		advisory("SA-1", rpmmd.AdvisoryTypeSecurity,
+			// source packages never apply to installed packages
+			advPkg("python3-foo", "2.0", "1", "src"),
+			advPkg("python3-foo", "1.1", "1", "noarch"),
+		),
+		advisory("SA-2", rpmmd.AdvisoryTypeSecurity,
+			// the exact arch is preferred over an older noarch build
+			advPkg("foo", "1.0", "5", "noarch"),
+			advPkg("foo", "1.0", "7", "x86_64"),
+			advPkg("foo", "1.0", "9", "nosrc"),
+		),
+	}
+
+	update, ok := rpmmd.SecurityUpdate(rpmPkg("python3-foo", "1.0", "1", "noarch"), advisories)
+	assert.True(t, ok)
+	assert.Equal(t, advPkg("python3-foo", "1.1", "1", "noarch"), update)
+
+	update, ok = rpmmd.SecurityUpdate(rpmPkg("foo", "1.0", "1", "x86_64"), advisories)
+	assert.True(t, ok)
+	assert.Equal(t, advPkg("foo", "1.0", "7", "x86_64"), update)
+
+	// only the noarch build applies to other arches
+	update, ok = rpmmd.SecurityUpdate(rpmPkg("foo", "1.0", "1", "aarch64"), advisories)
+	assert.True(t, ok)
+	assert.Equal(t, advPkg("foo", "1.0", "5", "noarch"), update)
+}
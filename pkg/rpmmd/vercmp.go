@@ -0,0 +1,127 @@
+package rpmmd
+
+import (
+	"cmp"
+	"strings"
+)
+
+// VersionCompare compares two rpm version (or release) strings using the
+// same algorithm as rpmvercmp() from librpm. It returns -1 if a is older than
+// b, 0 if they are equal and 1 if a is newer than b.
+func VersionCompare(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for {
+		// skip separators, "~" and "^" are handled below
+		a = strings.TrimLeftFunc(a, isVersionSeparator)
+		b = strings.TrimLeftFunc(b, isVersionSeparator)
+
+		// "~" sorts before everything, even the end of the string
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// "^" sorts after the end of the string but before everything
+		// else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var segA, segB string
+		numeric := isVersionDigit(rune(a[0]))
+		if numeric {
+			segA, a = splitVersionSegment(a, isVersionDigit)
+			segB, b = splitVersionSegment(b, isVersionDigit)
+		} else {
+			segA, a = splitVersionSegment(a, isVersionLetter)
+			segB, b = splitVersionSegment(b, isVersionLetter)
+		}
+
+		// numeric segments are always newer than alpha segments
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if res := cmp.Compare(len(segA), len(segB)); res != 0 {
+				return res
+			}
+		}
+		if res := strings.Compare(segA, segB); res != 0 {
+			return res
+		}
+	}
+
+	// the version with remaining characters is newer
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// CompareEVR compares the epoch, version and release of two packages (in
+// this order) and returns -1, 0 or 1 like VersionCompare.
+func (p Package) CompareEVR(other Package) int {
+	if res := cmp.Compare(p.Epoch, other.Epoch); res != 0 {
+		return res
+	}
+	if res := VersionCompare(p.Version, other.Version); res != 0 {
+		return res
+	}
+	return VersionCompare(p.Release, other.Release)
+}
+
+func isVersionDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isVersionLetter(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVersionSeparator(c rune) bool {
+	return !isVersionDigit(c) && !isVersionLetter(c) && c != '~' && c != '^'
+}
+
+func splitVersionSegment(s string, f func(rune) bool) (string, string) {
+	idx := strings.IndexFunc(s, func(c rune) bool { return !f(c) })
+	if idx < 0 {
+		return s, ""
+	}
+	return s[:idx], s[idx:]
+}
@@ -0,0 +1,65 @@
+package rpmmd_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestVersionCompare(t *testing.T) {
+	// test cases taken from rpm's tests/rpmvercmp.at
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1a", "2.0.1", 1},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p10", "5.5p1", 1},
+		{"10xyz", "10.1xyz", -1},
+		{"xyz10", "xyz10.1", -1},
+		{"xyz.4", "8", -1},
+		{"1.0aa", "1.0a", 1},
+		{"1.0010", "1.9", 1},
+		{"1.05", "1.5", 0},
+		{"1.0", "1", 1},
+		{"2.50", "2.5", 1},
+		{"fc4", "fc.4", 0},
+		{"FC5", "fc4", -1},
+		{"2a", "2.0", -1},
+		{"1.0", "1.fc4", 1},
+		{"3.0.0_fc", "3.0.0.fc", 0},
+		{"1++", "1_", 0},
+		{"+", "_", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0^", "1.0", 1},
+		{"1.0^git1", "1.0^git2", -1},
+		{"1.0^git1", "1.01", -1},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+	} {
+		t.Run(fmt.Sprintf("%s-%s", tc.a, tc.b), func(t *testing.T) {
+			assert.Equal(t, tc.expected, rpmmd.VersionCompare(tc.a, tc.b))
+			assert.Equal(t, -tc.expected, rpmmd.VersionCompare(tc.b, tc.a))
+		})
+	}
+}
+
+func TestPackageCompareEVR(t *testing.T) {
+	a := rpmmd.Package{Name: "bash", Epoch: 0, Version: "5.2.26", Release: "3.fc40"}
+	b := rpmmd.Package{Name: "bash", Epoch: 0, Version: "5.2.26", Release: "10.fc40"}
+	c := rpmmd.Package{Name: "bash", Epoch: 1, Version: "4.0", Release: "1"}
+	assert.Equal(t, -1, a.CompareEVR(b))
+	assert.Equal(t, 1, c.CompareEVR(b))
+	assert.Equal(t, 0, a.CompareEVR(a))
+}
@@ -0,0 +1,534 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImageContent describes everything that ships in an image, see
+// MergeImage(). The containers, commits and flatpaks are described by the
+// resolved specs of the respective packages (container.Spec,
+// ostree.CommitSpec and flatpak.Spec) which are not used directly to keep
+// this package free of their dependencies.
+type ImageContent struct {
+	// Name of the image, e.g. "fedora-42-qcow2-x86_64"
+	Name string
+	// Packages are the SBOMs of the package sets of the image
+	Packages []*Document
+
+	Containers []Container
+	Commits    []Commit
+	Flatpaks   []Flatpak
+}
+
+// Container is a container that is embedded in an image
+type Container struct {
+	// Name of the container in the image
+	Name string
+	// Source is the container reference without tag or digest
+	Source string
+	// Digest of the manifest of the container
+	Digest string
+	// Arch is the architecture of the container image (e.g. "amd64")
+	Arch string
+	// SBOM of the container, e.g. from an OCI referrer, optional. It is
+	// only nested in the SBOM of the image if it has the same type.
+	SBOM *Document
+}
+
+// Commit is an ostree commit that is embedded in an image
+type Commit struct {
+	Ref      string
+	URL      string
+	Checksum string
+}
+
+// Flatpak is a flatpak that is embedded in an image. Flatpaks are
+// distributed as containers.
+type Flatpak struct {
+	// Ref of the flatpak, e.g. "app/org.gnome.Calculator/x86_64/stable"
+	Ref       string
+	Container *Container
+}
+
+// MergeImage creates a single SBOM document of the given type that
+// describes all the content of an image: the packages of the package SBOMs
+// and the embedded containers (with the nested components of their own
+// SBOMs), ostree commits and flatpaks.
+func MergeImage(docType StandardType, content ImageContent) (*Document, error) {
+	for _, doc := range content.Packages {
+		if doc.DocType != docType {
+			return nil, fmt.Errorf("cannot merge %s SBOM into %s SBOM", doc.DocType, docType)
+		}
+	}
+
+	var merged any
+	var err error
+	switch docType {
+	case StandardTypeSpdx:
+		merged, err = mergeSpdx(content)
+	case StandardTypeCycloneDX:
+		merged, err = mergeCycloneDX(content)
+	default:
+		return nil, fmt.Errorf("unsupported SBOM document type: %s", docType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocument(docType, raw)
+}
+
+// object is a JSON object of an SBOM document, the documents are handled
+// as generic objects so that no information of the input documents is lost
+type object = map[string]any
+
+func decodeObject(doc *Document) (object, error) {
+	var obj object
+	if err := json.Unmarshal(doc.Document, &obj); err != nil {
+		return nil, fmt.Errorf("cannot parse %s SBOM: %w", doc.DocType, err)
+	}
+	return obj, nil
+}
+
+// objects returns the JSON objects of the array with the given key
+func objects(obj object, key string) []object {
+	arr, _ := obj[key].([]any)
+	res := make([]object, 0, len(arr))
+	for _, elem := range arr {
+		if o, ok := elem.(object); ok {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// serialUUID returns a UUID that only depends on the content of the image
+func serialUUID(content ImageContent) string {
+	var parts []string
+	parts = append(parts, content.Name)
+	for _, doc := range content.Packages {
+		parts = append(parts, string(doc.Document))
+	}
+	for _, cnt := range content.Containers {
+		parts = append(parts, cnt.Digest)
+	}
+	for _, commit := range content.Commits {
+		parts = append(parts, commit.Checksum)
+	}
+	for _, fp := range content.Flatpaks {
+		parts = append(parts, fp.Ref)
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(strings.Join(parts, "\n"))).String()
+}
+
+// ociPURL returns the package URL of the container, see
+// https://github.com/package-url/purl-spec/blob/main/PURL-TYPES.rst#oci
+func ociPURL(cnt Container) string {
+	source := cnt.Source
+	// the name is the last path component of the repository
+	name := source[strings.LastIndex(source, "/")+1:]
+	qualifiers := url.Values{}
+	if cnt.Arch != "" {
+		qualifiers.Set("arch", cnt.Arch)
+	}
+	qualifiers.Set("repository_url", source)
+	return fmt.Sprintf("pkg:oci/%s@%s?%s", strings.ToLower(name), url.QueryEscape(cnt.Digest), qualifiers.Encode())
+}
+
+// sha256Hash returns the hex encoded value of a "sha256:" digest or of a
+// plain sha256 checksum (like ostree commit checksums)
+func sha256Hash(digest string) (string, bool) {
+	alg, value, found := strings.Cut(digest, ":")
+	if !found {
+		alg, value = "sha256", digest
+	}
+	if alg != "sha256" || len(value) != 64 {
+		return "", false
+	}
+	return value, true
+}
+
+func mergeCycloneDX(content ImageContent) (object, error) {
+	var components, dependencies []any
+	seenComponents := make(map[string]bool)
+	seenDependencies := make(map[string]bool)
+	for _, pkgDoc := range content.Packages {
+		doc, err := decodeObject(pkgDoc)
+		if err != nil {
+			return nil, err
+		}
+		for _, comp := range objects(doc, "components") {
+			if ref, ok := comp["bom-ref"].(string); ok {
+				if seenComponents[ref] {
+					continue
+				}
+				seenComponents[ref] = true
+			}
+			components = append(components, comp)
+		}
+		for _, dep := range objects(doc, "dependencies") {
+			ref, _ := dep["ref"].(string)
+			if ref == "" || seenDependencies[ref] {
+				continue
+			}
+			seenDependencies[ref] = true
+			dependencies = append(dependencies, dep)
+		}
+	}
+
+	containerComponent := func(cnt Container, bomRef string) (object, []any, error) {
+		purl := ociPURL(cnt)
+		comp := object{
+			"type":    "container",
+			"bom-ref": bomRef,
+			"name":    cnt.Name,
+			"version": cnt.Digest,
+			"purl":    purl,
+		}
+		if hash, ok := sha256Hash(cnt.Digest); ok {
+			comp["hashes"] = []any{object{"alg": "SHA-256", "content": hash}}
+		}
+		if cnt.SBOM == nil || cnt.SBOM.DocType != StandardTypeCycloneDX {
+			return comp, nil, nil
+		}
+		nested, err := decodeObject(cnt.SBOM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot parse SBOM of container %s: %w", cnt.Name, err)
+		}
+		// bom-refs must be unique in the whole document, the packages of
+		// the container are often the same as the ones of the image
+		prefixCycloneDXRefs(nested, bomRef+"#")
+		if nestedComponents := objects(nested, "components"); len(nestedComponents) > 0 {
+			comp["components"] = nestedComponents
+		}
+		var nestedDependencies []any
+		for _, dep := range objects(nested, "dependencies") {
+			nestedDependencies = append(nestedDependencies, dep)
+		}
+		return comp, nestedDependencies, nil
+	}
+	commitComponent := func(commit Commit, bomRef string) object {
+		comp := object{
+			"type":    "operating-system",
+			"bom-ref": bomRef,
+			"name":    commit.Ref,
+			"version": commit.Checksum,
+		}
+		if commit.Ref == "" {
+			comp["name"] = commit.Checksum
+		}
+		if hash, ok := sha256Hash(commit.Checksum); ok {
+			comp["hashes"] = []any{object{"alg": "SHA-256", "content": hash}}
+		}
+		if commit.URL != "" {
+			comp["externalReferences"] = []any{object{"type": "distribution", "url": commit.URL}}
+		}
+		return comp
+	}
+
+	for _, cnt := range content.Containers {
+		comp, deps, err := containerComponent(cnt, ociPURL(cnt))
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, comp)
+		dependencies = append(dependencies, deps...)
+	}
+	for _, commit := range content.Commits {
+		components = append(components, commitComponent(commit, "ostree:"+commit.Checksum))
+	}
+	for _, fp := range content.Flatpaks {
+		if fp.Container == nil {
+			return nil, fmt.Errorf("flatpak %s has no container", fp.Ref)
+		}
+		comp, deps, err := containerComponent(*fp.Container, "flatpak:"+ociPURL(*fp.Container))
+		if err != nil {
+			return nil, err
+		}
+		dependencies = append(dependencies, deps...)
+		comp["type"] = "application"
+		comp["name"] = fp.Ref
+		components = append(components, comp)
+	}
+
+	if components == nil {
+		components = []any{}
+	}
+	if dependencies == nil {
+		dependencies = []any{}
+	}
+	return object{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + serialUUID(content),
+		"version":      1,
+		"metadata": object{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": object{
+				"components": []any{object{"type": "application", "name": "osbuild-images"}},
+			},
+			"component": object{
+				"type":    "operating-system",
+				"bom-ref": content.Name,
+				"name":    content.Name,
+			},
+		},
+		"components":   components,
+		"dependencies": dependencies,
+	}, nil
+}
+
+// prefixCycloneDXRefs adds the prefix to all bom-refs of the (nested)
+// components and the dependencies of the document
+func prefixCycloneDXRefs(doc object, prefix string) {
+	var prefixComponents func(obj object)
+	prefixComponents = func(obj object) {
+		for _, comp := range objects(obj, "components") {
+			if ref, ok := comp["bom-ref"].(string); ok {
+				comp["bom-ref"] = prefix + ref
+			}
+			prefixComponents(comp)
+		}
+	}
+	prefixComponents(doc)
+
+	for _, dep := range objects(doc, "dependencies") {
+		if ref, ok := dep["ref"].(string); ok {
+			dep["ref"] = prefix + ref
+		}
+		dependsOn, _ := dep["dependsOn"].([]any)
+		for idx, ref := range dependsOn {
+			if s, ok := ref.(string); ok {
+				dependsOn[idx] = prefix + s
+			}
+		}
+	}
+}
+
+const spdxImageID = "SPDXRef-Image"
+
+func spdxRelationship(from, relType, to string) object {
+	return object{
+		"spdxElementId":      from,
+		"relationshipType":   relType,
+		"relatedSpdxElement": to,
+	}
+}
+
+// spdxDescribed returns the ids of the elements that are described by the
+// document and the relationships of the document without the DESCRIBES
+// relationships
+func spdxDescribed(doc object) ([]string, []any) {
+	var described []string
+	if ids, ok := doc["documentDescribes"].([]any); ok {
+		for _, id := range ids {
+			if s, ok := id.(string); ok {
+				described = append(described, s)
+			}
+		}
+	}
+	var relationships []any
+	for _, rel := range objects(doc, "relationships") {
+		if rel["spdxElementId"] == "SPDXRef-DOCUMENT" && rel["relationshipType"] == "DESCRIBES" {
+			if s, ok := rel["relatedSpdxElement"].(string); ok && !slices.Contains(described, s) {
+				described = append(described, s)
+			}
+			continue
+		}
+		relationships = append(relationships, rel)
+	}
+	return described, relationships
+}
+
+// prefixSpdxIDs adds the prefix to all SPDX ids of the packages, files and
+// relationships of the document
+func prefixSpdxIDs(doc object, prefix string) {
+	prefixID := func(id any) any {
+		s, ok := id.(string)
+		if !ok || s == "SPDXRef-DOCUMENT" || !strings.HasPrefix(s, "SPDXRef-") {
+			return id
+		}
+		return "SPDXRef-" + prefix + "-" + strings.TrimPrefix(s, "SPDXRef-")
+	}
+	for _, key := range []string{"packages", "files"} {
+		for _, elem := range objects(doc, key) {
+			elem["SPDXID"] = prefixID(elem["SPDXID"])
+			if files, ok := elem["hasFiles"].([]any); ok {
+				for idx, id := range files {
+					files[idx] = prefixID(id)
+				}
+			}
+		}
+	}
+	if ids, ok := doc["documentDescribes"].([]any); ok {
+		for idx, id := range ids {
+			ids[idx] = prefixID(id)
+		}
+	}
+	for _, rel := range objects(doc, "relationships") {
+		rel["spdxElementId"] = prefixID(rel["spdxElementId"])
+		rel["relatedSpdxElement"] = prefixID(rel["relatedSpdxElement"])
+	}
+}
+
+func mergeSpdx(content ImageContent) (object, error) {
+	var packages, files []any
+	relationships := []any{
+		spdxRelationship("SPDXRef-DOCUMENT", "DESCRIBES", spdxImageID),
+	}
+	seen := make(map[string]bool)
+
+	// addDoc adds the packages, files and relationships of the document,
+	// the elements described by the document are contained in parent
+	addDoc := func(doc object, parent string) {
+		var ids []string
+		for _, key := range []string{"packages", "files"} {
+			for _, elem := range objects(doc, key) {
+				id, _ := elem["SPDXID"].(string)
+				if id == "" || seen[id] {
+					continue
+				}
+				seen[id] = true
+				if key == "packages" {
+					packages = append(packages, elem)
+					ids = append(ids, id)
+				} else {
+					files = append(files, elem)
+				}
+			}
+		}
+		described, rels := spdxDescribed(doc)
+		if len(described) == 0 {
+			described = ids
+		}
+		for _, id := range described {
+			relationships = append(relationships, spdxRelationship(parent, "CONTAINS", id))
+		}
+		relationships = append(relationships, rels...)
+	}
+
+	for _, pkgDoc := range content.Packages {
+		doc, err := decodeObject(pkgDoc)
+		if err != nil {
+			return nil, err
+		}
+		addDoc(doc, spdxImageID)
+	}
+
+	containerPackage := func(cnt Container, id string) (object, error) {
+		pkg := object{
+			"SPDXID":           id,
+			"name":             cnt.Name,
+			"versionInfo":      cnt.Digest,
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"externalRefs": []any{
+				object{
+					"referenceCategory": "PACKAGE-MANAGER",
+					"referenceType":     "purl",
+					"referenceLocator":  ociPURL(cnt),
+				},
+			},
+		}
+		if hash, ok := sha256Hash(cnt.Digest); ok {
+			pkg["checksums"] = []any{object{"algorithm": "SHA256", "checksumValue": hash}}
+		}
+		if cnt.SBOM == nil || cnt.SBOM.DocType != StandardTypeSpdx {
+			return pkg, nil
+		}
+		nested, err := decodeObject(cnt.SBOM)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse SBOM of container %s: %w", cnt.Name, err)
+		}
+		// SPDX ids must be unique in the whole document, the packages of
+		// the container are often the same as the ones of the image
+		prefixSpdxIDs(nested, strings.TrimPrefix(id, "SPDXRef-"))
+		addDoc(nested, id)
+		return pkg, nil
+	}
+	commitPackage := func(commit Commit, id string) object {
+		pkg := object{
+			"SPDXID":           id,
+			"name":             commit.Ref,
+			"versionInfo":      commit.Checksum,
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+		}
+		if commit.Ref == "" {
+			pkg["name"] = commit.Checksum
+		}
+		if commit.URL != "" {
+			pkg["downloadLocation"] = commit.URL
+		}
+		if hash, ok := sha256Hash(commit.Checksum); ok {
+			pkg["checksums"] = []any{object{"algorithm": "SHA256", "checksumValue": hash}}
+		}
+		return pkg
+	}
+
+	for idx, cnt := range content.Containers {
+		id := fmt.Sprintf("SPDXRef-Container-%d", idx)
+		pkg, err := containerPackage(cnt, id)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+		relationships = append(relationships, spdxRelationship(spdxImageID, "CONTAINS", id))
+	}
+	for idx, commit := range content.Commits {
+		id := fmt.Sprintf("SPDXRef-OSTreeCommit-%d", idx)
+		packages = append(packages, commitPackage(commit, id))
+		relationships = append(relationships, spdxRelationship(spdxImageID, "CONTAINS", id))
+	}
+	for idx, fp := range content.Flatpaks {
+		id := fmt.Sprintf("SPDXRef-Flatpak-%d", idx)
+		if fp.Container == nil {
+			return nil, fmt.Errorf("flatpak %s has no container", fp.Ref)
+		}
+		pkg, err := containerPackage(*fp.Container, id)
+		if err != nil {
+			return nil, err
+		}
+		pkg["name"] = fp.Ref
+		packages = append(packages, pkg)
+		relationships = append(relationships, spdxRelationship(spdxImageID, "CONTAINS", id))
+	}
+
+	imagePkg := object{
+		"SPDXID":                spdxImageID,
+		"name":                  content.Name,
+		"downloadLocation":      "NOASSERTION",
+		"filesAnalyzed":         false,
+		"primaryPackagePurpose": "OPERATING-SYSTEM",
+	}
+	packages = append([]any{imagePkg}, packages...)
+
+	doc := object{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              content.Name,
+		"documentNamespace": "https://osbuild.org/spdxdocs/" + url.PathEscape(content.Name) + "-" + serialUUID(content),
+		"creationInfo": object{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []any{"Tool: osbuild-images"},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+	if len(files) > 0 {
+		doc["files"] = files
+	}
+	return doc, nil
+}
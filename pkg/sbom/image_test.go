@@ -0,0 +1,192 @@
+package sbom
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testContainerDigest = "sha256:505fe73a6102a624a46a1732e14e47034b9cca6f1ceaa3ef728aefbb2390f026"
+	testCommitChecksum  = "02604b2da6e954bd34b8b82a835e5a77d2b60ffa4d0ae8e5ac6d8f3ac91a4f94"
+)
+
+func mustDocument(t *testing.T, docType StandardType, data string) *Document {
+	t.Helper()
+	doc, err := NewDocument(docType, json.RawMessage(data))
+	require.NoError(t, err)
+	return doc
+}
+
+func mergedObject(t *testing.T, doc *Document) object {
+	t.Helper()
+	obj, err := decodeObject(doc)
+	require.NoError(t, err)
+	return obj
+}
+
+// refs returns the values of the given key of the objects
+func refs(objs []object, key string) []any {
+	var res []any
+	for _, obj := range objs {
+		res = append(res, obj[key])
+	}
+	return res
+}
+
+func TestMergeImageCycloneDX(t *testing.T) {
+	pkgs := mustDocument(t, StandardTypeCycloneDX, `{
+		"bomFormat": "CycloneDX",
+		"components": [
+			{"type": "library", "bom-ref": "pkg:rpm/fedora/bash", "name": "bash"},
+			{"type": "library", "bom-ref": "pkg:rpm/fedora/glibc", "name": "glibc"}
+		],
+		"dependencies": [
+			{"ref": "pkg:rpm/fedora/bash", "dependsOn": ["pkg:rpm/fedora/glibc"]}
+		]
+	}`)
+	cntSBOM := mustDocument(t, StandardTypeCycloneDX, `{
+		"bomFormat": "CycloneDX",
+		"components": [
+			{"type": "library", "bom-ref": "pkg:rpm/fedora/bash", "name": "bash"}
+		],
+		"dependencies": [
+			{"ref": "pkg:rpm/fedora/bash", "dependsOn": []}
+		]
+	}`)
+
+	content := ImageContent{
+		Name:     "fedora-42-qcow2-x86_64",
+		Packages: []*Document{pkgs},
+		Containers: []Container{
+			{
+				Name:   "registry.example.org/fedora:latest",
+				Source: "registry.example.org/fedora",
+				Digest: testContainerDigest,
+				Arch:   "amd64",
+				SBOM:   cntSBOM,
+			},
+		},
+		Commits: []Commit{
+			{Ref: "fedora/x86_64/iot", URL: "https://ostree.example.org/repo", Checksum: testCommitChecksum},
+		},
+		Flatpaks: []Flatpak{
+			{
+				Ref: "app/org.example.App/x86_64/stable",
+				Container: &Container{
+					Name:   "registry.example.org/app:stable",
+					Source: "registry.example.org/app",
+					Digest: testContainerDigest,
+					Arch:   "amd64",
+				},
+			},
+		},
+	}
+	doc, err := MergeImage(StandardTypeCycloneDX, content)
+	require.NoError(t, err)
+	assert.Equal(t, StandardTypeCycloneDX, doc.DocType)
+
+	obj := mergedObject(t, doc)
+	components := objects(obj, "components")
+	purl := "pkg:oci/fedora@sha256%3A505fe73a6102a624a46a1732e14e47034b9cca6f1ceaa3ef728aefbb2390f026?arch=amd64&repository_url=registry.example.org%2Ffedora"
+	assert.Equal(t, []any{
+		"pkg:rpm/fedora/bash",
+		"pkg:rpm/fedora/glibc",
+		purl,
+		"ostree:" + testCommitChecksum,
+		"flatpak:pkg:oci/app@sha256%3A505fe73a6102a624a46a1732e14e47034b9cca6f1ceaa3ef728aefbb2390f026?arch=amd64&repository_url=registry.example.org%2Fapp",
+	}, refs(components, "bom-ref"))
+	assert.Equal(t, []any{"library", "library", "container", "operating-system", "application"}, refs(components, "type"))
+	assert.Equal(t, "app/org.example.App/x86_64/stable", components[4]["name"])
+
+	// the components of the container SBOM are nested and their refs are
+	// made unique
+	nested := objects(components[2], "components")
+	assert.Equal(t, []any{purl + "#pkg:rpm/fedora/bash"}, refs(nested, "bom-ref"))
+	assert.Equal(t, []any{"pkg:rpm/fedora/bash", purl + "#pkg:rpm/fedora/bash"}, refs(objects(obj, "dependencies"), "ref"))
+
+	// the serial number only depends on the content
+	again, err := MergeImage(StandardTypeCycloneDX, content)
+	require.NoError(t, err)
+	assert.Equal(t, obj["serialNumber"], mergedObject(t, again)["serialNumber"])
+}
+
+func TestMergeImageSpdx(t *testing.T) {
+	pkgs := mustDocument(t, StandardTypeSpdx, `{
+		"spdxVersion": "SPDX-2.3",
+		"packages": [
+			{"SPDXID": "SPDXRef-RPM-bash", "name": "bash"}
+		],
+		"relationships": [
+			{"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": "SPDXRef-RPM-bash"}
+		]
+	}`)
+	cntSBOM := mustDocument(t, StandardTypeSpdx, `{
+		"spdxVersion": "SPDX-2.3",
+		"packages": [
+			{"SPDXID": "SPDXRef-RPM-bash", "name": "bash"}
+		]
+	}`)
+
+	doc, err := MergeImage(StandardTypeSpdx, ImageContent{
+		Name:     "fedora-42-qcow2-x86_64",
+		Packages: []*Document{pkgs},
+		Containers: []Container{
+			{
+				Name:   "registry.example.org/fedora:latest",
+				Source: "registry.example.org/fedora",
+				Digest: testContainerDigest,
+				SBOM:   cntSBOM,
+			},
+		},
+		Flatpaks: []Flatpak{
+			{
+				Ref: "app/org.example.App/x86_64/stable",
+				Container: &Container{
+					Name:   "registry.example.org/app:stable",
+					Source: "registry.example.org/app",
+					Digest: testContainerDigest,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StandardTypeSpdx, doc.DocType)
+
+	obj := mergedObject(t, doc)
+	assert.Equal(t, []any{
+		"SPDXRef-Image",
+		"SPDXRef-RPM-bash",
+		"SPDXRef-Container-0-RPM-bash",
+		"SPDXRef-Container-0",
+		"SPDXRef-Flatpak-0",
+	}, refs(objects(obj, "packages"), "SPDXID"))
+
+	var contains [][2]any
+	for _, rel := range objects(obj, "relationships") {
+		if rel["relationshipType"] == "CONTAINS" {
+			contains = append(contains, [2]any{rel["spdxElementId"], rel["relatedSpdxElement"]})
+		}
+	}
+	assert.Equal(t, [][2]any{
+		{"SPDXRef-Image", "SPDXRef-RPM-bash"},
+		{"SPDXRef-Container-0", "SPDXRef-Container-0-RPM-bash"},
+		{"SPDXRef-Image", "SPDXRef-Container-0"},
+		{"SPDXRef-Image", "SPDXRef-Flatpak-0"},
+	}, contains)
+}
+
+func TestMergeImageErrors(t *testing.T) {
+	spdx := mustDocument(t, StandardTypeSpdx, `{"spdxVersion": "SPDX-2.3"}`)
+
+	_, err := MergeImage(StandardTypeCycloneDX, ImageContent{Packages: []*Document{spdx}})
+	assert.EqualError(t, err, "cannot merge spdx SBOM into cyclonedx SBOM")
+
+	_, err = MergeImage(StandardTypeNone, ImageContent{})
+	assert.EqualError(t, err, "unsupported SBOM document type: none")
+
+	_, err = MergeImage(StandardTypeSpdx, ImageContent{Flatpaks: []Flatpak{{Ref: "app/org.example.App/x86_64/stable"}}})
+	assert.EqualError(t, err, "flatpak app/org.example.App/x86_64/stable has no container")
+}
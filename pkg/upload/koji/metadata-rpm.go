@@ -2,8 +2,10 @@ package koji
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/rpmmd"
 )
 
 // RPM represents an RPM package in the Koji metadata format.
@@ -66,3 +68,28 @@ func OSBuildMetadataToRPMs(stagesMetadata map[string]osbuild.StageMetadata) []RP
 	}
 	return rpms
 }
+
+// RPMsToPackageList converts a list of RPMs (e.g. from
+// OSBuildMetadataToRPMs()) to a package list, e.g. to compare them with
+// depsolve results.
+func RPMsToPackageList(rpms []RPM) (rpmmd.PackageList, error) {
+	pkgs := make(rpmmd.PackageList, 0, len(rpms))
+	for _, rpm := range rpms {
+		var epoch uint64
+		if rpm.Epoch != nil && *rpm.Epoch != "" {
+			var err error
+			epoch, err = strconv.ParseUint(*rpm.Epoch, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid epoch %q of package %s: %w", *rpm.Epoch, rpm.Name, err)
+			}
+		}
+		pkgs = append(pkgs, rpmmd.Package{
+			Name:    rpm.Name,
+			Epoch:   uint(epoch),
+			Version: rpm.Version,
+			Release: rpm.Release,
+			Arch:    rpm.Arch,
+		})
+	}
+	return pkgs, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/rpmmd"
 )
 
 func TestRPMDeduplication(t *testing.T) {
@@ -206,3 +207,19 @@ func Test_OSBuildMetadataToRPMs(t *testing.T) {
 	// if neither GPG nor PGP is set, the signature is nil
 	require.Nil(t, rpms[2].Signature)
 }
+
+func TestRPMsToPackageList(t *testing.T) {
+	rpms := []RPM{
+		{Type: "rpm", Name: "bash", Version: "5.2.26", Release: "3.fc41", Arch: "x86_64"},
+		{Type: "rpm", Name: "shadow-utils", Epoch: common.ToPtr("2"), Version: "4.15.1", Release: "12.fc41", Arch: "x86_64"},
+	}
+	pkgs, err := RPMsToPackageList(rpms)
+	require.NoError(t, err)
+	require.Equal(t, rpmmd.PackageList{
+		{Name: "bash", Version: "5.2.26", Release: "3.fc41", Arch: "x86_64"},
+		{Name: "shadow-utils", Epoch: 2, Version: "4.15.1", Release: "12.fc41", Arch: "x86_64"},
+	}, pkgs)
+
+	_, err = RPMsToPackageList([]RPM{{Name: "bad", Epoch: common.ToPtr("x")}})
+	require.ErrorContains(t, err, `invalid epoch "x" of package bad`)
+}